@@ -0,0 +1,15 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+// Hub marks v1 as the version every other ZookeeperCluster version is
+// converted to and from
+func (*ZookeeperCluster) Hub() {}
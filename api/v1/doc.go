@@ -0,0 +1,15 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+// Package v1 contains API Schema definitions for the zookeeper v1 API
+// group
+// +k8s:deepcopy-gen=package,register
+// +groupName=zookeeper.pravega.io
+package v1
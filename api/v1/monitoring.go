@@ -0,0 +1,127 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+const (
+	// DefaultAlertFor is how long the condition of an alert holds before
+	// it fires by default
+	DefaultAlertFor = "5m"
+
+	// DefaultOutstandingRequestsThreshold is the default number of queued
+	// requests of a member above which it is overloaded
+	DefaultOutstandingRequestsThreshold = 10
+
+	// DefaultFsyncLatencyThreshold is the default average time in
+	// milliseconds a member takes to sync its transaction log to disk
+	// above which its disk is too slow
+	DefaultFsyncLatencyThreshold = 100
+
+	// DefaultZnodeGrowthThreshold is the default number of znodes created
+	// in an hour above which a client is suspected to leak them
+	DefaultZnodeGrowthThreshold = 100000
+)
+
+// MonitorKind is the kind of the resource the Prometheus operator discovers
+// the metrics endpoints of the members with
+// +kubebuilder:validation:Enum=PodMonitor;ServiceMonitor
+type MonitorKind string
+
+const (
+	// PodMonitorKind scrapes the pods of the members
+	PodMonitorKind MonitorKind = "PodMonitor"
+	// ServiceMonitorKind scrapes the members through their headless
+	// services
+	ServiceMonitorKind MonitorKind = "ServiceMonitor"
+)
+
+// Monitoring makes the operator create the monitoring.coreos.com resources
+// of the Prometheus operator, which scrape the metrics of the members. They
+// are skipped when their CRDs are not installed.
+type Monitoring struct {
+	// Kind is PodMonitor or ServiceMonitor. Defaults to PodMonitor.
+	// +optional
+	Kind MonitorKind `json:"kind,omitempty"`
+
+	// Interval between two scrapes of a member, e.g. 30s. Defaults to the
+	// scrape interval of Prometheus.
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)(ms|s|m|h|d|w|y))+)$`
+	// +optional
+	Interval string `json:"interval,omitempty"`
+
+	// Labels are added to the monitor and the rule, so that the selectors
+	// of the Prometheus instance match them
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Alerts adds a PrometheusRule with alerts on the health of the cluster
+	// +optional
+	Alerts *Alerts `json:"alerts,omitempty"`
+}
+
+// Alerts are the thresholds of the alerts of the PrometheusRule of a
+// cluster. The loss of the quorum has no threshold.
+type Alerts struct {
+	// For is how long the condition of an alert holds before it fires.
+	// Defaults to 5m.
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)(ms|s|m|h|d|w|y))+)$`
+	// +optional
+	For string `json:"for,omitempty"`
+
+	// OutstandingRequests is the number of queued requests of a member
+	// above which it is overloaded. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	OutstandingRequests int32 `json:"outstandingRequests,omitempty"`
+
+	// FsyncLatencyMilliseconds is the average time a member takes to sync
+	// its transaction log to disk above which its disk is too slow.
+	// Defaults to 100.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FsyncLatencyMilliseconds int32 `json:"fsyncLatencyMilliseconds,omitempty"`
+
+	// ZnodeGrowthPerHour is the number of znodes created in an hour above
+	// which a client is suspected to leak them. Defaults to 100000.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ZnodeGrowthPerHour int32 `json:"znodeGrowthPerHour,omitempty"`
+}
+
+func (m *Monitoring) withDefaults() (changed bool) {
+	if m.Kind == "" {
+		m.Kind = PodMonitorKind
+		changed = true
+	}
+	if m.Alerts != nil && m.Alerts.withDefaults() {
+		changed = true
+	}
+	return changed
+}
+
+func (a *Alerts) withDefaults() (changed bool) {
+	if a.For == "" {
+		a.For = DefaultAlertFor
+		changed = true
+	}
+	if a.OutstandingRequests == 0 {
+		a.OutstandingRequests = DefaultOutstandingRequestsThreshold
+		changed = true
+	}
+	if a.FsyncLatencyMilliseconds == 0 {
+		a.FsyncLatencyMilliseconds = DefaultFsyncLatencyThreshold
+		changed = true
+	}
+	if a.ZnodeGrowthPerHour == 0 {
+		a.ZnodeGrowthPerHour = DefaultZnodeGrowthThreshold
+		changed = true
+	}
+	return changed
+}
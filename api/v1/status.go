@@ -0,0 +1,786 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ClusterConditionPodsReady = "PodsReady"
+	ClusterConditionUpgrading = "Upgrading"
+	ClusterConditionError     = "Error"
+	ClusterConditionPaused    = "Paused"
+
+	// ClusterConditionQuorumHealthy is true while the voting members report
+	// a single leader followed by a majority of them
+	ClusterConditionQuorumHealthy = "QuorumHealthy"
+
+	// ClusterConditionDynamicConfigDrift is true while the dynamic config
+	// committed by the ensemble does not hold exactly the members of the
+	// cluster
+	ClusterConditionDynamicConfigDrift = "DynamicConfigDrift"
+
+	// Reasons for cluster upgrading condition
+	UpgradeStartedReason    = "UpgradeStarted"
+	UpdatingZookeeperReason = "UpdatingZookeeper"
+	UpgradePausedReason     = "UpgradePaused"
+	CanaryUpgradedReason    = "CanaryUpgraded"
+	UpgradeRefusedReason    = "UpgradeRefused"
+	UpgradeErrorReason      = "UpgradeError"
+	NotUpgradingReason      = "NotUpgrading"
+
+	// Reasons for cluster pods ready condition
+	PodsReadyReason    = "PodsReady"
+	PodsNotReadyReason = "PodsNotReady"
+
+	// Reasons for cluster error condition
+	UpgradeFailedReason = "UpgradeFailed"
+	ErrorReason         = "Error"
+	NoErrorReason       = "NoError"
+
+	// Reasons for cluster paused condition
+	PausedReason  = "Paused"
+	ResumedReason = "Resumed"
+
+	// Reasons for cluster quorum healthy condition
+	QuorumHealthyReason   = "QuorumHealthy"
+	NoLeaderReason        = "NoLeader"
+	MultipleLeadersReason = "MultipleLeaders"
+	NoQuorumReason        = "NoQuorum"
+	// the voting members disagree on the dynamic config
+	ConfigVersionMismatchReason = "ConfigVersionMismatch"
+
+	// Reasons for cluster dynamic config drift condition
+	DynamicConfigInSyncReason       = "InSync"
+	DynamicConfigDriftReason        = "Drift"
+	DynamicConfigRepairFailedReason = "RepairFailed"
+)
+
+// ZookeeperClusterStatus defines the observed state of ZookeeperCluster
+type ZookeeperClusterStatus struct {
+	// Members is the zookeeper members in the cluster
+	Members MembersStatus `json:"members,omitempty"`
+
+	// Replicas is the number of number of desired replicas in the cluster
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of number of ready replicas in the cluster
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// InternalClientEndpoint is the internal client IP and port
+	InternalClientEndpoint string `json:"internalClientEndpoint,omitempty"`
+
+	// ExternalClientEndpoint is the internal client IP and port
+	ExternalClientEndpoint string `json:"externalClientEndpoint,omitempty"`
+
+	MetaRootCreated bool `json:"metaRootCreated,omitempty"`
+
+	// MetaRootSecured is true once the metadata znodes of the cluster only
+	// grant access to the super user, while authentication is enabled
+	// +optional
+	MetaRootSecured bool `json:"metaRootSecured,omitempty"`
+
+	// CurrentVersion is the current cluster version
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	TargetVersion string `json:"targetVersion,omitempty"`
+
+	// Conditions list all the applied conditions
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// QuorumTLS is the TLS configuration the members currently use between
+	// each other, it is unset while quorum TLS is disabled
+	// +optional
+	QuorumTLS *QuorumTLSStatus `json:"quorumTLS,omitempty"`
+
+	// QuorumAuth is the authentication the members currently use between
+	// each other, it is unset while quorum authentication is disabled
+	// +optional
+	QuorumAuth *QuorumAuthStatus `json:"quorumAuth,omitempty"`
+
+	// Restore is the progress of seeding the members with the snapshot of
+	// Spec.RestoreFrom
+	// +optional
+	Restore *RestoreStatus `json:"restore,omitempty"`
+
+	// VolumeExpansion is the progress of expanding the data volumes of the
+	// members to the storage requested in Spec.Persistence, it is unset
+	// until the request first grows
+	// +optional
+	VolumeExpansion *VolumeExpansionStatus `json:"volumeExpansion,omitempty"`
+
+	// Upgrade is the member the operator is restarting with the new pod
+	// template, with the LeaderAware upgrade strategy
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// UpgradeHistory are the last upgrades of the cluster, the latest last
+	// +optional
+	UpgradeHistory []UpgradeRecord `json:"upgradeHistory,omitempty"`
+
+	// Config is the status of the configuration the operator generates for
+	// the members
+	// +optional
+	Config *ConfigStatus `json:"config,omitempty"`
+
+	// Health is the state of the members as reported by their admin
+	// server, when the operator last probed them
+	// +optional
+	Health *HealthStatus `json:"health,omitempty"`
+}
+
+// HealthStatus is the state of the ensemble reported by the members
+type HealthStatus struct {
+	// Leader is the name of the member reporting it is the leader
+	// +optional
+	Leader string `json:"leader,omitempty"`
+
+	// Members are the voting members and the observers
+	// +optional
+	Members []MemberHealth `json:"members,omitempty"`
+
+	// LastProbeTime is when a probe of the members last found a change of
+	// their state
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+}
+
+// Leaders returns the names of the members reporting they are the leader
+func (hs *HealthStatus) Leaders() []string {
+	var leaders []string
+	for _, m := range hs.Members {
+		if m.Role == MemberRoleLeader {
+			leaders = append(leaders, m.Name)
+		}
+	}
+	return leaders
+}
+
+// SameState returns true if the members report the same leader, and the
+// same role, zxid, config version and error each, whatever their statistics
+// and the time of the probe
+func (hs *HealthStatus) SameState(other *HealthStatus) bool {
+	if hs == nil || other == nil {
+		return hs == other
+	}
+	if hs.Leader != other.Leader || len(hs.Members) != len(other.Members) {
+		return false
+	}
+	for i, m := range hs.Members {
+		o := other.Members[i]
+		if m.Name != o.Name || m.Role != o.Role || m.Zxid != o.Zxid || m.ConfigVersion != o.ConfigVersion || m.Error != o.Error {
+			return false
+		}
+	}
+	return true
+}
+
+// MemberHealth is the state a member reports about itself
+type MemberHealth struct {
+	// Name is the name of the pod of the member
+	Name string `json:"name"`
+
+	// Role is leader, follower, observer, or looking while the member is
+	// not part of a quorum
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// Zxid is the last transaction the member processed, in hexadecimal
+	// +optional
+	Zxid string `json:"zxid,omitempty"`
+
+	// Epoch is the epoch of the leader of the last transaction the member
+	// processed, the high 32 bits of the zxid
+	// +optional
+	Epoch int64 `json:"epoch,omitempty"`
+
+	// ConfigVersion is the version of the dynamic config of a voting member,
+	// in hexadecimal
+	// +optional
+	ConfigVersion string `json:"configVersion,omitempty"`
+
+	// OutstandingRequests is the number of requests queued by the member
+	// +optional
+	OutstandingRequests int64 `json:"outstandingRequests,omitempty"`
+
+	// AvgLatency is the average time in milliseconds the member takes to
+	// serve a request
+	// +optional
+	AvgLatency string `json:"avgLatency,omitempty"`
+
+	// MaxLatency is the longest time in milliseconds the member took to
+	// serve a request
+	// +optional
+	MaxLatency int64 `json:"maxLatency,omitempty"`
+
+	// Connections is the number of clients connected to the member
+	// +optional
+	Connections int64 `json:"connections,omitempty"`
+
+	// Error is why the member could not be probed
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// ConfigStatus is the status of the ConfigMap of the members
+type ConfigStatus struct {
+	// Hash is the hash of the data of the ConfigMap
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	// RolledOutHash is the hash carried by the pod template, the members
+	// run the configuration with this hash once they are restarted with
+	// the current pod template
+	// +optional
+	RolledOutHash string `json:"rolledOutHash,omitempty"`
+
+	// RestartTrigger is spec.restartTrigger when the configuration was
+	// last rolled out
+	// +optional
+	RestartTrigger string `json:"restartTrigger,omitempty"`
+
+	// RestartPending is true while the ConfigMap has a configuration which
+	// is not rolled out, with the Manual restart policy
+	// +optional
+	RestartPending bool `json:"restartPending,omitempty"`
+
+	// TLSSecretsHash is the hash of the data of the TLS secrets of the
+	// members. Their keystores are built when they start, so the pod
+	// template carries it to restart them when the certificates rotate.
+	// +optional
+	TLSSecretsHash string `json:"tlsSecretsHash,omitempty"`
+}
+
+// MaxUpgradeHistory is the number of upgrades kept in Status.UpgradeHistory
+const MaxUpgradeHistory = 10
+
+// UpgradeResult is how an upgrade ended
+type UpgradeResult string
+
+const (
+	// UpgradeSucceeded is the result of an upgrade all the members
+	// completed
+	UpgradeSucceeded UpgradeResult = "Succeeded"
+	// UpgradeFailed is the result of an upgrade which made no progress
+	// before its deadline, and was left to be fixed by hand
+	UpgradeFailed UpgradeResult = "Failed"
+	// UpgradeRolledBack is the result of an upgrade which failed, the
+	// members were reverted to the former version
+	UpgradeRolledBack UpgradeResult = "RolledBack"
+)
+
+// UpgradeRecord is an upgrade of the cluster
+type UpgradeRecord struct {
+	// From is the version the cluster was upgraded from
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// To is the version the cluster was upgraded to
+	To string `json:"to"`
+
+	// Result is how the upgrade ended
+	Result UpgradeResult `json:"result"`
+
+	// Reason explains why the upgrade failed
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// CompletionTime is when the upgrade succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// RecordUpgrade adds an upgrade to the history, which keeps the last
+// MaxUpgradeHistory ones
+func (zs *ZookeeperClusterStatus) RecordUpgrade(from string, to string, result UpgradeResult, reason string) {
+	zs.UpgradeHistory = append(zs.UpgradeHistory, UpgradeRecord{
+		From:           from,
+		To:             to,
+		Result:         result,
+		Reason:         reason,
+		CompletionTime: &metav1.Time{Time: time.Now()},
+	})
+	if n := len(zs.UpgradeHistory); n > MaxUpgradeHistory {
+		zs.UpgradeHistory = zs.UpgradeHistory[n-MaxUpgradeHistory:]
+	}
+}
+
+// Roles of the members, as reported by their admin server
+const (
+	MemberRoleLeader   = "leader"
+	MemberRoleFollower = "follower"
+	MemberRoleObserver = "observer"
+)
+
+// UpgradeStatus is the member being restarted by a LeaderAware upgrade
+type UpgradeStatus struct {
+	// Member is the name of the pod being restarted
+	Member string `json:"member"`
+
+	// Role of the member when it was restarted, leader or follower
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// StartTime is when the pod of the member was deleted
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// RestorePhase is the progress of a restore
+type RestorePhase string
+
+const (
+	// RestorePending is the phase of a restore waiting for its backup to
+	// succeed
+	RestorePending RestorePhase = "Pending"
+	// RestoreRunning is the phase of a restore seeding the members one
+	// after the other
+	RestoreRunning RestorePhase = "Running"
+	// RestoreSucceeded is the phase of a restore whose members are all
+	// seeded, they start from then on
+	RestoreSucceeded RestorePhase = "Succeeded"
+	// RestoreFailed is the phase of a restore which could not seed a
+	// member, the members are not started
+	RestoreFailed RestorePhase = "Failed"
+)
+
+// RestoreStatus is the progress of seeding the members with a snapshot
+type RestoreStatus struct {
+	// Phase is the progress of the restore
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// Location of the restored snapshot
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Zxid is the last transaction of the restored snapshot, in hex
+	// +optional
+	Zxid string `json:"zxid,omitempty"`
+
+	// Members is the number of members seeded with the snapshot, the
+	// replicas of the cluster when the restore started
+	// +optional
+	Members int32 `json:"members,omitempty"`
+
+	// RestoredMembers is the number of members seeded so far
+	// +optional
+	RestoredMembers int32 `json:"restoredMembers,omitempty"`
+
+	// StartTime is when the first member started to be seeded
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message explains why a restore is pending or failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// Restoring returns true if the members of the cluster wait for the
+// restore of a snapshot before they start. A cluster which ran before
+// Spec.RestoreFrom was set has no restore status and is never restored.
+func (z *ZookeeperCluster) Restoring() bool {
+	return z.Spec.RestoreFrom != nil && z.Status.Restore != nil && z.Status.Restore.Phase != RestoreSucceeded
+}
+
+// VolumeExpansionPhase is the progress of expanding the data volume of a
+// member
+type VolumeExpansionPhase string
+
+const (
+	// VolumeExpansionPending is the phase of a volume whose claim was not
+	// patched yet, because its StorageClass does not allow expansion
+	VolumeExpansionPending VolumeExpansionPhase = "Pending"
+	// VolumeExpansionResizing is the phase of a volume being expanded by
+	// its provisioner
+	VolumeExpansionResizing VolumeExpansionPhase = "Resizing"
+	// VolumeExpansionFileSystemResizePending is the phase of a volume
+	// whose file system is resized by the kubelet, some drivers only do it
+	// once the member restarts
+	VolumeExpansionFileSystemResizePending VolumeExpansionPhase = "FileSystemResizePending"
+	// VolumeExpansionCompleted is the phase of a volume with the capacity
+	// requested
+	VolumeExpansionCompleted VolumeExpansionPhase = "Completed"
+)
+
+// VolumeExpansionStatus is the progress of expanding the data volumes of
+// the members
+type VolumeExpansionStatus struct {
+	// Size is the storage the volumes are expanded to
+	Size string `json:"size"`
+
+	// Members is the progress of the volume of each member
+	// +optional
+	Members []MemberVolumeExpansion `json:"members,omitempty"`
+
+	// Message explains why the volumes are not expanded
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MemberVolumeExpansion is the progress of expanding the data volume of a
+// member
+type MemberVolumeExpansion struct {
+	// Name of the member
+	Name string `json:"name"`
+
+	// Claim is the name of the PersistentVolumeClaim of the member
+	Claim string `json:"claim"`
+
+	// Capacity is the current capacity of the volume
+	// +optional
+	Capacity string `json:"capacity,omitempty"`
+
+	// Phase is the progress of the expansion
+	Phase VolumeExpansionPhase `json:"phase"`
+}
+
+// QuorumTLSPhase is a step of the rolling restarts enabling TLS between the
+// members of a running cluster, following the zero downtime sequence of the
+// zookeeper administrator guide. Disabling it goes through the same phases
+// in reverse order.
+// +kubebuilder:validation:Enum=PortUnification;SSLQuorum;Enabled
+type QuorumTLSPhase string
+
+const (
+	// QuorumTLSDisabled members only use plaintext connections
+	QuorumTLSDisabled QuorumTLSPhase = ""
+
+	// QuorumTLSPortUnification members accept both plaintext and TLS
+	// connections, and connect to each other in plaintext
+	QuorumTLSPortUnification QuorumTLSPhase = "PortUnification"
+
+	// QuorumTLSSSLQuorum members accept both plaintext and TLS connections,
+	// and connect to each other over TLS
+	QuorumTLSSSLQuorum QuorumTLSPhase = "SSLQuorum"
+
+	// QuorumTLSEnabled members only accept TLS connections
+	QuorumTLSEnabled QuorumTLSPhase = "Enabled"
+)
+
+var quorumTLSPhases = []QuorumTLSPhase{
+	QuorumTLSDisabled,
+	QuorumTLSPortUnification,
+	QuorumTLSSSLQuorum,
+	QuorumTLSEnabled,
+}
+
+// NextQuorumTLSPhase returns the phase following p towards quorum TLS being
+// enabled, or disabled if enable is false. Each phase has to be rolled out
+// to all members before moving to the next one, so that members restarted
+// with it and the ones still running the previous one understand each other.
+func NextQuorumTLSPhase(p QuorumTLSPhase, enable bool) QuorumTLSPhase {
+	return nextPhase(quorumTLSPhases, p, enable)
+}
+
+func nextPhase[P ~string](phases []P, p P, enable bool) P {
+	for i, phase := range phases {
+		if phase != p {
+			continue
+		}
+		if enable && i < len(phases)-1 {
+			return phases[i+1]
+		}
+		if !enable && i > 0 {
+			return phases[i-1]
+		}
+	}
+	return p
+}
+
+// QuorumTLSStatus is the TLS configuration the members use between each other
+type QuorumTLSStatus struct {
+	// Phase is the step of the rolling restarts the pod template is at
+	Phase QuorumTLSPhase `json:"phase,omitempty"`
+
+	// SecretName is the Secret the members load their certificates from. It
+	// is kept while disabling quorum TLS, the members need it until the
+	// last phase is rolled out.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// QuorumTLSPhase returns the quorum TLS phase of the members
+func (zs *ZookeeperClusterStatus) QuorumTLSPhase() QuorumTLSPhase {
+	if zs.QuorumTLS == nil {
+		return QuorumTLSDisabled
+	}
+	return zs.QuorumTLS.Phase
+}
+
+// MembersStatus is the status of the members of the cluster with both
+// ready and unready node membership lists
+type MembersStatus struct {
+	//+nullable
+	Ready []string `json:"ready,omitempty"`
+	//+nullable
+	Unready []string `json:"unready,omitempty"`
+
+	// Observers are the observer members, which are not part of the lists
+	// above
+	// +optional
+	Observers *ObserverMembersStatus `json:"observers,omitempty"`
+}
+
+// ObserverMembersStatus lists the ready and unready observers
+type ObserverMembersStatus struct {
+	//+nullable
+	Ready []string `json:"ready,omitempty"`
+	//+nullable
+	Unready []string `json:"unready,omitempty"`
+}
+
+// DefaultConditionReason returns the reason used for a condition which is
+// set without one. Conditions require a reason, and the status helpers only
+// pass one when there is more to tell than the status itself.
+func DefaultConditionReason(condType string, status metav1.ConditionStatus) string {
+	switch condType {
+	case ClusterConditionPodsReady:
+		if status == metav1.ConditionTrue {
+			return PodsReadyReason
+		}
+		return PodsNotReadyReason
+	case ClusterConditionUpgrading:
+		if status == metav1.ConditionTrue {
+			return UpgradeStartedReason
+		}
+		return NotUpgradingReason
+	case ClusterConditionError:
+		if status == metav1.ConditionTrue {
+			return ErrorReason
+		}
+		return NoErrorReason
+	}
+	return string(status)
+}
+
+func (zs *ZookeeperClusterStatus) Init() {
+	// Initialise conditions
+	conditionTypes := []string{
+		ClusterConditionPodsReady,
+		ClusterConditionUpgrading,
+		ClusterConditionError,
+	}
+	for _, conditionType := range conditionTypes {
+		if _, condition := zs.GetClusterCondition(conditionType); condition == nil {
+			zs.setClusterCondition(conditionType, metav1.ConditionFalse, "", "")
+		}
+	}
+}
+
+func (zs *ZookeeperClusterStatus) SetPodsReadyConditionTrue() {
+	zs.setClusterCondition(ClusterConditionPodsReady, metav1.ConditionTrue, "", "")
+}
+
+func (zs *ZookeeperClusterStatus) SetPodsReadyConditionFalse() {
+	zs.setClusterCondition(ClusterConditionPodsReady, metav1.ConditionFalse, "", "")
+}
+
+func (zs *ZookeeperClusterStatus) SetUpgradingConditionTrue(reason, message string) {
+	zs.setClusterCondition(ClusterConditionUpgrading, metav1.ConditionTrue, reason, message)
+}
+
+func (zs *ZookeeperClusterStatus) SetUpgradingConditionFalse() {
+	zs.setClusterCondition(ClusterConditionUpgrading, metav1.ConditionFalse, "", "")
+}
+
+// SetUpgradeRefused sets the upgrading condition to false with the reason
+// the upgrade to the image of the spec is refused
+func (zs *ZookeeperClusterStatus) SetUpgradeRefused(message string) {
+	zs.setClusterCondition(ClusterConditionUpgrading, metav1.ConditionFalse, UpgradeRefusedReason, message)
+}
+
+func (zs *ZookeeperClusterStatus) SetErrorConditionTrue(reason, message string) {
+	zs.setClusterCondition(ClusterConditionError, metav1.ConditionTrue, reason, message)
+}
+
+func (zs *ZookeeperClusterStatus) SetErrorConditionFalse() {
+	zs.setClusterCondition(ClusterConditionError, metav1.ConditionFalse, "", "")
+}
+
+// SetPausedConditionTrue sets the paused condition, the message names what
+// is paused
+func (zs *ZookeeperClusterStatus) SetPausedConditionTrue(message string) {
+	zs.setClusterCondition(ClusterConditionPaused, metav1.ConditionTrue, PausedReason, message)
+}
+
+func (zs *ZookeeperClusterStatus) SetPausedConditionFalse() {
+	zs.setClusterCondition(ClusterConditionPaused, metav1.ConditionFalse, ResumedReason, "")
+}
+
+// SetQuorumHealthyCondition sets the quorum healthy condition, with the
+// reason it is not healthy
+func (zs *ZookeeperClusterStatus) SetQuorumHealthyCondition(healthy bool, reason, message string) {
+	status := metav1.ConditionFalse
+	if healthy {
+		status = metav1.ConditionTrue
+	}
+	zs.setClusterCondition(ClusterConditionQuorumHealthy, status, reason, message)
+}
+
+// SetDynamicConfigDriftCondition sets the dynamic config drift condition
+func (zs *ZookeeperClusterStatus) SetDynamicConfigDriftCondition(drift bool, reason, message string) {
+	status := metav1.ConditionFalse
+	if drift {
+		status = metav1.ConditionTrue
+	}
+	zs.setClusterCondition(ClusterConditionDynamicConfigDrift, status, reason, message)
+}
+
+// IsQuorumHealthy returns true if the members last reported a healthy
+// quorum, and false if they were never probed
+func (zs *ZookeeperClusterStatus) IsQuorumHealthy() bool {
+	_, condition := zs.GetClusterCondition(ClusterConditionQuorumHealthy)
+	return condition != nil && condition.Status == metav1.ConditionTrue
+}
+
+// GetClusterCondition returns the index and a copy of the condition of the
+// given type, or -1 and nil if the condition is not set
+func (zs *ZookeeperClusterStatus) GetClusterCondition(t string) (int, *metav1.Condition) {
+	for i, c := range zs.Conditions {
+		if t == c.Type {
+			return i, &c
+		}
+	}
+	return -1, nil
+}
+
+func (zs *ZookeeperClusterStatus) setClusterCondition(condType string, status metav1.ConditionStatus, reason, message string) {
+	if reason == "" {
+		reason = DefaultConditionReason(condType, status)
+	}
+	meta.SetStatusCondition(&zs.Conditions, metav1.Condition{
+		Type:    condType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (zs *ZookeeperClusterStatus) IsClusterInUpgradeFailedState() bool {
+	_, errorCondition := zs.GetClusterCondition(ClusterConditionError)
+	if errorCondition == nil {
+		return false
+	}
+	if errorCondition.Status == metav1.ConditionTrue && errorCondition.Reason == UpgradeFailedReason {
+		return true
+	}
+	return false
+}
+
+func (zs *ZookeeperClusterStatus) IsClusterInUpgradingState() bool {
+	_, upgradeCondition := zs.GetClusterCondition(ClusterConditionUpgrading)
+	if upgradeCondition == nil {
+		return false
+	}
+	if upgradeCondition.Status == metav1.ConditionTrue {
+		return true
+	}
+	return false
+}
+
+func (zs *ZookeeperClusterStatus) IsClusterInReadyState() bool {
+	_, readyCondition := zs.GetClusterCondition(ClusterConditionPodsReady)
+	if readyCondition != nil && readyCondition.Status == metav1.ConditionTrue {
+		return true
+	}
+	return false
+}
+
+// UpdateProgress records the number of updated replicas of an upgrade in the
+// message of the upgrading condition. The transition time is reset whenever
+// the progress changes, so that a stalled upgrade can be detected.
+func (zs *ZookeeperClusterStatus) UpdateProgress(reason, updatedReplicas string) {
+	i, upgradeCondition := zs.GetClusterCondition(ClusterConditionUpgrading)
+	if upgradeCondition == nil || upgradeCondition.Status != metav1.ConditionTrue {
+		return
+	}
+	if upgradeCondition.Reason != reason || upgradeCondition.Message != updatedReplicas {
+		upgradeCondition.Reason = reason
+		upgradeCondition.Message = updatedReplicas
+		upgradeCondition.LastTransitionTime = metav1.Now()
+		zs.Conditions[i] = *upgradeCondition
+	}
+}
+
+func (zs *ZookeeperClusterStatus) GetLastCondition() (lastCondition *metav1.Condition) {
+	if zs.IsClusterInUpgradingState() {
+		_, lastCondition := zs.GetClusterCondition(ClusterConditionUpgrading)
+		return lastCondition
+	}
+	// nothing to do if we are not upgrading
+	return nil
+}
+
+// QuorumAuthPhase is a step of the rolling restarts enabling SASL
+// authentication between the members of a running cluster, following the
+// rolling upgrade sequence of the zookeeper documentation. Disabling it goes
+// through the same phases in reverse order.
+// +kubebuilder:validation:Enum=SASLEnabled;LearnerRequired;Required
+type QuorumAuthPhase string
+
+const (
+	// QuorumAuthDisabled members do not authenticate each other
+	QuorumAuthDisabled QuorumAuthPhase = ""
+
+	// QuorumAuthSASLEnabled members authenticate to each other, but accept
+	// members which do not
+	QuorumAuthSASLEnabled QuorumAuthPhase = "SASLEnabled"
+
+	// QuorumAuthLearnerRequired members only follow a leader which
+	// authenticates
+	QuorumAuthLearnerRequired QuorumAuthPhase = "LearnerRequired"
+
+	// QuorumAuthRequired members only accept members which authenticate
+	QuorumAuthRequired QuorumAuthPhase = "Required"
+)
+
+var quorumAuthPhases = []QuorumAuthPhase{
+	QuorumAuthDisabled,
+	QuorumAuthSASLEnabled,
+	QuorumAuthLearnerRequired,
+	QuorumAuthRequired,
+}
+
+// NextQuorumAuthPhase returns the phase following p towards quorum
+// authentication being required, or disabled if enable is false. Like the
+// quorum TLS phases, each one has to be rolled out to all members first.
+func NextQuorumAuthPhase(p QuorumAuthPhase, enable bool) QuorumAuthPhase {
+	return nextPhase(quorumAuthPhases, p, enable)
+}
+
+// QuorumAuthStatus is the authentication the members use between each other
+type QuorumAuthStatus struct {
+	// Phase is the step of the rolling restarts the pod template is at
+	Phase QuorumAuthPhase `json:"phase,omitempty"`
+
+	// User is the user the members authenticate as. It is kept while
+	// disabling quorum authentication, the members need it until the last
+	// phase is rolled out.
+	User string `json:"user,omitempty"`
+}
+
+// QuorumAuthPhase returns the quorum authentication phase of the members
+func (zs *ZookeeperClusterStatus) QuorumAuthPhase() QuorumAuthPhase {
+	if zs.QuorumAuth == nil {
+		return QuorumAuthDisabled
+	}
+	return zs.QuorumAuth.Phase
+}
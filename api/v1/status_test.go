@@ -0,0 +1,220 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1_test
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+var _ = Describe("ZookeeperCluster Status", func() {
+
+	var z v1.ZookeeperCluster
+
+	BeforeEach(func() {
+		z = v1.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "default",
+			},
+		}
+	})
+
+	Context("with nil status conditions", func() {
+		It("should not be upgrading, failed or ready", func() {
+			Ω(z.Status.IsClusterInUpgradingState()).To(BeFalse())
+			Ω(z.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+			Ω(z.Status.IsClusterInReadyState()).To(BeFalse())
+			Ω(z.Status.GetLastCondition()).To(BeNil())
+		})
+	})
+
+	Context("#Init", func() {
+		BeforeEach(func() {
+			z.Status.Init()
+		})
+
+		It("should set all conditions to false with their default reason", func() {
+			for _, t := range []string{v1.ClusterConditionPodsReady, v1.ClusterConditionUpgrading, v1.ClusterConditionError} {
+				_, condition := z.Status.GetClusterCondition(t)
+				Ω(condition).NotTo(BeNil())
+				Ω(condition.Status).To(Equal(metav1.ConditionFalse))
+				Ω(condition.Reason).To(Equal(v1.DefaultConditionReason(t, metav1.ConditionFalse)))
+				Ω(condition.LastTransitionTime.IsZero()).To(BeFalse())
+			}
+		})
+
+		It("should not duplicate conditions when called again", func() {
+			z.Status.Init()
+			Ω(z.Status.Conditions).To(HaveLen(3))
+		})
+	})
+
+	Context("pods ready condition", func() {
+		BeforeEach(func() {
+			z.Status.SetPodsReadyConditionFalse()
+			z.Status.SetPodsReadyConditionTrue()
+		})
+
+		It("should be true with the pods ready reason", func() {
+			_, condition := z.Status.GetClusterCondition(v1.ClusterConditionPodsReady)
+			Ω(condition.Status).To(Equal(metav1.ConditionTrue))
+			Ω(condition.Reason).To(Equal(v1.PodsReadyReason))
+			Ω(z.Status.IsClusterInReadyState()).To(BeTrue())
+		})
+
+		It("should be false again after it is reset", func() {
+			z.Status.SetPodsReadyConditionFalse()
+			Ω(z.Status.IsClusterInReadyState()).To(BeFalse())
+		})
+	})
+
+	Context("upgrading condition", func() {
+		BeforeEach(func() {
+			z.Status.SetUpgradingConditionFalse()
+			z.Status.SetUpgradingConditionTrue("", "")
+		})
+
+		It("should be true and returned as the last condition", func() {
+			Ω(z.Status.IsClusterInUpgradingState()).To(BeTrue())
+			condition := z.Status.GetLastCondition()
+			Ω(condition.Type).To(Equal(v1.ClusterConditionUpgrading))
+			Ω(condition.Reason).To(Equal(v1.UpgradeStartedReason))
+		})
+
+		It("should record progress and reset the transition time", func() {
+			_, before := z.Status.GetClusterCondition(v1.ClusterConditionUpgrading)
+			before.LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Hour))
+			z.Status.Conditions[0] = *before
+			z.Status.UpdateProgress(v1.UpdatingZookeeperReason, "3")
+
+			_, condition := z.Status.GetClusterCondition(v1.ClusterConditionUpgrading)
+			Ω(condition.Reason).To(Equal(v1.UpdatingZookeeperReason))
+			Ω(condition.Message).To(Equal("3"))
+			Ω(condition.LastTransitionTime.After(before.LastTransitionTime.Time)).To(BeTrue())
+		})
+
+		It("should not be upgrading after it is reset", func() {
+			z.Status.SetUpgradingConditionFalse()
+			Ω(z.Status.IsClusterInUpgradingState()).To(BeFalse())
+			Ω(z.Status.GetLastCondition()).To(BeNil())
+		})
+	})
+
+	Context("error condition", func() {
+		BeforeEach(func() {
+			z.Status.SetErrorConditionFalse()
+			z.Status.SetErrorConditionTrue(v1.UpgradeFailedReason, "")
+		})
+
+		It("should report a failed upgrade", func() {
+			_, condition := z.Status.GetClusterCondition(v1.ClusterConditionError)
+			Ω(condition.Status).To(Equal(metav1.ConditionTrue))
+			Ω(z.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+		})
+
+		It("should not report a failed upgrade after it is reset", func() {
+			z.Status.SetErrorConditionFalse()
+			_, condition := z.Status.GetClusterCondition(v1.ClusterConditionError)
+			Ω(condition.Reason).To(Equal(v1.NoErrorReason))
+			Ω(z.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+		})
+	})
+
+	Context("quorum TLS phase", func() {
+		It("should be disabled without a quorum TLS status", func() {
+			Ω(z.Status.QuorumTLSPhase()).To(Equal(v1.QuorumTLSDisabled))
+		})
+
+		It("should go through port unification when enabling", func() {
+			phase := v1.QuorumTLSDisabled
+			var phases []v1.QuorumTLSPhase
+			for phase != v1.QuorumTLSEnabled {
+				phase = v1.NextQuorumTLSPhase(phase, true)
+				phases = append(phases, phase)
+			}
+			Ω(phases).To(Equal([]v1.QuorumTLSPhase{v1.QuorumTLSPortUnification, v1.QuorumTLSSSLQuorum, v1.QuorumTLSEnabled}))
+			Ω(v1.NextQuorumTLSPhase(v1.QuorumTLSEnabled, true)).To(Equal(v1.QuorumTLSEnabled))
+		})
+
+		It("should go through the same phases in reverse when disabling", func() {
+			Ω(v1.NextQuorumTLSPhase(v1.QuorumTLSEnabled, false)).To(Equal(v1.QuorumTLSSSLQuorum))
+			Ω(v1.NextQuorumTLSPhase(v1.QuorumTLSSSLQuorum, false)).To(Equal(v1.QuorumTLSPortUnification))
+			Ω(v1.NextQuorumTLSPhase(v1.QuorumTLSPortUnification, false)).To(Equal(v1.QuorumTLSDisabled))
+			Ω(v1.NextQuorumTLSPhase(v1.QuorumTLSDisabled, false)).To(Equal(v1.QuorumTLSDisabled))
+		})
+
+		It("should start a new cluster with the final phase", func() {
+			z.Spec.TLS = &v1.TLS{Quorum: &v1.QuorumTLS{SecretName: "example-quorum-tls"}}
+			z.InitQuorumTLS()
+			Ω(z.Status.QuorumTLS).To(Equal(&v1.QuorumTLSStatus{Phase: v1.QuorumTLSEnabled, SecretName: "example-quorum-tls"}))
+		})
+	})
+
+	Context("upgrade history", func() {
+		It("should keep the last upgrades", func() {
+			for i := 0; i < v1.MaxUpgradeHistory+2; i++ {
+				z.Status.RecordUpgrade("0.2.6", fmt.Sprintf("0.2.%d", i), v1.UpgradeSucceeded, "")
+			}
+			Ω(z.Status.UpgradeHistory).To(HaveLen(v1.MaxUpgradeHistory))
+			Ω(z.Status.UpgradeHistory[0].To).To(Equal("0.2.2"))
+			last := z.Status.UpgradeHistory[v1.MaxUpgradeHistory-1]
+			Ω(last.To).To(Equal(fmt.Sprintf("0.2.%d", v1.MaxUpgradeHistory+1)))
+			Ω(last.CompletionTime.IsZero()).To(BeFalse())
+		})
+	})
+
+	Context("quorum auth phase", func() {
+		It("should require authentication last when enabling", func() {
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthDisabled, true)).To(Equal(v1.QuorumAuthSASLEnabled))
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthSASLEnabled, true)).To(Equal(v1.QuorumAuthLearnerRequired))
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthLearnerRequired, true)).To(Equal(v1.QuorumAuthRequired))
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthRequired, true)).To(Equal(v1.QuorumAuthRequired))
+		})
+
+		It("should stop requiring authentication first when disabling", func() {
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthRequired, false)).To(Equal(v1.QuorumAuthLearnerRequired))
+			Ω(v1.NextQuorumAuthPhase(v1.QuorumAuthSASLEnabled, false)).To(Equal(v1.QuorumAuthDisabled))
+		})
+
+		It("should start a new cluster with authentication required", func() {
+			z.Spec.Auth = &v1.Auth{SecretName: "example-users", QuorumUser: "quorum"}
+			z.InitQuorumAuth()
+			Ω(z.Status.QuorumAuth).To(Equal(&v1.QuorumAuthStatus{Phase: v1.QuorumAuthRequired, User: "quorum"}))
+			Ω(z.Status.QuorumAuthPhase()).To(Equal(v1.QuorumAuthRequired))
+		})
+	})
+
+	Context("health", func() {
+		It("should compare the state of the members only", func() {
+			health := &v1.HealthStatus{
+				Leader:        "default-0",
+				Members:       []v1.MemberHealth{{Name: "default-0", Role: "leader", Zxid: "0x100000002", AvgLatency: "0.5"}},
+				LastProbeTime: &metav1.Time{Time: time.Now()},
+			}
+			probed := health.DeepCopy()
+			probed.LastProbeTime = &metav1.Time{Time: time.Now().Add(time.Minute)}
+			probed.Members[0].AvgLatency = "1.5"
+			Ω(probed.SameState(health)).To(BeTrue())
+			probed.Members[0].ConfigVersion = "0x100000004"
+			Ω(probed.SameState(health)).To(BeFalse())
+			probed.Members[0].ConfigVersion = health.Members[0].ConfigVersion
+			probed.Members[0].Zxid = "0x100000003"
+			Ω(probed.SameState(health)).To(BeFalse())
+			Ω(probed.SameState(nil)).To(BeFalse())
+		})
+	})
+})
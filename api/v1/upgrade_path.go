@@ -0,0 +1,162 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// AllowUpgradeAnnotation is the annotation of a ZookeeperCluster which lets
+// the upgrade to the image tag it is set to through, even though it is not
+// a supported upgrade path
+const AllowUpgradeAnnotation = "zookeeper.pravega.io/allow-upgrade"
+
+// ZookeeperVersion is a release of ZooKeeper
+// +kubebuilder:object:generate=false
+type ZookeeperVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// zookeeperVersionRegexp matches the ZooKeeper releases the operator knows
+// of, 3.x.y, at the start of an image tag such as 3.8.4 or v3.9.3-jre-17
+var zookeeperVersionRegexp = regexp.MustCompile(`^v?(3)\.(\d+)\.(\d+)`)
+
+// upgradePaths are the minor releases each minor release of ZooKeeper can
+// be upgraded to with a rolling upgrade. Minor releases cannot be skipped.
+var upgradePaths = map[int][]int{
+	4: {5},
+	5: {6},
+	6: {7},
+	7: {8},
+	8: {9},
+}
+
+// operatorImageVersions are the releases of ZooKeeper the tags of the
+// default image of the operator run, which are not ZooKeeper versions
+var operatorImageVersions = map[string]string{
+	DefaultZkContainerVersion: "3.9.3",
+}
+
+// snapshotFormatChanges are the first releases writing snapshots and
+// transaction logs which the releases before them cannot read
+var snapshotFormatChanges = []ZookeeperVersion{
+	{Major: 3, Minor: 5},
+	{Major: 3, Minor: 6},
+}
+
+// ParseZookeeperVersion returns the release of ZooKeeper at the start of the
+// given tag
+func ParseZookeeperVersion(tag string) (ZookeeperVersion, error) {
+	m := zookeeperVersionRegexp.FindStringSubmatch(tag)
+	if m == nil {
+		return ZookeeperVersion{}, fmt.Errorf("%s is not a ZooKeeper version", tag)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return ZookeeperVersion{Major: major, Minor: minor, Patch: patch}, nil
+}
+
+func (v ZookeeperVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less returns true if the version is an earlier release than the other
+func (v ZookeeperVersion) Less(o ZookeeperVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// CheckUpgradePath returns an error if the members of a cluster cannot be
+// restarted one at a time from one release to the other, because the
+// upgrade skips a minor release or the downgrade goes back past a change
+// of the format of the snapshots
+func CheckUpgradePath(from, to ZookeeperVersion) error {
+	if to.Less(from) {
+		for _, change := range snapshotFormatChanges {
+			if to.Less(change) && !from.Less(change) {
+				return fmt.Errorf("downgrade from %s to %s is not supported, the snapshots of %d.%d cannot be read by earlier releases",
+					from, to, change.Major, change.Minor)
+			}
+		}
+		return nil
+	}
+	if from.Major == to.Major && from.Minor == to.Minor {
+		return nil
+	}
+	next := upgradePaths[from.Minor]
+	for _, minor := range next {
+		if from.Major == to.Major && to.Minor == minor {
+			return nil
+		}
+	}
+	if len(next) == 0 {
+		return fmt.Errorf("upgrade from %s to %s is not a supported upgrade path", from, to)
+	}
+	return fmt.Errorf("upgrade from %s to %s skips minor releases, upgrade to %d.%d first", from, to, from.Major, next[len(next)-1])
+}
+
+// TagVersion returns the release of ZooKeeper the image with the given tag
+// runs, from the versions of the upgrade policy, the tags of the default
+// image or the tag itself, or nil if it is unknown
+func (z *ZookeeperCluster) TagVersion(tag string) *ZookeeperVersion {
+	if version, ok := z.upgradePolicyVersion(tag); ok {
+		tag = version
+	} else if version, ok := operatorImageVersions[tag]; ok &&
+		(z.Spec.Image.Repository == "" || z.Spec.Image.Repository == DefaultZkContainerRepository) {
+		tag = version
+	}
+	version, err := ParseZookeeperVersion(tag)
+	if err != nil {
+		return nil
+	}
+	return &version
+}
+
+func (z *ZookeeperCluster) upgradePolicyVersion(tag string) (string, bool) {
+	if z.Spec.UpgradePolicy == nil {
+		return "", false
+	}
+	version, ok := z.Spec.UpgradePolicy.Versions[tag]
+	return version, ok
+}
+
+// UpgradeRefusal returns why the upgrade from Status.CurrentVersion to the
+// image tag of the spec is refused, or nil if it is a supported upgrade
+// path. Tags of unknown versions are not checked, see UpgradeUnchecked, and
+// the AllowUpgradeAnnotation lets any tag through.
+func (z *ZookeeperCluster) UpgradeRefusal() error {
+	from, to := z.Status.CurrentVersion, z.Spec.Image.Tag
+	if from == "" || from == to || z.Annotations[AllowUpgradeAnnotation] == to {
+		return nil
+	}
+	fromVersion, toVersion := z.TagVersion(from), z.TagVersion(to)
+	if fromVersion == nil || toVersion == nil {
+		return nil
+	}
+	return CheckUpgradePath(*fromVersion, *toVersion)
+}
+
+// UpgradeUnchecked returns true if the upgrade path from Status.CurrentVersion
+// to the image tag of the spec cannot be checked, because the ZooKeeper
+// version of either tag is unknown
+func (z *ZookeeperCluster) UpgradeUnchecked() bool {
+	return z.TagVersion(z.Status.CurrentVersion) == nil || z.TagVersion(z.Spec.Image.Tag) == nil
+}
@@ -0,0 +1,116 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1_test
+
+import (
+	v1 "github.com/pravega/zookeeper-operator/api/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade paths", func() {
+	check := func(from, to string) error {
+		fromVersion, err := v1.ParseZookeeperVersion(from)
+		Ω(err).To(BeNil())
+		toVersion, err := v1.ParseZookeeperVersion(to)
+		Ω(err).To(BeNil())
+		return v1.CheckUpgradePath(fromVersion, toVersion)
+	}
+
+	Context("#ParseZookeeperVersion", func() {
+		It("should parse the version at the start of the tag", func() {
+			version, err := v1.ParseZookeeperVersion("v3.9.3-jre-17")
+			Ω(err).To(BeNil())
+			Ω(version).To(Equal(v1.ZookeeperVersion{Major: 3, Minor: 9, Patch: 3}))
+		})
+
+		It("should not parse the tags of the operator images", func() {
+			_, err := v1.ParseZookeeperVersion("0.2.15")
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("#CheckUpgradePath", func() {
+		It("should accept the next minor release and patch releases", func() {
+			Ω(check("3.8.3", "3.9.3")).To(BeNil())
+			Ω(check("3.8.3", "3.8.4")).To(BeNil())
+			Ω(check("3.4.14", "3.5.10")).To(BeNil())
+		})
+
+		It("should refuse to skip minor releases", func() {
+			err := check("3.4.14", "3.7.2")
+			Ω(err).NotTo(BeNil())
+			Ω(err.Error()).To(Equal("upgrade from 3.4.14 to 3.7.2 skips minor releases, upgrade to 3.5 first"))
+		})
+
+		It("should refuse an upgrade from an unknown release", func() {
+			Ω(check("3.3.6", "3.4.14")).NotTo(BeNil())
+		})
+
+		It("should accept a downgrade to a release which reads the snapshots", func() {
+			Ω(check("3.9.3", "3.8.4")).To(BeNil())
+			Ω(check("3.9.3", "3.6.4")).To(BeNil())
+		})
+
+		It("should refuse a downgrade past a change of the snapshots", func() {
+			err := check("3.6.4", "3.5.10")
+			Ω(err).NotTo(BeNil())
+			Ω(err.Error()).To(ContainSubstring("the snapshots of 3.6 cannot be read"))
+			Ω(check("3.5.10", "3.4.14")).NotTo(BeNil())
+		})
+	})
+
+	Context("#UpgradeRefusal", func() {
+		var z *v1.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &v1.ZookeeperCluster{}
+			z.WithDefaults()
+			z.Status.CurrentVersion = "3.6.4"
+			z.Spec.Image.Tag = "3.8.4"
+		})
+
+		It("should refuse the upgrade and keep the current version", func() {
+			Ω(z.UpgradeRefusal()).NotTo(BeNil())
+			Ω(z.Image().Tag).To(Equal("3.6.4"))
+		})
+
+		It("should let the annotated tag through", func() {
+			z.Annotations = map[string]string{v1.AllowUpgradeAnnotation: "3.8.4"}
+			Ω(z.UpgradeRefusal()).To(BeNil())
+			Ω(z.Image().Tag).To(Equal("3.8.4"))
+		})
+
+		It("should map the tags to versions", func() {
+			z.Status.CurrentVersion = "0.2.14"
+			z.Spec.Image.Tag = "0.2.15"
+			Ω(z.UpgradeUnchecked()).To(BeTrue())
+			Ω(z.UpgradeRefusal()).To(BeNil())
+			z.Spec.UpgradePolicy = &v1.UpgradePolicy{Versions: map[string]string{"0.2.14": "3.5.10", "0.2.15": "3.8.4"}}
+			Ω(z.UpgradeRefusal()).NotTo(BeNil())
+		})
+
+		It("should know the version of the tags of the default image", func() {
+			z.Spec.Image.Tag = v1.DefaultZkContainerVersion
+			Ω(z.UpgradeUnchecked()).To(BeFalse())
+			Ω(z.UpgradeRefusal()).To(MatchError("upgrade from 3.6.4 to 3.9.3 skips minor releases, upgrade to 3.7 first"))
+			z.Spec.Image.Repository = "example.com/zookeeper"
+			Ω(z.UpgradeUnchecked()).To(BeTrue())
+			Ω(z.UpgradeRefusal()).To(BeNil())
+		})
+
+		It("should not check a new cluster", func() {
+			z.Status.CurrentVersion = ""
+			Ω(z.UpgradeRefusal()).To(BeNil())
+		})
+	})
+})
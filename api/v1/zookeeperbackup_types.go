@@ -0,0 +1,289 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultBackupS3Region is the region snapshots are signed for when the
+	// S3 target does not set one. S3-compatible stores like MinIO accept it
+	// whatever their configured region.
+	DefaultBackupS3Region = "us-east-1"
+)
+
+// ZookeeperBackupSpec defines the desired state of ZookeeperBackup
+type ZookeeperBackupSpec struct {
+	// ZookeeperCluster is the name of the cluster to back up, in the
+	// namespace of the backup
+	// +kubebuilder:validation:MinLength=1
+	ZookeeperCluster string `json:"zookeeperCluster"`
+
+	// Target is where the snapshot is stored, exactly one of its fields
+	// must be set
+	Target BackupTarget `json:"target"`
+}
+
+// BackupTarget is where the snapshot of a backup is stored
+type BackupTarget struct {
+	// PVC stores the snapshot on a persistent volume claim
+	// +optional
+	PVC *PVCTarget `json:"pvc,omitempty"`
+
+	// S3 uploads the snapshot to an S3-compatible bucket
+	// +optional
+	S3 *S3Target `json:"s3,omitempty"`
+}
+
+// PVCTarget stores snapshots under a directory of a persistent volume claim,
+// each backup in a sub-directory named after it
+type PVCTarget struct {
+	// ClaimName is the name of a persistent volume claim in the namespace
+	// of the backup
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path is the directory of the claim the backups are stored under.
+	// Defaults to the root of the claim.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// S3Target uploads snapshots to a bucket of an S3-compatible store, each
+// backup under a key prefix named after it
+type S3Target struct {
+	// Endpoint is the URL of the store, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://minio.minio.svc:9000. Buckets are addressed path-style.
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the name of the bucket
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Prefix is prepended to the keys of the snapshots
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region the requests are signed for. Defaults to us-east-1.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecret is the name of a Secret in the namespace of the
+	// backup holding the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the
+	// store
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// GetRegion returns the region of the target, or the default one
+func (s *S3Target) GetRegion() string {
+	return s3Region(s.Region)
+}
+
+func s3Region(region string) string {
+	if region == "" {
+		return DefaultBackupS3Region
+	}
+	return region
+}
+
+// Validate returns an error if the target does not set exactly one store
+func (t *BackupTarget) Validate() error {
+	if (t.PVC == nil) == (t.S3 == nil) {
+		return fmt.Errorf("exactly one of target.pvc and target.s3 must be set")
+	}
+	return nil
+}
+
+// RestoreSource is the snapshot a new cluster is restored from, exactly one
+// of its fields must be set
+type RestoreSource struct {
+	// Backup is the name of a succeeded ZookeeperBackup in the namespace of
+	// the cluster
+	// +optional
+	Backup string `json:"backup,omitempty"`
+
+	// Snapshot is the location of a snapshot taken outside of the operator
+	// +optional
+	Snapshot *SnapshotSource `json:"snapshot,omitempty"`
+}
+
+// SnapshotSource is the location of a snapshot file. The file must keep the
+// snapshot.<zxid> name zookeeper gave it, the zxid is read from it.
+type SnapshotSource struct {
+	// PVC reads the snapshot from a persistent volume claim
+	// +optional
+	PVC *PVCSnapshot `json:"pvc,omitempty"`
+
+	// S3 downloads the snapshot from an S3-compatible bucket
+	// +optional
+	S3 *S3Snapshot `json:"s3,omitempty"`
+
+	// Checksum is the hex encoded SHA-256 the snapshot is verified against
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// PVCSnapshot is a snapshot file on a persistent volume claim
+type PVCSnapshot struct {
+	// ClaimName is the name of a persistent volume claim in the namespace
+	// of the cluster
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path of the snapshot file in the claim
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
+// S3Snapshot is a snapshot object of an S3-compatible bucket
+type S3Snapshot struct {
+	// Endpoint is the URL of the store. Buckets are addressed path-style.
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the name of the bucket
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Key of the snapshot object
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// Region the requests are signed for. Defaults to us-east-1.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecret is the name of a Secret in the namespace of the
+	// cluster holding the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the
+	// store
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// GetRegion returns the region of the snapshot, or the default one
+func (s *S3Snapshot) GetRegion() string {
+	return s3Region(s.Region)
+}
+
+// Validate returns an error if the source does not set exactly one
+// snapshot
+func (r *RestoreSource) Validate() error {
+	if (r.Backup == "") == (r.Snapshot == nil) {
+		return fmt.Errorf("exactly one of restoreFrom.backup and restoreFrom.snapshot must be set")
+	}
+	if r.Snapshot != nil && (r.Snapshot.PVC == nil) == (r.Snapshot.S3 == nil) {
+		return fmt.Errorf("exactly one of restoreFrom.snapshot.pvc and restoreFrom.snapshot.s3 must be set")
+	}
+	return nil
+}
+
+// BackupPhase is the progress of a backup
+type BackupPhase string
+
+const (
+	// BackupPending is the phase of a backup whose job has not started yet
+	BackupPending BackupPhase = "Pending"
+	// BackupRunning is the phase of a backup whose job is taking the
+	// snapshot
+	BackupRunning BackupPhase = "Running"
+	// BackupSucceeded is the phase of a backup whose snapshot is stored
+	BackupSucceeded BackupPhase = "Succeeded"
+	// BackupFailed is the phase of a backup which did not store a
+	// snapshot, backups are not retried
+	BackupFailed BackupPhase = "Failed"
+)
+
+// ZookeeperBackupStatus defines the observed state of ZookeeperBackup
+type ZookeeperBackupStatus struct {
+	// Phase is the progress of the backup
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Location of the snapshot, pvc://<claim>/<path> or s3://<bucket>/<key>
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Zxid is the last transaction included in the snapshot, in hex
+	// +optional
+	Zxid string `json:"zxid,omitempty"`
+
+	// Size of the snapshot in bytes
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// Checksum is the hex encoded SHA-256 of the snapshot
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// StartTime is when the backup job was created
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the backup succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message explains why a backup failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// Finished returns true if the backup succeeded or failed
+func (s *ZookeeperBackupStatus) Finished() bool {
+	return s.Phase == BackupSucceeded || s.Phase == BackupFailed
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=zkbackup
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.zookeeperCluster`,description="The backed up ZookeeperCluster"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="The progress of the backup"
+// +kubebuilder:printcolumn:name="Zxid",type=string,JSONPath=`.status.zxid`,description="The last transaction included in the snapshot"
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.size`,description="The size of the snapshot in bytes"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ZookeeperBackup is the Schema for the zookeeperbackups API
+type ZookeeperBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperBackupSpec   `json:"spec,omitempty"`
+	Status ZookeeperBackupStatus `json:"status,omitempty"`
+}
+
+// JobName returns the name of the job taking the snapshot
+func (b *ZookeeperBackup) JobName() string {
+	return fmt.Sprintf("%s-backup", b.GetName())
+}
+
+// CleanupJobName returns the name of the job deleting the snapshot of a
+// deleted backup
+func (b *ZookeeperBackup) CleanupJobName() string {
+	return fmt.Sprintf("%s-cleanup", b.GetName())
+}
+
+// +kubebuilder:object:root=true
+
+// ZookeeperBackupList contains a list of ZookeeperBackup
+type ZookeeperBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperBackup{}, &ZookeeperBackupList{})
+}
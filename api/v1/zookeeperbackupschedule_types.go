@@ -0,0 +1,142 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ZookeeperBackupScheduleSpec defines the desired state of
+// ZookeeperBackupSchedule
+type ZookeeperBackupScheduleSpec struct {
+	// Schedule is a cron expression in UTC, e.g. "0 2 * * *" for 2am every
+	// night. The @hourly, @daily, @weekly, @monthly and @yearly macros are
+	// accepted too.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// ZookeeperCluster is the name of the cluster to back up, in the
+	// namespace of the schedule
+	// +kubebuilder:validation:MinLength=1
+	ZookeeperCluster string `json:"zookeeperCluster"`
+
+	// Target is where the snapshots are stored, exactly one of its fields
+	// must be set
+	Target BackupTarget `json:"target"`
+
+	// Retention is which backups are kept, the others are deleted together
+	// with their snapshot. All backups are kept when it is not set.
+	// +optional
+	Retention BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention is which succeeded backups of a schedule are kept. A
+// backup is kept if either rule keeps it. Failed backups are deleted once a
+// later backup succeeded.
+type BackupRetention struct {
+	// KeepLast is how many of the last succeeded backups are kept
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepLast int32 `json:"keepLast,omitempty"`
+
+	// KeepDailyDays is for how many days the last succeeded backup of the
+	// day is kept, today included. Days are in UTC.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepDailyDays int32 `json:"keepDailyDays,omitempty"`
+}
+
+// IsSet returns true if backups are pruned
+func (r *BackupRetention) IsSet() bool {
+	return r.KeepLast > 0 || r.KeepDailyDays > 0
+}
+
+// BackupRun is a finished backup of a schedule
+type BackupRun struct {
+	// Name of the ZookeeperBackup
+	Name string `json:"name"`
+
+	// CompletionTime is when the backup succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Zxid is the last transaction included in the snapshot of a succeeded
+	// backup
+	// +optional
+	Zxid string `json:"zxid,omitempty"`
+
+	// Message explains why a backup failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ZookeeperBackupScheduleStatus defines the observed state of
+// ZookeeperBackupSchedule
+type ZookeeperBackupScheduleStatus struct {
+	// LastScheduleTime is when a backup was last due
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is when the next backup is due
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// LastSuccessfulRun is the last backup which succeeded
+	// +optional
+	LastSuccessfulRun *BackupRun `json:"lastSuccessfulRun,omitempty"`
+
+	// LastFailedRun is the last backup which failed
+	// +optional
+	LastFailedRun *BackupRun `json:"lastFailedRun,omitempty"`
+
+	// Message explains why no backup is scheduled
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=zkbackupschedule
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.zookeeperCluster`,description="The backed up ZookeeperCluster"
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`,description="The cron expression of the schedule"
+// +kubebuilder:printcolumn:name="Last Success",type=date,JSONPath=`.status.lastSuccessfulRun.completionTime`,description="When a backup last succeeded"
+// +kubebuilder:printcolumn:name="Last Failure",type=date,JSONPath=`.status.lastFailedRun.completionTime`,description="When a backup last failed"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ZookeeperBackupSchedule is the Schema for the zookeeperbackupschedules API
+type ZookeeperBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperBackupScheduleSpec   `json:"spec,omitempty"`
+	Status ZookeeperBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// BackupName returns the name of the backup due at the given time
+func (s *ZookeeperBackupSchedule) BackupName(t time.Time) string {
+	return fmt.Sprintf("%s-%s", s.GetName(), t.UTC().Format("20060102-1504"))
+}
+
+// +kubebuilder:object:root=true
+
+// ZookeeperBackupScheduleList contains a list of ZookeeperBackupSchedule
+type ZookeeperBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperBackupSchedule{}, &ZookeeperBackupScheduleList{})
+}
@@ -0,0 +1,1409 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultZkContainerRepository is the default docker repo for the zookeeper
+	// container
+	DefaultZkContainerRepository = "pravega/zookeeper"
+
+	// DefaultZkContainerVersion is the default tag used for for the zookeeper
+	// container
+	DefaultZkContainerVersion = "0.2.15"
+
+	// DefaultZkContainerPolicy is the default container pull policy used
+	DefaultZkContainerPolicy = "Always"
+
+	// DefaultTerminationGracePeriod is the default time given before the
+	// container is stopped. This gives clients time to disconnect from a
+	// specific node gracefully.
+	DefaultTerminationGracePeriod = 30
+
+	// DefaultZookeeperCacheVolumeSize is the default volume size for the
+	// Zookeeper cache volume
+	DefaultZookeeperCacheVolumeSize = "20Gi"
+
+	// DefaultZookeeperDataLogVolumeSize is the default volume size for the
+	// transaction logs, when they have a volume of their own
+	DefaultZookeeperDataLogVolumeSize = "10Gi"
+
+	// DefaultReadinessProbeInitialDelaySeconds is the default initial delay (in seconds)
+	// for the readiness probe
+	DefaultReadinessProbeInitialDelaySeconds = 10
+
+	// DefaultReadinessProbePeriodSeconds is the default probe period (in seconds)
+	// for the readiness probe
+	DefaultReadinessProbePeriodSeconds = 10
+
+	// DefaultReadinessProbeFailureThreshold is the default probe failure threshold
+	// for the readiness probe
+	DefaultReadinessProbeFailureThreshold = 3
+
+	// DefaultReadinessProbeSuccessThreshold is the default probe success threshold
+	// for the readiness probe
+	DefaultReadinessProbeSuccessThreshold = 1
+
+	// DefaultReadinessProbeTimeoutSeconds is the default probe timeout (in seconds)
+	// for the readiness probe
+	DefaultReadinessProbeTimeoutSeconds = 10
+
+	// DefaultLivenessProbeInitialDelaySeconds is the default initial delay (in seconds)
+	// for the liveness probe
+	DefaultLivenessProbeInitialDelaySeconds = 10
+
+	// DefaultLivenessProbePeriodSeconds is the default probe period (in seconds)
+	// for the liveness probe
+	DefaultLivenessProbePeriodSeconds = 10
+
+	// DefaultLivenessProbeFailureThreshold is the default probe failure threshold
+	// for the liveness probe
+	DefaultLivenessProbeFailureThreshold = 3
+
+	// DefaultLivenessProbeTimeoutSeconds is the default probe timeout (in seconds)
+	// for the liveness probe
+	DefaultLivenessProbeTimeoutSeconds = 10
+
+	// DefaultClientPort is the default port clients connect to
+	DefaultClientPort = 2181
+
+	// DefaultQuorumPort is the default port followers use to connect to the
+	// leader
+	DefaultQuorumPort = 2888
+
+	// DefaultLeaderElectionPort is the default port used for leader election
+	DefaultLeaderElectionPort = 3888
+
+	// DefaultMetricsPort is the default port of the prometheus metrics
+	// provider
+	DefaultMetricsPort = 7000
+
+	// DefaultAdminServerPort is the default port of the embedded admin server
+	DefaultAdminServerPort = 8080
+
+	// DefaultSecureClientPort is the default port clients connect to over TLS
+	DefaultSecureClientPort = 2281
+
+	// RestartTriggerAnnotation is the pod template annotation carrying
+	// Spec.RestartTrigger
+	RestartTriggerAnnotation = "zookeeper.pravega.io/restart-trigger"
+
+	// ConfigHashAnnotation is the pod template annotation carrying
+	// Status.Config.RolledOutHash, a new hash rolls all pods
+	ConfigHashAnnotation = "zookeeper.pravega.io/config-hash"
+
+	// TLSSecretsHashAnnotation is the pod template annotation carrying
+	// Status.Config.TLSSecretsHash, a rotation of the certificates rolls
+	// all pods
+	TLSSecretsHashAnnotation = "zookeeper.pravega.io/tls-secrets-hash"
+
+	// QuorumTLSPhaseAnnotation is the pod template annotation carrying
+	// Status.QuorumTLS.Phase, a new phase rolls all pods
+	QuorumTLSPhaseAnnotation = "zookeeper.pravega.io/quorum-tls-phase"
+
+	// QuorumAuthPhaseAnnotation is the pod template annotation carrying
+	// Status.QuorumAuth.Phase, a new phase rolls all pods
+	QuorumAuthPhaseAnnotation = "zookeeper.pravega.io/quorum-auth-phase"
+
+	// DefaultSuperUser is the default user granted all permissions when
+	// authentication is enabled
+	DefaultSuperUser = "super"
+
+	// ObserverIDOffset is added to the ordinal of an observer to get its
+	// server id, which keeps the ids of the observers apart from those of
+	// the voting members. Server ids are part of the session ids and must
+	// not exceed 255.
+	ObserverIDOffset = 128
+
+	// MaxObservers is the largest number of observers, the highest server
+	// id is 255
+	MaxObservers = 127
+
+	// DefaultProgressDeadlineSeconds is the default time (in seconds) an
+	// upgrade may make no progress before it fails
+	DefaultProgressDeadlineSeconds = 600
+)
+
+// StorageType is the kind of volume backing the zookeeper data directory
+// +kubebuilder:validation:Enum=persistence;ephemeral
+type StorageType string
+
+const (
+	// StorageTypePersistence stores the data on a PersistentVolumeClaim per
+	// member
+	StorageTypePersistence StorageType = "persistence"
+
+	// StorageTypeEphemeral stores the data on an emptyDir volume, which is
+	// lost when the pod is deleted
+	StorageTypeEphemeral StorageType = "ephemeral"
+)
+
+// ZookeeperClusterSpec defines the desired state of ZookeeperCluster
+type ZookeeperClusterSpec struct {
+	// Image is the  container image. default is zookeeper:0.2.10
+	Image ContainerImage `json:"image,omitempty"`
+
+	// Labels specifies the labels to attach to all resources the operator
+	// creates for the zookeeper cluster, including StatefulSet, Pod,
+	// PersistentVolumeClaim, Service, ConfigMap, et al.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Replicas is the expected size of the zookeeper cluster.
+	// The pravega-operator will eventually make the size of the running cluster
+	// equal to the expected size.
+	//
+	// The valid range of size is from 1 to 7.
+	// +kubebuilder:validation:Minimum=1
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Ports are the ports the zookeeper container listens on
+	Ports Ports `json:"ports,omitempty"`
+
+	// TLS configures encrypted connections to the zookeeper cluster
+	// +optional
+	TLS *TLS `json:"tls,omitempty"`
+
+	// Auth enables authentication and the checks of the znode ACLs, which
+	// are skipped otherwise
+	// +optional
+	Auth *Auth `json:"auth,omitempty"`
+
+	// Pod defines the policy to create pod for the zookeeper cluster.
+	// Updating the Pod does not take effect on any existing pods.
+	Pod PodPolicy `json:"pod,omitempty"`
+
+	// Observers adds members which serve clients without voting, so that
+	// the cluster serves more reads without slowing down the writes
+	// +optional
+	Observers *Observers `json:"observers,omitempty"`
+
+	// RestoreFrom seeds the data of the members of a new cluster with a
+	// snapshot before they first start. It is ignored once the cluster
+	// has members, the progress is reported in Status.Restore.
+	// +optional
+	RestoreFrom *RestoreSource `json:"restoreFrom,omitempty"`
+
+	// UpgradePolicy is how the members are restarted when their pod
+	// template changes, e.g. to upgrade their image
+	// +optional
+	UpgradePolicy *UpgradePolicy `json:"upgradePolicy,omitempty"`
+
+	// Monitoring makes the operator create the resources of the Prometheus
+	// operator which scrape the metrics of the members, and alert on the
+	// health of the cluster
+	// +optional
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// Paused stops the operator from changing the resources of the cluster,
+	// e.g. during an incident. Only its status is kept up to date, and its
+	// deletion waits until it is resumed.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// PausedSubsystems are the parts of the cluster the operator leaves
+	// alone, while it goes on reconciling the others
+	// +optional
+	PausedSubsystems []Subsystem `json:"pausedSubsystems,omitempty"`
+
+	// AdminServerService defines the policy to create AdminServer Service
+	// for the zookeeper cluster.
+	AdminServerService AdminServerServicePolicy `json:"adminServerService,omitempty"`
+
+	// ClientService defines the policy to create client Service
+	// for the zookeeper cluster.
+	ClientService ClientServicePolicy `json:"clientService,omitempty"`
+
+	// RestartTrigger is copied to an annotation of the pod template. Setting
+	// it to a new value, e.g. the current time, restarts all the pods in the
+	// zookeeper cluster one at a time. The operator never modifies it.
+	RestartTrigger string `json:"restartTrigger,omitempty"`
+
+	// HeadlessService defines the policy to create headless Service
+	// for the zookeeper cluster.
+	HeadlessService HeadlessServicePolicy `json:"headlessService,omitempty"`
+
+	// StorageType is used to tell which type of storage we will be using.
+	// Default StorageType is persistence storage
+	StorageType StorageType `json:"storageType,omitempty"`
+
+	// Persistence is the configuration for zookeeper persistent layer.
+	// PersistentVolumeClaimSpec and VolumeReclaimPolicy can be specified in here.
+	Persistence *Persistence `json:"persistence,omitempty"`
+
+	// Ephemeral is the configuration which helps create ephemeral storage
+	// At anypoint only one of Persistence or Ephemeral should be present in the manifest
+	Ephemeral *Ephemeral `json:"ephemeral,omitempty"`
+
+	// Conf is the zookeeper configuration, which will be used to generate the
+	// static zookeeper configuration. If no configuration is provided required
+	// default values will be provided, and optional values will be excluded.
+	Conf ZookeeperConfig `json:"config,omitempty"`
+
+	// External host name appended for dns annotation
+	DomainName string `json:"domainName,omitempty"`
+
+	// Domain of the kubernetes cluster, defaults to cluster.local
+	KubernetesClusterDomain string `json:"kubernetesClusterDomain,omitempty"`
+
+	// Containers defines to support multi containers
+	Containers []v1.Container `json:"containers,omitempty"`
+
+	// Init containers to support initialization
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+
+	// Volumes defines to support customized volumes
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts defines to support customized volumeMounts
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Probes specifies the timeout values for the Readiness and Liveness Probes
+	// for the zookeeper pods.
+	// +optional
+	Probes *Probes `json:"probes,omitempty"`
+
+	// MaxUnavailableReplicas defines the
+	// MaxUnavailable Replicas in pdb.
+	// Default is 1.
+	MaxUnavailableReplicas int32 `json:"maxUnavailableReplicas,omitempty"`
+}
+
+type Probes struct {
+	// +optional
+	ReadinessProbe *Probe `json:"readinessProbe,omitempty"`
+	// +optional
+	LivenessProbe *Probe `json:"livenessProbe,omitempty"`
+}
+
+func (s *ZookeeperClusterSpec) withDefaults(z *ZookeeperCluster) (changed bool) {
+	changed = s.Image.withDefaults()
+	if s.Conf.withDefaults() {
+		changed = true
+	}
+	if s.Replicas == 0 {
+		s.Replicas = 3
+		changed = true
+	}
+	if s.Probes == nil {
+		changed = true
+		s.Probes = &Probes{}
+	}
+	if s.Probes.withDefaults() {
+		changed = true
+	}
+
+	if s.Ports.withDefaults() {
+		changed = true
+	}
+	if s.TLS.ClientEnabled() && s.Ports.SecureClient == 0 {
+		s.Ports.SecureClient = DefaultSecureClientPort
+		changed = true
+	} else if !s.TLS.ClientEnabled() && s.Ports.SecureClient != 0 {
+		// the port defaulted when client TLS was enabled would otherwise
+		// stay exposed once it is disabled
+		s.Ports.SecureClient = 0
+		changed = true
+	}
+	if s.Auth != nil && s.Auth.SuperUser == "" {
+		s.Auth.SuperUser = DefaultSuperUser
+		changed = true
+	}
+
+	if z.Spec.Labels == nil {
+		z.Spec.Labels = map[string]string{}
+		changed = true
+	}
+	if _, ok := z.Spec.Labels["app"]; !ok {
+		z.Spec.Labels["app"] = z.GetName()
+		changed = true
+	}
+	if _, ok := z.Spec.Labels["release"]; !ok {
+		z.Spec.Labels["release"] = z.GetName()
+		changed = true
+	}
+	if s.Pod.withDefaults(z) {
+		changed = true
+	}
+	if s.Observers != nil && s.Observers.withDefaults(z) {
+		changed = true
+	}
+	if s.Monitoring != nil && s.Monitoring.withDefaults() {
+		changed = true
+	}
+	if s.StorageType == StorageTypeEphemeral {
+		if s.Ephemeral == nil {
+			s.Ephemeral = &Ephemeral{}
+			s.Ephemeral.EmptyDirVolumeSource = v1.EmptyDirVolumeSource{}
+			changed = true
+		}
+	} else {
+		if s.StorageType == "" {
+			s.StorageType = StorageTypePersistence
+			changed = true
+		}
+		if s.Persistence == nil {
+			s.Persistence = &Persistence{}
+			changed = true
+		}
+		if s.Persistence.withDefaults() {
+			changed = true
+		}
+	}
+	if s.MaxUnavailableReplicas < 1 {
+		s.MaxUnavailableReplicas = 1
+		changed = true
+	}
+	return changed
+}
+
+type Probe struct {
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessThreshold int32 `json:"successThreshold"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds"`
+}
+
+// Generate CRD using kubebuilder
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=zk
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`,description="The number of ZooKeeper servers in the ensemble"
+// +kubebuilder:printcolumn:name="Ready Replicas",type=integer,JSONPath=`.status.readyReplicas`,description="The number of ZooKeeper servers in the ensemble that are in a Ready state"
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`,description="The current Zookeeper version"
+// +kubebuilder:printcolumn:name="Desired Version",type=string,JSONPath=`.spec.image.tag`,description="The desired Zookeeper version"
+// +kubebuilder:printcolumn:name="Internal Endpoint",type=string,JSONPath=`.status.internalClientEndpoint`,description="Client endpoint internal to cluster network"
+// +kubebuilder:printcolumn:name="External Endpoint",type=string,JSONPath=`.status.externalClientEndpoint`,description="Client endpoint external to cluster network via LoadBalancer"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:openapi-gen=true
+
+// ZookeeperCluster is the Schema for the zookeeperclusters API
+type ZookeeperCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperClusterSpec   `json:"spec,omitempty"`
+	Status ZookeeperClusterStatus `json:"status,omitempty"`
+}
+
+// WithDefaults set default values when not defined in the spec.
+func (z *ZookeeperCluster) WithDefaults() bool {
+	return z.Spec.withDefaults(z)
+}
+
+// InitQuorumTLS sets the quorum TLS status of a cluster which has no members
+// yet, they start with the final phase right away as there is nothing to roll
+func (z *ZookeeperCluster) InitQuorumTLS() {
+	if !z.Spec.TLS.QuorumEnabled() {
+		z.Status.QuorumTLS = nil
+		return
+	}
+	z.Status.QuorumTLS = &QuorumTLSStatus{
+		Phase:      QuorumTLSEnabled,
+		SecretName: z.Spec.TLS.Quorum.SecretName,
+	}
+}
+
+// InitQuorumAuth sets the quorum authentication status of a cluster which has
+// no members yet, they start with the final phase right away as there is
+// nothing to roll
+func (z *ZookeeperCluster) InitQuorumAuth() {
+	if !z.Spec.Auth.QuorumEnabled() {
+		z.Status.QuorumAuth = nil
+		return
+	}
+	z.Status.QuorumAuth = &QuorumAuthStatus{
+		Phase: QuorumAuthRequired,
+		User:  z.Spec.Auth.QuorumUser,
+	}
+}
+
+// ConfigMapName returns the name of the cluster config-map
+func (z *ZookeeperCluster) ConfigMapName() string {
+	return fmt.Sprintf("%s-configmap", z.GetName())
+}
+
+// AuthSecretName returns the name of the secret holding the JAAS
+// configuration the operator generates for the members
+func (z *ZookeeperCluster) AuthSecretName() string {
+	return fmt.Sprintf("%s-auth", z.GetName())
+}
+
+// DataLog returns the volume of the transaction logs, or nil if they are
+// stored on the data volume
+func (z *ZookeeperCluster) DataLog() *DataLogPersistence {
+	if z.Spec.StorageType == StorageTypeEphemeral || z.Spec.Persistence == nil {
+		return nil
+	}
+	return z.Spec.Persistence.DataLog
+}
+
+// GetKubernetesClusterDomain returns the cluster domain of kubernetes
+func (z *ZookeeperCluster) GetKubernetesClusterDomain() string {
+	if z.Spec.KubernetesClusterDomain == "" {
+		return "cluster.local"
+	}
+	return z.Spec.KubernetesClusterDomain
+}
+
+// GetObserverName returns the name of the observer StatefulSet of the
+// cluster, which is also the app label of the observer pods
+func (z *ZookeeperCluster) GetObserverName() string {
+	return fmt.Sprintf("%s-observer", z.GetName())
+}
+
+// GetObserverHeadlessServiceName returns the name of the headless service
+// the observers are registered under
+func (z *ZookeeperCluster) GetObserverHeadlessServiceName() string {
+	return fmt.Sprintf("%s-observer-headless", z.GetName())
+}
+
+// GetObserverClientServiceName returns the name of the client service
+// selecting only the observers
+func (z *ZookeeperCluster) GetObserverClientServiceName() string {
+	return fmt.Sprintf("%s-observer-client", z.GetName())
+}
+
+// ObserverReplicas returns the number of observers of the cluster
+func (z *ZookeeperCluster) ObserverReplicas() int32 {
+	if z.Spec.Observers == nil {
+		return 0
+	}
+	return z.Spec.Observers.Replicas
+}
+
+// GetClientServiceName returns the name of the client service for the cluster
+func (z *ZookeeperCluster) GetClientServiceName() string {
+	return fmt.Sprintf("%s-client", z.GetName())
+}
+
+// GetAdminServerServiceName returns the name of the admin server service for the cluster
+func (z *ZookeeperCluster) GetAdminServerServiceName() string {
+	return fmt.Sprintf("%s-admin-server", z.GetName())
+}
+
+// Ports are the ports a zookeeper cluster member listens on
+type Ports struct {
+	// Client is the port clients connect to. The default value is 2181.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Client int32 `json:"client,omitempty"`
+
+	// Quorum is the port followers use to connect to the leader. The default
+	// value is 2888.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Quorum int32 `json:"quorum,omitempty"`
+
+	// LeaderElection is the port used for leader election. The default value
+	// is 3888.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	LeaderElection int32 `json:"leaderElection,omitempty"`
+
+	// Metrics is the port of the prometheus metrics provider. The default
+	// value is 7000.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Metrics int32 `json:"metrics,omitempty"`
+
+	// AdminServer is the port of the embedded admin server. The default value
+	// is 8080.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	AdminServer int32 `json:"adminServer,omitempty"`
+
+	// SecureClient is the port clients connect to over TLS. It is only used
+	// when client TLS is enabled, the default value is then 2281, and it is
+	// cleared when client TLS is disabled.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	SecureClient int32 `json:"secureClient,omitempty"`
+
+	// Additional are further ports exposed by the zookeeper container, for
+	// instance for a java agent. They are not added to any service.
+	// +optional
+	Additional []v1.ContainerPort `json:"additional,omitempty"`
+}
+
+func (p *Ports) withDefaults() (changed bool) {
+	if p.Client == 0 {
+		changed = true
+		p.Client = DefaultClientPort
+	}
+	if p.Quorum == 0 {
+		changed = true
+		p.Quorum = DefaultQuorumPort
+	}
+	if p.LeaderElection == 0 {
+		changed = true
+		p.LeaderElection = DefaultLeaderElectionPort
+	}
+	if p.Metrics == 0 {
+		changed = true
+		p.Metrics = DefaultMetricsPort
+	}
+	if p.AdminServer == 0 {
+		changed = true
+		p.AdminServer = DefaultAdminServerPort
+	}
+	return changed
+}
+
+// ContainerPorts returns the ports of the zookeeper container
+func (p *Ports) ContainerPorts() []v1.ContainerPort {
+	ports := []v1.ContainerPort{
+		{Name: "client", ContainerPort: p.Client},
+		{Name: "quorum", ContainerPort: p.Quorum},
+		{Name: "leader-election", ContainerPort: p.LeaderElection},
+		{Name: "metrics", ContainerPort: p.Metrics},
+		{Name: "admin-server", ContainerPort: p.AdminServer},
+	}
+	if p.SecureClient != 0 {
+		ports = append(ports, v1.ContainerPort{Name: "client-tls", ContainerPort: p.SecureClient})
+	}
+	return append(ports, p.Additional...)
+}
+
+// TLS configures encrypted connections to the zookeeper cluster
+type TLS struct {
+	// Client enables TLS on the secure client port
+	// +optional
+	Client *ClientTLS `json:"client,omitempty"`
+
+	// Quorum enables TLS between the members of the cluster, on the quorum
+	// and leader election ports. Enabling or disabling it on a running
+	// cluster takes three rolling restarts, tracked in Status.QuorumTLS.
+	// +optional
+	Quorum *QuorumTLS `json:"quorum,omitempty"`
+}
+
+// ClientTLS configures the secure client port
+type ClientTLS struct {
+	// SecretName is the name of a Secret in the namespace of the cluster
+	// holding the PEM encoded server certificate in tls.crt, its private key
+	// in tls.key and the CA certificate in ca.crt, like the Secret of a
+	// cert-manager Certificate. The certificate must be valid for the client
+	// service, the operator presents it as well to connect to the cluster.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+// QuorumTLS configures TLS between the members of the cluster
+type QuorumTLS struct {
+	// SecretName is the name of a Secret in the namespace of the cluster
+	// holding the PEM encoded member certificate in tls.crt, its private key
+	// in tls.key and the CA certificate in ca.crt. Members verify the host
+	// name of each other, so the certificate must be valid for the members
+	// in the headless service, e.g. *.<name>-headless.<namespace>.svc.cluster.local,
+	// and of the observers in *.<name>-observer-headless.<namespace>.svc.cluster.local,
+	// for both server and client authentication.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+// ClientEnabled returns true if TLS is configured on the secure client port
+func (t *TLS) ClientEnabled() bool {
+	return t != nil && t.Client != nil
+}
+
+// QuorumEnabled returns true if TLS is configured between the members
+func (t *TLS) QuorumEnabled() bool {
+	return t != nil && t.Quorum != nil
+}
+
+// Auth configures SASL authentication with the DIGEST-MD5 mechanism
+type Auth struct {
+	// SecretName is the name of a Secret in the namespace of the cluster
+	// holding the password of each zookeeper user under the user name. The
+	// operator generates the JAAS configuration of the members from it, a
+	// change is picked up when the pods restart.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// SuperUser is the user of the Secret granted all permissions on every
+	// znode. The operator and the membership scripts of the pods
+	// authenticate as it. Defaults to super.
+	// +optional
+	SuperUser string `json:"superUser,omitempty"`
+
+	// QuorumUser enables SASL authentication between the members, which
+	// authenticate as this user of the Secret. Enabling or disabling it on
+	// a running cluster takes three rolling restarts, tracked in
+	// Status.QuorumAuth.
+	// +optional
+	QuorumUser string `json:"quorumUser,omitempty"`
+
+	// RequireClientAuth closes the sessions of clients which do not
+	// authenticate
+	// +optional
+	RequireClientAuth bool `json:"requireClientAuth,omitempty"`
+
+	// SkipACL keeps the znode ACLs unchecked, so that clients can move to
+	// authentication before the ACLs are enforced
+	// +optional
+	SkipACL bool `json:"skipACL,omitempty"`
+}
+
+// QuorumEnabled returns true if the members authenticate each other
+func (a *Auth) QuorumEnabled() bool {
+	return a != nil && a.QuorumUser != ""
+}
+
+// ContainerImage defines the fields needed for a Docker repository image. The
+// format here matches the predominant format used in Helm charts.
+type ContainerImage struct {
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	// +kubebuilder:validation:Enum="Always";"Never";"IfNotPresent"
+	PullPolicy v1.PullPolicy `json:"pullPolicy,omitempty"`
+}
+
+func (c *ContainerImage) withDefaults() (changed bool) {
+	if c.Repository == "" {
+		changed = true
+		c.Repository = DefaultZkContainerRepository
+	}
+	if c.Tag == "" {
+		changed = true
+		c.Tag = DefaultZkContainerVersion
+	}
+	if c.PullPolicy == "" {
+		changed = true
+		c.PullPolicy = DefaultZkContainerPolicy
+	}
+	return changed
+}
+
+// ToString formats a container image struct as a docker compatible repository
+// string.
+func (c *ContainerImage) ToString() string {
+	return fmt.Sprintf("%s:%s", c.Repository, c.Tag)
+}
+
+// PodPolicy defines the common pod configuration for Pods, including when used
+// in deployments, stateful-sets, etc.
+type PodPolicy struct {
+	// Labels specifies the labels to attach to pods the operator creates for the
+	// zookeeper cluster. Overrides any values specified in Spec.Labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// NodeSelector specifies a map of key-value pairs. For the pod to be
+	// eligible to run on a node, the node must have each of the indicated
+	// key-value pairs as labels.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// The scheduling constraints on pods.
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints to apply to the pods
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// Resources is the resource requirements for the container.
+	// This field cannot be updated once the cluster is created.
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Tolerations specifies the pod's tolerations.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// List of environment variables to set in the container.
+	// This field cannot be updated.
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// Annotations specifies the annotations to attach to pods the operator
+	// creates.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// SecurityContext specifies the security context for the entire pod
+	// More info: https://kubernetes.io/docs/tasks/configure-pod-container/security-context
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// TerminationGracePeriodSeconds is the amount of time that kubernetes will
+	// give for a pod instance to shutdown normally.
+	// The default value is 30.
+	TerminationGracePeriodSeconds int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// Service Account to be used in pods
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ImagePullSecrets is a list of references to secrets in the same namespace to use for pulling any images
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+func (p *PodPolicy) withDefaults(z *ZookeeperCluster) (changed bool) {
+	if p.Labels == nil {
+		p.Labels = map[string]string{}
+		changed = true
+	}
+	if p.TerminationGracePeriodSeconds == 0 {
+		p.TerminationGracePeriodSeconds = DefaultTerminationGracePeriod
+		changed = true
+	}
+	if p.ServiceAccountName == "" {
+		p.ServiceAccountName = "default"
+		changed = true
+	}
+	if z.Spec.Pod.Labels == nil {
+		p.Labels = map[string]string{}
+		changed = true
+	}
+	if _, ok := p.Labels["app"]; !ok {
+		p.Labels["app"] = z.GetName()
+		changed = true
+	}
+	if _, ok := p.Labels["release"]; !ok {
+		p.Labels["release"] = z.GetName()
+		changed = true
+	}
+	if p.Affinity == nil {
+		p.Affinity = preferredAntiAffinity(z.GetName())
+		changed = true
+	}
+	return changed
+}
+
+// preferredAntiAffinity spreads the pods with the given app label over the
+// nodes when possible
+func preferredAntiAffinity(app string) *v1.Affinity {
+	return &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{
+					Weight: 20,
+					PodAffinityTerm: v1.PodAffinityTerm{
+						TopologyKey: "kubernetes.io/hostname",
+						LabelSelector: &metav1.LabelSelector{
+							MatchExpressions: []metav1.LabelSelectorRequirement{
+								{
+									Key:      "app",
+									Operator: metav1.LabelSelectorOpIn,
+									Values:   []string{app},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Observers are members of the cluster which never vote. They run in their
+// own StatefulSet and join the dynamic config as observers for good.
+type Observers struct {
+	// Replicas is the number of observers
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=127
+	Replicas int32 `json:"replicas"`
+
+	// Pod defines the policy to create the observer pods. The labels,
+	// annotations and environment of Spec.Pod are not inherited, the
+	// service account defaults to the one of Spec.Pod.
+	// +optional
+	Pod PodPolicy `json:"pod,omitempty"`
+
+	// ClientService defines the policy to create the client Service
+	// selecting only the observers
+	// +optional
+	ClientService ClientServicePolicy `json:"clientService,omitempty"`
+}
+
+func (o *Observers) withDefaults(z *ZookeeperCluster) (changed bool) {
+	p := &o.Pod
+	if p.TerminationGracePeriodSeconds == 0 {
+		p.TerminationGracePeriodSeconds = DefaultTerminationGracePeriod
+		changed = true
+	}
+	if p.ServiceAccountName == "" {
+		p.ServiceAccountName = z.Spec.Pod.ServiceAccountName
+		changed = true
+	}
+	if p.Affinity == nil {
+		p.Affinity = preferredAntiAffinity(z.GetObserverName())
+		changed = true
+	}
+	return changed
+}
+
+// UpgradeStrategy is how the voting members are restarted with a new pod
+// template
+// +kubebuilder:validation:Enum=RollingUpdate;LeaderAware
+type UpgradeStrategy string
+
+const (
+	// RollingUpdateUpgrade leaves the order of the restarts to the
+	// rolling update of the StatefulSet, from the highest ordinal down
+	RollingUpdateUpgrade UpgradeStrategy = "RollingUpdate"
+
+	// LeaderAwareUpgrade has the operator delete the pods of the members
+	// itself, one at a time with the leader last, each once the previous
+	// one rejoined the ensemble
+	LeaderAwareUpgrade UpgradeStrategy = "LeaderAware"
+)
+
+// UpgradePolicy is how the members are restarted when their pod template
+// changes
+type UpgradePolicy struct {
+	// Strategy is RollingUpdate or LeaderAware. LeaderAware restarts the
+	// followers first and the leader last, so that it is elected only
+	// once, and waits for each member to rejoin the ensemble and sync
+	// before the next one. The observers keep the rolling update of
+	// their StatefulSet. Defaults to RollingUpdate.
+	// +optional
+	Strategy UpgradeStrategy `json:"strategy,omitempty"`
+
+	// AutoRollback reverts the members to the image of
+	// Status.CurrentVersion when an upgrade fails, instead of waiting for
+	// the failed upgrade to be fixed by hand. The failed tag is not tried
+	// again until spec.image.tag changes.
+	// +optional
+	AutoRollback bool `json:"autoRollback,omitempty"`
+
+	// ProgressDeadlineSeconds is how long an upgrade may go without a
+	// member being restarted with the new pod template before it is
+	// marked as failed. Defaults to 600.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// Canary is the number of voting members, from the highest ordinal
+	// down, which get the new image of an upgrade. The others keep the
+	// former one until the canary is raised or removed, and the observers
+	// are only upgraded after them. All the members get it when not set.
+	// Other changes of the pod template restart all the members.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Canary *int32 `json:"canary,omitempty"`
+
+	// Paused stops restarting members with the new image of an upgrade,
+	// the ones already restarted keep it. A paused upgrade never exceeds
+	// its progress deadline.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Versions maps the image tags which are not a ZooKeeper version, such
+	// as release-1, to the version of ZooKeeper they run, e.g. 3.8.4. The
+	// operator refuses an upgrade which is not a supported upgrade path of
+	// ZooKeeper; tags of unknown versions are not checked.
+	// +optional
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// ProgressDeadline returns how long an upgrade may make no progress
+func (z *ZookeeperCluster) ProgressDeadline() time.Duration {
+	if z.Spec.UpgradePolicy == nil || z.Spec.UpgradePolicy.ProgressDeadlineSeconds == 0 {
+		return DefaultProgressDeadlineSeconds * time.Second
+	}
+	return time.Duration(z.Spec.UpgradePolicy.ProgressDeadlineSeconds) * time.Second
+}
+
+// UpgradesPaused returns true if no more members are restarted with a new
+// pod template
+func (z *ZookeeperCluster) UpgradesPaused() bool {
+	return z.Spec.UpgradePolicy != nil && z.Spec.UpgradePolicy.Paused
+}
+
+// Upgrading returns true while the members are upgraded to a new image tag,
+// from the update of their StatefulSet until Status.TargetVersion is cleared
+func (z *ZookeeperCluster) Upgrading() bool {
+	if z.Status.TargetVersion != "" {
+		return true
+	}
+	return z.Status.CurrentVersion != "" && z.Image().Tag != z.Status.CurrentVersion
+}
+
+// UpgradePartition returns the ordinal from which the voting members get a
+// new pod template, which is the partition of the rolling update of their
+// StatefulSet. The canary and the pause only hold back upgrades, the other
+// changes of the pod template and a rollback restart all of them.
+func (z *ZookeeperCluster) UpgradePartition() int32 {
+	policy := z.Spec.UpgradePolicy
+	if policy == nil || z.UpgradeRolledBack() || !z.Upgrading() {
+		return 0
+	}
+	if policy.Paused {
+		return z.Spec.Replicas
+	}
+	if policy.Canary != nil && *policy.Canary < z.Spec.Replicas {
+		return z.Spec.Replicas - *policy.Canary
+	}
+	return 0
+}
+
+// AutoRollback returns true if failed upgrades are rolled back
+func (z *ZookeeperCluster) AutoRollback() bool {
+	return z.Spec.UpgradePolicy != nil && z.Spec.UpgradePolicy.AutoRollback
+}
+
+// UpgradeRolledBack returns true if the upgrade to the image tag of the spec
+// failed and was rolled back. The members keep the image of
+// Status.CurrentVersion until the tag changes.
+func (z *ZookeeperCluster) UpgradeRolledBack() bool {
+	history := z.Status.UpgradeHistory
+	if len(history) == 0 || z.Status.CurrentVersion == "" {
+		return false
+	}
+	last := history[len(history)-1]
+	return last.Result == UpgradeRolledBack && last.To == z.Spec.Image.Tag
+}
+
+// Image returns the image of the members, the one of the spec unless its
+// upgrade was rolled back or refused
+func (z *ZookeeperCluster) Image() *ContainerImage {
+	image := z.Spec.Image
+	if z.UpgradeRolledBack() || z.UpgradeRefusal() != nil {
+		image.Tag = z.Status.CurrentVersion
+	}
+	return &image
+}
+
+// LeaderAwareUpgrades returns true if the operator restarts the voting
+// members itself, the leader last
+func (z *ZookeeperCluster) LeaderAwareUpgrades() bool {
+	return z.Spec.UpgradePolicy != nil && z.Spec.UpgradePolicy.Strategy == LeaderAwareUpgrade
+}
+
+type AdminServerServicePolicy struct {
+	// Annotations specifies the annotations to attach to AdminServer service the operator
+	// creates.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	External bool `json:"external,omitempty"`
+}
+
+type ClientServicePolicy struct {
+	// Annotations specifies the annotations to attach to client service the operator
+	// creates.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type HeadlessServicePolicy struct {
+	// Annotations specifies the annotations to attach to headless service the operator
+	// creates.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (s *Probes) withDefaults() (changed bool) {
+	if s.ReadinessProbe == nil {
+		changed = true
+		s.ReadinessProbe = &Probe{}
+		s.ReadinessProbe.InitialDelaySeconds = DefaultReadinessProbeInitialDelaySeconds
+		s.ReadinessProbe.PeriodSeconds = DefaultReadinessProbePeriodSeconds
+		s.ReadinessProbe.FailureThreshold = DefaultReadinessProbeFailureThreshold
+		s.ReadinessProbe.SuccessThreshold = DefaultReadinessProbeSuccessThreshold
+		s.ReadinessProbe.TimeoutSeconds = DefaultReadinessProbeTimeoutSeconds
+	}
+
+	if s.LivenessProbe == nil {
+		changed = true
+		s.LivenessProbe = &Probe{}
+		s.LivenessProbe.InitialDelaySeconds = DefaultLivenessProbeInitialDelaySeconds
+		s.LivenessProbe.PeriodSeconds = DefaultLivenessProbePeriodSeconds
+		s.LivenessProbe.FailureThreshold = DefaultLivenessProbeFailureThreshold
+		s.LivenessProbe.TimeoutSeconds = DefaultLivenessProbeTimeoutSeconds
+	}
+
+	return changed
+}
+
+// ZookeeperConfig is the current configuration of each Zookeeper node, which
+// sets these values in the config-map
+type ZookeeperConfig struct {
+	// InitLimit is the amount of time, in ticks, to allow followers to connect
+	// and sync to a leader.
+	//
+	// Default value is 10.
+	InitLimit int `json:"initLimit,omitempty"`
+
+	// TickTime is the length of a single tick, which is the basic time unit used
+	// by Zookeeper, as measured in milliseconds
+	//
+	// The default value is 2000.
+	TickTime int `json:"tickTime,omitempty"`
+
+	// SyncLimit is the amount of time, in ticks, to allow followers to sync with
+	// Zookeeper.
+	//
+	// The default value is 2.
+	SyncLimit int `json:"syncLimit,omitempty"`
+
+	// Clients can submit requests faster than ZooKeeper can process them, especially
+	// if there are a lot of clients. Zookeeper will throttle Clients so that requests
+	// won't exceed global outstanding limit.
+	//
+	// The default value is 1000
+	GlobalOutstandingLimit int `json:"globalOutstandingLimit,omitempty"`
+
+	// To avoid seeks ZooKeeper allocates space in the transaction log file in
+	// blocks of preAllocSize kilobytes
+	//
+	// The default value is 64M
+	PreAllocSize int `json:"preAllocSize,omitempty"`
+
+	// ZooKeeper records its transactions using snapshots and a transaction log
+	// The number of transactions recorded in the transaction log before a snapshot
+	// can be taken is determined by snapCount
+	//
+	// The default value is 100,000
+	SnapCount int `json:"snapCount,omitempty"`
+
+	// Zookeeper maintains an in-memory list of last committed requests for fast
+	// synchronization with followers
+	//
+	// The default value is 500
+	CommitLogCount int `json:"commitLogCount,omitempty"`
+
+	// Snapshot size limit in Kb
+	//
+	// The defult value is 4GB
+	SnapSizeLimitInKb int `json:"snapSizeLimitInKb,omitempty"`
+
+	// Limits the total number of concurrent connections that can be made to a
+	//zookeeper server
+	//
+	// The defult value is 0, indicating no limit
+	MaxCnxns int `json:"maxCnxns,omitempty"`
+
+	// Limits the number of concurrent connections that a single client, identified
+	// by IP address, may make to a single member of the ZooKeeper ensemble.
+	//
+	// The default value is 60
+	MaxClientCnxns int `json:"maxClientCnxns,omitempty"`
+
+	// The minimum session timeout in milliseconds that the server will allow the
+	// client to negotiate
+	//
+	// The default value is 4000
+	MinSessionTimeout int `json:"minSessionTimeout,omitempty"`
+
+	// The maximum session timeout in milliseconds that the server will allow the
+	// client to negotiate.
+	//
+	// The default value is 40000
+	MaxSessionTimeout int `json:"maxSessionTimeout,omitempty"`
+
+	// Retain the snapshots according to retain count
+	//
+	// The default value is 3
+	AutoPurgeSnapRetainCount int `json:"autoPurgeSnapRetainCount,omitempty"`
+
+	// The time interval in hours for which the purge task has to be triggered
+	//
+	// Disabled by default
+	AutoPurgePurgeInterval int `json:"autoPurgePurgeInterval,omitempty"`
+
+	// QuorumListenOnAllIPs when set to true the ZooKeeper server will listen for
+	// connections from its peers on all available IP addresses, and not only the
+	// address configured in the server list of the configuration file. It affects
+	// the connections handling the ZAB protocol and the Fast Leader Election protocol.
+	//
+	// The default value is false.
+	QuorumListenOnAllIPs bool `json:"quorumListenOnAllIPs,omitempty"`
+
+	// key-value map of additional zookeeper configuration parameters
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	AdditionalConfig map[string]string `json:"additionalConfig,omitempty"`
+
+	// RestartPolicy is how the members are restarted when the zoo.cfg,
+	// log4j.properties or env.sh the operator generates for them changes.
+	// Automatic restarts them one at a time, like a new pod template.
+	// Manual leaves them running until spec.restartTrigger changes, and
+	// shows the pending change in status.config meanwhile. Defaults to
+	// Automatic.
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	// +optional
+	RestartPolicy ConfigRestartPolicy `json:"restartPolicy,omitempty"`
+}
+
+// Subsystem is a part of a cluster the operator reconciles, which can be
+// paused
+// +kubebuilder:validation:Enum=StatefulSet;ConfigMap;Services;PodDisruptionBudget;VolumeExpansion;PVCCleanup;Monitoring;DynamicConfig
+type Subsystem string
+
+const (
+	// SubsystemStatefulSet is the StatefulSets of the members, which
+	// includes scaling, upgrading and restarting them
+	SubsystemStatefulSet Subsystem = "StatefulSet"
+	// SubsystemConfigMap is the ConfigMap of the configuration of the
+	// members and the Secret of their authentication
+	SubsystemConfigMap Subsystem = "ConfigMap"
+	// SubsystemServices is the client, headless and admin server services
+	SubsystemServices Subsystem = "Services"
+	// SubsystemPodDisruptionBudget is the PodDisruptionBudget
+	SubsystemPodDisruptionBudget Subsystem = "PodDisruptionBudget"
+	// SubsystemVolumeExpansion is the expansion of the data volumes
+	SubsystemVolumeExpansion Subsystem = "VolumeExpansion"
+	// SubsystemPVCCleanup is the deletion of the volume claims of the
+	// members which are gone, or of all of them with the cluster
+	SubsystemPVCCleanup Subsystem = "PVCCleanup"
+	// SubsystemMonitoring is the monitor and the rule of the Prometheus
+	// operator
+	SubsystemMonitoring Subsystem = "Monitoring"
+	// SubsystemDynamicConfig is the repair of the drift of the dynamic
+	// config of the ensemble, which is still reported
+	SubsystemDynamicConfig Subsystem = "DynamicConfig"
+)
+
+// PausedAnnotation pauses the operator like Spec.Paused when set to true,
+// or the comma separated subsystems it is set to like
+// Spec.PausedSubsystems. It can be set while the spec cannot be changed.
+const PausedAnnotation = "zookeeper.pravega.io/paused"
+
+// IsPaused returns true if the operator leaves everything but the status of
+// the cluster alone
+func (z *ZookeeperCluster) IsPaused() bool {
+	return z.Spec.Paused || strings.TrimSpace(z.Annotations[PausedAnnotation]) == "true"
+}
+
+// PausedSubsystems returns the subsystems paused by the spec and the
+// annotation, or nil if the whole cluster is paused
+func (z *ZookeeperCluster) PausedSubsystems() []Subsystem {
+	if z.IsPaused() {
+		return nil
+	}
+	paused := append([]Subsystem{}, z.Spec.PausedSubsystems...)
+	if value := z.Annotations[PausedAnnotation]; value != "" {
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				paused = append(paused, Subsystem(s))
+			}
+		}
+	}
+	return paused
+}
+
+// IsSubsystemPaused returns true if the operator leaves the subsystem alone
+func (z *ZookeeperCluster) IsSubsystemPaused(subsystem Subsystem) bool {
+	if z.IsPaused() {
+		return true
+	}
+	for _, s := range z.PausedSubsystems() {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigRestartPolicy is how the members are restarted when their
+// configuration changes
+type ConfigRestartPolicy string
+
+const (
+	// ConfigRestartAutomatic restarts the members as soon as their
+	// configuration changes
+	ConfigRestartAutomatic ConfigRestartPolicy = "Automatic"
+
+	// ConfigRestartManual restarts the members with their new
+	// configuration when the restart trigger changes
+	ConfigRestartManual ConfigRestartPolicy = "Manual"
+)
+
+// ManualConfigRestarts returns true if the members are only restarted with
+// a new configuration when the restart trigger changes
+func (z *ZookeeperCluster) ManualConfigRestarts() bool {
+	return z.Spec.Conf.RestartPolicy == ConfigRestartManual
+}
+
+func (c *ZookeeperConfig) withDefaults() (changed bool) {
+	if c.InitLimit == 0 {
+		changed = true
+		c.InitLimit = 10
+	}
+	if c.TickTime == 0 {
+		changed = true
+		c.TickTime = 2000
+	}
+	if c.SyncLimit == 0 {
+		changed = true
+		c.SyncLimit = 2
+	}
+	if c.GlobalOutstandingLimit == 0 {
+		changed = true
+		c.GlobalOutstandingLimit = 1000
+	}
+	if c.PreAllocSize == 0 {
+		changed = true
+		c.PreAllocSize = 65536
+	}
+	if c.SnapCount == 0 {
+		changed = true
+		c.SnapCount = 10000
+	}
+	if c.CommitLogCount == 0 {
+		changed = true
+		c.CommitLogCount = 500
+	}
+	if c.SnapSizeLimitInKb == 0 {
+		changed = true
+		c.SnapSizeLimitInKb = 4194304
+	}
+	if c.MaxClientCnxns == 0 {
+		changed = true
+		c.MaxClientCnxns = 60
+	}
+	if c.MinSessionTimeout == 0 {
+		changed = true
+		c.MinSessionTimeout = 2 * c.TickTime
+	}
+	if c.MaxSessionTimeout == 0 {
+		changed = true
+		c.MaxSessionTimeout = 20 * c.TickTime
+	}
+	if c.AutoPurgeSnapRetainCount == 0 {
+		changed = true
+		c.AutoPurgeSnapRetainCount = 3
+	}
+	if c.AutoPurgePurgeInterval == 0 {
+		changed = true
+		c.AutoPurgePurgeInterval = 1
+	}
+
+	return changed
+}
+
+type Persistence struct {
+	// VolumeReclaimPolicy is a zookeeper operator configuration. If it's set to Delete,
+	// the corresponding PVCs will be deleted by the operator when zookeeper cluster is deleted.
+	// The default value is Retain.
+	// +kubebuilder:validation:Enum="Delete";"Retain"
+	VolumeReclaimPolicy VolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// PersistentVolumeClaimSpec is the spec to describe PVC for the container
+	// This field is optional. If no PVC is specified default persistentvolume
+	// will get created.
+	PersistentVolumeClaimSpec v1.PersistentVolumeClaimSpec `json:"spec,omitempty"`
+	// Annotations specifies the annotations to attach to pvc the operator
+	// creates.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// DataLog stores the transaction logs on a volume of their own, mounted
+	// at /datalog, rather than with the snapshots on the data volume, as
+	// zookeeper recommends a dedicated low latency device for them. The logs
+	// of the members of a running cluster are moved when it is set. It
+	// cannot be changed or removed afterwards.
+	// +optional
+	DataLog *DataLogPersistence `json:"dataLog,omitempty"`
+}
+
+// DataLogPersistence is the volume of the transaction logs of the members
+type DataLogPersistence struct {
+	// VolumeReclaimPolicy of the transaction log volumes. If it's set to
+	// Delete, their PVCs are deleted by the operator when the cluster is
+	// deleted. The default value is Retain.
+	// +kubebuilder:validation:Enum="Delete";"Retain"
+	VolumeReclaimPolicy VolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// PersistentVolumeClaimSpec is the spec of the PVC of the transaction
+	// logs, its storageClassName selects the device.
+	PersistentVolumeClaimSpec v1.PersistentVolumeClaimSpec `json:"spec,omitempty"`
+	// Annotations specifies the annotations to attach to the PVCs of the
+	// transaction logs.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Ephemeral struct {
+	//EmptyDirVolumeSource is optional and this will create the emptydir volume
+	//It has two parameters Medium and SizeLimit which are optional as well
+	//Medium specifies What type of storage medium should back this directory.
+	//SizeLimit specifies Total amount of local storage required for this EmptyDir volume.
+	EmptyDirVolumeSource v1.EmptyDirVolumeSource `json:"emptydirvolumesource,omitempty"`
+}
+
+func (p *Persistence) withDefaults() (changed bool) {
+	if !p.VolumeReclaimPolicy.isValid() {
+		changed = true
+		p.VolumeReclaimPolicy = VolumeReclaimPolicyRetain
+	}
+	p.PersistentVolumeClaimSpec.AccessModes = []v1.PersistentVolumeAccessMode{
+		v1.ReadWriteOnce,
+	}
+
+	storage, _ := p.PersistentVolumeClaimSpec.Resources.Requests["storage"]
+	if storage.IsZero() {
+		p.PersistentVolumeClaimSpec.Resources.Requests = v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse(DefaultZookeeperCacheVolumeSize),
+		}
+		changed = true
+	}
+	if p.DataLog != nil && p.DataLog.withDefaults() {
+		changed = true
+	}
+	return changed
+}
+
+func (p *DataLogPersistence) withDefaults() (changed bool) {
+	if !p.VolumeReclaimPolicy.isValid() {
+		changed = true
+		p.VolumeReclaimPolicy = VolumeReclaimPolicyRetain
+	}
+	p.PersistentVolumeClaimSpec.AccessModes = []v1.PersistentVolumeAccessMode{
+		v1.ReadWriteOnce,
+	}
+	storage := p.PersistentVolumeClaimSpec.Resources.Requests[v1.ResourceStorage]
+	if storage.IsZero() {
+		p.PersistentVolumeClaimSpec.Resources.Requests = v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse(DefaultZookeeperDataLogVolumeSize),
+		}
+		changed = true
+	}
+	return changed
+}
+
+func (v VolumeReclaimPolicy) isValid() bool {
+	if v != VolumeReclaimPolicyDelete && v != VolumeReclaimPolicyRetain {
+		return false
+	}
+	return true
+}
+
+type VolumeReclaimPolicy string
+
+const (
+	VolumeReclaimPolicyRetain VolumeReclaimPolicy = "Retain"
+	VolumeReclaimPolicyDelete VolumeReclaimPolicy = "Delete"
+)
+
+// +kubebuilder:object:root=true
+
+// ZookeeperClusterList contains a list of ZookeeperCluster
+type ZookeeperClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperCluster{}, &ZookeeperClusterList{})
+}
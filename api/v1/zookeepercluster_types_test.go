@@ -0,0 +1,275 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1_test
+
+import (
+	"time"
+
+	v1 "github.com/pravega/zookeeper-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZookeeperCluster Types", func() {
+	var z v1.ZookeeperCluster
+	BeforeEach(func() {
+		z = v1.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "example",
+			},
+		}
+	})
+
+	Context("#WithDefaults", func() {
+		var changed bool
+		BeforeEach(func() {
+			changed = z.WithDefaults()
+		})
+
+		It("should return as changed", func() {
+			Ω(changed).To(BeTrue())
+		})
+
+		It("should not change on the second call", func() {
+			Ω(z.WithDefaults()).To(BeFalse())
+		})
+
+		It("should have a replica count of 3", func() {
+			Ω(z.Spec.Replicas).To(BeEquivalentTo(3))
+		})
+
+		It("should have an app label", func() {
+			Ω(z.Spec.Labels["app"]).To(Equal("example"))
+		})
+
+		It("should have an empty restart trigger", func() {
+			Ω(z.Spec.RestartTrigger).To(Equal(""))
+		})
+
+		It("should default the super user when auth is enabled", func() {
+			Ω(z.Spec.Auth).To(BeNil())
+			z.Spec.Auth = &v1.Auth{SecretName: "example-users"}
+			Ω(z.WithDefaults()).To(BeTrue())
+			Ω(z.Spec.Auth.SuperUser).To(Equal(v1.DefaultSuperUser))
+		})
+
+		It("should default the observer pods", func() {
+			z.Spec.Pod.ServiceAccountName = "zookeeper"
+			z.Spec.Observers = &v1.Observers{Replicas: 2}
+			Ω(z.WithDefaults()).To(BeTrue())
+			pod := z.Spec.Observers.Pod
+			Ω(pod.TerminationGracePeriodSeconds).To(BeEquivalentTo(v1.DefaultTerminationGracePeriod))
+			Ω(pod.ServiceAccountName).To(Equal("zookeeper"))
+			term := pod.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+			Ω(term.LabelSelector.MatchExpressions[0].Values).To(Equal([]string{"example-observer"}))
+			Ω(z.ObserverReplicas()).To(BeEquivalentTo(2))
+		})
+
+		It("should have the default image", func() {
+			Ω(z.Spec.Image.ToString()).To(Equal("pravega/zookeeper:0.2.15"))
+		})
+
+		It("should use persistent storage", func() {
+			Ω(z.Spec.StorageType).To(Equal(v1.StorageTypePersistence))
+			Ω(z.Spec.Persistence).NotTo(BeNil())
+			Ω(z.Spec.Ephemeral).To(BeNil())
+		})
+
+		It("should have a 20Gi RWO volume request", func() {
+			p := z.Spec.Persistence.PersistentVolumeClaimSpec
+			Ω(p.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+			Ω(p.Resources.Requests).To(Equal(corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("20Gi"),
+			}))
+		})
+
+		It("should set the zookeeper config defaults", func() {
+			Ω(z.Spec.Conf.InitLimit).To(Equal(10))
+			Ω(z.Spec.Conf.TickTime).To(Equal(2000))
+			Ω(z.Spec.Conf.SyncLimit).To(Equal(2))
+		})
+
+		It("should have the default ports", func() {
+			Ω(z.Spec.Ports.Client).To(BeEquivalentTo(v1.DefaultClientPort))
+			Ω(z.Spec.Ports.Quorum).To(BeEquivalentTo(v1.DefaultQuorumPort))
+			Ω(z.Spec.Ports.LeaderElection).To(BeEquivalentTo(v1.DefaultLeaderElectionPort))
+			Ω(z.Spec.Ports.Metrics).To(BeEquivalentTo(v1.DefaultMetricsPort))
+			Ω(z.Spec.Ports.AdminServer).To(BeEquivalentTo(v1.DefaultAdminServerPort))
+		})
+
+		Context("Ephemeral storage", func() {
+			BeforeEach(func() {
+				z = v1.ZookeeperCluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "example"},
+					Spec:       v1.ZookeeperClusterSpec{StorageType: v1.StorageTypeEphemeral},
+				}
+				z.WithDefaults()
+			})
+
+			It("should set an empty dir volume and no persistence", func() {
+				Ω(z.Spec.Ephemeral).NotTo(BeNil())
+				Ω(z.Spec.Ephemeral.EmptyDirVolumeSource.Medium).To(BeEquivalentTo(""))
+				Ω(z.Spec.Persistence).To(BeNil())
+			})
+		})
+
+		Context("Ports set by the user", func() {
+			BeforeEach(func() {
+				z = v1.ZookeeperCluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "example"},
+					Spec: v1.ZookeeperClusterSpec{
+						Ports: v1.Ports{Client: 2182, AdminServer: 8118},
+					},
+				}
+				z.WithDefaults()
+			})
+
+			It("should keep them and default the others", func() {
+				Ω(z.Spec.Ports.Client).To(BeEquivalentTo(2182))
+				Ω(z.Spec.Ports.AdminServer).To(BeEquivalentTo(8118))
+				Ω(z.Spec.Ports.Quorum).To(BeEquivalentTo(v1.DefaultQuorumPort))
+			})
+		})
+	})
+
+	Context("#Image", func() {
+		BeforeEach(func() {
+			z.WithDefaults()
+			z.Spec.Image.Tag = "0.2.7"
+			z.Status.CurrentVersion = "0.2.6"
+		})
+
+		It("should return the image of the spec", func() {
+			Ω(z.UpgradeRolledBack()).To(BeFalse())
+			Ω(z.Image().Tag).To(Equal("0.2.7"))
+		})
+
+		It("should return the former version after a rollback", func() {
+			z.Status.RecordUpgrade("0.2.6", "0.2.7", v1.UpgradeRolledBack, "progress deadline exceeded")
+			Ω(z.UpgradeRolledBack()).To(BeTrue())
+			Ω(z.Image().Tag).To(Equal("0.2.6"))
+			Ω(z.Spec.Image.Tag).To(Equal("0.2.7"))
+		})
+
+		It("should return the image of the spec once the tag changes", func() {
+			z.Status.RecordUpgrade("0.2.6", "0.2.7", v1.UpgradeRolledBack, "progress deadline exceeded")
+			z.Spec.Image.Tag = "0.2.8"
+			Ω(z.UpgradeRolledBack()).To(BeFalse())
+			Ω(z.Image().Tag).To(Equal("0.2.8"))
+		})
+	})
+
+	Context("#UpgradePartition", func() {
+		BeforeEach(func() {
+			z.WithDefaults()
+			z.Spec.UpgradePolicy = &v1.UpgradePolicy{}
+			z.Status.CurrentVersion = "0.2.6"
+			z.Status.TargetVersion = z.Spec.Image.Tag
+		})
+
+		It("should upgrade all the members by default", func() {
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(0))
+			Ω(z.ProgressDeadline()).To(Equal(10 * time.Minute))
+		})
+
+		It("should only upgrade the canary", func() {
+			canary := int32(1)
+			z.Spec.UpgradePolicy.Canary = &canary
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(2))
+			canary = 5
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(0))
+		})
+
+		It("should hold back the members once the tag of the spec changes", func() {
+			z.Status.TargetVersion = ""
+			z.Spec.UpgradePolicy.Paused = true
+			Ω(z.Upgrading()).To(BeTrue())
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(3))
+		})
+
+		It("should restart all the members without an upgrade", func() {
+			canary := int32(1)
+			z.Spec.UpgradePolicy.Canary = &canary
+			z.Status.CurrentVersion = z.Spec.Image.Tag
+			z.Status.TargetVersion = ""
+			Ω(z.Upgrading()).To(BeFalse())
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(0))
+			z.Spec.UpgradePolicy.Paused = true
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(0))
+		})
+
+		It("should upgrade no member when paused", func() {
+			z.Spec.UpgradePolicy.Paused = true
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(3))
+		})
+
+		It("should roll back all the members", func() {
+			z.Spec.UpgradePolicy.Paused = true
+			z.Spec.Image.Tag = "0.2.7"
+			z.Status.CurrentVersion = "0.2.6"
+			z.Status.RecordUpgrade("0.2.6", "0.2.7", v1.UpgradeRolledBack, "progress deadline exceeded")
+			Ω(z.UpgradePartition()).To(BeEquivalentTo(0))
+		})
+
+		It("should use the progress deadline of the policy", func() {
+			z.Spec.UpgradePolicy.ProgressDeadlineSeconds = 60
+			Ω(z.ProgressDeadline()).To(Equal(time.Minute))
+		})
+	})
+
+	Context("#IsSubsystemPaused", func() {
+		It("should pause everything with the spec or the annotation", func() {
+			Ω(z.IsPaused()).To(BeFalse())
+			z.Spec.Paused = true
+			Ω(z.IsSubsystemPaused(v1.SubsystemServices)).To(BeTrue())
+			z.Spec.Paused = false
+			z.Annotations = map[string]string{v1.PausedAnnotation: "true"}
+			Ω(z.IsPaused()).To(BeTrue())
+			Ω(z.PausedSubsystems()).To(BeNil())
+		})
+
+		It("should pause the subsystems of the spec and the annotation", func() {
+			z.Spec.PausedSubsystems = []v1.Subsystem{v1.SubsystemStatefulSet}
+			z.Annotations = map[string]string{v1.PausedAnnotation: "PVCCleanup, Services"}
+			Ω(z.IsPaused()).To(BeFalse())
+			Ω(z.PausedSubsystems()).To(Equal([]v1.Subsystem{v1.SubsystemStatefulSet, v1.SubsystemPVCCleanup, v1.SubsystemServices}))
+			Ω(z.IsSubsystemPaused(v1.SubsystemServices)).To(BeTrue())
+			Ω(z.IsSubsystemPaused(v1.SubsystemConfigMap)).To(BeFalse())
+		})
+	})
+
+	Context("#ContainerPorts", func() {
+		var ports []corev1.ContainerPort
+
+		BeforeEach(func() {
+			z.WithDefaults()
+			z.Spec.Ports.Additional = []corev1.ContainerPort{
+				{Name: "jmx", ContainerPort: 9999},
+			}
+			ports = z.Spec.Ports.ContainerPorts()
+		})
+
+		It("should return the named zookeeper ports followed by the additional ones", func() {
+			Ω(ports).To(Equal([]corev1.ContainerPort{
+				{Name: "client", ContainerPort: 2181},
+				{Name: "quorum", ContainerPort: 2888},
+				{Name: "leader-election", ContainerPort: 3888},
+				{Name: "metrics", ContainerPort: 7000},
+				{Name: "admin-server", ContainerPort: 8080},
+				{Name: "jmx", ContainerPort: 9999},
+			}))
+		})
+	})
+})
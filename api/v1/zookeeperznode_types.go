@@ -0,0 +1,202 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ZNodeType is the type a znode is created with
+type ZNodeType string
+
+const (
+	// ZNodePersistent is a znode which stays until it is deleted
+	ZNodePersistent ZNodeType = "Persistent"
+	// ZNodeContainer is a znode the server deletes once its last child
+	// is deleted, the operator creates it again then
+	ZNodeContainer ZNodeType = "Container"
+)
+
+// ZNodeDeletionPolicy is what happens to a znode when its ZookeeperZNode is
+// deleted
+type ZNodeDeletionPolicy string
+
+const (
+	// ZNodeOrphan keeps the znode
+	ZNodeOrphan ZNodeDeletionPolicy = "Orphan"
+	// ZNodeDelete deletes the znode together with its children
+	ZNodeDelete ZNodeDeletionPolicy = "Delete"
+)
+
+// ZookeeperZNodeSpec defines the desired state of ZookeeperZNode
+type ZookeeperZNodeSpec struct {
+	// ZookeeperCluster is the name of the cluster the znode is created in,
+	// in the namespace of the ZookeeperZNode
+	// +kubebuilder:validation:MinLength=1
+	ZookeeperCluster string `json:"zookeeperCluster"`
+
+	// Path is the absolute path of the znode. Its missing parents are
+	// created as persistent znodes with the default ACL, and are not
+	// managed. It cannot be changed.
+	// +kubebuilder:validation:Pattern=`^(/[^/]+)+$`
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="path is immutable"
+	Path string `json:"path"`
+
+	// Data of the znode, empty if not set
+	// +optional
+	Data ZNodeData `json:"data,omitempty"`
+
+	// ACLs of the znode. When not set, the znode is created with the
+	// default ACL of the operator, which is the super user when the
+	// cluster has authentication, and its ACL is not managed.
+	// +optional
+	ACLs []ZNodeACL `json:"acls,omitempty"`
+
+	// Type of the znode, Persistent or Container. Defaults to Persistent.
+	// The type of an existing znode is not changed.
+	// +kubebuilder:validation:Enum=Persistent;Container
+	// +optional
+	Type ZNodeType `json:"type,omitempty"`
+
+	// DeletionPolicy is what happens to the znode when the ZookeeperZNode
+	// is deleted. Orphan keeps it, Delete deletes it together with all of
+	// its children. Defaults to Orphan.
+	// +kubebuilder:validation:Enum=Orphan;Delete
+	// +optional
+	DeletionPolicy ZNodeDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ZNodeData is the data of a znode, at most one of its fields may be set
+type ZNodeData struct {
+	// Value is the data inline
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// ConfigMapKeyRef reads the data from a key of a ConfigMap in the
+	// namespace of the ZookeeperZNode
+	// +optional
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef reads the data from a key of a Secret in the namespace
+	// of the ZookeeperZNode
+	// +optional
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ZNodeACL is an entry of the ACL of a znode
+type ZNodeACL struct {
+	// Scheme is the authentication scheme, world, digest, ip, sasl or x509
+	// +kubebuilder:validation:Enum=world;digest;ip;sasl;x509
+	Scheme string `json:"scheme"`
+
+	// ID is the identity in the scheme, e.g. anyone for world, or
+	// <user>:<base64 sha1 of user:password> for digest. Defaults to anyone
+	// for world.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Permissions of the identity, read, write, create, delete, admin or
+	// all
+	// +kubebuilder:validation:MinItems=1
+	Permissions []ZNodePermission `json:"permissions"`
+}
+
+// ZNodePermission is a permission on a znode
+// +kubebuilder:validation:Enum=read;write;create;delete;admin;all
+type ZNodePermission string
+
+// IsContainer returns true if the znode is created as a container
+func (s *ZookeeperZNodeSpec) IsContainer() bool {
+	return s.Type == ZNodeContainer
+}
+
+// DeletesZNode returns true if the znode is deleted with the ZookeeperZNode
+func (s *ZookeeperZNodeSpec) DeletesZNode() bool {
+	return s.DeletionPolicy == ZNodeDelete
+}
+
+// Validate returns an error if the spec sets a path the operator manages,
+// or more than one data source
+func (s *ZookeeperZNodeSpec) Validate() error {
+	if s.Path == "/zookeeper" || strings.HasPrefix(s.Path, "/zookeeper/") {
+		return fmt.Errorf("path %s is reserved by zookeeper", s.Path)
+	}
+	if s.Path == "/zookeeper-operator" || strings.HasPrefix(s.Path, "/zookeeper-operator/") {
+		return fmt.Errorf("path %s is managed by the operator", s.Path)
+	}
+	sources := 0
+	if s.Data.Value != "" {
+		sources++
+	}
+	if s.Data.ConfigMapKeyRef != nil {
+		sources++
+	}
+	if s.Data.SecretKeyRef != nil {
+		sources++
+	}
+	if sources > 1 {
+		return fmt.Errorf("at most one of data.value, data.configMapKeyRef and data.secretKeyRef may be set")
+	}
+	return nil
+}
+
+// ZookeeperZNodeStatus defines the observed state of ZookeeperZNode
+type ZookeeperZNodeStatus struct {
+	// Synced is true if the znode had the data and ACLs of the spec when
+	// last reconciled
+	Synced bool `json:"synced"`
+
+	// ObservedGeneration is the generation of the spec last synced
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// DataVersion is the version of the data of the znode
+	// +optional
+	DataVersion int32 `json:"dataVersion,omitempty"`
+
+	// Message explains why the znode is not synced
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=zknode
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.zookeeperCluster`,description="The ZookeeperCluster of the znode"
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`,description="The path of the znode"
+// +kubebuilder:printcolumn:name="Synced",type=boolean,JSONPath=`.status.synced`,description="Whether the znode has the data and ACLs of the spec"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ZookeeperZNode is the Schema for the zookeeperznodes API
+type ZookeeperZNode struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZookeeperZNodeSpec   `json:"spec,omitempty"`
+	Status ZookeeperZNodeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ZookeeperZNodeList contains a list of ZookeeperZNode
+type ZookeeperZNodeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ZookeeperZNode `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ZookeeperZNode{}, &ZookeeperZNodeList{})
+}
@@ -0,0 +1,436 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// ConversionDataAnnotation keeps the fields of a v1 ZookeeperCluster which
+// cannot be represented in v1beta1, so that reading and writing a cluster
+// through v1beta1 does not lose them
+const ConversionDataAnnotation = "zookeeper.pravega.io/v1-conversion-data"
+
+// hubOnlyData are the fields stored in ConversionDataAnnotation
+type hubOnlyData struct {
+	RestartTrigger      string                          `json:"restartTrigger,omitempty"`
+	TLS                 *zookeeperv1.TLS                `json:"tls,omitempty"`
+	Auth                *zookeeperv1.Auth               `json:"auth,omitempty"`
+	Observers           *zookeeperv1.Observers          `json:"observers,omitempty"`
+	RestoreFrom         *zookeeperv1.RestoreSource      `json:"restoreFrom,omitempty"`
+	DataLog             *zookeeperv1.DataLogPersistence `json:"dataLog,omitempty"`
+	UpgradePolicy       *zookeeperv1.UpgradePolicy      `json:"upgradePolicy,omitempty"`
+	ConfigRestartPolicy zookeeperv1.ConfigRestartPolicy `json:"configRestartPolicy,omitempty"`
+	Paused              bool                            `json:"paused,omitempty"`
+	PausedSubsystems    []zookeeperv1.Subsystem         `json:"pausedSubsystems,omitempty"`
+	Monitoring          *zookeeperv1.Monitoring         `json:"monitoring,omitempty"`
+	Status              *hubOnlyStatus                  `json:"status,omitempty"`
+}
+
+// hubOnlyStatus are the fields of the status stored in
+// ConversionDataAnnotation, so that a status written through v1beta1 does
+// not lose the state of the rollouts and upgrades of the operator
+type hubOnlyStatus struct {
+	Observers       *zookeeperv1.ObserverMembersStatus `json:"observers,omitempty"`
+	QuorumTLS       *zookeeperv1.QuorumTLSStatus       `json:"quorumTLS,omitempty"`
+	QuorumAuth      *zookeeperv1.QuorumAuthStatus      `json:"quorumAuth,omitempty"`
+	Restore         *zookeeperv1.RestoreStatus         `json:"restore,omitempty"`
+	VolumeExpansion *zookeeperv1.VolumeExpansionStatus `json:"volumeExpansion,omitempty"`
+	Upgrade         *zookeeperv1.UpgradeStatus         `json:"upgrade,omitempty"`
+	UpgradeHistory  []zookeeperv1.UpgradeRecord        `json:"upgradeHistory,omitempty"`
+	Config          *zookeeperv1.ConfigStatus          `json:"config,omitempty"`
+	Health          *zookeeperv1.HealthStatus          `json:"health,omitempty"`
+	MetaRootSecured bool                               `json:"metaRootSecured,omitempty"`
+}
+
+// conditionReasons maps the reasons set by the v1beta1 status helpers to
+// their v1 equivalent, v1 reasons may not contain spaces
+var conditionReasons = map[string]string{
+	UpdatingZookeeperReason: zookeeperv1.UpdatingZookeeperReason,
+	UpgradeErrorReason:      zookeeperv1.UpgradeErrorReason,
+}
+
+var invalidReasonChars = regexp.MustCompile(`[^A-Za-z0-9_,:]`)
+
+var _ conversion.Convertible = &ZookeeperCluster{}
+
+// ConvertTo converts this ZookeeperCluster to the hub version (v1).
+// Setting TriggerRollingRestart sets the v1 RestartTrigger to a value
+// derived from the generation of the object, which restarts the cluster
+// once however many times the same object is converted.
+func (src *ZookeeperCluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*zookeeperv1.ZookeeperCluster)
+	if !ok {
+		return fmt.Errorf("expected a v1 ZookeeperCluster but got a %T", dstRaw)
+	}
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	hubData := hubOnlyData{}
+	if data, ok := dst.Annotations[ConversionDataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &hubData); err != nil {
+			return fmt.Errorf("failed to read annotation %s: %v", ConversionDataAnnotation, err)
+		}
+		delete(dst.Annotations, ConversionDataAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	convertSpecTo(&in.Spec, &dst.Spec)
+	dst.Spec.RestartTrigger = hubData.RestartTrigger
+	dst.Spec.TLS = hubData.TLS
+	dst.Spec.Auth = hubData.Auth
+	dst.Spec.Observers = hubData.Observers
+	dst.Spec.RestoreFrom = hubData.RestoreFrom
+	dst.Spec.UpgradePolicy = hubData.UpgradePolicy
+	dst.Spec.Conf.RestartPolicy = hubData.ConfigRestartPolicy
+	dst.Spec.Paused = hubData.Paused
+	dst.Spec.PausedSubsystems = hubData.PausedSubsystems
+	dst.Spec.Monitoring = hubData.Monitoring
+	if dst.Spec.Persistence != nil {
+		dst.Spec.Persistence.DataLog = hubData.DataLog
+	}
+	if in.Spec.TriggerRollingRestart {
+		dst.Spec.RestartTrigger = fmt.Sprintf("v1beta1-generation-%d", in.Generation)
+	}
+	convertStatusTo(&in.Status, &dst.Status, in.CreationTimestamp)
+	if s := hubData.Status; s != nil {
+		dst.Status.Members.Observers = s.Observers
+		dst.Status.QuorumTLS = s.QuorumTLS
+		dst.Status.QuorumAuth = s.QuorumAuth
+		dst.Status.Restore = s.Restore
+		dst.Status.VolumeExpansion = s.VolumeExpansion
+		dst.Status.Upgrade = s.Upgrade
+		dst.Status.UpgradeHistory = s.UpgradeHistory
+		dst.Status.Config = s.Config
+		dst.Status.Health = s.Health
+		dst.Status.MetaRootSecured = s.MetaRootSecured
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1) to this version
+func (dst *ZookeeperCluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*zookeeperv1.ZookeeperCluster)
+	if !ok {
+		return fmt.Errorf("expected a v1 ZookeeperCluster but got a %T", srcRaw)
+	}
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	hubData := hubOnlyData{
+		RestartTrigger:      in.Spec.RestartTrigger,
+		TLS:                 in.Spec.TLS,
+		Auth:                in.Spec.Auth,
+		Observers:           in.Spec.Observers,
+		RestoreFrom:         in.Spec.RestoreFrom,
+		UpgradePolicy:       in.Spec.UpgradePolicy,
+		ConfigRestartPolicy: in.Spec.Conf.RestartPolicy,
+		Paused:              in.Spec.Paused,
+		PausedSubsystems:    in.Spec.PausedSubsystems,
+		Monitoring:          in.Spec.Monitoring,
+	}
+	if in.Spec.Persistence != nil {
+		hubData.DataLog = in.Spec.Persistence.DataLog
+	}
+	status := hubOnlyStatus{
+		Observers:       in.Status.Members.Observers,
+		QuorumTLS:       in.Status.QuorumTLS,
+		QuorumAuth:      in.Status.QuorumAuth,
+		Restore:         in.Status.Restore,
+		VolumeExpansion: in.Status.VolumeExpansion,
+		Upgrade:         in.Status.Upgrade,
+		UpgradeHistory:  in.Status.UpgradeHistory,
+		Config:          in.Status.Config,
+		Health:          in.Status.Health,
+		MetaRootSecured: in.Status.MetaRootSecured,
+	}
+	if !reflect.DeepEqual(status, hubOnlyStatus{}) {
+		hubData.Status = &status
+	}
+	if !reflect.DeepEqual(hubData, hubOnlyData{}) {
+		data, err := json.Marshal(hubData)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[ConversionDataAnnotation] = string(data)
+	}
+
+	convertSpecFrom(&in.Spec, &dst.Spec)
+	convertStatusFrom(&in.Status, &dst.Status)
+	return nil
+}
+
+func convertSpecTo(in *ZookeeperClusterSpec, out *zookeeperv1.ZookeeperClusterSpec) {
+	out.Image = zookeeperv1.ContainerImage(in.Image)
+	out.Labels = in.Labels
+	out.Replicas = in.Replicas
+	out.Ports = convertPortsTo(in.Ports)
+	out.Pod = zookeeperv1.PodPolicy(in.Pod)
+	out.AdminServerService = zookeeperv1.AdminServerServicePolicy(in.AdminServerService)
+	out.ClientService = zookeeperv1.ClientServicePolicy(in.ClientService)
+	out.HeadlessService = zookeeperv1.HeadlessServicePolicy(in.HeadlessService)
+	out.StorageType = convertStorageTypeTo(in.StorageType)
+	if in.Persistence != nil {
+		out.Persistence = &zookeeperv1.Persistence{
+			VolumeReclaimPolicy:       zookeeperv1.VolumeReclaimPolicy(in.Persistence.VolumeReclaimPolicy),
+			PersistentVolumeClaimSpec: in.Persistence.PersistentVolumeClaimSpec,
+			Annotations:               in.Persistence.Annotations,
+		}
+	}
+	out.Ephemeral = (*zookeeperv1.Ephemeral)(in.Ephemeral)
+	out.Conf = convertConfigTo(in.Conf)
+	out.DomainName = in.DomainName
+	out.KubernetesClusterDomain = in.KubernetesClusterDomain
+	out.Containers = in.Containers
+	out.InitContainers = in.InitContainers
+	out.Volumes = in.Volumes
+	out.VolumeMounts = in.VolumeMounts
+	if in.Probes != nil {
+		out.Probes = &zookeeperv1.Probes{
+			ReadinessProbe: (*zookeeperv1.Probe)(in.Probes.ReadinessProbe),
+			LivenessProbe:  (*zookeeperv1.Probe)(in.Probes.LivenessProbe),
+		}
+	}
+	out.MaxUnavailableReplicas = in.MaxUnavailableReplicas
+}
+
+func convertSpecFrom(in *zookeeperv1.ZookeeperClusterSpec, out *ZookeeperClusterSpec) {
+	out.Image = ContainerImage(in.Image)
+	out.Labels = in.Labels
+	out.Replicas = in.Replicas
+	out.Ports = convertPortsFrom(&in.Ports)
+	out.Pod = PodPolicy(in.Pod)
+	out.AdminServerService = AdminServerServicePolicy(in.AdminServerService)
+	out.ClientService = ClientServicePolicy(in.ClientService)
+	out.HeadlessService = HeadlessServicePolicy(in.HeadlessService)
+	out.StorageType = string(in.StorageType)
+	if in.Persistence != nil {
+		out.Persistence = &Persistence{
+			VolumeReclaimPolicy:       VolumeReclaimPolicy(in.Persistence.VolumeReclaimPolicy),
+			PersistentVolumeClaimSpec: in.Persistence.PersistentVolumeClaimSpec,
+			Annotations:               in.Persistence.Annotations,
+		}
+	}
+	out.Ephemeral = (*Ephemeral)(in.Ephemeral)
+	out.Conf = convertConfigFrom(in.Conf)
+	out.DomainName = in.DomainName
+	out.KubernetesClusterDomain = in.KubernetesClusterDomain
+	out.Containers = in.Containers
+	out.InitContainers = in.InitContainers
+	out.Volumes = in.Volumes
+	out.VolumeMounts = in.VolumeMounts
+	if in.Probes != nil {
+		out.Probes = &Probes{
+			ReadinessProbe: (*Probe)(in.Probes.ReadinessProbe),
+			LivenessProbe:  (*Probe)(in.Probes.LivenessProbe),
+		}
+	}
+	out.MaxUnavailableReplicas = in.MaxUnavailableReplicas
+}
+
+// convertConfigTo copies the zookeeper configuration, v1 adds the restart
+// policy which is kept in the conversion data
+func convertConfigTo(in ZookeeperConfig) zookeeperv1.ZookeeperConfig {
+	return zookeeperv1.ZookeeperConfig{
+		InitLimit:                in.InitLimit,
+		TickTime:                 in.TickTime,
+		SyncLimit:                in.SyncLimit,
+		GlobalOutstandingLimit:   in.GlobalOutstandingLimit,
+		PreAllocSize:             in.PreAllocSize,
+		SnapCount:                in.SnapCount,
+		CommitLogCount:           in.CommitLogCount,
+		SnapSizeLimitInKb:        in.SnapSizeLimitInKb,
+		MaxCnxns:                 in.MaxCnxns,
+		MaxClientCnxns:           in.MaxClientCnxns,
+		MinSessionTimeout:        in.MinSessionTimeout,
+		MaxSessionTimeout:        in.MaxSessionTimeout,
+		AutoPurgeSnapRetainCount: in.AutoPurgeSnapRetainCount,
+		AutoPurgePurgeInterval:   in.AutoPurgePurgeInterval,
+		QuorumListenOnAllIPs:     in.QuorumListenOnAllIPs,
+		AdditionalConfig:         in.AdditionalConfig,
+	}
+}
+
+func convertConfigFrom(in zookeeperv1.ZookeeperConfig) ZookeeperConfig {
+	return ZookeeperConfig{
+		InitLimit:                in.InitLimit,
+		TickTime:                 in.TickTime,
+		SyncLimit:                in.SyncLimit,
+		GlobalOutstandingLimit:   in.GlobalOutstandingLimit,
+		PreAllocSize:             in.PreAllocSize,
+		SnapCount:                in.SnapCount,
+		CommitLogCount:           in.CommitLogCount,
+		SnapSizeLimitInKb:        in.SnapSizeLimitInKb,
+		MaxCnxns:                 in.MaxCnxns,
+		MaxClientCnxns:           in.MaxClientCnxns,
+		MinSessionTimeout:        in.MinSessionTimeout,
+		MaxSessionTimeout:        in.MaxSessionTimeout,
+		AutoPurgeSnapRetainCount: in.AutoPurgeSnapRetainCount,
+		AutoPurgePurgeInterval:   in.AutoPurgePurgeInterval,
+		QuorumListenOnAllIPs:     in.QuorumListenOnAllIPs,
+		AdditionalConfig:         in.AdditionalConfig,
+	}
+}
+
+// convertPortsTo picks the well-known ports out of a v1beta1 port list by
+// name, like ZookeeperPorts does. Only the port number of those is kept,
+// every other port is carried over unchanged.
+func convertPortsTo(in []v1.ContainerPort) zookeeperv1.Ports {
+	out := zookeeperv1.Ports{}
+	for _, p := range in {
+		switch p.Name {
+		case "client":
+			out.Client = p.ContainerPort
+		case "quorum":
+			out.Quorum = p.ContainerPort
+		case "leader-election":
+			out.LeaderElection = p.ContainerPort
+		case "metrics":
+			out.Metrics = p.ContainerPort
+		case "admin-server":
+			out.AdminServer = p.ContainerPort
+		case "client-tls":
+			out.SecureClient = p.ContainerPort
+		default:
+			out.Additional = append(out.Additional, p)
+		}
+	}
+	return out
+}
+
+func convertPortsFrom(in *zookeeperv1.Ports) []v1.ContainerPort {
+	var out []v1.ContainerPort
+	for _, p := range in.ContainerPorts() {
+		if p.ContainerPort != 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// convertStorageTypeTo maps the free form v1beta1 storage type to the v1
+// enum. Any value other than ephemeral has always meant persistence.
+func convertStorageTypeTo(in string) zookeeperv1.StorageType {
+	switch {
+	case in == "":
+		return ""
+	case strings.EqualFold(in, string(zookeeperv1.StorageTypeEphemeral)):
+		return zookeeperv1.StorageTypeEphemeral
+	default:
+		return zookeeperv1.StorageTypePersistence
+	}
+}
+
+func convertStatusTo(in *ZookeeperClusterStatus, out *zookeeperv1.ZookeeperClusterStatus, created metav1.Time) {
+	out.Members = zookeeperv1.MembersStatus{Ready: in.Members.Ready, Unready: in.Members.Unready}
+	out.Replicas = in.Replicas
+	out.ReadyReplicas = in.ReadyReplicas
+	out.InternalClientEndpoint = in.InternalClientEndpoint
+	out.ExternalClientEndpoint = in.ExternalClientEndpoint
+	out.MetaRootCreated = in.MetaRootCreated
+	out.CurrentVersion = in.CurrentVersion
+	out.TargetVersion = in.TargetVersion
+	out.Conditions = nil
+	for _, c := range in.Conditions {
+		out.Conditions = append(out.Conditions, convertConditionTo(c, created))
+	}
+}
+
+func convertStatusFrom(in *zookeeperv1.ZookeeperClusterStatus, out *ZookeeperClusterStatus) {
+	out.Members = MembersStatus{Ready: in.Members.Ready, Unready: in.Members.Unready}
+	out.Replicas = in.Replicas
+	out.ReadyReplicas = in.ReadyReplicas
+	out.InternalClientEndpoint = in.InternalClientEndpoint
+	out.ExternalClientEndpoint = in.ExternalClientEndpoint
+	out.MetaRootCreated = in.MetaRootCreated
+	out.CurrentVersion = in.CurrentVersion
+	out.TargetVersion = in.TargetVersion
+	out.Conditions = nil
+	for _, c := range in.Conditions {
+		out.Conditions = append(out.Conditions, convertConditionFrom(c))
+	}
+}
+
+// convertConditionTo converts a v1beta1 condition. A v1 condition requires a
+// reason and a transition time, the v1beta1 status helpers leave both empty
+// on conditions that never changed.
+func convertConditionTo(in ClusterCondition, created metav1.Time) metav1.Condition {
+	status := metav1.ConditionStatus(in.Status)
+	if status == "" {
+		status = metav1.ConditionUnknown
+	}
+	reason, ok := conditionReasons[in.Reason]
+	if !ok {
+		reason = invalidReasonChars.ReplaceAllString(in.Reason, "")
+	}
+	if reason == "" {
+		reason = zookeeperv1.DefaultConditionReason(string(in.Type), status)
+	}
+	// The v1beta1 update time is what tells a stalled upgrade apart, which
+	// is what the v1 transition time is used for
+	transition := created
+	for _, t := range []string{in.LastUpdateTime, in.LastTransitionTime} {
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			transition = metav1.NewTime(parsed)
+			break
+		}
+	}
+	if transition.IsZero() {
+		transition = metav1.NewTime(time.Unix(0, 0).UTC())
+	}
+	return metav1.Condition{
+		Type:               string(in.Type),
+		Status:             status,
+		Reason:             reason,
+		Message:            in.Message,
+		LastTransitionTime: transition,
+	}
+}
+
+func convertConditionFrom(in metav1.Condition) ClusterCondition {
+	reason := in.Reason
+	if reason == zookeeperv1.DefaultConditionReason(in.Type, in.Status) {
+		reason = ""
+	}
+	for v1beta1Reason, v1Reason := range conditionReasons {
+		if reason == v1Reason {
+			reason = v1beta1Reason
+		}
+	}
+	transition := ""
+	if !in.LastTransitionTime.IsZero() {
+		transition = in.LastTransitionTime.UTC().Format(time.RFC3339)
+	}
+	return ClusterCondition{
+		Type:               ClusterConditionType(in.Type),
+		Status:             v1.ConditionStatus(in.Status),
+		Reason:             reason,
+		Message:            in.Message,
+		LastUpdateTime:     transition,
+		LastTransitionTime: transition,
+	}
+}
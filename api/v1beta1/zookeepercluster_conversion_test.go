@@ -0,0 +1,280 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package v1beta1_test
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/api/v1beta1"
+)
+
+var _ = Describe("ZookeeperCluster Conversion", func() {
+
+	Context("converting a v1beta1 cluster to v1", func() {
+		var (
+			z   *v1beta1.ZookeeperCluster
+			hub *zookeeperv1.ZookeeperCluster
+			err error
+		)
+
+		BeforeEach(func() {
+			z = &v1beta1.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "example",
+					Namespace:         "default",
+					CreationTimestamp: metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+				},
+				Spec: v1beta1.ZookeeperClusterSpec{
+					Replicas:    5,
+					StorageType: "Ephemeral",
+					Ports: []corev1.ContainerPort{
+						{Name: "client", ContainerPort: 2182},
+						{Name: "admin-server", ContainerPort: 8118},
+						{Name: "jmx", ContainerPort: 9999, Protocol: corev1.ProtocolTCP},
+					},
+				},
+				Status: v1beta1.ZookeeperClusterStatus{
+					CurrentVersion: "0.2.14",
+					Conditions: []v1beta1.ClusterCondition{
+						{
+							Type:               v1beta1.ClusterConditionUpgrading,
+							Status:             corev1.ConditionTrue,
+							Reason:             v1beta1.UpdatingZookeeperReason,
+							Message:            "1",
+							LastUpdateTime:     "2021-02-01T10:00:00Z",
+							LastTransitionTime: "2021-02-01T09:00:00Z",
+						},
+						{
+							Type:   v1beta1.ClusterConditionPodsReady,
+							Status: corev1.ConditionFalse,
+						},
+					},
+				},
+			}
+			hub = &zookeeperv1.ZookeeperCluster{}
+		})
+
+		JustBeforeEach(func() {
+			err = z.ConvertTo(hub)
+		})
+
+		It("should convert the spec", func() {
+			Ω(err).To(BeNil())
+			Ω(hub.Name).To(Equal("example"))
+			Ω(hub.Spec.Replicas).To(BeEquivalentTo(5))
+			Ω(hub.Spec.StorageType).To(Equal(zookeeperv1.StorageTypeEphemeral))
+			Ω(hub.Spec.RestartTrigger).To(Equal(""))
+		})
+
+		It("should pick the well-known ports by name", func() {
+			Ω(hub.Spec.Ports.Client).To(BeEquivalentTo(2182))
+			Ω(hub.Spec.Ports.AdminServer).To(BeEquivalentTo(8118))
+			Ω(hub.Spec.Ports.Quorum).To(BeEquivalentTo(0))
+			Ω(hub.Spec.Ports.Additional).To(Equal([]corev1.ContainerPort{
+				{Name: "jmx", ContainerPort: 9999, Protocol: corev1.ProtocolTCP},
+			}))
+		})
+
+		It("should convert the conditions", func() {
+			Ω(hub.Status.CurrentVersion).To(Equal("0.2.14"))
+			_, upgrading := hub.Status.GetClusterCondition(zookeeperv1.ClusterConditionUpgrading)
+			Ω(upgrading.Status).To(Equal(metav1.ConditionTrue))
+			Ω(upgrading.Reason).To(Equal(zookeeperv1.UpdatingZookeeperReason))
+			Ω(upgrading.Message).To(Equal("1"))
+			Ω(upgrading.LastTransitionTime.UTC()).To(Equal(time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC)))
+		})
+
+		It("should fill in the reason and time of conditions that never changed", func() {
+			_, ready := hub.Status.GetClusterCondition(zookeeperv1.ClusterConditionPodsReady)
+			Ω(ready.Reason).To(Equal(zookeeperv1.PodsNotReadyReason))
+			Ω(ready.LastTransitionTime).To(Equal(z.CreationTimestamp))
+		})
+
+		Context("with any other storage type", func() {
+			BeforeEach(func() {
+				z.Spec.StorageType = "local"
+			})
+
+			It("should use persistence", func() {
+				Ω(hub.Spec.StorageType).To(Equal(zookeeperv1.StorageTypePersistence))
+			})
+		})
+
+		Context("with a rolling restart triggered", func() {
+			BeforeEach(func() {
+				z.Spec.TriggerRollingRestart = true
+			})
+
+			It("should set a restart trigger", func() {
+				Ω(hub.Spec.RestartTrigger).NotTo(Equal(""))
+			})
+
+			It("should set the same trigger for the same generation", func() {
+				again := &zookeeperv1.ZookeeperCluster{}
+				Ω(z.ConvertTo(again)).To(Succeed())
+				Ω(again.Spec.RestartTrigger).To(Equal(hub.Spec.RestartTrigger))
+				z.Generation++
+				Ω(z.ConvertTo(again)).To(Succeed())
+				Ω(again.Spec.RestartTrigger).NotTo(Equal(hub.Spec.RestartTrigger))
+			})
+		})
+
+		Context("with an invalid reason", func() {
+			BeforeEach(func() {
+				z.Status.Conditions[0].Reason = "Not a reason!"
+			})
+
+			It("should drop the invalid characters", func() {
+				_, upgrading := hub.Status.GetClusterCondition(zookeeperv1.ClusterConditionUpgrading)
+				Ω(upgrading.Reason).To(Equal("Notareason"))
+			})
+		})
+	})
+
+	Context("converting a v1 cluster to v1beta1 and back", func() {
+		var (
+			hub, back *zookeeperv1.ZookeeperCluster
+			z         *v1beta1.ZookeeperCluster
+			err, err2 error
+		)
+
+		BeforeEach(func() {
+			hub = &zookeeperv1.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "example",
+					Namespace:   "default",
+					Annotations: map[string]string{"foo": "bar"},
+				},
+				Spec: zookeeperv1.ZookeeperClusterSpec{
+					RestartTrigger: "1",
+					TLS: &zookeeperv1.TLS{
+						Client: &zookeeperv1.ClientTLS{SecretName: "example-tls"},
+						Quorum: &zookeeperv1.QuorumTLS{SecretName: "example-quorum-tls"},
+					},
+					Auth:          &zookeeperv1.Auth{SecretName: "example-users", QuorumUser: "quorum"},
+					Observers:     &zookeeperv1.Observers{Replicas: 2},
+					RestoreFrom:   &zookeeperv1.RestoreSource{Backup: "example-backup"},
+					UpgradePolicy: &zookeeperv1.UpgradePolicy{Strategy: zookeeperv1.LeaderAwareUpgrade},
+					Persistence: &zookeeperv1.Persistence{
+						DataLog: &zookeeperv1.DataLogPersistence{VolumeReclaimPolicy: zookeeperv1.VolumeReclaimPolicyDelete},
+					},
+					Ports: zookeeperv1.Ports{
+						Additional: []corev1.ContainerPort{{Name: "jmx", ContainerPort: 9999}},
+					},
+					Conf:             zookeeperv1.ZookeeperConfig{RestartPolicy: zookeeperv1.ConfigRestartManual},
+					PausedSubsystems: []zookeeperv1.Subsystem{zookeeperv1.SubsystemPVCCleanup},
+					Monitoring:       &zookeeperv1.Monitoring{Alerts: &zookeeperv1.Alerts{}},
+				},
+			}
+			hub.WithDefaults()
+			hub.Status.Init()
+			hub.Status.SetUpgradingConditionTrue("", "")
+			hub.Status.UpdateProgress(zookeeperv1.UpdatingZookeeperReason, "2")
+			hub.Status.SetPausedConditionTrue("The operator does not change PVCCleanup")
+			hub.Status.SetQuorumHealthyCondition(true, zookeeperv1.QuorumHealthyReason, "2 of the 3 voting members follow the leader example-1")
+			hub.Status.SetDynamicConfigDriftCondition(false, zookeeperv1.DynamicConfigInSyncReason, "")
+			// the v1 only status, with timestamps of a second precision in
+			// the local time zone, as decoded from the annotation
+			at := &metav1.Time{Time: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC).Local()}
+			hub.Status.Members = zookeeperv1.MembersStatus{
+				Ready:     []string{"example-0", "example-1"},
+				Unready:   []string{"example-2"},
+				Observers: &zookeeperv1.ObserverMembersStatus{Ready: []string{"example-observer-0"}},
+			}
+			hub.Status.QuorumTLS = &zookeeperv1.QuorumTLSStatus{Phase: zookeeperv1.QuorumTLSSSLQuorum, SecretName: "example-quorum-tls"}
+			hub.Status.QuorumAuth = &zookeeperv1.QuorumAuthStatus{Phase: zookeeperv1.QuorumAuthRequired, User: "quorum"}
+			hub.Status.Restore = &zookeeperv1.RestoreStatus{
+				Phase:           zookeeperv1.RestoreSucceeded,
+				Location:        "example-backup",
+				Zxid:            "0x200000005",
+				Members:         3,
+				RestoredMembers: 3,
+				StartTime:       at,
+				CompletionTime:  at,
+			}
+			hub.Status.VolumeExpansion = &zookeeperv1.VolumeExpansionStatus{
+				Size: "20Gi",
+				Members: []zookeeperv1.MemberVolumeExpansion{
+					{Name: "example-0", Claim: "data-example-0", Capacity: "10Gi", Phase: zookeeperv1.VolumeExpansionResizing},
+				},
+			}
+			hub.Status.Upgrade = &zookeeperv1.UpgradeStatus{Member: "example-2", Role: zookeeperv1.MemberRoleFollower, StartTime: at}
+			hub.Status.UpgradeHistory = []zookeeperv1.UpgradeRecord{
+				{From: "3.8.3", To: "3.8.4", Result: zookeeperv1.UpgradeSucceeded, CompletionTime: at},
+			}
+			hub.Status.Config = &zookeeperv1.ConfigStatus{Hash: "b", RolledOutHash: "a", RestartTrigger: "1", RestartPending: true, TLSSecretsHash: "c"}
+			hub.Status.MetaRootSecured = true
+			hub.Status.Health = &zookeeperv1.HealthStatus{
+				Leader:        "example-1",
+				LastProbeTime: at,
+				Members: []zookeeperv1.MemberHealth{
+					{Name: "example-1", Role: zookeeperv1.MemberRoleLeader, Zxid: "0x200000005", Epoch: 2, AvgLatency: "0.5", Connections: 4},
+					{Name: "example-2", Error: "not reachable"},
+				},
+			}
+			// v1beta1 keeps timestamps with a second precision
+			for i := range hub.Status.Conditions {
+				hub.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+			}
+			z = &v1beta1.ZookeeperCluster{}
+			back = &zookeeperv1.ZookeeperCluster{}
+			err = z.ConvertFrom(hub)
+			err2 = z.ConvertTo(back)
+		})
+
+		It("should stash the restart trigger in an annotation", func() {
+			Ω(err).To(BeNil())
+			Ω(z.Annotations).To(HaveKey(v1beta1.ConversionDataAnnotation))
+			Ω(z.Annotations["foo"]).To(Equal("bar"))
+			Ω(z.Spec.TriggerRollingRestart).To(BeFalse())
+		})
+
+		It("should convert the ports to a named list", func() {
+			Ω(z.Spec.Ports).To(ContainElement(corev1.ContainerPort{Name: "client", ContainerPort: 2181}))
+			Ω(z.Spec.Ports).To(ContainElement(corev1.ContainerPort{Name: "jmx", ContainerPort: 9999}))
+			Ω(z.Spec.Ports).To(ContainElement(corev1.ContainerPort{Name: "client-tls", ContainerPort: 2281}))
+			Ω(z.ZookeeperPorts().Leader).To(BeEquivalentTo(3888))
+		})
+
+		It("should use the v1beta1 reasons", func() {
+			_, upgrading := z.Status.GetClusterCondition(v1beta1.ClusterConditionUpgrading)
+			Ω(upgrading.Reason).To(Equal(v1beta1.UpdatingZookeeperReason))
+			_, ready := z.Status.GetClusterCondition(v1beta1.ClusterConditionPodsReady)
+			Ω(ready.Reason).To(Equal(""))
+		})
+
+		It("should not lose anything", func() {
+			Ω(err2).To(BeNil())
+			Ω(back).To(Equal(hub))
+		})
+
+		It("should keep the v1 only status through a v1beta1 status", func() {
+			Ω(z.Annotations[v1beta1.ConversionDataAnnotation]).To(ContainSubstring(`"status":`))
+			Ω(back.Status.Upgrade).To(Equal(hub.Status.Upgrade))
+			Ω(back.Status.Config).To(Equal(hub.Status.Config))
+			Ω(back.Status.Members.Observers).To(Equal(hub.Status.Members.Observers))
+		})
+	})
+
+	Context("converting to an unexpected hub", func() {
+		It("should fail", func() {
+			z := &v1beta1.ZookeeperCluster{}
+			Ω(z.ConvertTo(nil)).NotTo(Succeed())
+			Ω(z.ConvertFrom(nil)).NotTo(Succeed())
+		})
+	})
+})
@@ -49,3 +49,26 @@ Default volume template
 {{- define "chart.additionalVolumes"}}
 {{ toYaml .Values.additionalVolumes }}
 {{- end}}
+
+{{/*
+Annotations asking cert-manager to inject the webhook CA into the CRD
+*/}}
+{{- define "zookeeper-operator.crdWebhookAnnotations" -}}
+cert-manager.io/inject-ca-from: {{ .Release.Namespace }}/{{ template "zookeeper-operator.fullname" . }}-webhook-cert
+{{- end -}}
+
+{{/*
+Conversion webhook serving the v1beta1 version of the CRD
+*/}}
+{{- define "zookeeper-operator.crdConversion" -}}
+conversion:
+  strategy: Webhook
+  webhook:
+    clientConfig:
+      service:
+        name: {{ template "zookeeper-operator.fullname" . }}-webhook
+        namespace: {{ .Release.Namespace }}
+        path: /convert
+    conversionReviewVersions:
+    - v1
+{{- end -}}
@@ -0,0 +1,77 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/migration"
+	"github.com/pravega/zookeeper-operator/pkg/version"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var (
+	log    = ctrl.Log.WithName("cmd")
+	scheme = apimachineryruntime.NewScheme()
+)
+
+func init() {
+	utilruntime.Must(api.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+}
+
+func printVersion() {
+	log.Info(fmt.Sprintf("zookeeper-operator Version: %v", version.Version))
+	log.Info(fmt.Sprintf("Git SHA: %s", version.GitSHA))
+	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
+	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 10*time.Minute, "Time allowed for the whole migration")
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseDevMode(false)))
+	printVersion()
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		log.Error(err, "failed to get kubeconfig")
+		os.Exit(1)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		log.Error(err, "failed to create client")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	err = migration.MigrateStorageVersion(ctx, c, log)
+	if err != nil {
+		log.Error(err, "storage version migration failed")
+		os.Exit(1)
+	}
+	log.Info("Storage version migration completed")
+}
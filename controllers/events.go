@@ -0,0 +1,89 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package controllers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// reasons of the events of a ZookeeperCluster
+const (
+	StatefulSetCreatedEvent    = "StatefulSetCreated"
+	ScalingUpEvent             = "ScalingUp"
+	ScalingDownEvent           = "ScalingDown"
+	UpgradeStartedEvent        = "UpgradeStarted"
+	UpgradeProgressingEvent    = "UpgradeProgressing"
+	UpgradeCompletedEvent      = "UpgradeCompleted"
+	UpgradeFailedEvent         = "UpgradeFailed"
+	UpgradeRolledBackEvent     = "UpgradeRolledBack"
+	UpgradeRefusedEvent        = "UpgradeRefused"
+	RollingRestartEvent        = "RollingRestart"
+	PVCDeletedEvent            = "PVCDeleted"
+	MetadataZnodeCreatedEvent  = "MetadataZnodeCreated"
+	ZookeeperConnectErrorEvent = "ZookeeperConnectError"
+	QuorumLostEvent            = "QuorumLost"
+	QuorumRestoredEvent        = "QuorumRestored"
+	DynamicConfigDriftEvent    = "DynamicConfigDrift"
+	DynamicConfigRepairedEvent = "DynamicConfigRepaired"
+)
+
+// EventDedupWindow is how long an event of a cluster is not recorded again
+// with the same reason and message, so that the requeues every
+// ReconcileTime do not repeat it
+const EventDedupWindow = 10 * time.Minute
+
+// eventCache remembers when the events of the clusters were last recorded
+type eventCache struct {
+	mu       sync.Mutex
+	recorded map[string]time.Time
+}
+
+func eventKey(instance *zookeeperv1.ZookeeperCluster, reason, message string) string {
+	return string(instance.UID) + "/" + instance.Namespace + "/" + instance.Name + "/" + reason + "/" + message
+}
+
+// record returns true if the event was not recorded within the
+// EventDedupWindow, and remembers it was now
+func (c *eventCache) record(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.recorded == nil {
+		c.recorded = map[string]time.Time{}
+	}
+	for k, t := range c.recorded {
+		if now.Sub(t) >= EventDedupWindow {
+			delete(c.recorded, k)
+		}
+	}
+	if _, ok := c.recorded[key]; ok {
+		return false
+	}
+	c.recorded[key] = now
+	return true
+}
+
+// forget lets the events of the cluster with the given reasons be recorded
+// again right away, once the state they reported is over
+func (c *eventCache) forget(instance *zookeeperv1.ZookeeperCluster, reasons ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, reason := range reasons {
+		prefix := eventKey(instance, reason, "")
+		for k := range c.recorded {
+			if strings.HasPrefix(k, prefix) {
+				delete(c.recorded, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+const metricsNamespace = "zookeeper_operator"
+
+// reasons of the PVC deletions
+const (
+	pvcOrphaned      = "orphaned"
+	pvcClusterDelete = "cluster_deleted"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconcile steps of a ZookeeperCluster",
+		Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+	}, []string{"namespace", "cluster", "step"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of reconcile steps of a ZookeeperCluster which failed",
+	}, []string{"namespace", "cluster", "step"})
+
+	clusterDesiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_desired_replicas",
+		Help:      "Number of members of the spec of a ZookeeperCluster",
+	}, []string{"namespace", "cluster"})
+
+	clusterReadyReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_ready_replicas",
+		Help:      "Number of ready members of a ZookeeperCluster",
+	}, []string{"namespace", "cluster"})
+
+	clusterUpgrading = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_upgrading",
+		Help:      "1 while a ZookeeperCluster is being upgraded",
+	}, []string{"namespace", "cluster"})
+
+	clusterUpgradeFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_upgrade_failed",
+		Help:      "1 if the last upgrade of a ZookeeperCluster failed",
+	}, []string{"namespace", "cluster"})
+
+	clusterMetaRootCreated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_metaroot_created",
+		Help:      "1 once the metadata znode of a ZookeeperCluster is created",
+	}, []string{"namespace", "cluster"})
+
+	clusterQuorumHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_quorum_healthy",
+		Help:      "1 while the members of a ZookeeperCluster report a healthy quorum",
+	}, []string{"namespace", "cluster"})
+
+	pvcDeletions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pvc_deletions_total",
+		Help:      "Number of PVCs of a ZookeeperCluster deleted by the operator",
+	}, []string{"namespace", "cluster", "reason"})
+
+	zkConnectFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "zookeeper_connect_failures_total",
+		Help:      "Number of failed connections of the operator to a ZookeeperCluster",
+	}, []string{"namespace", "cluster"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileDuration,
+		reconcileErrors,
+		clusterDesiredReplicas,
+		clusterReadyReplicas,
+		clusterUpgrading,
+		clusterUpgradeFailed,
+		clusterMetaRootCreated,
+		clusterQuorumHealthy,
+		pvcDeletions,
+		zkConnectFailures,
+	)
+}
+
+// observeReconcileStep runs a step of the reconciliation of the cluster,
+// recording its duration and whether it failed
+func observeReconcileStep(instance *zookeeperv1.ZookeeperCluster, step string, fun reconcileFun) error {
+	start := time.Now()
+	err := fun(instance)
+	reconcileDuration.WithLabelValues(instance.Namespace, instance.Name, step).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(instance.Namespace, instance.Name, step).Inc()
+	}
+	return err
+}
+
+// updateClusterMetrics sets the gauges of the cluster from its spec and
+// status
+func updateClusterMetrics(instance *zookeeperv1.ZookeeperCluster) {
+	clusterDesiredReplicas.WithLabelValues(instance.Namespace, instance.Name).Set(float64(instance.Spec.Replicas))
+	clusterReadyReplicas.WithLabelValues(instance.Namespace, instance.Name).Set(float64(instance.Status.ReadyReplicas))
+	clusterUpgrading.WithLabelValues(instance.Namespace, instance.Name).Set(boolValue(instance.Status.IsClusterInUpgradingState()))
+	clusterUpgradeFailed.WithLabelValues(instance.Namespace, instance.Name).Set(boolValue(instance.Status.IsClusterInUpgradeFailedState()))
+	clusterMetaRootCreated.WithLabelValues(instance.Namespace, instance.Name).Set(boolValue(instance.Status.MetaRootCreated))
+	clusterQuorumHealthy.WithLabelValues(instance.Namespace, instance.Name).Set(boolValue(instance.Status.IsQuorumHealthy()))
+}
+
+// deleteClusterMetrics removes the metrics of a deleted cluster, so that it
+// is not reported anymore
+func deleteClusterMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "cluster": name}
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{
+		reconcileDuration,
+		reconcileErrors,
+		clusterDesiredReplicas,
+		clusterReadyReplicas,
+		clusterUpgrading,
+		clusterUpgradeFailed,
+		clusterMetaRootCreated,
+		clusterQuorumHealthy,
+		pvcDeletions,
+		zkConnectFailures,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
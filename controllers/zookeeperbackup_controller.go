@@ -0,0 +1,289 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+)
+
+var backupLog = logf.Log.WithName("controller_zookeeperbackup")
+
+var _ reconcile.Reconciler = &ZookeeperBackupReconciler{}
+
+// ZookeeperBackupReconciler reconciles a ZookeeperBackup object
+type ZookeeperBackupReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+func (r *ZookeeperBackupReconciler) Reconcile(_ context.Context, request ctrl.Request) (ctrl.Result, error) {
+	r.Log = backupLog.WithValues(
+		"Request.Namespace", request.Namespace,
+		"Request.Name", request.Name)
+	r.Log.Info("Reconciling ZookeeperBackup")
+
+	backup := &zookeeperv1.ZookeeperBackup{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, backup)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if backup.DeletionTimestamp != nil {
+		return reconcile.Result{}, r.reconcileBackupCleanup(backup)
+	}
+	// a backup is a one-off, its job is not run again once it finished
+	if backup.Status.Finished() {
+		return reconcile.Result{}, nil
+	}
+
+	job := &batchv1.Job{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: backup.JobName(), Namespace: backup.Namespace}, job)
+	if err != nil && errors.IsNotFound(err) {
+		return reconcile.Result{}, r.createBackupJob(backup)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, r.reconcileBackupJob(backup, job)
+}
+
+// createBackupJob creates the job of a new backup, the backup fails right
+// away if its cluster or target is not usable. The admin server only serves
+// snapshots to a user with all permissions on the root znode, so the cluster
+// needs spec.auth for the job to authenticate as its super user.
+func (r *ZookeeperBackupReconciler) createBackupJob(backup *zookeeperv1.ZookeeperBackup) error {
+	if err := backup.Spec.Target.Validate(); err != nil {
+		return r.setBackupFailed(backup, err.Error())
+	}
+	cluster := &zookeeperv1.ZookeeperCluster{}
+	name := types.NamespacedName{Name: backup.Spec.ZookeeperCluster, Namespace: backup.Namespace}
+	if err := r.Client.Get(context.TODO(), name, cluster); err != nil {
+		if errors.IsNotFound(err) {
+			return r.setBackupFailed(backup, fmt.Sprintf("ZookeeperCluster %s not found", name.Name))
+		}
+		return err
+	}
+	cluster.WithDefaults()
+	if cluster.Spec.Auth == nil {
+		return r.setBackupFailed(backup, fmt.Sprintf("ZookeeperCluster %s has no spec.auth, the admin server only serves snapshots to its super user", name.Name))
+	}
+
+	job := zk.MakeBackupJob(backup, cluster)
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return err
+	}
+	r.Log.Info("Creating a new backup job",
+		"Job.Namespace", job.Namespace,
+		"Job.Name", job.Name)
+	if err := r.Client.Create(context.TODO(), job); err != nil {
+		return err
+	}
+	now := metav1.Now()
+	backup.Status.Phase = zookeeperv1.BackupPending
+	backup.Status.StartTime = &now
+	return r.Client.Status().Update(context.TODO(), backup)
+}
+
+// reconcileBackupJob records the progress of the job in the status of the
+// backup, and the stored snapshot once it completed
+func (r *ZookeeperBackupReconciler) reconcileBackupJob(backup *zookeeperv1.ZookeeperBackup, job *batchv1.Job) error {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			message, err := jobTerminationMessage(r.Client, backup.Namespace, zk.BackupJobLabels(backup))
+			if err != nil {
+				return err
+			}
+			result, err := zk.ParseBackupResult(message)
+			if err != nil {
+				return r.setBackupFailed(backup, err.Error())
+			}
+			return r.setBackupSucceeded(backup, result)
+		case batchv1.JobFailed:
+			message, err := jobTerminationMessage(r.Client, backup.Namespace, zk.BackupJobLabels(backup))
+			if err != nil {
+				return err
+			}
+			if message == "" {
+				message = c.Message
+			}
+			return r.setBackupFailed(backup, message)
+		}
+	}
+	if job.Status.Active > 0 && backup.Status.Phase != zookeeperv1.BackupRunning {
+		backup.Status.Phase = zookeeperv1.BackupRunning
+		return r.Client.Status().Update(context.TODO(), backup)
+	}
+	return nil
+}
+
+// jobTerminationMessage returns the termination message of the last
+// container which terminated among the pods with the given labels, the
+// scripts of the image report their result or failure in it
+func jobTerminationMessage(c client.Client, namespace string, podLabels map[string]string) (string, error) {
+	pods := &corev1.PodList{}
+	listOps := &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.SelectorFromSet(podLabels),
+	}
+	if err := c.List(context.TODO(), pods, listOps); err != nil {
+		return "", err
+	}
+	var message string
+	var finishedAt metav1.Time
+	for _, p := range pods.Items {
+		for _, s := range p.Status.ContainerStatuses {
+			t := s.State.Terminated
+			if t != nil && !t.FinishedAt.Before(&finishedAt) {
+				message = t.Message
+				finishedAt = t.FinishedAt
+			}
+		}
+	}
+	return message, nil
+}
+
+// reconcileBackupCleanup deletes the snapshot of a backup being deleted
+// before releasing it, if the backup has the finalizer asking for it. A
+// failed cleanup keeps the backup, the snapshot is left behind if the
+// finalizer is removed by hand.
+func (r *ZookeeperBackupReconciler) reconcileBackupCleanup(backup *zookeeperv1.ZookeeperBackup) error {
+	if !utils.ContainsString(backup.Finalizers, utils.ZkBackupFinalizer) {
+		return nil
+	}
+	if backup.Status.Phase != zookeeperv1.BackupSucceeded {
+		return r.removeBackupFinalizer(backup)
+	}
+	job := &batchv1.Job{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: backup.CleanupJobName(), Namespace: backup.Namespace}, job)
+	if err != nil && errors.IsNotFound(err) {
+		return r.createBackupCleanupJob(backup)
+	} else if err != nil {
+		return err
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			r.Log.Info("Deleted the snapshot of the backup", "Location", backup.Status.Location)
+			return r.removeBackupFinalizer(backup)
+		case batchv1.JobFailed:
+			message, err := jobTerminationMessage(r.Client, backup.Namespace, zk.BackupCleanupJobLabels(backup))
+			if err != nil {
+				return err
+			}
+			if message == "" {
+				message = c.Message
+			}
+			message = fmt.Sprintf("Deleting the snapshot failed: %s", message)
+			if backup.Status.Message == message {
+				return nil
+			}
+			r.Log.Info("Deleting the snapshot of the backup failed", "Message", message)
+			backup.Status.Message = message
+			return r.Client.Status().Update(context.TODO(), backup)
+		}
+	}
+	return nil
+}
+
+func (r *ZookeeperBackupReconciler) createBackupCleanupJob(backup *zookeeperv1.ZookeeperBackup) error {
+	// the cluster only provides the image and pod settings of the job, the
+	// snapshot of a deleted cluster is deleted with the default ones
+	cluster := &zookeeperv1.ZookeeperCluster{}
+	name := types.NamespacedName{Name: backup.Spec.ZookeeperCluster, Namespace: backup.Namespace}
+	if err := r.Client.Get(context.TODO(), name, cluster); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cluster = &zookeeperv1.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+		}
+	}
+	cluster.WithDefaults()
+
+	job, err := zk.MakeBackupCleanupJob(backup, cluster)
+	if err != nil {
+		r.Log.Info("Not deleting the snapshot of the backup", "Error", err.Error())
+		return r.removeBackupFinalizer(backup)
+	}
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return err
+	}
+	r.Log.Info("Creating a new backup cleanup job",
+		"Job.Namespace", job.Namespace,
+		"Job.Name", job.Name)
+	return r.Client.Create(context.TODO(), job)
+}
+
+func (r *ZookeeperBackupReconciler) removeBackupFinalizer(backup *zookeeperv1.ZookeeperBackup) error {
+	backup.Finalizers = utils.RemoveString(backup.Finalizers, utils.ZkBackupFinalizer)
+	return r.Client.Update(context.TODO(), backup)
+}
+
+func (r *ZookeeperBackupReconciler) setBackupSucceeded(backup *zookeeperv1.ZookeeperBackup, result *zk.BackupResult) error {
+	r.Log.Info("Backup succeeded", "Location", result.Location, "Zxid", result.Zxid)
+	now := metav1.Now()
+	backup.Status.Phase = zookeeperv1.BackupSucceeded
+	backup.Status.Location = result.Location
+	backup.Status.Zxid = result.Zxid
+	backup.Status.Size = result.Size
+	backup.Status.Checksum = result.Checksum
+	backup.Status.CompletionTime = &now
+	backup.Status.Message = ""
+	return r.Client.Status().Update(context.TODO(), backup)
+}
+
+func (r *ZookeeperBackupReconciler) setBackupFailed(backup *zookeeperv1.ZookeeperBackup, message string) error {
+	r.Log.Info("Backup failed", "Message", message)
+	now := metav1.Now()
+	backup.Status.Phase = zookeeperv1.BackupFailed
+	backup.Status.CompletionTime = &now
+	backup.Status.Message = message
+	return r.Client.Status().Update(context.TODO(), backup)
+}
+
+func (r *ZookeeperBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&zookeeperv1.ZookeeperBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
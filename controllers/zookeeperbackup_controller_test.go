@@ -0,0 +1,310 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZookeeperBackup Controller", func() {
+	const (
+		Name      = "nightly"
+		Namespace = "default"
+	)
+
+	var (
+		s   = scheme.Scheme
+		r   *ZookeeperBackupReconciler
+		cl  client.Client
+		req reconcile.Request
+		z   *api.ZookeeperCluster
+		b   *api.ZookeeperBackup
+		err error
+	)
+
+	BeforeEach(func() {
+		req = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+		}
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: Namespace,
+			},
+			Spec: api.ZookeeperClusterSpec{
+				Auth: &api.Auth{SecretName: "example-users"},
+			},
+		}
+		b = &api.ZookeeperBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+			Spec: api.ZookeeperBackupSpec{
+				ZookeeperCluster: "example",
+				Target: api.BackupTarget{
+					PVC: &api.PVCTarget{ClaimName: "backups"},
+				},
+			},
+		}
+		s.AddKnownTypes(api.GroupVersion, z, b, &api.ZookeeperBackupList{})
+	})
+
+	reconcileBackup := func(objs ...client.Object) {
+		cl = fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(b).Build()
+		r = &ZookeeperBackupReconciler{Client: cl, Scheme: s}
+		_, err = r.Reconcile(context.TODO(), req)
+	}
+
+	foundBackup := func() *api.ZookeeperBackup {
+		found := &api.ZookeeperBackup{}
+		Ω(cl.Get(context.TODO(), req.NamespacedName, found)).To(Succeed())
+		return found
+	}
+
+	finishedJob := func(condition batchv1.JobConditionType) *batchv1.Job {
+		job := zk.MakeBackupJob(b, z)
+		job.Status.Conditions = []batchv1.JobCondition{
+			{Type: condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+		}
+		return job
+	}
+
+	backupPod := func(message string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Name + "-backup-abcde",
+				Namespace: Namespace,
+				Labels:    zk.BackupJobLabels(b),
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "backup",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{Message: message},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	Context("A new backup", func() {
+		BeforeEach(func() {
+			reconcileBackup(z, b)
+		})
+
+		It("should not error", func() {
+			Ω(err).To(BeNil())
+		})
+
+		It("should create the backup job", func() {
+			job := &batchv1.Job{}
+			err = cl.Get(context.TODO(), types.NamespacedName{Name: Name + "-backup", Namespace: Namespace}, job)
+			Ω(err).To(BeNil())
+			Ω(job.OwnerReferences).To(HaveLen(1))
+			Ω(job.OwnerReferences[0].Name).To(Equal(Name))
+		})
+
+		It("should be pending", func() {
+			found := foundBackup()
+			Ω(found.Status.Phase).To(Equal(api.BackupPending))
+			Ω(found.Status.StartTime).NotTo(BeNil())
+		})
+	})
+
+	Context("A backup of a missing cluster", func() {
+		BeforeEach(func() {
+			reconcileBackup(b)
+		})
+
+		It("should fail", func() {
+			Ω(err).To(BeNil())
+			found := foundBackup()
+			Ω(found.Status.Phase).To(Equal(api.BackupFailed))
+			Ω(found.Status.Message).To(ContainSubstring("not found"))
+		})
+	})
+
+	Context("A backup of a cluster without auth", func() {
+		BeforeEach(func() {
+			z.Spec.Auth = nil
+			reconcileBackup(z, b)
+		})
+
+		It("should fail without a job", func() {
+			Ω(err).To(BeNil())
+			found := foundBackup()
+			Ω(found.Status.Phase).To(Equal(api.BackupFailed))
+			Ω(found.Status.Message).To(ContainSubstring("has no spec.auth"))
+			jobs := &batchv1.JobList{}
+			Ω(cl.List(context.TODO(), jobs)).To(Succeed())
+			Ω(jobs.Items).To(BeEmpty())
+		})
+	})
+
+	Context("A backup without a target", func() {
+		BeforeEach(func() {
+			b.Spec.Target = api.BackupTarget{}
+			reconcileBackup(z, b)
+		})
+
+		It("should fail without a job", func() {
+			Ω(err).To(BeNil())
+			Ω(foundBackup().Status.Phase).To(Equal(api.BackupFailed))
+			jobs := &batchv1.JobList{}
+			Ω(cl.List(context.TODO(), jobs)).To(Succeed())
+			Ω(jobs.Items).To(BeEmpty())
+		})
+	})
+
+	Context("A running backup", func() {
+		BeforeEach(func() {
+			z.WithDefaults()
+			job := zk.MakeBackupJob(b, z)
+			job.Status.Active = 1
+			reconcileBackup(z, b, job)
+		})
+
+		It("should be running", func() {
+			Ω(err).To(BeNil())
+			Ω(foundBackup().Status.Phase).To(Equal(api.BackupRunning))
+		})
+	})
+
+	Context("A completed backup", func() {
+		BeforeEach(func() {
+			z.WithDefaults()
+			pod := backupPod(`{"zxid":"0x100000002","size":42,"checksum":"abc","location":"pvc://backups/nightly/snapshot.100000002"}`)
+			reconcileBackup(z, b, finishedJob(batchv1.JobComplete), pod)
+		})
+
+		It("should record the snapshot", func() {
+			Ω(err).To(BeNil())
+			found := foundBackup()
+			Ω(found.Status.Phase).To(Equal(api.BackupSucceeded))
+			Ω(found.Status.Zxid).To(Equal("0x100000002"))
+			Ω(found.Status.Size).To(BeEquivalentTo(42))
+			Ω(found.Status.Checksum).To(Equal("abc"))
+			Ω(found.Status.Location).To(Equal("pvc://backups/nightly/snapshot.100000002"))
+			Ω(found.Status.CompletionTime).NotTo(BeNil())
+		})
+	})
+
+	Context("A failed backup", func() {
+		BeforeEach(func() {
+			z.WithDefaults()
+			reconcileBackup(z, b, finishedJob(batchv1.JobFailed), backupPod("No leader found"))
+		})
+
+		It("should record the message of the script", func() {
+			Ω(err).To(BeNil())
+			found := foundBackup()
+			Ω(found.Status.Phase).To(Equal(api.BackupFailed))
+			Ω(found.Status.Message).To(Equal("No leader found"))
+		})
+	})
+
+	Context("A finished backup", func() {
+		BeforeEach(func() {
+			b.Status.Phase = api.BackupSucceeded
+			reconcileBackup(z, b)
+		})
+
+		It("should not create a job again", func() {
+			Ω(err).To(BeNil())
+			jobs := &batchv1.JobList{}
+			Ω(cl.List(context.TODO(), jobs)).To(Succeed())
+			Ω(jobs.Items).To(BeEmpty())
+		})
+	})
+
+	Context("A deleted backup", func() {
+		var cleanupJob *batchv1.Job
+
+		BeforeEach(func() {
+			now := metav1.Now()
+			b.DeletionTimestamp = &now
+			b.Finalizers = []string{utils.ZkBackupFinalizer}
+			b.Status.Phase = api.BackupSucceeded
+			b.Status.Location = "pvc://backups/nightly/snapshot.100000002"
+			z.WithDefaults()
+			cleanupJob, _ = zk.MakeBackupCleanupJob(b, z)
+		})
+
+		foundCleanupJob := func() error {
+			return cl.Get(context.TODO(), types.NamespacedName{Name: Name + "-cleanup", Namespace: Namespace}, &batchv1.Job{})
+		}
+
+		It("should create the cleanup job", func() {
+			reconcileBackup(z, b)
+			Ω(err).To(BeNil())
+			Ω(foundCleanupJob()).To(Succeed())
+			Ω(foundBackup().Finalizers).To(ContainElement(utils.ZkBackupFinalizer))
+		})
+
+		It("should clean up after its cluster was deleted", func() {
+			reconcileBackup(b)
+			Ω(err).To(BeNil())
+			Ω(foundCleanupJob()).To(Succeed())
+		})
+
+		It("should be released once its snapshot is deleted", func() {
+			cleanupJob.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			}
+			reconcileBackup(z, b, cleanupJob)
+			Ω(err).To(BeNil())
+			found := &api.ZookeeperBackup{}
+			Ω(cl.Get(context.TODO(), req.NamespacedName, found)).NotTo(Succeed())
+		})
+
+		It("should be kept if deleting its snapshot failed", func() {
+			cleanupJob.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+			}
+			reconcileBackup(z, b, cleanupJob)
+			Ω(err).To(BeNil())
+			found := foundBackup()
+			Ω(found.Finalizers).To(ContainElement(utils.ZkBackupFinalizer))
+			Ω(found.Status.Message).To(ContainSubstring("Deleting the snapshot failed"))
+		})
+
+		It("should be released right away if it stored no snapshot", func() {
+			b.Status.Phase = api.BackupFailed
+			reconcileBackup(z, b)
+			Ω(err).To(BeNil())
+			Ω(foundCleanupJob()).NotTo(Succeed())
+			found := &api.ZookeeperBackup{}
+			Ω(cl.Get(context.TODO(), req.NamespacedName, found)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,279 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+)
+
+var backupScheduleLog = logf.Log.WithName("controller_zookeeperbackupschedule")
+
+var _ reconcile.Reconciler = &ZookeeperBackupScheduleReconciler{}
+
+// ZookeeperBackupScheduleReconciler reconciles a ZookeeperBackupSchedule object
+type ZookeeperBackupScheduleReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// now returns the current time, the tests set it
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperbackupschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperbackupschedules/status,verbs=get;update;patch
+
+func (r *ZookeeperBackupScheduleReconciler) Reconcile(_ context.Context, request ctrl.Request) (ctrl.Result, error) {
+	r.Log = backupScheduleLog.WithValues(
+		"Request.Namespace", request.Namespace,
+		"Request.Name", request.Name)
+	r.Log.Info("Reconciling ZookeeperBackupSchedule")
+
+	schedule := &zookeeperv1.ZookeeperBackupSchedule{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, schedule)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+
+	backups := &zookeeperv1.ZookeeperBackupList{}
+	listOps := &client.ListOptions{
+		Namespace:     schedule.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{"zookeeper-backup-schedule": schedule.GetName()}),
+	}
+	if err = r.Client.List(context.TODO(), backups, listOps); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	status := schedule.Status.DeepCopy()
+	recordBackupRuns(schedule, backups.Items)
+	if err = r.pruneBackups(schedule, backups.Items, now); err != nil {
+		return reconcile.Result{}, err
+	}
+	result, err := r.scheduleBackup(schedule, backups.Items, now)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !reflect.DeepEqual(status, &schedule.Status) {
+		if err = r.Client.Status().Update(context.TODO(), schedule); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// scheduleBackup creates the backup of the last run which is due, if any,
+// and returns when to reconcile again for the next one. Runs missed while
+// the operator was down are not caught up on but for the last one, and a
+// run is skipped while the backup of a former one is still in progress.
+func (r *ZookeeperBackupScheduleReconciler) scheduleBackup(schedule *zookeeperv1.ZookeeperBackupSchedule, backups []zookeeperv1.ZookeeperBackup, now time.Time) (ctrl.Result, error) {
+	cron, err := utils.ParseCron(schedule.Spec.Schedule)
+	if err != nil {
+		schedule.Status.Message = err.Error()
+		schedule.Status.NextScheduleTime = nil
+		return reconcile.Result{}, nil
+	}
+	if err = schedule.Spec.Target.Validate(); err != nil {
+		schedule.Status.Message = err.Error()
+		schedule.Status.NextScheduleTime = nil
+		return reconcile.Result{}, nil
+	}
+
+	since := schedule.CreationTimestamp.Time
+	if last := schedule.Status.LastScheduleTime; last != nil {
+		since = last.Time
+	}
+	var due time.Time
+	for t := cron.Next(since); !t.IsZero() && !t.After(now); t = cron.Next(t) {
+		due = t
+	}
+	if !due.IsZero() {
+		if active := activeBackup(backups); active != "" {
+			r.Log.Info("Skipping a backup, the former one is still in progress",
+				"Due", due, "Backup", active)
+		} else if err = r.createScheduledBackup(schedule, due); err != nil {
+			return reconcile.Result{}, err
+		}
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: due}
+	}
+
+	next := cron.Next(now)
+	if next.IsZero() {
+		schedule.Status.Message = fmt.Sprintf("Schedule %q never runs", schedule.Spec.Schedule)
+		schedule.Status.NextScheduleTime = nil
+		return reconcile.Result{}, nil
+	}
+	schedule.Status.Message = ""
+	schedule.Status.NextScheduleTime = &metav1.Time{Time: next}
+	return reconcile.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+func (r *ZookeeperBackupScheduleReconciler) createScheduledBackup(schedule *zookeeperv1.ZookeeperBackupSchedule, due time.Time) error {
+	backup := zk.MakeScheduledBackup(schedule, due)
+	// the snapshot is deleted together with the backup, once pruned
+	backup.Finalizers = append(backup.Finalizers, utils.ZkBackupFinalizer)
+	if err := controllerutil.SetControllerReference(schedule, backup, r.Scheme); err != nil {
+		return err
+	}
+	r.Log.Info("Creating a new scheduled backup",
+		"Backup.Namespace", backup.Namespace,
+		"Backup.Name", backup.Name)
+	err := r.Client.Create(context.TODO(), backup)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// activeBackup returns the name of a backup which did not finish yet
+func activeBackup(backups []zookeeperv1.ZookeeperBackup) string {
+	for _, b := range backups {
+		if b.DeletionTimestamp == nil && !b.Status.Finished() {
+			return b.Name
+		}
+	}
+	return ""
+}
+
+// recordBackupRuns records the last succeeded and failed backups in the
+// status of the schedule. They stay recorded once pruned.
+func recordBackupRuns(schedule *zookeeperv1.ZookeeperBackupSchedule, backups []zookeeperv1.ZookeeperBackup) {
+	for _, b := range backups {
+		completed := b.Status.CompletionTime
+		if completed == nil {
+			continue
+		}
+		switch b.Status.Phase {
+		case zookeeperv1.BackupSucceeded:
+			last := schedule.Status.LastSuccessfulRun
+			if last == nil || last.CompletionTime == nil || completed.After(last.CompletionTime.Time) {
+				schedule.Status.LastSuccessfulRun = &zookeeperv1.BackupRun{
+					Name:           b.Name,
+					CompletionTime: completed,
+					Zxid:           b.Status.Zxid,
+				}
+			}
+		case zookeeperv1.BackupFailed:
+			last := schedule.Status.LastFailedRun
+			if last == nil || last.CompletionTime == nil || completed.After(last.CompletionTime.Time) {
+				schedule.Status.LastFailedRun = &zookeeperv1.BackupRun{
+					Name:           b.Name,
+					CompletionTime: completed,
+					Message:        b.Status.Message,
+				}
+			}
+		}
+	}
+}
+
+// pruneBackups deletes the finished backups the retention of the schedule
+// does not keep, their finalizer deletes their snapshot
+func (r *ZookeeperBackupScheduleReconciler) pruneBackups(schedule *zookeeperv1.ZookeeperBackupSchedule, backups []zookeeperv1.ZookeeperBackup, now time.Time) error {
+	retention := schedule.Spec.Retention
+	if !retention.IsSet() {
+		return nil
+	}
+	for _, b := range expiredBackups(retention, backups, now) {
+		r.Log.Info("Deleting an expired backup",
+			"Backup.Namespace", b.Namespace,
+			"Backup.Name", b.Name)
+		if err := r.Client.Delete(context.TODO(), b); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredBackups returns the backups a retention does not keep. Failed
+// backups expire once a later backup succeeded, backups in progress never
+// expire.
+func expiredBackups(retention zookeeperv1.BackupRetention, backups []zookeeperv1.ZookeeperBackup, now time.Time) []*zookeeperv1.ZookeeperBackup {
+	var succeeded []*zookeeperv1.ZookeeperBackup
+	for i := range backups {
+		b := &backups[i]
+		if b.DeletionTimestamp == nil && b.Status.Phase == zookeeperv1.BackupSucceeded {
+			succeeded = append(succeeded, b)
+		}
+	}
+	// newest first
+	sort.Slice(succeeded, func(i, j int) bool {
+		ti, tj := succeeded[i].CreationTimestamp, succeeded[j].CreationTimestamp
+		if ti.Equal(&tj) {
+			return succeeded[i].Name > succeeded[j].Name
+		}
+		return tj.Before(&ti)
+	})
+
+	kept := map[string]bool{}
+	for i, b := range succeeded {
+		if int32(i) < retention.KeepLast {
+			kept[b.Name] = true
+		}
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	days := map[time.Time]bool{}
+	for _, b := range succeeded {
+		day := b.CreationTimestamp.UTC().Truncate(24 * time.Hour)
+		if days[day] || today.Sub(day) >= time.Duration(retention.KeepDailyDays)*24*time.Hour {
+			continue
+		}
+		days[day] = true
+		kept[b.Name] = true
+	}
+
+	var expired []*zookeeperv1.ZookeeperBackup
+	for _, b := range succeeded {
+		if !kept[b.Name] {
+			expired = append(expired, b)
+		}
+	}
+	if len(succeeded) > 0 {
+		latest := succeeded[0].CreationTimestamp
+		for i := range backups {
+			b := &backups[i]
+			if b.DeletionTimestamp == nil && b.Status.Phase == zookeeperv1.BackupFailed && b.CreationTimestamp.Before(&latest) {
+				expired = append(expired, b)
+			}
+		}
+	}
+	return expired
+}
+
+func (r *ZookeeperBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&zookeeperv1.ZookeeperBackupSchedule{}).
+		Owns(&zookeeperv1.ZookeeperBackup{}).
+		Complete(r)
+}
@@ -0,0 +1,283 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZookeeperBackupSchedule Controller", func() {
+	const (
+		Name      = "nightly"
+		Namespace = "default"
+	)
+
+	var (
+		s      = scheme.Scheme
+		r      *ZookeeperBackupScheduleReconciler
+		cl     client.Client
+		req    reconcile.Request
+		sched  *api.ZookeeperBackupSchedule
+		now    time.Time
+		res    reconcile.Result
+		err    error
+		create = time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		req = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+		}
+		sched = &api.ZookeeperBackupSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              Name,
+				Namespace:         Namespace,
+				CreationTimestamp: metav1.NewTime(create),
+			},
+			Spec: api.ZookeeperBackupScheduleSpec{
+				Schedule:         "0 2 * * *",
+				ZookeeperCluster: "example",
+				Target: api.BackupTarget{
+					PVC: &api.PVCTarget{ClaimName: "backups"},
+				},
+			},
+		}
+		now = create
+		s.AddKnownTypes(api.GroupVersion, sched, &api.ZookeeperBackupScheduleList{},
+			&api.ZookeeperBackup{}, &api.ZookeeperBackupList{})
+	})
+
+	reconcileSchedule := func(objs ...client.Object) {
+		cl = fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(sched).Build()
+		r = &ZookeeperBackupScheduleReconciler{Client: cl, Scheme: s, now: func() time.Time { return now }}
+		res, err = r.Reconcile(context.TODO(), req)
+	}
+
+	foundSchedule := func() *api.ZookeeperBackupSchedule {
+		found := &api.ZookeeperBackupSchedule{}
+		Ω(cl.Get(context.TODO(), req.NamespacedName, found)).To(Succeed())
+		return found
+	}
+
+	backupNames := func() []string {
+		backups := &api.ZookeeperBackupList{}
+		Ω(cl.List(context.TODO(), backups)).To(Succeed())
+		var names []string
+		for _, b := range backups.Items {
+			if b.DeletionTimestamp == nil {
+				names = append(names, b.Name)
+			}
+		}
+		return names
+	}
+
+	// scheduledBackup returns a backup of the schedule due at the given
+	// time which finished in the given phase
+	scheduledBackup := func(due time.Time, phase api.BackupPhase) *api.ZookeeperBackup {
+		b := zk.MakeScheduledBackup(sched, due)
+		b.Finalizers = []string{utils.ZkBackupFinalizer}
+		b.CreationTimestamp = metav1.NewTime(due)
+		b.Status.Phase = phase
+		if b.Status.Finished() {
+			completed := metav1.NewTime(due.Add(time.Minute))
+			b.Status.CompletionTime = &completed
+		}
+		return b
+	}
+
+	Context("A new schedule", func() {
+		BeforeEach(func() {
+			reconcileSchedule(sched)
+		})
+
+		It("should not back up before the first run", func() {
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(BeEmpty())
+		})
+
+		It("should requeue for the next run", func() {
+			next := time.Date(2024, 1, 11, 2, 0, 0, 0, time.UTC)
+			Ω(res.RequeueAfter).To(Equal(next.Sub(now)))
+			Ω(foundSchedule().Status.NextScheduleTime.Time).To(BeTemporally("==", next))
+		})
+	})
+
+	Context("A schedule with a run due", func() {
+		BeforeEach(func() {
+			now = time.Date(2024, 1, 11, 2, 0, 30, 0, time.UTC)
+			reconcileSchedule(sched)
+		})
+
+		It("should create the backup of the run", func() {
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240111-0200"))
+			b := &api.ZookeeperBackup{}
+			Ω(cl.Get(context.TODO(), types.NamespacedName{Name: "nightly-20240111-0200", Namespace: Namespace}, b)).To(Succeed())
+			Ω(b.OwnerReferences).To(HaveLen(1))
+			Ω(b.OwnerReferences[0].Name).To(Equal(Name))
+			Ω(b.Finalizers).To(ContainElement(utils.ZkBackupFinalizer))
+		})
+
+		It("should record the run", func() {
+			found := foundSchedule()
+			Ω(found.Status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2024, 1, 11, 2, 0, 0, 0, time.UTC)))
+			Ω(found.Status.NextScheduleTime.Time).To(BeTemporally("==", time.Date(2024, 1, 12, 2, 0, 0, 0, time.UTC)))
+		})
+	})
+
+	Context("A schedule which missed runs", func() {
+		BeforeEach(func() {
+			now = time.Date(2024, 1, 14, 12, 0, 0, 0, time.UTC)
+			reconcileSchedule(sched)
+		})
+
+		It("should only back up for the last one", func() {
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240114-0200"))
+		})
+	})
+
+	Context("A schedule with a backup in progress", func() {
+		BeforeEach(func() {
+			last := metav1.NewTime(time.Date(2024, 1, 11, 2, 0, 0, 0, time.UTC))
+			sched.Status.LastScheduleTime = &last
+			now = time.Date(2024, 1, 12, 2, 0, 0, 0, time.UTC)
+			reconcileSchedule(sched, scheduledBackup(last.Time, api.BackupRunning))
+		})
+
+		It("should skip the run", func() {
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240111-0200"))
+			Ω(foundSchedule().Status.LastScheduleTime.Time).To(BeTemporally("==", now))
+		})
+	})
+
+	Context("A schedule with finished backups", func() {
+		BeforeEach(func() {
+			last := metav1.NewTime(time.Date(2024, 1, 12, 2, 0, 0, 0, time.UTC))
+			sched.Status.LastScheduleTime = &last
+			now = time.Date(2024, 1, 12, 3, 0, 0, 0, time.UTC)
+			reconcileSchedule(sched,
+				scheduledBackup(time.Date(2024, 1, 11, 2, 0, 0, 0, time.UTC), api.BackupSucceeded),
+				scheduledBackup(last.Time, api.BackupFailed))
+		})
+
+		It("should record the last successful and failed runs", func() {
+			Ω(err).To(BeNil())
+			found := foundSchedule()
+			Ω(found.Status.LastSuccessfulRun.Name).To(Equal("nightly-20240111-0200"))
+			Ω(found.Status.LastFailedRun.Name).To(Equal("nightly-20240112-0200"))
+		})
+
+		It("should keep all backups without a retention", func() {
+			Ω(backupNames()).To(HaveLen(2))
+		})
+	})
+
+	Context("A schedule with a retention", func() {
+		day := func(d, hour int) time.Time {
+			return time.Date(2024, 1, d, hour, 0, 0, 0, time.UTC)
+		}
+
+		reconcileRetention := func(retention api.BackupRetention, objs ...client.Object) {
+			sched.Spec.Schedule = "0 */12 * * *"
+			sched.Spec.Retention = retention
+			last := metav1.NewTime(day(20, 12))
+			sched.Status.LastScheduleTime = &last
+			now = day(20, 13)
+			reconcileSchedule(append([]client.Object{sched}, objs...)...)
+		}
+
+		backups := func() []client.Object {
+			return []client.Object{
+				scheduledBackup(day(17, 0), api.BackupSucceeded),
+				scheduledBackup(day(17, 12), api.BackupSucceeded),
+				scheduledBackup(day(18, 0), api.BackupFailed),
+				scheduledBackup(day(18, 12), api.BackupSucceeded),
+				scheduledBackup(day(19, 0), api.BackupSucceeded),
+				scheduledBackup(day(19, 12), api.BackupSucceeded),
+				scheduledBackup(day(20, 0), api.BackupSucceeded),
+				scheduledBackup(day(20, 12), api.BackupFailed),
+			}
+		}
+
+		It("should keep the last backups", func() {
+			reconcileRetention(api.BackupRetention{KeepLast: 2}, backups()...)
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240119-1200", "nightly-20240120-0000", "nightly-20240120-1200"))
+		})
+
+		It("should keep the last backup of the last days", func() {
+			reconcileRetention(api.BackupRetention{KeepDailyDays: 3}, backups()...)
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240118-1200", "nightly-20240119-1200", "nightly-20240120-0000", "nightly-20240120-1200"))
+		})
+
+		It("should keep the backups either rule keeps", func() {
+			reconcileRetention(api.BackupRetention{KeepLast: 3, KeepDailyDays: 2}, backups()...)
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240119-0000", "nightly-20240119-1200", "nightly-20240120-0000", "nightly-20240120-1200"))
+		})
+
+		It("should not delete a backup in progress", func() {
+			reconcileRetention(api.BackupRetention{KeepLast: 1},
+				scheduledBackup(day(19, 0), api.BackupRunning),
+				scheduledBackup(day(19, 12), api.BackupSucceeded),
+				scheduledBackup(day(20, 0), api.BackupSucceeded))
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(ConsistOf("nightly-20240119-0000", "nightly-20240120-0000"))
+		})
+
+		It("should delete the snapshots of the expired backups", func() {
+			reconcileRetention(api.BackupRetention{KeepLast: 1},
+				scheduledBackup(day(19, 12), api.BackupSucceeded),
+				scheduledBackup(day(20, 0), api.BackupSucceeded))
+			Ω(err).To(BeNil())
+			b := &api.ZookeeperBackup{}
+			Ω(cl.Get(context.TODO(), types.NamespacedName{Name: "nightly-20240119-1200", Namespace: Namespace}, b)).To(Succeed())
+			Ω(b.DeletionTimestamp).NotTo(BeNil())
+			Ω(b.Finalizers).To(ContainElement(utils.ZkBackupFinalizer))
+		})
+	})
+
+	Context("A schedule with an invalid cron expression", func() {
+		BeforeEach(func() {
+			sched.Spec.Schedule = "every night"
+			now = time.Date(2024, 1, 12, 3, 0, 0, 0, time.UTC)
+			reconcileSchedule(sched)
+		})
+
+		It("should not back up", func() {
+			Ω(err).To(BeNil())
+			Ω(backupNames()).To(BeEmpty())
+			Ω(res.RequeueAfter).To(BeZero())
+			Ω(foundSchedule().Status.Message).To(ContainSubstring("every night"))
+		})
+	})
+})
@@ -11,12 +11,19 @@ package controllers
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/pravega/zookeeper-operator/pkg/controller/config"
@@ -26,10 +33,16 @@ import (
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -39,7 +52,7 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	zookeeperv1beta1 "github.com/pravega/zookeeper-operator/api/v1beta1"
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
 )
 
 // ReconcileTime is the delay between reconciliations
@@ -51,13 +64,27 @@ var _ reconcile.Reconciler = &ZookeeperClusterReconciler{}
 
 // ZookeeperClusterReconciler reconciles a ZookeeperCluster object
 type ZookeeperClusterReconciler struct {
-	Client   client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	ZkClient zk.ZookeeperClient
+	Client      client.Client
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	ZkClient    zk.ZookeeperClient
+	AdminClient zk.AdminClient
+	Recorder    record.EventRecorder
+	// events keeps the events recorded by the reconciler from being
+	// repeated by the next reconciles
+	events eventCache
 }
 
-type reconcileFun func(cluster *zookeeperv1beta1.ZookeeperCluster) error
+type reconcileFun func(cluster *zookeeperv1.ZookeeperCluster) error
+
+// reconcileStep is a reconcileFun with the subsystem pausing it, the steps
+// without one are only skipped when the whole cluster is paused. The name
+// labels the metrics of the step.
+type reconcileStep struct {
+	subsystem zookeeperv1.Subsystem
+	name      string
+	fun       reconcileFun
+}
 
 // +kubebuilder:rbac:groups=zookeeper.pravega.io.zookeeper.pravega.io,resources=zookeeperclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=zookeeper.pravega.io.zookeeper.pravega.io,resources=zookeeperclusters/status,verbs=get;update;patch
@@ -69,7 +96,7 @@ func (r *ZookeeperClusterReconciler) Reconcile(_ context.Context, request ctrl.R
 	r.Log.Info("Reconciling ZookeeperCluster")
 
 	// Fetch the ZookeeperCluster instance
-	instance := &zookeeperv1beta1.ZookeeperCluster{}
+	instance := &zookeeperv1.ZookeeperCluster{}
 	err := r.Client.Get(context.TODO(), request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -77,49 +104,100 @@ func (r *ZookeeperClusterReconciler) Reconcile(_ context.Context, request ctrl.R
 			// request. Owned objects are automatically garbage collected. For
 			// additional cleanup logic use finalizers.
 			// Return and don't requeue
+			deleteClusterMetrics(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
-	changed := instance.WithDefaults()
-	if instance.GetTriggerRollingRestart() {
-		r.Log.Info("Restarting zookeeper cluster")
-		annotationkey, annotationvalue := getRollingRestartAnnotation()
-		if instance.Spec.Pod.Annotations == nil {
-			instance.Spec.Pod.Annotations = make(map[string]string)
-		}
-		instance.Spec.Pod.Annotations[annotationkey] = annotationvalue
-		instance.SetTriggerRollingRestart(false)
-		changed = true
+	// Defaults are applied by the mutating webhook when the cluster is stored.
+	// Clusters created before the webhook was installed only get them in
+	// memory, the operator never writes them back to the spec.
+	if instance.WithDefaults() {
+		r.Log.Info("Applying default settings to zookeeper-cluster in memory")
 	}
-	if changed {
-		r.Log.Info("Setting default settings for zookeeper-cluster")
-		if err := r.Client.Update(context.TODO(), instance); err != nil {
-			return reconcile.Result{}, err
-		}
-		return reconcile.Result{Requeue: true}, nil
+	defer updateClusterMetrics(instance)
+	if err = observeReconcileStep(instance, "reconcilePause", r.reconcilePause); err != nil {
+		return reconcile.Result{}, err
 	}
-	for _, fun := range []reconcileFun{
-		r.reconcileFinalizers,
-		r.reconcileConfigMap,
-		r.reconcileStatefulSet,
-		r.reconcileClientService,
-		r.reconcileHeadlessService,
-		r.reconcileAdminServerService,
-		r.reconcilePodDisruptionBudget,
-		r.reconcileClusterStatus,
+	for _, step := range []reconcileStep{
+		{"", "reconcileFinalizers", r.reconcileFinalizers},
+		{"", "reconcileQuorumPhases", r.reconcileQuorumPhases},
+		{zookeeperv1.SubsystemConfigMap, "reconcileAuthSecret", r.reconcileAuthSecret},
+		{zookeeperv1.SubsystemConfigMap, "reconcileConfigMap", r.reconcileConfigMap},
+		{zookeeperv1.SubsystemConfigMap, "reconcileTLSSecrets", r.reconcileTLSSecrets},
+		{zookeeperv1.SubsystemStatefulSet, "reconcileRestore", r.reconcileRestore},
+		{zookeeperv1.SubsystemVolumeExpansion, "reconcileVolumeExpansion", r.reconcileVolumeExpansion},
+		{zookeeperv1.SubsystemStatefulSet, "reconcileStatefulSet", r.reconcileStatefulSet},
+		{zookeeperv1.SubsystemStatefulSet, "reconcileObservers", r.reconcileObservers},
+		{zookeeperv1.SubsystemServices, "reconcileClientService", r.reconcileClientService},
+		{zookeeperv1.SubsystemServices, "reconcileHeadlessService", r.reconcileHeadlessService},
+		{zookeeperv1.SubsystemServices, "reconcileAdminServerService", r.reconcileAdminServerService},
+		{zookeeperv1.SubsystemPodDisruptionBudget, "reconcilePodDisruptionBudget", r.reconcilePodDisruptionBudget},
+		{zookeeperv1.SubsystemMonitoring, "reconcileMonitoring", r.reconcileMonitoring},
 	} {
-		if err = fun(instance); err != nil {
+		if instance.IsPaused() || (step.subsystem != "" && instance.IsSubsystemPaused(step.subsystem)) {
+			continue
+		}
+		if err = observeReconcileStep(instance, step.name, step.fun); err != nil {
 			return reconcile.Result{}, err
 		}
 	}
+	// the status is kept up to date while paused
+	if err = observeReconcileStep(instance, "reconcileClusterStatus", r.reconcileClusterStatus); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err = observeReconcileStep(instance, "reconcileHealth", r.reconcileHealth); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err = observeReconcileStep(instance, "reconcileDynamicConfig", r.reconcileDynamicConfig); err != nil {
+		return reconcile.Result{}, err
+	}
 	// Recreate any missing resources every 'ReconcileTime'
 	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
 }
 
-func getRollingRestartAnnotation() (string, string) {
-	return "restartTime", time.Now().Format(time.RFC850)
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// reconcilePause sets the paused condition, and records an event when the
+// cluster or some of its subsystems are paused or resumed. The status is
+// updated right away, since reconcileClusterStatus leaves it alone during
+// an upgrade.
+func (r *ZookeeperClusterReconciler) reconcilePause(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	message := ""
+	if instance.IsPaused() {
+		message = "The operator does not change the cluster"
+	} else if paused := instance.PausedSubsystems(); len(paused) > 0 {
+		names := make([]string, len(paused))
+		for i, s := range paused {
+			names[i] = string(s)
+		}
+		message = "The operator does not change " + strings.Join(names, ", ")
+	}
+	_, condition := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionPaused)
+	switch {
+	case message != "" && (condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message):
+		r.Log.Info("Pausing the cluster", "Message", message)
+		instance.Status.SetPausedConditionTrue(message)
+		r.events.forget(instance, zookeeperv1.ResumedReason)
+		r.recordEvent(instance, corev1.EventTypeWarning, zookeeperv1.PausedReason, message)
+	case message == "" && condition != nil && condition.Status == metav1.ConditionTrue:
+		r.Log.Info("Resuming the cluster")
+		instance.Status.SetPausedConditionFalse()
+		r.events.forget(instance, zookeeperv1.PausedReason)
+		r.recordEvent(instance, corev1.EventTypeNormal, zookeeperv1.ResumedReason, "The operator reconciles the cluster again")
+	default:
+		return nil
+	}
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+// recordEvent records an event of the cluster, if the reconciler has a
+// recorder and the same event was not recorded within the EventDedupWindow
+func (r *ZookeeperClusterReconciler) recordEvent(instance *zookeeperv1.ZookeeperCluster, eventType, reason, message string) {
+	if r.Recorder != nil && r.events.record(eventKey(instance, reason, message), time.Now()) {
+		r.Recorder.Event(instance, eventType, reason, message)
+	}
 }
 
 // compareResourceVersion compare resoure versions for the supplied ZookeeperCluster and StatefulSet
@@ -128,7 +206,7 @@ func getRollingRestartAnnotation() (string, string) {
 // 0 if versions are equal
 // -1 if ZookeeperCluster version is less than StatefulSet version
 // 1 if ZookeeperCluster version is greater than StatefulSet version
-func compareResourceVersion(zk *zookeeperv1beta1.ZookeeperCluster, sts *appsv1.StatefulSet) int {
+func compareResourceVersion(zk *zookeeperv1.ZookeeperCluster, sts *appsv1.StatefulSet) int {
 
 	zkResourceVersion, zkErr := strconv.Atoi(zk.ResourceVersion)
 	stsVersion, stsVersionFound := sts.Labels["owner-rv"]
@@ -157,7 +235,7 @@ func compareResourceVersion(zk *zookeeperv1beta1.ZookeeperCluster, sts *appsv1.S
 	return 0
 }
 
-func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1.ZookeeperCluster) (err error) {
 
 	// we cannot upgrade if cluster is in UpgradeFailed
 	if instance.Status.IsClusterInUpgradeFailedState() {
@@ -170,6 +248,9 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 			Name:      sts.Name,
 			Namespace: sts.Namespace,
 		}, foundSts)
+		if err == nil && instance.UpgradeRolledBack() {
+			return r.rollBackStatefulSet(instance, foundSts, sts)
+		}
 		if err == nil {
 			err = r.Client.Update(context.TODO(), foundSts)
 			if err != nil {
@@ -177,6 +258,9 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 			}
 			if foundSts.Status.Replicas == foundSts.Status.ReadyReplicas && foundSts.Status.CurrentRevision == foundSts.Status.UpdateRevision {
 				r.Log.Info("failed upgrade completed", "upgrade from:", instance.Status.CurrentVersion, "upgrade to:", instance.Status.TargetVersion)
+				r.recordEvent(instance, corev1.EventTypeNormal, UpgradeCompletedEvent,
+					fmt.Sprintf("Upgraded from %s to %s", instance.Status.CurrentVersion, instance.Status.TargetVersion))
+				instance.Status.RecordUpgrade(instance.Status.CurrentVersion, instance.Status.TargetVersion, zookeeperv1.UpgradeSucceeded, "")
 				instance.Status.CurrentVersion = instance.Status.TargetVersion
 				instance.Status.SetErrorConditionFalse()
 				return r.clearUpgradeStatus(instance)
@@ -225,6 +309,10 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 		Namespace: sts.Namespace,
 	}, foundSts)
 	if err != nil && errors.IsNotFound(err) {
+		if instance.Restoring() {
+			r.Log.Info("Waiting for the restore before creating the Zookeeper StatefulSet")
+			return nil
+		}
 		r.Log.Info("Creating a new Zookeeper StatefulSet",
 			"StatefulSet.Namespace", sts.Namespace,
 			"StatefulSet.Name", sts.Name)
@@ -234,9 +322,17 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 		if err != nil {
 			return err
 		}
+		r.recordEvent(instance, corev1.EventTypeNormal, StatefulSetCreatedEvent,
+			fmt.Sprintf("Created StatefulSet %s with %d members", sts.Name, *sts.Spec.Replicas))
 		return nil
 	} else if err != nil {
 		return err
+	} else if foundSts.DeletionTimestamp != nil {
+		r.Log.Info("Waiting for the Zookeeper StatefulSet to be deleted before creating it again")
+		return nil
+	} else if zk.VolumeClaimTemplatesAdded(foundSts, sts) {
+		// the pods roll to mount the new volume once adopted
+		return r.orphanStatefulSet(foundSts, "Adding a volume claim template")
 	} else {
 		// check whether zookeeperCluster is updated before updating the sts
 		cmp := compareResourceVersion(instance, foundSts)
@@ -250,13 +346,21 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 		newSTSSize := *sts.Spec.Replicas
 		if newSTSSize != foundSTSSize {
 			zkUri := utils.GetZkServiceUri(instance)
-			err = r.ZkClient.Connect(zkUri)
+			err = r.connectZk(instance, zkUri)
 			if err != nil {
 				return fmt.Errorf("Error storing cluster size %v", err)
 			}
 			defer r.ZkClient.Close()
 			r.Log.Info("Connected to ZK", "ZKURI", zkUri)
 
+			if newSTSSize < foundSTSSize {
+				// the servers leave the ensemble before their pods are
+				// deleted, rather than from their preStop hook
+				if err = r.removeServers(newSTSSize+1, zookeeperv1.ObserverIDOffset); err != nil {
+					return err
+				}
+			}
+
 			path := utils.GetMetaPath(instance)
 			version, err := r.ZkClient.NodeExists(path)
 			if err != nil {
@@ -265,20 +369,36 @@ func (r *ZookeeperClusterReconciler) reconcileStatefulSet(instance *zookeeperv1b
 
 			data := "CLUSTER_SIZE=" + strconv.Itoa(int(newSTSSize))
 			r.Log.Info("Updating Cluster Size.", "New Data:", data, "Version", version)
-			r.ZkClient.UpdateNode(path, data, version)
+			if err = r.ZkClient.UpdateNode(path, data, version); err != nil {
+				return fmt.Errorf("Error storing cluster size %v", err)
+			}
+			reason := ScalingUpEvent
+			if newSTSSize < foundSTSSize {
+				reason = ScalingDownEvent
+			}
+			r.recordEvent(instance, corev1.EventTypeNormal, reason,
+				fmt.Sprintf("Scaling from %d to %d members", foundSTSSize, newSTSSize))
 		}
 		err = r.updateStatefulSet(instance, foundSts, sts)
 		if err != nil {
 			return err
 		}
+		if instance.LeaderAwareUpgrades() {
+			if err = r.restartMembers(instance, foundSts); err != nil {
+				return err
+			}
+		} else {
+			instance.Status.Upgrade = nil
+		}
 		return r.upgradeStatefulSet(instance, foundSts)
 	}
 }
 
-func (r *ZookeeperClusterReconciler) updateStatefulSet(instance *zookeeperv1beta1.ZookeeperCluster, foundSts *appsv1.StatefulSet, sts *appsv1.StatefulSet) (err error) {
+func (r *ZookeeperClusterReconciler) updateStatefulSet(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet, sts *appsv1.StatefulSet) (err error) {
 	r.Log.Info("Updating StatefulSet",
 		"StatefulSet.Namespace", foundSts.Namespace,
 		"StatefulSet.Name", foundSts.Name)
+	r.recordRestart(instance, foundSts, sts)
 	zk.SyncStatefulSet(foundSts, sts)
 
 	err = r.Client.Update(context.TODO(), foundSts)
@@ -290,10 +410,24 @@ func (r *ZookeeperClusterReconciler) updateStatefulSet(instance *zookeeperv1beta
 	return nil
 }
 
-func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1beta1.ZookeeperCluster, foundSts *appsv1.StatefulSet) (err error) {
+// recordRestart records an event when the restart trigger or the
+// configuration of the pod template change, which restarts all the members
+func (r *ZookeeperClusterReconciler) recordRestart(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet, sts *appsv1.StatefulSet) {
+	found, annotations := foundSts.Spec.Template.Annotations, sts.Spec.Template.Annotations
+	if trigger := annotations[zookeeperv1.RestartTriggerAnnotation]; trigger != "" && trigger != found[zookeeperv1.RestartTriggerAnnotation] {
+		r.recordEvent(instance, corev1.EventTypeNormal, RollingRestartEvent,
+			fmt.Sprintf("Restarting the members, the restart trigger is set to %s", trigger))
+	}
+	if hash := annotations[zookeeperv1.ConfigHashAnnotation]; hash != "" && hash != found[zookeeperv1.ConfigHashAnnotation] {
+		r.recordEvent(instance, corev1.EventTypeNormal, RollingRestartEvent,
+			fmt.Sprintf("Restarting the members with the configuration %s", hash))
+	}
+}
+
+func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet) (err error) {
 
 	// Getting the upgradeCondition from the zk clustercondition
-	_, upgradeCondition := instance.Status.GetClusterCondition(zookeeperv1beta1.ClusterConditionUpgrading)
+	_, upgradeCondition := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionUpgrading)
 
 	if upgradeCondition == nil {
 		// Initially set upgrading condition to false
@@ -303,16 +437,34 @@ func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1bet
 
 	// Setting the upgrade condition to true to trigger the upgrade
 	// When the zk cluster is upgrading Statefulset CurrentRevision and UpdateRevision are not equal and zk cluster image tag is not equal to CurrentVersion
-	if upgradeCondition.Status == corev1.ConditionFalse {
-		if instance.Status.IsClusterInReadyState() && foundSts.Status.CurrentRevision != foundSts.Status.UpdateRevision && instance.Spec.Image.Tag != instance.Status.CurrentVersion {
+	if upgradeCondition.Status == metav1.ConditionFalse {
+		// the members keep the current version while the upgrade to the
+		// image of the spec is refused
+		if refusal := instance.UpgradeRefusal(); refusal != nil {
+			if upgradeCondition.Message != refusal.Error() {
+				r.Log.Info("Refusing the upgrade", "Reason", refusal.Error())
+				r.recordEvent(instance, corev1.EventTypeWarning, UpgradeRefusedEvent, refusal.Error())
+			}
+			instance.Status.SetUpgradeRefused(refusal.Error())
+		} else if upgradeCondition.Reason == zookeeperv1.UpgradeRefusedReason {
+			instance.Status.SetUpgradingConditionFalse()
+		}
+		if instance.Status.IsClusterInReadyState() && foundSts.Status.CurrentRevision != foundSts.Status.UpdateRevision && instance.Spec.Image.Tag != instance.Status.CurrentVersion && !instance.UpgradeRolledBack() && instance.UpgradeRefusal() == nil {
+			if instance.UpgradeUnchecked() {
+				r.Log.Info("Not checking the upgrade path, the ZooKeeper version of the tags is unknown",
+					"From", instance.Status.CurrentVersion, "To", instance.Spec.Image.Tag)
+			}
 			instance.Status.TargetVersion = instance.Spec.Image.Tag
 			instance.Status.SetPodsReadyConditionFalse()
 			instance.Status.SetUpgradingConditionTrue("", "")
+			r.events.forget(instance, UpgradeProgressingEvent, UpgradeCompletedEvent, UpgradeFailedEvent, UpgradeRolledBackEvent)
+			r.recordEvent(instance, corev1.EventTypeNormal, UpgradeStartedEvent,
+				fmt.Sprintf("Upgrading from %s to %s", instance.Status.CurrentVersion, instance.Status.TargetVersion))
 		}
 	}
 
 	// checking if the upgrade is in progress
-	if upgradeCondition.Status == corev1.ConditionTrue {
+	if upgradeCondition.Status == metav1.ConditionTrue {
 		// checking when the targetversion is empty
 		if instance.Status.TargetVersion == "" {
 			r.Log.Info("upgrading to an unknown version: cancelling upgrade process")
@@ -320,6 +472,9 @@ func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1bet
 		}
 		// Checking for upgrade completion
 		if foundSts.Status.CurrentRevision == foundSts.Status.UpdateRevision {
+			instance.Status.RecordUpgrade(instance.Status.CurrentVersion, instance.Status.TargetVersion, zookeeperv1.UpgradeSucceeded, "")
+			r.recordEvent(instance, corev1.EventTypeNormal, UpgradeCompletedEvent,
+				fmt.Sprintf("Upgraded from %s to %s", instance.Status.CurrentVersion, instance.Status.TargetVersion))
 			instance.Status.CurrentVersion = instance.Status.TargetVersion
 			r.Log.Info("upgrade completed")
 			return r.clearUpgradeStatus(instance)
@@ -327,12 +482,30 @@ func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1bet
 		// updating the upgradecondition if upgrade is in progress
 		if foundSts.Status.CurrentRevision != foundSts.Status.UpdateRevision {
 			r.Log.Info("upgrade in progress")
-			if fmt.Sprint(foundSts.Status.UpdatedReplicas) != upgradeCondition.Message {
-				instance.Status.UpdateProgress(zookeeperv1beta1.UpdatingZookeeperReason, fmt.Sprint(foundSts.Status.UpdatedReplicas))
+			if reason := upgradeHoldReason(instance, foundSts); reason != "" {
+				// the members the policy lets through are upgraded, the
+				// deadline does not apply until the upgrade goes on
+				r.Log.Info("upgrade on hold", "Reason", reason, "UpdatedReplicas", foundSts.Status.UpdatedReplicas)
+				instance.Status.UpdateProgress(reason, fmt.Sprint(foundSts.Status.UpdatedReplicas))
+			} else if fmt.Sprint(foundSts.Status.UpdatedReplicas) != upgradeCondition.Message || upgradeCondition.Reason != zookeeperv1.UpdatingZookeeperReason {
+				instance.Status.UpdateProgress(zookeeperv1.UpdatingZookeeperReason, fmt.Sprint(foundSts.Status.UpdatedReplicas))
+				r.recordEvent(instance, corev1.EventTypeNormal, UpgradeProgressingEvent,
+					fmt.Sprintf("%d of %d members upgraded to %s", foundSts.Status.UpdatedReplicas, instance.Spec.Replicas, instance.Status.TargetVersion))
 			} else {
-				err = checkSyncTimeout(instance, zookeeperv1beta1.UpdatingZookeeperReason, foundSts.Status.UpdatedReplicas, 10*time.Minute)
+				err = checkSyncTimeout(instance, zookeeperv1.UpdatingZookeeperReason, foundSts.Status.UpdatedReplicas, instance.ProgressDeadline())
 				if err != nil {
-					instance.Status.SetErrorConditionTrue("UpgradeFailed", err.Error())
+					instance.Status.SetErrorConditionTrue(zookeeperv1.UpgradeFailedReason, err.Error())
+					message := fmt.Sprintf("Upgrade from %s to %s failed: %v", instance.Status.CurrentVersion, instance.Status.TargetVersion, err)
+					result := zookeeperv1.UpgradeFailed
+					if instance.AutoRollback() && instance.Status.CurrentVersion != "" {
+						// the StatefulSet gets the former image from the
+						// next reconcile on
+						r.Log.Info("Rolling back the failed upgrade", "Version", instance.Status.CurrentVersion, "FailedVersion", instance.Status.TargetVersion)
+						result = zookeeperv1.UpgradeRolledBack
+						message += ", rolling back to " + instance.Status.CurrentVersion
+					}
+					r.recordEvent(instance, corev1.EventTypeWarning, UpgradeFailedEvent, message)
+					instance.Status.RecordUpgrade(instance.Status.CurrentVersion, instance.Status.TargetVersion, result, err.Error())
 					return r.Client.Status().Update(context.TODO(), instance)
 				} else {
 					return nil
@@ -343,23 +516,80 @@ func (r *ZookeeperClusterReconciler) upgradeStatefulSet(instance *zookeeperv1bet
 	return r.Client.Status().Update(context.TODO(), instance)
 }
 
-func (r *ZookeeperClusterReconciler) clearUpgradeStatus(z *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	z.Status.SetUpgradingConditionFalse()
-	z.Status.TargetVersion = ""
-	// need to deep copy the status struct, otherwise it will be overwritten
-	// when updating the CR below
-	status := z.Status.DeepCopy()
+// upgradeHoldReason returns the reason of the upgrading condition while the
+// upgrade policy holds the upgrade, UpgradePaused when it is paused, or
+// CanaryUpgraded once the members of the canary are upgraded and ready. It
+// returns an empty string while the upgrade goes on.
+func upgradeHoldReason(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet) string {
+	if instance.UpgradeRolledBack() {
+		return ""
+	}
+	if instance.UpgradesPaused() {
+		return zookeeperv1.UpgradePausedReason
+	}
+	partition := instance.UpgradePartition()
+	status := foundSts.Status
+	if partition > 0 && status.UpdatedReplicas >= instance.Spec.Replicas-partition && status.ReadyReplicas == instance.Spec.Replicas {
+		return zookeeperv1.CanaryUpgradedReason
+	}
+	return ""
+}
 
-	err = r.Client.Update(context.TODO(), z)
+// rollBackStatefulSet reverts the StatefulSet to the image of
+// Status.CurrentVersion after a failed upgrade. The StatefulSet does not
+// replace the members which are not ready, so their pods are deleted. The
+// rollback is over once all the members run the former revision again.
+func (r *ZookeeperClusterReconciler) rollBackStatefulSet(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet, sts *appsv1.StatefulSet) (err error) {
+	zk.SyncStatefulSet(foundSts, sts)
+	if err = r.Client.Update(context.TODO(), foundSts); err != nil {
+		return err
+	}
+	status := foundSts.Status
+	if status.ObservedGeneration < foundSts.Generation {
+		return nil
+	}
+	if status.CurrentRevision == status.UpdateRevision && status.UpdatedReplicas == *foundSts.Spec.Replicas && status.ReadyReplicas == *foundSts.Spec.Replicas {
+		r.Log.Info("failed upgrade rolled back", "Version", instance.Status.CurrentVersion, "FailedVersion", instance.Status.TargetVersion)
+		r.recordEvent(instance, corev1.EventTypeNormal, UpgradeRolledBackEvent,
+			fmt.Sprintf("Rolled back to %s after the failed upgrade to %s", instance.Status.CurrentVersion, instance.Status.TargetVersion))
+		instance.Status.SetErrorConditionFalse()
+		return r.clearUpgradeStatus(instance)
+	}
+	pods := &corev1.PodList{}
+	err = r.Client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     foundSts.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{"app": foundSts.Name}),
+	})
 	if err != nil {
 		return err
 	}
+	stuck := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || podReady(pod) || pod.Labels[appsv1.ControllerRevisionHashLabelKey] == status.UpdateRevision {
+			continue
+		}
+		r.Log.Info("Deleting the member stuck with the failed upgrade", "Pod", pod.Name)
+		if err = r.Client.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		stuck = true
+	}
+	if !stuck && instance.LeaderAwareUpgrades() {
+		return r.restartMembers(instance, foundSts)
+	}
+	return nil
+}
 
-	z.Status = *status
+func (r *ZookeeperClusterReconciler) clearUpgradeStatus(z *zookeeperv1.ZookeeperCluster) (err error) {
+	z.Status.SetUpgradingConditionFalse()
+	z.Status.TargetVersion = ""
+	// the status is written by reconcileClusterStatus, updating the whole CR
+	// here would also store the defaults Reconcile applied in memory
 	return nil
 }
 
-func checkSyncTimeout(z *zookeeperv1beta1.ZookeeperCluster, reason string, updatedReplicas int32, t time.Duration) error {
+func checkSyncTimeout(z *zookeeperv1.ZookeeperCluster, reason string, updatedReplicas int32, t time.Duration) error {
 	lastCondition := z.Status.GetLastCondition()
 	if lastCondition == nil {
 		return nil
@@ -367,8 +597,7 @@ func checkSyncTimeout(z *zookeeperv1beta1.ZookeeperCluster, reason string, updat
 	if lastCondition.Reason == reason && lastCondition.Message == fmt.Sprint(updatedReplicas) {
 		// if reason and message are the same as before, which means there is no progress since the last reconciling,
 		// then check if it reaches the timeout.
-		parsedTime, _ := time.Parse(time.RFC3339, lastCondition.LastUpdateTime)
-		if time.Now().After(parsedTime.Add(t)) {
+		if time.Now().After(lastCondition.LastTransitionTime.Add(t)) {
 			// timeout
 			return fmt.Errorf("progress deadline exceeded")
 		}
@@ -376,220 +605,1185 @@ func checkSyncTimeout(z *zookeeperv1beta1.ZookeeperCluster, reason string, updat
 	return nil
 }
 
-func (r *ZookeeperClusterReconciler) reconcileClientService(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	svc := zk.MakeClientService(instance)
-	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+
+// restartMembers deletes the pod of a voting member which runs an outdated
+// revision of the StatefulSet, with its OnDelete strategy, one member at a
+// time. The followers go first and the leader last, so that the ensemble
+// elects a new leader only once. The next member is only restarted when all
+// the members are ready and the last one restarted rejoined the ensemble.
+func (r *ZookeeperClusterReconciler) restartMembers(instance *zookeeperv1.ZookeeperCluster, sts *appsv1.StatefulSet) (err error) {
+	revision := sts.Status.UpdateRevision
+	if revision == "" || sts.Status.ObservedGeneration < sts.Generation {
+		// the StatefulSet controller did not compute the revision yet
+		return nil
+	}
+	pods := &corev1.PodList{}
+	err = r.Client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     sts.Namespace,
+		LabelSelector: labels.SelectorFromSet(map[string]string{"app": sts.Name}),
+	})
+	if err != nil {
 		return err
 	}
-	foundSvc := &corev1.Service{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      svc.Name,
-		Namespace: svc.Namespace,
-	}, foundSvc)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating new client service",
-			"Service.Namespace", svc.Namespace,
-			"Service.Name", svc.Name)
-		err = r.Client.Create(context.TODO(), svc)
-		if err != nil {
-			return err
+	if int32(len(pods.Items)) != *sts.Spec.Replicas {
+		r.Log.Info("Waiting for the members to be created before restarting the next one")
+		return nil
+	}
+	// the members below the partition of the upgrade policy keep their
+	// revision, as with the rolling update
+	partition := instance.UpgradePartition()
+	var outdated []*corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || !podReady(pod) {
+			r.Log.Info("Waiting for the member to be ready before restarting the next one", "Pod", pod.Name)
+			return nil
+		}
+		if pod.Labels[appsv1.ControllerRevisionHashLabelKey] != revision && int32(podOrdinal(pod.Name)) >= partition {
+			outdated = append(outdated, pod)
+		}
+	}
+	if upgrade := instance.Status.Upgrade; upgrade != nil {
+		state, err := r.AdminClient.ServerState(zk.MemberAdminAddress(instance, upgrade.Member))
+		if err != nil || (state != zookeeperv1.MemberRoleLeader && state != zookeeperv1.MemberRoleFollower) {
+			r.Log.Info("Waiting for the member to rejoin the ensemble", "Pod", upgrade.Member, "State", state)
+			return nil
 		}
+		r.Log.Info("Member rejoined the ensemble", "Pod", upgrade.Member, "State", state)
+		instance.Status.Upgrade = nil
+	}
+	if len(outdated) == 0 {
 		return nil
-	} else if err != nil {
-		return err
-	} else {
-		r.Log.Info("Updating existing client service",
-			"Service.Namespace", foundSvc.Namespace,
-			"Service.Name", foundSvc.Name)
-		zk.SyncService(foundSvc, svc)
-		err = r.Client.Update(context.TODO(), foundSvc)
+	}
+	// the highest ordinals first, as with a rolling update
+	sort.Slice(outdated, func(i, j int) bool {
+		return podOrdinal(outdated[i].Name) > podOrdinal(outdated[j].Name)
+	})
+	var leader *corev1.Pod
+	for _, pod := range outdated {
+		state, err := r.AdminClient.ServerState(zk.MemberAdminAddress(instance, pod.Name))
 		if err != nil {
 			return err
 		}
-		port := instance.ZookeeperPorts().Client
-		instance.Status.InternalClientEndpoint = fmt.Sprintf("%s:%d",
-			foundSvc.Spec.ClusterIP, port)
-		if foundSvc.Spec.Type == "LoadBalancer" {
-			for _, i := range foundSvc.Status.LoadBalancer.Ingress {
-				if i.IP != "" {
-					instance.Status.ExternalClientEndpoint = fmt.Sprintf("%s:%d",
-						i.IP, port)
-				}
-			}
-		} else {
-			instance.Status.ExternalClientEndpoint = "N/A"
+		if state == zookeeperv1.MemberRoleLeader {
+			leader = pod
+			continue
 		}
+		return r.restartMember(instance, pod, state)
 	}
-	return nil
+	return r.restartMember(instance, leader, zookeeperv1.MemberRoleLeader)
 }
 
-func (r *ZookeeperClusterReconciler) reconcileHeadlessService(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	svc := zk.MakeHeadlessService(instance)
-	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+func (r *ZookeeperClusterReconciler) restartMember(instance *zookeeperv1.ZookeeperCluster, pod *corev1.Pod, role string) error {
+	r.Log.Info("Restarting the member with the new revision", "Pod", pod.Name, "Role", role)
+	if err := r.Client.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	foundSvc := &corev1.Service{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      svc.Name,
-		Namespace: svc.Namespace,
-	}, foundSvc)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating new headless service",
-			"Service.Namespace", svc.Namespace,
-			"Service.Name", svc.Name)
-		err = r.Client.Create(context.TODO(), svc)
-		if err != nil {
-			return err
+	instance.Status.Upgrade = &zookeeperv1.UpgradeStatus{
+		Member:    pod.Name,
+		Role:      role,
+		StartTime: &metav1.Time{Time: time.Now()},
+	}
+	return nil
+}
+
+// podReady returns true if the pod has the Ready condition
+// zookeeperRunning returns true if the zookeeper container of the pod runs,
+// whether its readiness probe passes or not
+func zookeeperRunning(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.Name == "zookeeper" {
+			return c.State.Running != nil
 		}
-		return nil
-	} else if err != nil {
-		return err
-	} else {
-		r.Log.Info("Updating existing headless service",
-			"Service.Namespace", foundSvc.Namespace,
-			"Service.Name", foundSvc.Name)
-		zk.SyncService(foundSvc, svc)
-		err = r.Client.Update(context.TODO(), foundSvc)
-		if err != nil {
-			return err
+	}
+	return false
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
 		}
 	}
-	return nil
+	return false
 }
 
-func (r *ZookeeperClusterReconciler) reconcileAdminServerService(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	svc := zk.MakeAdminServerService(instance)
-	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
-		return err
+// podOrdinal returns the ordinal of a pod of a StatefulSet, or -1 if its
+// name has none
+func podOrdinal(name string) int {
+	i := strings.LastIndex(name, "-")
+	ord, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return -1
 	}
-	foundSvc := &corev1.Service{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      svc.Name,
-		Namespace: svc.Namespace,
-	}, foundSvc)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating admin server service",
-			"Service.Namespace", svc.Namespace,
-			"Service.Name", svc.Name)
-		err = r.Client.Create(context.TODO(), svc)
-		if err != nil {
+	return ord
+}
+
+// reconcileRestore seeds the data volumes of the members of a new cluster
+// with the snapshot of spec.restoreFrom, one member after the other so that
+// a claim holding the snapshot can be mounted by a single node at a time.
+// The StatefulSet is only created once all of them are seeded.
+func (r *ZookeeperClusterReconciler) reconcileRestore(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	from := instance.Spec.RestoreFrom
+	if from == nil {
+		return nil
+	}
+	if instance.Status.Restore == nil {
+		// only a cluster which never ran is restored
+		if instance.Status.MetaRootCreated {
+			return nil
+		}
+		foundSts := &appsv1.StatefulSet{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.Namespace}, foundSts)
+		if err == nil {
+			return nil
+		} else if !errors.IsNotFound(err) {
 			return err
 		}
+		instance.Status.Restore = &zookeeperv1.RestoreStatus{
+			Phase:   zookeeperv1.RestorePending,
+			Members: instance.Spec.Replicas,
+		}
+	}
+	if !instance.Restoring() || instance.Status.Restore.Phase == zookeeperv1.RestoreFailed {
 		return nil
-	} else if err != nil {
-		return err
-	} else {
-		r.Log.Info("Updating existing admin server service",
-			"Service.Namespace", foundSvc.Namespace,
-			"Service.Name", foundSvc.Name)
-		zk.SyncService(foundSvc, svc)
-		err = r.Client.Update(context.TODO(), foundSvc)
-		if err != nil {
+	}
+	if err = from.Validate(); err != nil {
+		return r.setRestoreFailed(instance, err.Error())
+	}
+	if instance.Spec.StorageType == zookeeperv1.StorageTypeEphemeral {
+		return r.setRestoreFailed(instance, "a restore needs persistent storage")
+	}
+	var snapshot *zk.RestoreSnapshot
+	if from.Backup != "" {
+		backup := &zookeeperv1.ZookeeperBackup{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: from.Backup, Namespace: instance.Namespace}, backup)
+		if err != nil && !errors.IsNotFound(err) {
 			return err
 		}
+		if errors.IsNotFound(err) || !backup.Status.Finished() {
+			return r.setRestorePending(instance, fmt.Sprintf("Waiting for ZookeeperBackup %s to succeed", from.Backup))
+		}
+		if backup.Status.Phase == zookeeperv1.BackupFailed {
+			return r.setRestoreFailed(instance, fmt.Sprintf("ZookeeperBackup %s failed", from.Backup))
+		}
+		snapshot, err = zk.BackupSnapshot(backup)
+	} else {
+		snapshot, err = zk.SourceSnapshot(from.Snapshot)
 	}
-	return nil
+	if err != nil {
+		return r.setRestoreFailed(instance, err.Error())
+	}
+	return r.restoreMembers(instance, snapshot)
 }
 
-func (r *ZookeeperClusterReconciler) reconcilePodDisruptionBudget(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	pdb := zk.MakePodDisruptionBudget(instance)
-	if err = controllerutil.SetControllerReference(instance, pdb, r.Scheme); err != nil {
-		return err
+func (r *ZookeeperClusterReconciler) restoreMembers(instance *zookeeperv1.ZookeeperCluster, snapshot *zk.RestoreSnapshot) (err error) {
+	restore := instance.Status.Restore
+	if restore.Phase == zookeeperv1.RestorePending {
+		now := metav1.Now()
+		restore.Phase = zookeeperv1.RestoreRunning
+		restore.Location = snapshot.Location()
+		restore.Zxid = snapshot.Zxid
+		restore.StartTime = &now
+		restore.Message = ""
+		r.Log.Info("Restoring the members", "Location", restore.Location, "Zxid", restore.Zxid)
+		if err = r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return err
+		}
 	}
-	foundPdb := &policyv1.PodDisruptionBudget{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      pdb.Name,
-		Namespace: pdb.Namespace,
-	}, foundPdb)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating new pod-disruption-budget",
-			"PodDisruptionBudget.Namespace", pdb.Namespace,
-			"PodDisruptionBudget.Name", pdb.Name)
-		err = r.Client.Create(context.TODO(), pdb)
+	restored := restore.RestoredMembers
+	for restore.RestoredMembers < restore.Members {
+		done, err := r.restoreMember(instance, snapshot, restore.RestoredMembers)
 		if err != nil {
 			return err
 		}
+		if !done {
+			break
+		}
+		restore.RestoredMembers++
+		r.Log.Info("Member restored", "Restored", restore.RestoredMembers, "Members", restore.Members)
+	}
+	if restore.RestoredMembers == restore.Members {
+		now := metav1.Now()
+		restore.Phase = zookeeperv1.RestoreSucceeded
+		restore.CompletionTime = &now
+	} else if restore.RestoredMembers == restored {
 		return nil
-	} else if err != nil {
-		return err
 	}
-	return nil
+	return r.Client.Status().Update(context.TODO(), instance)
 }
 
-func (r *ZookeeperClusterReconciler) reconcileConfigMap(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	cm := zk.MakeConfigMap(instance)
-	if err = controllerutil.SetControllerReference(instance, cm, r.Scheme); err != nil {
-		return err
+// restoreMember creates the volumes of the member with the given ordinal and
+// the job seeding it, it returns true once the job completed
+func (r *ZookeeperClusterReconciler) restoreMember(instance *zookeeperv1.ZookeeperCluster, snapshot *zk.RestoreSnapshot, ord int32) (done bool, err error) {
+	pvcs := []*corev1.PersistentVolumeClaim{zk.MakeRestorePVC(instance, ord)}
+	if dataLog := zk.MakeRestoreDataLogPVC(instance, ord); dataLog != nil {
+		pvcs = append(pvcs, dataLog)
 	}
-	foundCm := &corev1.ConfigMap{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      cm.Name,
-		Namespace: cm.Namespace,
-	}, foundCm)
+	for _, pvc := range pvcs {
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, &corev1.PersistentVolumeClaim{})
+		if err != nil && errors.IsNotFound(err) {
+			r.Log.Info("Creating a volume of a restored member",
+				"PersistentVolumeClaim.Namespace", pvc.Namespace,
+				"PersistentVolumeClaim.Name", pvc.Name)
+			if err = r.Client.Create(context.TODO(), pvc); err != nil {
+				return false, err
+			}
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	job := zk.MakeRestoreJob(instance, snapshot, ord, instance.Status.Restore.Members)
+	foundJob := &batchv1.Job{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, foundJob)
 	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("Creating a new Zookeeper Config Map",
-			"ConfigMap.Namespace", cm.Namespace,
-			"ConfigMap.Name", cm.Name)
-		err = r.Client.Create(context.TODO(), cm)
-		if err != nil {
-			return err
+		if err = controllerutil.SetControllerReference(instance, job, r.Scheme); err != nil {
+			return false, err
 		}
-		return nil
+		r.Log.Info("Creating a new restore job",
+			"Job.Namespace", job.Namespace,
+			"Job.Name", job.Name)
+		return false, r.Client.Create(context.TODO(), job)
 	} else if err != nil {
-		return err
-	} else {
-		r.Log.Info("Updating existing config-map",
-			"ConfigMap.Namespace", foundCm.Namespace,
-			"ConfigMap.Name", foundCm.Name)
-		zk.SyncConfigMap(foundCm, cm)
-		err = r.Client.Update(context.TODO(), foundCm)
-		if err != nil {
-			return err
+		return false, err
+	}
+	for _, c := range foundJob.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			message, err := jobTerminationMessage(r.Client, instance.Namespace, zk.RestoreJobLabels(instance, ord))
+			if err != nil {
+				return false, err
+			}
+			if message == "" {
+				message = c.Message
+			}
+			return false, r.setRestoreFailed(instance, fmt.Sprintf("Restoring member %d failed: %s", ord, message))
 		}
 	}
-	return nil
+	return false, nil
 }
 
-func (r *ZookeeperClusterReconciler) reconcileClusterStatus(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	if instance.Status.IsClusterInUpgradingState() || instance.Status.IsClusterInUpgradeFailedState() {
+func (r *ZookeeperClusterReconciler) setRestorePending(instance *zookeeperv1.ZookeeperCluster, message string) error {
+	restore := instance.Status.Restore
+	if restore.Phase == zookeeperv1.RestorePending && restore.Message == message {
 		return nil
 	}
-	instance.Status.Init()
-	foundPods := &corev1.PodList{}
-	labelSelector := labels.SelectorFromSet(map[string]string{"app": instance.GetName()})
-	listOps := &client.ListOptions{
-		Namespace:     instance.Namespace,
-		LabelSelector: labelSelector,
-	}
-	err = r.Client.List(context.TODO(), foundPods, listOps)
-	if err != nil {
-		return err
+	r.Log.Info("Restore pending", "Message", message)
+	restore.Phase = zookeeperv1.RestorePending
+	restore.Message = message
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+func (r *ZookeeperClusterReconciler) setRestoreFailed(instance *zookeeperv1.ZookeeperCluster, message string) error {
+	r.Log.Info("Restore failed", "Message", message)
+	now := metav1.Now()
+	restore := instance.Status.Restore
+	restore.Phase = zookeeperv1.RestoreFailed
+	restore.CompletionTime = &now
+	restore.Message = message
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+
+// reconcileVolumeExpansion expands the data volumes of the members once the
+// storage requested in the spec grows. The claims of the members are patched
+// if their StorageClass allows expansion, then the StatefulSets are deleted
+// without their pods and recreated with the larger volume claim template,
+// which the API does not allow to change in place. The members keep running
+// throughout.
+func (r *ZookeeperClusterReconciler) reconcileVolumeExpansion(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if instance.Spec.StorageType != zookeeperv1.StorageTypePersistence || instance.Spec.Persistence == nil {
+		return nil
+	}
+	size := instance.Spec.Persistence.PersistentVolumeClaimSpec.Resources.Requests[corev1.ResourceStorage]
+	names := []string{instance.GetName()}
+	if instance.Spec.Observers != nil {
+		// observers being removed are deleted together with their
+		// StatefulSet
+		names = append(names, instance.GetObserverName())
+	}
+	var smaller []*appsv1.StatefulSet
+	for _, name := range names {
+		foundSts := &appsv1.StatefulSet{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, foundSts)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if current, ok := zk.DataVolumeClaimSize(foundSts); ok && foundSts.DeletionTimestamp == nil && current.Cmp(size) < 0 {
+			smaller = append(smaller, foundSts)
+		}
+	}
+	status := instance.Status.DeepCopy()
+	expansion := instance.Status.VolumeExpansion
+	if len(smaller) > 0 && (expansion == nil || expansion.Size != size.String()) {
+		r.Log.Info("Expanding the volumes of the members", "Size", size.String())
+		expansion = &zookeeperv1.VolumeExpansionStatus{Size: size.String()}
+		instance.Status.VolumeExpansion = expansion
+	}
+	if expansion == nil {
+		return nil
+	}
+
+	var pvcs []corev1.PersistentVolumeClaim
+	for _, app := range []string{instance.GetName(), instance.GetObserverName()} {
+		pvcList, err := r.listPVCs(instance, app)
+		if err != nil {
+			return err
+		}
+		for _, pvc := range pvcList.Items {
+			// the transaction log volumes keep their size
+			if strings.HasPrefix(pvc.Name, "data-") {
+				pvcs = append(pvcs, pvc)
+			}
+		}
+	}
+	expansion.Message = ""
+	if len(smaller) > 0 {
+		expansion.Message, err = r.expandPVCs(pvcs, size)
+		if err != nil {
+			return err
+		}
+	}
+	expansion.Members = volumeExpansionProgress(pvcs, size)
+	if expansion.Message == "" {
+		for _, sts := range smaller {
+			if err = r.orphanStatefulSet(sts, "Expanding the volume claim template"); err != nil {
+				return err
+			}
+		}
+	}
+	if reflect.DeepEqual(status, &instance.Status) {
+		return nil
+	}
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+// orphanStatefulSet deletes a StatefulSet without its pods, so that it is
+// created again with volume claim templates the API does not allow to change
+// in place. The new StatefulSet adopts the pods.
+func (r *ZookeeperClusterReconciler) orphanStatefulSet(sts *appsv1.StatefulSet, reason string) error {
+	r.Log.Info("Deleting the StatefulSet without its pods to create it again",
+		"StatefulSet.Namespace", sts.Namespace,
+		"StatefulSet.Name", sts.Name,
+		"Reason", reason)
+	err := r.Client.Delete(context.TODO(), sts, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// expandPVCs patches the claims requesting less than the given size. It
+// returns why the claims cannot be expanded if their StorageClass does not
+// allow it, in which case none is patched.
+func (r *ZookeeperClusterReconciler) expandPVCs(pvcs []corev1.PersistentVolumeClaim, size resource.Quantity) (string, error) {
+	var expand []*corev1.PersistentVolumeClaim
+	classes := map[string]bool{}
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		if pvc.Spec.Resources.Requests.Storage().Cmp(size) >= 0 {
+			continue
+		}
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+			return fmt.Sprintf("PersistentVolumeClaim %s has no StorageClass and cannot be expanded", pvc.Name), nil
+		}
+		classes[*pvc.Spec.StorageClassName] = true
+		expand = append(expand, pvc)
+	}
+	for name := range classes {
+		class := &storagev1.StorageClass{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name}, class)
+		if errors.IsForbidden(err) {
+			// without access to the StorageClass, the API server
+			// rejects the patch if it does not allow expansion
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("Error getting StorageClass %s: %v", name, err)
+		}
+		if class.AllowVolumeExpansion == nil || !*class.AllowVolumeExpansion {
+			return fmt.Sprintf("StorageClass %s does not allow volume expansion", name), nil
+		}
+	}
+	for _, pvc := range expand {
+		r.Log.Info("Expanding PVC", "PVC.Name", pvc.Name, "Size", size.String())
+		patch := client.MergeFrom(pvc.DeepCopy())
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+		if err := r.Client.Patch(context.TODO(), pvc, patch); err != nil {
+			if errors.IsForbidden(err) || errors.IsInvalid(err) {
+				return fmt.Sprintf("PersistentVolumeClaim %s cannot be expanded: %v", pvc.Name, err), nil
+			}
+			return "", fmt.Errorf("Error expanding PVC %s: %v", pvc.Name, err)
+		}
+	}
+	return "", nil
+}
+
+// volumeExpansionProgress returns the progress of expanding each claim to
+// the given size
+func volumeExpansionProgress(pvcs []corev1.PersistentVolumeClaim, size resource.Quantity) []zookeeperv1.MemberVolumeExpansion {
+	members := make([]zookeeperv1.MemberVolumeExpansion, 0, len(pvcs))
+	for _, pvc := range pvcs {
+		member := zookeeperv1.MemberVolumeExpansion{
+			Name:  strings.TrimPrefix(pvc.Name, "data-"),
+			Claim: pvc.Name,
+			Phase: zookeeperv1.VolumeExpansionResizing,
+		}
+		capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+		if ok {
+			member.Capacity = capacity.String()
+		}
+		switch {
+		case ok && capacity.Cmp(size) >= 0:
+			member.Phase = zookeeperv1.VolumeExpansionCompleted
+		case pvc.Spec.Resources.Requests.Storage().Cmp(size) < 0:
+			member.Phase = zookeeperv1.VolumeExpansionPending
+		default:
+			for _, c := range pvc.Status.Conditions {
+				if c.Type == corev1.PersistentVolumeClaimFileSystemResizePending && c.Status == corev1.ConditionTrue {
+					member.Phase = zookeeperv1.VolumeExpansionFileSystemResizePending
+				}
+			}
+		}
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members
+}
+
+// reconcileObservers reconciles the observer StatefulSet and its services.
+// Observers join once the voting members are ready. When they are removed
+// from the spec they are scaled down first, so that they leave the dynamic
+// config, before their resources are deleted.
+func (r *ZookeeperClusterReconciler) reconcileObservers(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if instance.Status.IsClusterInUpgradeFailedState() {
+		return nil
+	}
+	foundSts := &appsv1.StatefulSet{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      instance.GetObserverName(),
+		Namespace: instance.Namespace,
+	}, foundSts)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	stsFound := err == nil
+	if stsFound && foundSts.DeletionTimestamp != nil {
+		r.Log.Info("Waiting for the observer StatefulSet to be deleted before creating it again")
+		return nil
+	}
+	if instance.Spec.Observers == nil {
+		if stsFound && (*foundSts.Spec.Replicas > 0 || foundSts.Status.Replicas > 0) {
+			// the StatefulSet and the services stay until the
+			// observers are gone
+			return r.updateObserverStatefulSet(instance, foundSts)
+		}
+		if stsFound {
+			r.Log.Info("Deleting the observer StatefulSet",
+				"StatefulSet.Namespace", foundSts.Namespace,
+				"StatefulSet.Name", foundSts.Name)
+			if err = r.Client.Delete(context.TODO(), foundSts); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		return r.deleteObserverServices(instance)
+	}
+	for _, svc := range []*corev1.Service{
+		zk.MakeObserverHeadlessService(instance),
+		zk.MakeObserverClientService(instance),
+	} {
+		if err = r.reconcileObserverService(instance, svc); err != nil {
+			return err
+		}
+	}
+	if stsFound {
+		return r.updateObserverStatefulSet(instance, foundSts)
+	}
+	if instance.Spec.Observers.Replicas == 0 {
+		return nil
+	}
+	if !instance.Status.MetaRootCreated {
+		r.Log.Info("Waiting for the voting members before creating the observers")
+		return nil
+	}
+	sts := zk.MakeObserverStatefulSet(instance)
+	if err = controllerutil.SetControllerReference(instance, sts, r.Scheme); err != nil {
+		return err
+	}
+	if err = r.storeObserverCount(instance, *sts.Spec.Replicas); err != nil {
+		return err
+	}
+	r.Log.Info("Creating the observer StatefulSet",
+		"StatefulSet.Namespace", sts.Namespace,
+		"StatefulSet.Name", sts.Name)
+	if err = r.Client.Create(context.TODO(), sts); err != nil {
+		return err
+	}
+	r.recordEvent(instance, corev1.EventTypeNormal, StatefulSetCreatedEvent,
+		fmt.Sprintf("Created StatefulSet %s with %d observers", sts.Name, *sts.Spec.Replicas))
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) updateObserverStatefulSet(instance *zookeeperv1.ZookeeperCluster, foundSts *appsv1.StatefulSet) (err error) {
+	sts := zk.MakeObserverStatefulSet(instance)
+	if *sts.Spec.Replicas < *foundSts.Spec.Replicas {
+		if err = r.removeObservers(instance, *sts.Spec.Replicas); err != nil {
+			return err
+		}
+	}
+	if *sts.Spec.Replicas != *foundSts.Spec.Replicas {
+		// the observers being removed check the count when they stop
+		if err = r.storeObserverCount(instance, *sts.Spec.Replicas); err != nil {
+			return err
+		}
+	}
+	r.Log.Info("Updating the observer StatefulSet",
+		"StatefulSet.Namespace", foundSts.Namespace,
+		"StatefulSet.Name", foundSts.Name)
+	if instance.Spec.Observers == nil {
+		// the observers being removed keep their pod template
+		foundSts.Spec.Replicas = sts.Spec.Replicas
+	} else if zk.VolumeClaimTemplatesAdded(foundSts, sts) {
+		return r.orphanStatefulSet(foundSts, "Adding a volume claim template")
+	} else {
+		zk.SyncStatefulSet(foundSts, sts)
+	}
+	return r.Client.Update(context.TODO(), foundSts)
+}
+
+// removeObservers removes the observers beyond the given count from the
+// dynamic config
+func (r *ZookeeperClusterReconciler) removeObservers(instance *zookeeperv1.ZookeeperCluster, count int32) (err error) {
+	zkUri := utils.GetZkServiceUri(instance)
+	if err = r.connectZk(instance, zkUri); err != nil {
+		return fmt.Errorf("Error removing observers %v", err)
+	}
+	defer r.ZkClient.Close()
+	return r.removeServers(zookeeperv1.ObserverIDOffset+count+1, math.MaxInt32)
+}
+
+// removeServers removes the servers with an id between first and last from
+// the dynamic config of the ensemble the client is connected to, and reads
+// the config again to confirm they left. Their StatefulSet must only be
+// scaled down then.
+func (r *ZookeeperClusterReconciler) removeServers(first int32, last int32) (err error) {
+	config, err := r.ZkClient.GetConfig()
+	if err != nil {
+		return err
+	}
+	ids := config.ServersIn(first, last)
+	if len(ids) == 0 {
+		return nil
+	}
+	leaving := make([]string, 0, len(ids))
+	for _, id := range ids {
+		leaving = append(leaving, strconv.Itoa(int(id)))
+	}
+	r.Log.Info("Removing servers from the ensemble", "Servers", leaving, "ConfigVersion", config.Version)
+	if err = r.ZkClient.IncrementalReconfig(nil, leaving, config.Version); err != nil {
+		return err
+	}
+	if config, err = r.ZkClient.GetConfig(); err != nil {
+		return err
+	}
+	if ids = config.ServersIn(first, last); len(ids) > 0 {
+		return fmt.Errorf("Servers %v are still in the dynamic config", ids)
+	}
+	return nil
+}
+
+// storeObserverCount stores the number of observers in zookeeper, where
+// zookeeperTeardown.sh reads it to tell an observer being removed from one
+// being restarted
+func (r *ZookeeperClusterReconciler) storeObserverCount(instance *zookeeperv1.ZookeeperCluster, count int32) (err error) {
+	zkUri := utils.GetZkServiceUri(instance)
+	if err = r.connectZk(instance, zkUri); err != nil {
+		return fmt.Errorf("Error storing observer count %v", err)
+	}
+	defer r.ZkClient.Close()
+	path := utils.GetObserversMetaPath(instance)
+	data := "CLUSTER_SIZE=" + strconv.Itoa(int(count))
+	r.Log.Info("Updating observer count", "Path", path, "New Data:", data)
+	return r.ZkClient.SetNode(path, data)
+}
+
+func (r *ZookeeperClusterReconciler) reconcileObserverService(instance *zookeeperv1.ZookeeperCluster, svc *corev1.Service) (err error) {
+	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+		return err
+	}
+	foundSvc := &corev1.Service{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+	}, foundSvc)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating new observer service",
+			"Service.Namespace", svc.Namespace,
+			"Service.Name", svc.Name)
+		return r.Client.Create(context.TODO(), svc)
+	} else if err != nil {
+		return err
+	}
+	r.Log.Info("Updating existing observer service",
+		"Service.Namespace", foundSvc.Namespace,
+		"Service.Name", foundSvc.Name)
+	zk.SyncService(foundSvc, svc)
+	return r.Client.Update(context.TODO(), foundSvc)
+}
+
+func (r *ZookeeperClusterReconciler) deleteObserverServices(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	for _, name := range []string{
+		instance.GetObserverHeadlessServiceName(),
+		instance.GetObserverClientServiceName(),
+	} {
+		err = r.Client.Delete(context.TODO(), &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: instance.Namespace,
+			},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) reconcileClientService(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	svc := zk.MakeClientService(instance)
+	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+		return err
+	}
+	foundSvc := &corev1.Service{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+	}, foundSvc)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating new client service",
+			"Service.Namespace", svc.Namespace,
+			"Service.Name", svc.Name)
+		err = r.Client.Create(context.TODO(), svc)
+		if err != nil {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		r.Log.Info("Updating existing client service",
+			"Service.Namespace", foundSvc.Namespace,
+			"Service.Name", foundSvc.Name)
+		zk.SyncService(foundSvc, svc)
+		err = r.Client.Update(context.TODO(), foundSvc)
+		if err != nil {
+			return err
+		}
+		port := instance.Spec.Ports.Client
+		instance.Status.InternalClientEndpoint = fmt.Sprintf("%s:%d",
+			foundSvc.Spec.ClusterIP, port)
+		if foundSvc.Spec.Type == "LoadBalancer" {
+			for _, i := range foundSvc.Status.LoadBalancer.Ingress {
+				if i.IP != "" {
+					instance.Status.ExternalClientEndpoint = fmt.Sprintf("%s:%d",
+						i.IP, port)
+				}
+			}
+		} else {
+			instance.Status.ExternalClientEndpoint = "N/A"
+		}
+	}
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) reconcileHeadlessService(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	svc := zk.MakeHeadlessService(instance)
+	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+		return err
+	}
+	foundSvc := &corev1.Service{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+	}, foundSvc)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating new headless service",
+			"Service.Namespace", svc.Namespace,
+			"Service.Name", svc.Name)
+		err = r.Client.Create(context.TODO(), svc)
+		if err != nil {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		r.Log.Info("Updating existing headless service",
+			"Service.Namespace", foundSvc.Namespace,
+			"Service.Name", foundSvc.Name)
+		zk.SyncService(foundSvc, svc)
+		err = r.Client.Update(context.TODO(), foundSvc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) reconcileAdminServerService(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	svc := zk.MakeAdminServerService(instance)
+	if err = controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+		return err
+	}
+	foundSvc := &corev1.Service{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+	}, foundSvc)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating admin server service",
+			"Service.Namespace", svc.Namespace,
+			"Service.Name", svc.Name)
+		err = r.Client.Create(context.TODO(), svc)
+		if err != nil {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		r.Log.Info("Updating existing admin server service",
+			"Service.Namespace", foundSvc.Namespace,
+			"Service.Name", foundSvc.Name)
+		zk.SyncService(foundSvc, svc)
+		err = r.Client.Update(context.TODO(), foundSvc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) reconcilePodDisruptionBudget(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	pdb := zk.MakePodDisruptionBudget(instance)
+	if err = controllerutil.SetControllerReference(instance, pdb, r.Scheme); err != nil {
+		return err
+	}
+	foundPdb := &policyv1.PodDisruptionBudget{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      pdb.Name,
+		Namespace: pdb.Namespace,
+	}, foundPdb)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating new pod-disruption-budget",
+			"PodDisruptionBudget.Namespace", pdb.Namespace,
+			"PodDisruptionBudget.Name", pdb.Name)
+		err = r.Client.Create(context.TODO(), pdb)
+		if err != nil {
+			return err
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=podmonitors;servicemonitors;prometheusrules,verbs=get;list;watch;create;update;delete
+
+// reconcileMonitoring creates the monitor and the rule of Spec.Monitoring,
+// and deletes the ones the spec does not ask for anymore. The kinds whose
+// CRDs are not installed are skipped.
+func (r *ZookeeperClusterReconciler) reconcileMonitoring(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	desired := zk.MakeMonitoring(instance)
+	for _, kind := range zk.MonitoringKinds {
+		found := &unstructured.Unstructured{}
+		found.SetGroupVersionKind(zk.MonitoringGroupVersion.WithKind(kind))
+		err = r.Client.Get(context.TODO(), types.NamespacedName{
+			Name:      instance.GetName(),
+			Namespace: instance.Namespace,
+		}, found)
+		if meta.IsNoMatchError(err) {
+			if desired[kind] != nil {
+				r.Log.Info("Skipping the monitoring resource, its CRD is not installed", "Kind", kind)
+			}
+			continue
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		exists := err == nil
+		obj := desired[kind]
+		switch {
+		case obj == nil:
+			if !exists || !metav1.IsControlledBy(found, instance) {
+				continue
+			}
+			r.Log.Info("Deleting the monitoring resource", "Kind", kind, "Name", found.GetName())
+			if err = r.Client.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		case !exists:
+			if err = controllerutil.SetControllerReference(instance, obj, r.Scheme); err != nil {
+				return err
+			}
+			r.Log.Info("Creating the monitoring resource", "Kind", kind, "Name", obj.GetName())
+			if err = r.Client.Create(context.TODO(), obj); err != nil {
+				return err
+			}
+		case !equality.Semantic.DeepDerivative(obj.Object["spec"], found.Object["spec"]) ||
+			!reflect.DeepEqual(obj.GetLabels(), found.GetLabels()):
+			r.Log.Info("Updating the monitoring resource", "Kind", kind, "Name", found.GetName())
+			found.Object["spec"] = obj.Object["spec"]
+			found.SetLabels(obj.GetLabels())
+			if err = r.Client.Update(context.TODO(), found); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileQuorumPhases moves the quorum TLS and authentication statuses one
+// phase towards the spec once the StatefulSet rolled out the current ones.
+// The config map and the pod template follow the statuses, so each phase is
+// a rolling restart.
+func (r *ZookeeperClusterReconciler) reconcileQuorumPhases(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	foundSts := &appsv1.StatefulSet{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      instance.GetName(),
+		Namespace: instance.Namespace,
+	}, foundSts)
+	if err != nil && errors.IsNotFound(err) {
+		status := instance.Status.DeepCopy()
+		instance.InitQuorumTLS()
+		instance.InitQuorumAuth()
+		if reflect.DeepEqual(status, &instance.Status) {
+			return nil
+		}
+		// stored right away, the StatefulSet is created with it
+		return r.Client.Status().Update(context.TODO(), instance)
+	} else if err != nil {
+		return err
+	}
+	stss := []*appsv1.StatefulSet{foundSts}
+	foundObserverSts := &appsv1.StatefulSet{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      instance.GetObserverName(),
+		Namespace: instance.Namespace,
+	}, foundObserverSts)
+	if err == nil {
+		stss = append(stss, foundObserverSts)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	tlsChanged := r.nextQuorumTLSPhase(instance, stss)
+	authChanged := r.nextQuorumAuthPhase(instance, stss)
+	if !tlsChanged && !authChanged {
+		return nil
+	}
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+func (r *ZookeeperClusterReconciler) nextQuorumTLSPhase(instance *zookeeperv1.ZookeeperCluster, stss []*appsv1.StatefulSet) bool {
+	enable := instance.Spec.TLS.QuorumEnabled()
+	phase := instance.Status.QuorumTLSPhase()
+	if enable && phase != zookeeperv1.QuorumTLSDisabled && instance.Status.QuorumTLS.SecretName != instance.Spec.TLS.Quorum.SecretName {
+		// the new secret is rolled out like any other pod template change
+		r.Log.Info("Updating quorum TLS secret", "SecretName", instance.Spec.TLS.Quorum.SecretName)
+		instance.Status.QuorumTLS.SecretName = instance.Spec.TLS.Quorum.SecretName
+		return true
+	}
+	next := zookeeperv1.NextQuorumTLSPhase(phase, enable)
+	if next == phase {
+		return false
+	}
+	if !podTemplateRolledOut(stss, zookeeperv1.QuorumTLSPhaseAnnotation, string(phase)) {
+		r.Log.Info("Waiting for the quorum TLS phase to roll out", "Phase", phase)
+		return false
+	}
+	r.Log.Info("Moving to the next quorum TLS phase", "From", phase, "To", next)
+	if next == zookeeperv1.QuorumTLSDisabled {
+		instance.Status.QuorumTLS = nil
+	} else if instance.Status.QuorumTLS == nil {
+		instance.Status.QuorumTLS = &zookeeperv1.QuorumTLSStatus{
+			Phase:      next,
+			SecretName: instance.Spec.TLS.Quorum.SecretName,
+		}
+	} else {
+		instance.Status.QuorumTLS.Phase = next
+	}
+	return true
+}
+
+func (r *ZookeeperClusterReconciler) nextQuorumAuthPhase(instance *zookeeperv1.ZookeeperCluster, stss []*appsv1.StatefulSet) bool {
+	enable := instance.Spec.Auth.QuorumEnabled()
+	phase := instance.Status.QuorumAuthPhase()
+	if enable && phase != zookeeperv1.QuorumAuthDisabled && instance.Status.QuorumAuth.User != instance.Spec.Auth.QuorumUser {
+		r.Log.Info("Updating quorum user", "User", instance.Spec.Auth.QuorumUser)
+		instance.Status.QuorumAuth.User = instance.Spec.Auth.QuorumUser
+		return true
+	}
+	next := zookeeperv1.NextQuorumAuthPhase(phase, enable)
+	if next == phase {
+		return false
+	}
+	if !podTemplateRolledOut(stss, zookeeperv1.QuorumAuthPhaseAnnotation, string(phase)) {
+		r.Log.Info("Waiting for the quorum authentication phase to roll out", "Phase", phase)
+		return false
+	}
+	r.Log.Info("Moving to the next quorum authentication phase", "From", phase, "To", next)
+	if next == zookeeperv1.QuorumAuthDisabled {
+		instance.Status.QuorumAuth = nil
+	} else if instance.Status.QuorumAuth == nil {
+		instance.Status.QuorumAuth = &zookeeperv1.QuorumAuthStatus{
+			Phase: next,
+			User:  instance.Spec.Auth.QuorumUser,
+		}
+	} else {
+		instance.Status.QuorumAuth.Phase = next
+	}
+	return true
+}
+
+// podTemplateRolledOut returns true once all members of the StatefulSets run
+// the pod template with the given annotation value and are ready
+func podTemplateRolledOut(stss []*appsv1.StatefulSet, annotation string, value string) bool {
+	for _, sts := range stss {
+		if sts.Spec.Template.Annotations[annotation] != value {
+			return false
+		}
+		replicas := *sts.Spec.Replicas
+		if sts.Status.ObservedGeneration != sts.Generation ||
+			sts.Status.CurrentRevision != sts.Status.UpdateRevision ||
+			sts.Status.UpdatedReplicas != replicas ||
+			sts.Status.ReadyReplicas != replicas {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileAuthSecret generates the JAAS configuration of the members from
+// the users secret
+func (r *ZookeeperClusterReconciler) reconcileAuthSecret(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if instance.Spec.Auth == nil {
+		if instance.Status.QuorumAuthPhase() != zookeeperv1.QuorumAuthDisabled {
+			// the members authenticating each other keep the last
+			// generated secret until quorum authentication is disabled
+			return nil
+		}
+		err = r.Client.Delete(context.TODO(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      instance.AuthSecretName(),
+				Namespace: instance.Namespace,
+			},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	users := &corev1.Secret{}
+	name := types.NamespacedName{Name: instance.Spec.Auth.SecretName, Namespace: instance.Namespace}
+	if err = r.Client.Get(context.TODO(), name, users); err != nil {
+		return fmt.Errorf("Error getting auth secret %s: %v", name, err)
+	}
+	secret, err := zk.MakeAuthSecret(instance, users)
+	if err != nil {
+		return err
+	}
+	if err = controllerutil.SetControllerReference(instance, secret, r.Scheme); err != nil {
+		return err
+	}
+	foundSecret := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+	}, foundSecret)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new Zookeeper auth secret",
+			"Secret.Namespace", secret.Namespace,
+			"Secret.Name", secret.Name)
+		return r.Client.Create(context.TODO(), secret)
+	} else if err != nil {
+		return err
 	}
-	var (
-		readyMembers   []string
-		unreadyMembers []string
-	)
-	for _, p := range foundPods.Items {
-		ready := true
-		for _, c := range p.Status.ContainerStatuses {
-			if !c.Ready {
-				ready = false
-			}
+	if reflect.DeepEqual(foundSecret.Data, secret.Data) {
+		return nil
+	}
+	r.Log.Info("Updating existing auth secret",
+		"Secret.Namespace", foundSecret.Namespace,
+		"Secret.Name", foundSecret.Name)
+	foundSecret.Data = secret.Data
+	return r.Client.Update(context.TODO(), foundSecret)
+}
+
+func (r *ZookeeperClusterReconciler) reconcileConfigMap(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	cm := zk.MakeConfigMap(instance)
+	if err = controllerutil.SetControllerReference(instance, cm, r.Scheme); err != nil {
+		return err
+	}
+	foundCm := &corev1.ConfigMap{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      cm.Name,
+		Namespace: cm.Namespace,
+	}, foundCm)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new Zookeeper Config Map",
+			"ConfigMap.Namespace", cm.Namespace,
+			"ConfigMap.Name", cm.Name)
+		err = r.Client.Create(context.TODO(), cm)
+		if err != nil {
+			return err
 		}
-		if ready {
-			readyMembers = append(readyMembers, p.Name)
-		} else {
-			unreadyMembers = append(unreadyMembers, p.Name)
+		r.updateConfigStatus(instance, cm, true)
+		return nil
+	} else if err != nil {
+		return err
+	} else {
+		r.Log.Info("Updating existing config-map",
+			"ConfigMap.Namespace", foundCm.Namespace,
+			"ConfigMap.Name", foundCm.Name)
+		zk.SyncConfigMap(foundCm, cm)
+		err = r.Client.Update(context.TODO(), foundCm)
+		if err != nil {
+			return err
+		}
+		r.updateConfigStatus(instance, cm, false)
+	}
+	return nil
+}
+
+// updateConfigStatus records the hash of the ConfigMap of the members. The
+// pod template carries the hash rolled out, which restarts the members when
+// it changes. The Automatic restart policy rolls out every new
+// configuration, the Manual one waits for a new restart trigger. The
+// members of a new cluster start with it.
+func (r *ZookeeperClusterReconciler) updateConfigStatus(instance *zookeeperv1.ZookeeperCluster, cm *corev1.ConfigMap, created bool) {
+	config := instance.Status.Config
+	if config == nil {
+		config = &zookeeperv1.ConfigStatus{}
+		instance.Status.Config = config
+	}
+	config.Hash = zk.ConfigMapHash(cm)
+	if created || !instance.ManualConfigRestarts() || config.RestartTrigger != instance.Spec.RestartTrigger {
+		if config.RolledOutHash != config.Hash {
+			r.Log.Info("Rolling out the configuration", "Hash", config.Hash)
+		}
+		config.RolledOutHash = config.Hash
+		config.RestartTrigger = instance.Spec.RestartTrigger
+	}
+	pending := config.RolledOutHash != config.Hash
+	if pending && !config.RestartPending {
+		r.Log.Info("Configuration waiting for a restart of the members", "Hash", config.Hash)
+	}
+	config.RestartPending = pending
+}
+
+// reconcileTLSSecrets records the hash of the TLS secrets of the members,
+// which the pod template carries. The members build their keystores from
+// the secrets when they start, a rotation of the certificates restarts
+// them. A secret which does not exist yet is hashed by its name alone.
+func (r *ZookeeperClusterReconciler) reconcileTLSSecrets(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	var secrets []*corev1.Secret
+	for _, name := range zk.TLSSecretNames(instance) {
+		secret := &corev1.Secret{}
+		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, secret)
+		if errors.IsNotFound(err) {
+			secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		} else if err != nil {
+			return fmt.Errorf("Error getting TLS secret %s: %v", name, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	if instance.Status.Config == nil {
+		instance.Status.Config = &zookeeperv1.ConfigStatus{}
+	}
+	hash := zk.TLSSecretsHash(secrets)
+	if instance.Status.Config.TLSSecretsHash != hash {
+		r.Log.Info("Rolling out the TLS secrets", "Hash", hash)
+		instance.Status.Config.TLSSecretsHash = hash
+	}
+	return nil
+}
+
+// secureMetaRoot restricts the metadata znodes to the super user. The
+// znodes created before authentication was enabled grant all permissions to
+// anyone, the ACL does not change with the configuration of the members.
+func (r *ZookeeperClusterReconciler) secureMetaRoot(instance *zookeeperv1.ZookeeperCluster) error {
+	password, err := superUserPassword(r.Client, instance)
+	if err != nil {
+		return err
+	}
+	zkUri := utils.GetZkServiceUri(instance)
+	if err := r.connectZk(instance, zkUri); err != nil {
+		return fmt.Errorf("Error securing cluster metaroot. Connect to zk failed %v", err)
+	}
+	defer r.ZkClient.Close()
+	acl := zk.SuperUserACL(instance.Spec.Auth.SuperUser, password)
+	for _, path := range []string{utils.ZKMetaRoot, utils.GetMetaPath(instance), utils.GetObserversMetaPath(instance)} {
+		node, err := r.ZkClient.GetNode(path)
+		if err != nil {
+			return err
+		}
+		if node == nil || zk.EqualACL(node.ACL, acl) {
+			continue
+		}
+		r.Log.Info("Restricting the metadata znode to the super user", "Path", path)
+		if err := r.ZkClient.SetACL(path, acl); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+func (r *ZookeeperClusterReconciler) reconcileClusterStatus(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if instance.Status.IsClusterInUpgradingState() || instance.Status.IsClusterInUpgradeFailedState() {
+		return nil
+	}
+	instance.Status.Init()
+	readyMembers, unreadyMembers, err := r.listMembers(instance, instance.GetName())
+	if err != nil {
+		return err
+	}
 	instance.Status.Members.Ready = readyMembers
 	instance.Status.Members.Unready = unreadyMembers
+	readyObservers, unreadyObservers, err := r.listMembers(instance, instance.GetObserverName())
+	if err != nil {
+		return err
+	}
+	if instance.Spec.Observers != nil || len(readyObservers)+len(unreadyObservers) > 0 {
+		instance.Status.Members.Observers = &zookeeperv1.ObserverMembersStatus{
+			Ready:   readyObservers,
+			Unready: unreadyObservers,
+		}
+	} else {
+		instance.Status.Members.Observers = nil
+	}
 
 	// If Cluster is in a ready state...
 	if instance.Spec.Replicas == instance.Status.ReadyReplicas && (!instance.Status.MetaRootCreated) {
 		r.Log.Info("Cluster is Ready, Creating ZK Metadata...")
 		zkUri := utils.GetZkServiceUri(instance)
-		err := r.ZkClient.Connect(zkUri)
+		err := r.connectZk(instance, zkUri)
 		if err != nil {
 			return fmt.Errorf("Error creating cluster metaroot. Connect to zk failed %v", err)
 		}
@@ -600,7 +1794,17 @@ func (r *ZookeeperClusterReconciler) reconcileClusterStatus(instance *zookeeperv
 			return fmt.Errorf("Error creating cluster metadata path %s, %v", metaPath, err)
 		}
 		r.Log.Info("Metadata znode created.")
+		r.recordEvent(instance, corev1.EventTypeNormal, MetadataZnodeCreatedEvent,
+			fmt.Sprintf("Created the metadata znode %s", metaPath))
 		instance.Status.MetaRootCreated = true
+		instance.Status.MetaRootSecured = instance.Spec.Auth != nil
+	} else if instance.Spec.Auth == nil {
+		instance.Status.MetaRootSecured = false
+	} else if instance.Status.MetaRootCreated && !instance.Status.MetaRootSecured && instance.Spec.Replicas == instance.Status.ReadyReplicas {
+		if err := r.secureMetaRoot(instance); err != nil {
+			return err
+		}
+		instance.Status.MetaRootSecured = true
 	}
 	r.Log.Info("Updating zookeeper status",
 		"StatefulSet.Namespace", instance.Namespace,
@@ -616,10 +1820,299 @@ func (r *ZookeeperClusterReconciler) reconcileClusterStatus(instance *zookeeperv
 	return r.Client.Status().Update(context.TODO(), instance)
 }
 
+// reconcileHealth probes the admin server of every member, since a pod can
+// be ready while the ensemble is split, and records what they report in the
+// status with the QuorumHealthy condition. An event is recorded when the
+// quorum is lost or restored. The status is only written when the roles,
+// the zxids or the errors of the members, or the condition change.
+func (r *ZookeeperClusterReconciler) reconcileHealth(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if r.AdminClient == nil {
+		return nil
+	}
+	ready, unready, err := r.listMembers(instance, instance.GetName())
+	if err != nil {
+		return err
+	}
+	readyObservers, unreadyObservers, err := r.listMembers(instance, instance.GetObserverName())
+	if err != nil {
+		return err
+	}
+	health := zk.ProbeMembers(r.AdminClient, instance,
+		append(ready, unready...), append(readyObservers, unreadyObservers...))
+	healthy, reason, message := zk.EvaluateQuorum(instance, health)
+	_, condition := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionQuorumHealthy)
+	switch {
+	case !healthy && condition != nil && condition.Status == metav1.ConditionTrue:
+		r.Log.Info("Quorum of the cluster lost", "Reason", reason, "Message", message)
+		r.events.forget(instance, QuorumRestoredEvent)
+		r.recordEvent(instance, corev1.EventTypeWarning, QuorumLostEvent, message)
+	case healthy && condition != nil && condition.Status != metav1.ConditionTrue:
+		r.Log.Info("Quorum of the cluster restored", "Message", message)
+		r.events.forget(instance, QuorumLostEvent)
+		r.recordEvent(instance, corev1.EventTypeNormal, QuorumRestoredEvent, message)
+	}
+	// the statistics and the time of the probe alone are not worth a write
+	if condition != nil && condition.Status == quorumStatus(healthy) && condition.Reason == reason &&
+		condition.Message == message && health.SameState(instance.Status.Health) {
+		return nil
+	}
+	instance.Status.Health = health
+	instance.Status.SetQuorumHealthyCondition(healthy, reason, message)
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+func quorumStatus(healthy bool) metav1.ConditionStatus {
+	if healthy {
+		return metav1.ConditionTrue
+	}
+	return metav1.ConditionFalse
+}
+
+// reconcileDynamicConfig compares the dynamic config committed by the
+// ensemble with the members of the cluster, and sets the DynamicConfigDrift
+// condition. The config is only read while the quorum is healthy and no
+// upgrade restarts the members.
+func (r *ZookeeperClusterReconciler) reconcileDynamicConfig(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if !instance.Status.IsQuorumHealthy() || instance.Status.IsClusterInUpgradingState() ||
+		instance.Status.IsClusterInUpgradeFailedState() {
+		return nil
+	}
+	zkUri := utils.GetZkServiceUri(instance)
+	if err = r.connectZk(instance, zkUri); err != nil {
+		return fmt.Errorf("Error reading the dynamic config %v", err)
+	}
+	defer r.ZkClient.Close()
+	config, err := r.ZkClient.GetConfig()
+	if err != nil {
+		return err
+	}
+	expected := zk.ExpectedServers(instance)
+	drift := zk.DetectConfigDrift(config, expected)
+	if drift.InSync() {
+		return r.setDynamicConfigDrift(instance, false, zookeeperv1.DynamicConfigInSyncReason, drift.String())
+	}
+	message := drift.String()
+	_, condition := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionDynamicConfigDrift)
+	// the drift must outlast a reconcile, so that the operator does not race
+	// with a member registering itself on its first start
+	persistent := condition != nil && condition.Status == metav1.ConditionTrue && condition.Message == message
+	if !persistent || instance.IsPaused() || instance.IsSubsystemPaused(zookeeperv1.SubsystemDynamicConfig) {
+		r.Log.Info("Dynamic config drift", "Message", message, "ConfigVersion", config.Version)
+		r.recordEvent(instance, corev1.EventTypeWarning, DynamicConfigDriftEvent, message)
+		return r.setDynamicConfigDrift(instance, true, zookeeperv1.DynamicConfigDriftReason, message)
+	}
+	repaired, err := r.repairDynamicConfig(instance, config, drift, expected)
+	if err != nil {
+		r.Log.Info("Failed to repair the dynamic config", "Error", err.Error())
+		r.recordEvent(instance, corev1.EventTypeWarning, DynamicConfigDriftEvent, err.Error())
+		return r.setDynamicConfigDrift(instance, true, zookeeperv1.DynamicConfigRepairFailedReason, err.Error())
+	}
+	if repaired != "" {
+		r.recordEvent(instance, corev1.EventTypeNormal, DynamicConfigRepairedEvent, repaired)
+		if config, err = r.ZkClient.GetConfig(); err != nil {
+			return err
+		}
+		drift = zk.DetectConfigDrift(config, expected)
+	}
+	if drift.InSync() {
+		return r.setDynamicConfigDrift(instance, false, zookeeperv1.DynamicConfigInSyncReason, drift.String())
+	}
+	return r.setDynamicConfigDrift(instance, true, zookeeperv1.DynamicConfigDriftReason, drift.String())
+}
+
+// setDynamicConfigDrift sets the DynamicConfigDrift condition, and only
+// writes the status when it changed
+func (r *ZookeeperClusterReconciler) setDynamicConfigDrift(instance *zookeeperv1.ZookeeperCluster, drift bool, reason, message string) error {
+	_, condition := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionDynamicConfigDrift)
+	instance.Status.SetDynamicConfigDriftCondition(drift, reason, message)
+	_, updated := instance.Status.GetClusterCondition(zookeeperv1.ClusterConditionDynamicConfigDrift)
+	if condition != nil && condition.Status == updated.Status && condition.Reason == updated.Reason && condition.Message == updated.Message {
+		return nil
+	}
+	return r.Client.Status().Update(context.TODO(), instance)
+}
+
+// repairDynamicConfig removes the servers which are not members of the
+// cluster and adds the members which are missing in a single incremental
+// reconfig, and returns what it did. Nothing is repaired while the
+// StatefulSets scale, the membership scripts register and remove the
+// members then. A ghost is only removed once its pod is gone, and a member
+// only added back as an observer while its zookeeper container runs, so that
+// a member which does not run never gets a vote. Its readiness probe fails
+// until it is in the config, then zookeeperReady.sh promotes it to a
+// participant, as it does the mismatched members.
+func (r *ZookeeperClusterReconciler) repairDynamicConfig(instance *zookeeperv1.ZookeeperCluster, config *zk.DynamicConfig, drift *zk.ConfigDrift, expected map[int32]string) (string, error) {
+	scaling, err := r.isScaling(instance)
+	if err != nil || scaling {
+		return "", err
+	}
+	getPod := func(id int32) (*corev1.Pod, error) {
+		pod := &corev1.Pod{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{
+			Name:      zk.ServerPodName(instance, id),
+			Namespace: instance.Namespace,
+		}, pod)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return pod, err
+	}
+	var joining, leaving []string
+	for _, id := range drift.Ghosts {
+		pod, err := getPod(id)
+		if err != nil {
+			return "", err
+		}
+		if pod == nil {
+			leaving = append(leaving, strconv.Itoa(int(id)))
+		}
+	}
+	for _, id := range drift.Missing {
+		pod, err := getPod(id)
+		if err != nil {
+			return "", err
+		}
+		if pod != nil && pod.DeletionTimestamp == nil && zookeeperRunning(pod) {
+			joining = append(joining, fmt.Sprintf("server.%d=%s", id, zk.ObserverSpec(expected[id])))
+		}
+	}
+	if len(joining) == 0 && len(leaving) == 0 {
+		return "", nil
+	}
+	r.Log.Info("Repairing the dynamic config", "Joining", joining, "Leaving", leaving, "ConfigVersion", config.Version)
+	if err := r.ZkClient.IncrementalReconfig(joining, leaving, config.Version); err != nil {
+		return "", err
+	}
+	var done []string
+	if len(leaving) == 1 {
+		done = append(done, "removed server "+leaving[0])
+	} else if len(leaving) > 1 {
+		done = append(done, "removed servers "+strings.Join(leaving, ", "))
+	}
+	if len(joining) > 0 {
+		done = append(done, "added "+strings.Join(joining, ", "))
+	}
+	return "Repaired the dynamic config, " + strings.Join(done, ", "), nil
+}
+
+// isScaling returns true while the StatefulSet of the members or of the
+// observers does not have the replicas of the spec
+func (r *ZookeeperClusterReconciler) isScaling(instance *zookeeperv1.ZookeeperCluster) (bool, error) {
+	for _, s := range []struct {
+		name     string
+		replicas int32
+	}{
+		{instance.GetName(), instance.Spec.Replicas},
+		{instance.GetObserverName(), instance.ObserverReplicas()},
+	} {
+		sts := &appsv1.StatefulSet{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: s.name, Namespace: instance.Namespace}, sts)
+		if errors.IsNotFound(err) {
+			if s.replicas > 0 {
+				return true, nil
+			}
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if sts.Spec.Replicas == nil || *sts.Spec.Replicas != s.replicas {
+			r.Log.Info("Leaving the dynamic config alone while the cluster scales", "StatefulSet.Name", s.name)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listMembers returns the names of the ready and unready pods with the given
+// app label
+func (r *ZookeeperClusterReconciler) listMembers(instance *zookeeperv1.ZookeeperCluster, app string) (readyMembers []string, unreadyMembers []string, err error) {
+	foundPods := &corev1.PodList{}
+	labelSelector := labels.SelectorFromSet(map[string]string{"app": app})
+	listOps := &client.ListOptions{
+		Namespace:     instance.Namespace,
+		LabelSelector: labelSelector,
+	}
+	err = r.Client.List(context.TODO(), foundPods, listOps)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range foundPods.Items {
+		ready := true
+		for _, c := range p.Status.ContainerStatuses {
+			if !c.Ready {
+				ready = false
+			}
+		}
+		if ready {
+			readyMembers = append(readyMembers, p.Name)
+		} else {
+			unreadyMembers = append(unreadyMembers, p.Name)
+		}
+	}
+	return readyMembers, unreadyMembers, nil
+}
+
+// connectZk connects the zookeeper client to the cluster, over TLS with the
+// certificates of the client TLS secret if client TLS is enabled, and
+// authenticates as the super user if authentication is enabled
+func (r *ZookeeperClusterReconciler) connectZk(instance *zookeeperv1.ZookeeperCluster, zkUri string) error {
+	err := connectZkClient(r.Client, r.ZkClient, instance, zkUri)
+	if err != nil {
+		r.recordEvent(instance, corev1.EventTypeWarning, ZookeeperConnectErrorEvent,
+			fmt.Sprintf("Error connecting to %s: %v", zkUri, err))
+	}
+	return err
+}
+
+func connectZkClient(c client.Client, zkClient zk.ZookeeperClient, instance *zookeeperv1.ZookeeperCluster, zkUri string) error {
+	var tlsConfig *tls.Config
+	if instance.Spec.TLS.ClientEnabled() {
+		secret := &corev1.Secret{}
+		name := types.NamespacedName{Name: instance.Spec.TLS.Client.SecretName, Namespace: instance.Namespace}
+		if err := c.Get(context.TODO(), name, secret); err != nil {
+			return fmt.Errorf("Error getting client TLS secret %s: %v", name, err)
+		}
+		var err error
+		tlsConfig, err = zk.NewClientTLSConfig(secret, utils.GetZkServiceHost(instance))
+		if err != nil {
+			return err
+		}
+	}
+	if err := zkClient.Connect(zkUri, tlsConfig); err != nil {
+		zkConnectFailures.WithLabelValues(instance.Namespace, instance.Name).Inc()
+		return err
+	}
+	if instance.Spec.Auth == nil {
+		return nil
+	}
+	password, err := superUserPassword(c, instance)
+	if err != nil {
+		zkClient.Close()
+		return err
+	}
+	if err := zkClient.Authenticate(instance.Spec.Auth.SuperUser, password); err != nil {
+		zkConnectFailures.WithLabelValues(instance.Namespace, instance.Name).Inc()
+		zkClient.Close()
+		return err
+	}
+	return nil
+}
+
+// superUserPassword returns the password of the super user in the users
+// secret of the cluster
+func superUserPassword(c client.Client, instance *zookeeperv1.ZookeeperCluster) (string, error) {
+	users := &corev1.Secret{}
+	name := types.NamespacedName{Name: instance.Spec.Auth.SecretName, Namespace: instance.Namespace}
+	if err := c.Get(context.TODO(), name, users); err != nil {
+		return "", fmt.Errorf("Error getting auth secret %s: %v", name, err)
+	}
+	return string(users.Data[instance.Spec.Auth.SuperUser]), nil
+}
+
 // YAMLExporterReconciler returns a fake Reconciler which is being used for generating YAML files
-func YAMLExporterReconciler(zookeepercluster *zookeeperv1beta1.ZookeeperCluster) *ZookeeperClusterReconciler {
+func YAMLExporterReconciler(zookeepercluster *zookeeperv1.ZookeeperCluster) *ZookeeperClusterReconciler {
 	var scheme = scheme.Scheme
-	scheme.AddKnownTypes(zookeeperv1beta1.GroupVersion, zookeepercluster)
+	scheme.AddKnownTypes(zookeeperv1.GroupVersion, zookeepercluster)
 	return &ZookeeperClusterReconciler{
 		Client:   fake.NewClientBuilder().WithRuntimeObjects(zookeepercluster).Build(),
 		Scheme:   scheme,
@@ -628,16 +2121,20 @@ func YAMLExporterReconciler(zookeepercluster *zookeeperv1beta1.ZookeeperCluster)
 }
 
 // GenerateYAML generated secondary resource of ZookeeperCluster resources YAML files
-func (r *ZookeeperClusterReconciler) GenerateYAML(inst *zookeeperv1beta1.ZookeeperCluster) error {
+func (r *ZookeeperClusterReconciler) GenerateYAML(inst *zookeeperv1.ZookeeperCluster) error {
 	if inst.WithDefaults() {
 		fmt.Println("set default values")
 	}
+	// the exported resources are for a new cluster
+	inst.InitQuorumTLS()
+	inst.InitQuorumAuth()
 	for _, fun := range []reconcileFun{
 		r.yamlConfigMap,
 		r.yamlStatefulSet,
 		r.yamlClientService,
 		r.yamlHeadlessService,
 		r.yamlPodDisruptionBudget,
+		r.yamlObservers,
 	} {
 		if err := fun(inst); err != nil {
 			return err
@@ -647,7 +2144,7 @@ func (r *ZookeeperClusterReconciler) GenerateYAML(inst *zookeeperv1beta1.Zookeep
 }
 
 // yamlStatefulSet will generates YAML file for StatefulSet
-func (r *ZookeeperClusterReconciler) yamlStatefulSet(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) yamlStatefulSet(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	sts := zk.MakeStatefulSet(instance)
 
 	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", sts.Labels["component"])
@@ -655,7 +2152,7 @@ func (r *ZookeeperClusterReconciler) yamlStatefulSet(instance *zookeeperv1beta1.
 }
 
 // yamlClientService will generates YAML file for zookeeper Client service
-func (r *ZookeeperClusterReconciler) yamlClientService(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) yamlClientService(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	svc := zk.MakeClientService(instance)
 
 	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", "client")
@@ -666,7 +2163,7 @@ func (r *ZookeeperClusterReconciler) yamlClientService(instance *zookeeperv1beta
 }
 
 // yamlHeadlessService will generates YAML file for zookeeper headless service
-func (r *ZookeeperClusterReconciler) yamlHeadlessService(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) yamlHeadlessService(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	svc := zk.MakeHeadlessService(instance)
 
 	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", "headless")
@@ -677,7 +2174,7 @@ func (r *ZookeeperClusterReconciler) yamlHeadlessService(instance *zookeeperv1be
 }
 
 // yamlPodDisruptionBudget will generates YAML file for zookeeper PDB
-func (r *ZookeeperClusterReconciler) yamlPodDisruptionBudget(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) yamlPodDisruptionBudget(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	pdb := zk.MakePodDisruptionBudget(instance)
 
 	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", "pdb")
@@ -687,8 +2184,36 @@ func (r *ZookeeperClusterReconciler) yamlPodDisruptionBudget(instance *zookeeper
 	return yamlexporter.GenerateOutputYAMLFile(subdir, pdb.Kind, pdb)
 }
 
+// yamlObservers will generates YAML files for the observer StatefulSet and services
+func (r *ZookeeperClusterReconciler) yamlObservers(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if instance.Spec.Observers == nil {
+		return nil
+	}
+	sts := zk.MakeObserverStatefulSet(instance)
+	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", "observer")
+	if err != nil {
+		return err
+	}
+	if err = yamlexporter.GenerateOutputYAMLFile(subdir, sts.Kind, sts); err != nil {
+		return err
+	}
+	for dir, svc := range map[string]*corev1.Service{
+		"observer-headless": zk.MakeObserverHeadlessService(instance),
+		"observer-client":   zk.MakeObserverClientService(instance),
+	} {
+		subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", dir)
+		if err != nil {
+			return err
+		}
+		if err = yamlexporter.GenerateOutputYAMLFile(subdir, svc.Kind, svc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // yamlConfigMap will generates YAML file for Zookeeper configmap
-func (r *ZookeeperClusterReconciler) yamlConfigMap(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) yamlConfigMap(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	cm := zk.MakeConfigMap(instance)
 
 	subdir, err := yamlexporter.CreateOutputSubDir("ZookeeperCluster", "config")
@@ -698,25 +2223,35 @@ func (r *ZookeeperClusterReconciler) yamlConfigMap(instance *zookeeperv1beta1.Zo
 	return yamlexporter.GenerateOutputYAMLFile(subdir, cm.Kind, cm)
 }
 
-func (r *ZookeeperClusterReconciler) reconcileFinalizers(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	if instance.Spec.Persistence != nil && instance.Spec.Persistence.VolumeReclaimPolicy != zookeeperv1beta1.VolumeReclaimPolicyDelete {
+func (r *ZookeeperClusterReconciler) reconcileFinalizers(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	if p := instance.Spec.Persistence; p != nil && p.VolumeReclaimPolicy != zookeeperv1.VolumeReclaimPolicyDelete &&
+		(instance.DataLog() == nil || instance.DataLog().VolumeReclaimPolicy != zookeeperv1.VolumeReclaimPolicyDelete) {
 		return nil
 	}
 	if instance.DeletionTimestamp.IsZero() {
 		if !utils.ContainsString(instance.ObjectMeta.Finalizers, utils.ZkFinalizer) && !config.DisableFinalizer {
+			patch := client.MergeFrom(instance.DeepCopy())
 			instance.ObjectMeta.Finalizers = append(instance.ObjectMeta.Finalizers, utils.ZkFinalizer)
-			if err = r.Client.Update(context.TODO(), instance); err != nil {
+			if err = r.Client.Patch(context.TODO(), instance, patch); err != nil {
 				return err
 			}
 		}
+		if instance.IsSubsystemPaused(zookeeperv1.SubsystemPVCCleanup) {
+			return nil
+		}
 		return r.cleanupOrphanPVCs(instance)
 	} else {
 		if utils.ContainsString(instance.ObjectMeta.Finalizers, utils.ZkFinalizer) {
+			if instance.IsSubsystemPaused(zookeeperv1.SubsystemPVCCleanup) {
+				r.Log.Info("Waiting for the PVC cleanup to be resumed before releasing the cluster")
+				return nil
+			}
 			if err = r.cleanUpAllPVCs(instance); err != nil {
 				return err
 			}
+			patch := client.MergeFrom(instance.DeepCopy())
 			instance.ObjectMeta.Finalizers = utils.RemoveString(instance.ObjectMeta.Finalizers, utils.ZkFinalizer)
-			if err = r.Client.Update(context.TODO(), instance); err != nil {
+			if err = r.Client.Patch(context.TODO(), instance, patch); err != nil {
 				return err
 			}
 		}
@@ -724,7 +2259,7 @@ func (r *ZookeeperClusterReconciler) reconcileFinalizers(instance *zookeeperv1be
 	return nil
 }
 
-func (r *ZookeeperClusterReconciler) getPVCCount(instance *zookeeperv1beta1.ZookeeperCluster) (pvcCount int, err error) {
+func (r *ZookeeperClusterReconciler) getPVCCount(instance *zookeeperv1.ZookeeperCluster) (pvcCount int, err error) {
 	pvcList, err := r.getPVCList(instance)
 	if err != nil {
 		return -1, err
@@ -733,7 +2268,7 @@ func (r *ZookeeperClusterReconciler) getPVCCount(instance *zookeeperv1beta1.Zook
 	return pvcCount, nil
 }
 
-func (r *ZookeeperClusterReconciler) cleanupOrphanPVCs(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
+func (r *ZookeeperClusterReconciler) cleanupOrphanPVCs(instance *zookeeperv1.ZookeeperCluster) (err error) {
 	// this check should make sure we do not delete the PVCs before the STS has scaled down
 	if instance.Status.ReadyReplicas == instance.Spec.Replicas {
 		pvcCount, err := r.getPVCCount(instance)
@@ -748,18 +2283,43 @@ func (r *ZookeeperClusterReconciler) cleanupOrphanPVCs(instance *zookeeperv1beta
 			}
 			for _, pvcItem := range pvcList.Items {
 				// delete only Orphan PVCs
-				if utils.IsPVCOrphan(pvcItem.Name, instance.Spec.Replicas) {
-					r.deletePVC(pvcItem)
+				if utils.IsPVCOrphan(pvcItem.Name, instance.Spec.Replicas) && reclaimsPVC(instance, pvcItem.Name) {
+					r.deletePVC(instance, pvcItem, pvcOrphaned)
 				}
 			}
 		}
 	}
+	return r.cleanupOrphanObserverPVCs(instance)
+}
+
+// cleanupOrphanObserverPVCs deletes the PVCs of the observers which were
+// scaled down, once the remaining observers are ready
+func (r *ZookeeperClusterReconciler) cleanupOrphanObserverPVCs(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	replicas := instance.ObserverReplicas()
+	if observers := instance.Status.Members.Observers; observers != nil &&
+		(len(observers.Ready) != int(replicas) || len(observers.Unready) > 0) {
+		return nil
+	}
+	pvcList, err := r.listPVCs(instance, instance.GetObserverName())
+	if err != nil {
+		return err
+	}
+	for _, pvcItem := range pvcList.Items {
+		if utils.IsPVCOrphan(pvcItem.Name, replicas) && reclaimsPVC(instance, pvcItem.Name) {
+			r.deletePVC(instance, pvcItem, pvcOrphaned)
+		}
+	}
 	return nil
 }
 
-func (r *ZookeeperClusterReconciler) getPVCList(instance *zookeeperv1beta1.ZookeeperCluster) (pvList corev1.PersistentVolumeClaimList, err error) {
+func (r *ZookeeperClusterReconciler) getPVCList(instance *zookeeperv1.ZookeeperCluster) (pvList corev1.PersistentVolumeClaimList, err error) {
+	return r.listPVCs(instance, instance.GetName())
+}
+
+// listPVCs returns the PVCs of the cluster with the given app label
+func (r *ZookeeperClusterReconciler) listPVCs(instance *zookeeperv1.ZookeeperCluster, app string) (pvList corev1.PersistentVolumeClaimList, err error) {
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
-		MatchLabels: map[string]string{"app": instance.GetName(), "uid": string(instance.UID)},
+		MatchLabels: map[string]string{"app": app, "uid": string(instance.UID)},
 	})
 	pvclistOps := &client.ListOptions{
 		Namespace:     instance.Namespace,
@@ -770,18 +2330,35 @@ func (r *ZookeeperClusterReconciler) getPVCList(instance *zookeeperv1beta1.Zooke
 	return *pvcList, err
 }
 
-func (r *ZookeeperClusterReconciler) cleanUpAllPVCs(instance *zookeeperv1beta1.ZookeeperCluster) (err error) {
-	pvcList, err := r.getPVCList(instance)
-	if err != nil {
-		return err
-	}
-	for _, pvcItem := range pvcList.Items {
-		r.deletePVC(pvcItem)
+func (r *ZookeeperClusterReconciler) cleanUpAllPVCs(instance *zookeeperv1.ZookeeperCluster) (err error) {
+	for _, app := range []string{instance.GetName(), instance.GetObserverName()} {
+		pvcList, err := r.listPVCs(instance, app)
+		if err != nil {
+			return err
+		}
+		for _, pvcItem := range pvcList.Items {
+			if reclaimsPVC(instance, pvcItem.Name) {
+				r.deletePVC(instance, pvcItem, pvcClusterDelete)
+			}
+		}
 	}
 	return nil
 }
 
-func (r *ZookeeperClusterReconciler) deletePVC(pvcItem corev1.PersistentVolumeClaim) {
+// reclaimsPVC returns true if the PVC is deleted with its member, as the
+// data and the transaction log volumes have reclaim policies of their own
+func reclaimsPVC(instance *zookeeperv1.ZookeeperCluster, name string) bool {
+	if instance.Spec.Persistence == nil {
+		return true
+	}
+	policy := instance.Spec.Persistence.VolumeReclaimPolicy
+	if dataLog := instance.DataLog(); dataLog != nil && strings.HasPrefix(name, zk.DataLogVolume+"-") {
+		policy = dataLog.VolumeReclaimPolicy
+	}
+	return policy == zookeeperv1.VolumeReclaimPolicyDelete
+}
+
+func (r *ZookeeperClusterReconciler) deletePVC(instance *zookeeperv1.ZookeeperCluster, pvcItem corev1.PersistentVolumeClaim, reason string) {
 	pvcDelete := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      pvcItem.Name,
@@ -792,15 +2369,41 @@ func (r *ZookeeperClusterReconciler) deletePVC(pvcItem corev1.PersistentVolumeCl
 	err := r.Client.Delete(context.TODO(), pvcDelete)
 	if err != nil {
 		r.Log.Error(err, "Error deleteing PVC.", "Name", pvcDelete.Name)
+		return
 	}
+	pvcDeletions.WithLabelValues(instance.Namespace, instance.Name, reason).Inc()
+	message := fmt.Sprintf("Deleted PVC %s of a removed member", pvcItem.Name)
+	if reason == pvcClusterDelete {
+		message = fmt.Sprintf("Deleted PVC %s of the deleted cluster", pvcItem.Name)
+	}
+	r.recordEvent(instance, corev1.EventTypeNormal, PVCDeletedEvent, message)
 }
 
 func (r *ZookeeperClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&zookeeperv1beta1.ZookeeperCluster{}).
+		For(&zookeeperv1.ZookeeperCluster{}).
 		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.Pod{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{},
+			annotationChangedPredicate(zookeeperv1.AllowUpgradeAnnotation, zookeeperv1.PausedAnnotation))).
 		Complete(r)
 }
+
+// annotationChangedPredicate lets the updates changing one of the given
+// annotations through, which do not change the generation of the object
+func annotationChangedPredicate(keys ...string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			for _, key := range keys {
+				if e.ObjectOld.GetAnnotations()[key] != e.ObjectNew.GetAnnotations()[key] {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
@@ -12,22 +12,44 @@ package controllers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	"github.com/pravega/zookeeper-operator/pkg/controller/config"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
 	"github.com/pravega/zookeeper-operator/pkg/zk"
 
 	. "github.com/onsi/ginkgo"
@@ -40,15 +62,48 @@ func TestZookeepercluster(t *testing.T) {
 }
 
 type MockZookeeperClient struct {
-	// dummy struct
+	tlsConfig  *tls.Config
+	user       string
+	password   string
+	nodes      map[string]string
+	acls       map[string][]zk.ACL
+	containers map[string]bool
+	config     *zk.DynamicConfig
+	// stuck keeps the servers in the config when they are removed
+	stuck bool
+}
+
+func (client *MockZookeeperClient) Connect(zkUri string, tlsConfig *tls.Config) (err error) {
+	client.tlsConfig = tlsConfig
+	return nil
+}
+
+// staleConfigZookeeperClient fails to reconfigure the ensemble, as if the
+// config changed since it was read
+type staleConfigZookeeperClient struct {
+	*MockZookeeperClient
+}
+
+func (client *staleConfigZookeeperClient) IncrementalReconfig(joining []string, leaving []string, version int64) (err error) {
+	return fmt.Errorf("bad version %d", version)
 }
 
-func (client *MockZookeeperClient) Connect(zkUri string) (err error) {
-	// do nothing
+// failingZookeeperClient cannot connect to the cluster
+type failingZookeeperClient struct {
+	MockZookeeperClient
+}
+
+func (client *failingZookeeperClient) Connect(zkUri string, tlsConfig *tls.Config) (err error) {
+	return fmt.Errorf("connection refused")
+}
+
+func (client *MockZookeeperClient) Authenticate(user string, password string) (err error) {
+	client.user = user
+	client.password = password
 	return nil
 }
 
-func (client *MockZookeeperClient) CreateNode(zoo *v1beta1.ZookeeperCluster, zNodePath string) (err error) {
+func (client *MockZookeeperClient) CreateNode(zoo *api.ZookeeperCluster, zNodePath string) (err error) {
 	return nil
 }
 
@@ -56,14 +111,123 @@ func (client *MockZookeeperClient) UpdateNode(path string, data string, version
 	return nil
 }
 
+func (client *MockZookeeperClient) SetNode(path string, data string) (err error) {
+	if client.nodes == nil {
+		client.nodes = map[string]string{}
+	}
+	client.nodes[path] = data
+	return nil
+}
+
 func (client *MockZookeeperClient) NodeExists(zNodePath string) (version int32, err error) {
 	return 0, nil
 }
 
+func (client *MockZookeeperClient) GetNode(path string) (*zk.ZNode, error) {
+	data, ok := client.nodes[path]
+	if !ok {
+		return nil, nil
+	}
+	return &zk.ZNode{Data: data, ACL: client.acls[path]}, nil
+}
+
+func (client *MockZookeeperClient) CreateZNode(path string, data string, acl []zk.ACL, container bool) (err error) {
+	if client.acls == nil {
+		client.acls = map[string][]zk.ACL{}
+		client.containers = map[string]bool{}
+	}
+	client.containers[path] = container
+	client.acls[path] = acl
+	return client.SetNode(path, data)
+}
+
+func (client *MockZookeeperClient) SetACL(path string, acl []zk.ACL) (err error) {
+	client.acls[path] = acl
+	return nil
+}
+
+func (client *MockZookeeperClient) DeleteNode(path string) (err error) {
+	for p := range client.nodes {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(client.nodes, p)
+		}
+	}
+	return nil
+}
+
+func (client *MockZookeeperClient) GetConfig() (*zk.DynamicConfig, error) {
+	config := &zk.DynamicConfig{Servers: map[int32]string{}, Version: -1}
+	if client.config != nil {
+		config.Version = client.config.Version
+		for id, server := range client.config.Servers {
+			config.Servers[id] = server
+		}
+	}
+	return config, nil
+}
+
+func (client *MockZookeeperClient) Reconfig(members []string, version int64) (err error) {
+	return nil
+}
+
+func (client *MockZookeeperClient) IncrementalReconfig(joining []string, leaving []string, version int64) (err error) {
+	if client.config == nil || version != client.config.Version {
+		return fmt.Errorf("bad version %d", version)
+	}
+	if client.stuck {
+		return nil
+	}
+	for _, id := range leaving {
+		n, _ := strconv.Atoi(id)
+		delete(client.config.Servers, int32(n))
+	}
+	for _, server := range joining {
+		key, spec, _ := strings.Cut(server, "=")
+		n, _ := strconv.Atoi(strings.TrimPrefix(key, "server."))
+		client.config.Servers[int32(n)] = spec
+	}
+	client.config.Version++
+	return nil
+}
+
 func (client *MockZookeeperClient) Close() {
 	return
 }
 
+type MockAdminClient struct {
+	// states are the server states by pod name
+	states map[string]string
+	// zxids are the last processed zxids by pod name
+	zxids map[string]int64
+	// versions are the versions of the dynamic config by pod name
+	versions map[string]int64
+}
+
+func (client *MockAdminClient) ServerState(address string) (string, error) {
+	stats, err := client.ServerStats(address)
+	if err != nil {
+		return "", err
+	}
+	return stats.ServerState, nil
+}
+
+func (client *MockAdminClient) ServerStats(address string) (*zk.ServerStats, error) {
+	pod := strings.Split(address, ".")[0]
+	state, ok := client.states[pod]
+	if !ok {
+		return nil, fmt.Errorf("%s is not reachable", address)
+	}
+	return &zk.ServerStats{ServerState: state, LastProcessedZxid: client.zxids[pod]}, nil
+}
+
+func (client *MockAdminClient) ConfigVersion(address string) (int64, error) {
+	pod := strings.Split(address, ".")[0]
+	if _, ok := client.states[pod]; !ok {
+		return 0, fmt.Errorf("%s is not reachable", address)
+	}
+	return client.versions[pod], nil
+}
+
 var _ = Describe("ZookeeperCluster Controller", func() {
 	const (
 		Name      = "example"
@@ -80,7 +244,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 		var (
 			res reconcile.Result
 			req reconcile.Request
-			z   *v1beta1.ZookeeperCluster
+			z   *api.ZookeeperCluster
 		)
 
 		BeforeEach(func() {
@@ -90,13 +254,13 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 					Namespace: Namespace,
 				},
 			}
-			z = &v1beta1.ZookeeperCluster{
+			z = &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      Name,
 					Namespace: Namespace,
 				},
 			}
-			s.AddKnownTypes(v1beta1.GroupVersion, z)
+			s.AddKnownTypes(api.GroupVersion, z)
 		})
 
 		Context("Before defaults are applied", func() {
@@ -115,15 +279,16 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 				Ω(err).To(BeNil())
 			})
 
-			It("should set the default zk spec options", func() {
-				foundZk := &v1beta1.ZookeeperCluster{}
-				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
-				Ω(err).To(BeNil())
-				Ω(foundZk.Spec.Replicas).To(BeEquivalentTo(3))
+			It("should requeue after ReconcileTime delay", func() {
+				Ω(res.Requeue).To(BeFalse())
+				Ω(res.RequeueAfter).To(Equal(ReconcileTime))
 			})
 
-			It("should requeue the request", func() {
-				Ω(res.Requeue).To(BeTrue())
+			It("should create a stateful-set using the defaults", func() {
+				foundSts := &appsv1.StatefulSet{}
+				err = cl.Get(context.TODO(), req.NamespacedName, foundSts)
+				Ω(err).To(BeNil())
+				Ω(*foundSts.Spec.Replicas).To(BeEquivalentTo(3))
 			})
 		})
 
@@ -251,11 +416,74 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 
 		})
 
+		Context("Scaling down", func() {
+			var (
+				cl  client.Client
+				err error
+			)
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				z.Status.Init()
+				next := z.DeepCopy()
+				st := zk.MakeStatefulSet(z)
+				next.Spec.Replicas = 1
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, st).WithStatusSubresource(next).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				mockZkClient.config = &zk.DynamicConfig{
+					Servers: map[int32]string{
+						1:   "example-0:2888:3888:participant;0.0.0.0:2181",
+						2:   "example-1:2888:3888:participant;0.0.0.0:2181",
+						3:   "example-2:2888:3888:participant;0.0.0.0:2181",
+						129: "example-observer-0:2888:3888:observer;0.0.0.0:2181",
+					},
+					Version: 0x100000004,
+				}
+			})
+
+			AfterEach(func() {
+				mockZkClient.config = nil
+				mockZkClient.stuck = false
+			})
+
+			foundReplicas := func() int32 {
+				foundSts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundSts)).To(Succeed())
+				return *foundSts.Spec.Replicas
+			}
+
+			It("should remove the departing servers before shrinking the sts", func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(mockZkClient.config.Servers).To(HaveLen(2))
+				Ω(mockZkClient.config.Servers).To(HaveKey(int32(1)))
+				Ω(mockZkClient.config.Servers).To(HaveKey(int32(129)))
+				Ω(foundReplicas()).To(BeEquivalentTo(1))
+			})
+
+			It("should not shrink the sts until the servers left", func() {
+				mockZkClient.stuck = true
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).NotTo(BeNil())
+				Ω(err.Error()).To(ContainSubstring("still in the dynamic config"))
+				Ω(foundReplicas()).To(BeEquivalentTo(3))
+			})
+
+			It("should shrink the sts once the servers already left", func() {
+				delete(mockZkClient.config.Servers, 2)
+				delete(mockZkClient.config.Servers, 3)
+				mockZkClient.config.Version = -2
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(foundReplicas()).To(BeEquivalentTo(1))
+			})
+		})
+
 		Context("With update to ImagePullSecrets", func() {
 			var (
 				cl   client.Client
 				err  error
-				next *v1beta1.ZookeeperCluster
+				next *api.ZookeeperCluster
 				sa   *corev1.ServiceAccount
 			)
 
@@ -323,21 +551,21 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 
 			It("should set the upgrade condition to true", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				Ω(err).To(BeNil())
 				Ω(foundZookeeper.Status.IsClusterInUpgradingState()).To(BeTrue())
 			})
 
 			It("should set the target version", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				Ω(err).To(BeNil())
 				Ω(foundZookeeper.Status.TargetVersion).To(BeEquivalentTo("0.2.7"))
 			})
 
 			It("should set the target version", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 
 				Ω(err).To(BeNil())
@@ -375,7 +603,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 				st.Status.UpdateRevision = "complete"
 				cl.Status().Update(context.TODO(), st)
 				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				res, err = r.Reconcile(context.TODO(), req)
 				res, err = r.Reconcile(context.TODO(), req)
@@ -387,14 +615,14 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 
 			It("should set the currentversion to Image.tag", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				Ω(err).To(BeNil())
 				Ω(foundZookeeper.Status.CurrentVersion).To(BeEquivalentTo("0.2.7"))
 			})
 
 			It("should set the target version to empty", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				Ω(err).To(BeNil())
 				Ω(foundZookeeper.Status.TargetVersion).To(BeEquivalentTo(""))
@@ -432,9 +660,9 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 
 			It("checking update replicas", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
-				_, condition := foundZookeeper.Status.GetClusterCondition(v1beta1.ClusterConditionUpgrading)
+				_, condition := foundZookeeper.Status.GetClusterCondition(api.ClusterConditionUpgrading)
 				Ω(condition.Message).To(Equal("2"))
 			})
 
@@ -460,7 +688,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 				next.Spec.Replicas = 3
 				next.Spec.Image.Tag = "0.2.7"
 				st := zk.MakeStatefulSet(z)
-				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, st).Build()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, st).WithStatusSubresource(next).Build()
 				st = &appsv1.StatefulSet{}
 				err = cl.Get(context.TODO(), req.NamespacedName, st)
 				// changing the Revision value to simulate the upgrade scenario
@@ -478,7 +706,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 
 			It("checking update replicas", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				condition := foundZookeeper.Status.CurrentVersion
 				Ω(condition).To(Equal("0.2.7"))
@@ -489,6 +717,286 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 		})
 
+		Context("automatic rollback", func() {
+			var (
+				cl      client.Client
+				err     error
+				foundZk *api.ZookeeperCluster
+			)
+
+			member := func(ord int, revision string, ready bool) *corev1.Pod {
+				status := corev1.ConditionTrue
+				if !ready {
+					status = corev1.ConditionFalse
+				}
+				return &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("example-%d", ord),
+						Namespace: Namespace,
+						Labels: map[string]string{
+							"app":                                 "example",
+							appsv1.ControllerRevisionHashLabelKey: revision,
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+					},
+				}
+			}
+
+			getSts := func() *appsv1.StatefulSet {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				return sts
+			}
+
+			setStsStatus := func(current, update string, updated, ready int32) {
+				sts := getSts()
+				sts.Status.ObservedGeneration = sts.Generation
+				sts.Status.CurrentRevision = current
+				sts.Status.UpdateRevision = update
+				sts.Status.Replicas = 3
+				sts.Status.UpdatedReplicas = updated
+				sts.Status.ReadyReplicas = ready
+				Ω(cl.Status().Update(context.TODO(), sts)).To(BeNil())
+			}
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			BeforeEach(func() {
+				z.Spec.UpgradePolicy = &api.UpgradePolicy{AutoRollback: true}
+				z.Spec.Image.Tag = "0.2.7"
+				z.WithDefaults()
+				z.Status.Init()
+				z.Status.CurrentVersion = "0.2.6"
+				z.Status.TargetVersion = "0.2.7"
+				z.Status.SetUpgradingConditionTrue(api.UpdatingZookeeperReason, "1")
+				// no progress for longer than the deadline
+				z.Status.Conditions[1].LastTransitionTime = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+			})
+
+			build := func(objs ...runtime.Object) {
+				objs = append(objs, z, zk.MakeStatefulSet(z))
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			}
+
+			It("should record the failed upgrade and revert the image", func() {
+				build(member(0, "v1", true), member(1, "v1", true), member(2, "v2", false))
+				setStsStatus("v1", "v2", 1, 2)
+				reconcileAndGet()
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+				Ω(foundZk.Status.UpgradeHistory).To(HaveLen(1))
+				record := foundZk.Status.UpgradeHistory[0]
+				Ω(record.From).To(Equal("0.2.6"))
+				Ω(record.To).To(Equal("0.2.7"))
+				Ω(record.Result).To(Equal(api.UpgradeRolledBack))
+				Ω(record.Reason).To(Equal("progress deadline exceeded"))
+
+				reconcileAndGet()
+				Ω(getSts().Spec.Template.Spec.Containers[0].Image).To(HaveSuffix(":0.2.6"))
+				// the StatefulSet controller goes back to the former revision
+				setStsStatus("v1", "v1", 2, 2)
+				reconcileAndGet()
+				err = cl.Get(context.TODO(), types.NamespacedName{Name: "example-2", Namespace: Namespace}, &corev1.Pod{})
+				Ω(errors.IsNotFound(err)).To(BeTrue())
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+
+				setStsStatus("v1", "v1", 3, 3)
+				reconcileAndGet()
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+				Ω(foundZk.Status.IsClusterInUpgradingState()).To(BeFalse())
+				Ω(foundZk.Status.CurrentVersion).To(Equal("0.2.6"))
+				Ω(foundZk.Status.TargetVersion).To(BeEmpty())
+			})
+
+			It("should not try the failed version again", func() {
+				z.Status.SetUpgradingConditionFalse()
+				z.Status.TargetVersion = ""
+				z.Status.SetPodsReadyConditionTrue()
+				z.Status.RecordUpgrade("0.2.6", "0.2.7", api.UpgradeRolledBack, "progress deadline exceeded")
+				build()
+				setStsStatus("v1", "v2", 0, 3)
+				reconcileAndGet()
+				Ω(foundZk.Status.IsClusterInUpgradingState()).To(BeFalse())
+				Ω(getSts().Spec.Template.Spec.Containers[0].Image).To(HaveSuffix(":0.2.6"))
+			})
+
+			It("should wait for a fix without automatic rollback", func() {
+				z.Spec.UpgradePolicy = nil
+				build()
+				setStsStatus("v1", "v2", 1, 2)
+				reconcileAndGet()
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+				Ω(foundZk.Status.UpgradeHistory[0].Result).To(Equal(api.UpgradeFailed))
+				reconcileAndGet()
+				Ω(getSts().Spec.Template.Spec.Containers[0].Image).To(HaveSuffix(":0.2.7"))
+			})
+		})
+
+		Context("upgrade policy", func() {
+			var (
+				cl       client.Client
+				err      error
+				foundZk  *api.ZookeeperCluster
+				recorder *record.FakeRecorder
+			)
+
+			BeforeEach(func() {
+				z.Spec.UpgradePolicy = &api.UpgradePolicy{}
+				z.Spec.Image.Tag = "0.2.7"
+				z.WithDefaults()
+				z.Status.Init()
+				z.Status.CurrentVersion = "0.2.6"
+				z.Status.TargetVersion = "0.2.7"
+				z.Status.SetUpgradingConditionTrue(api.UpdatingZookeeperReason, "1")
+				// no progress for the last 11 minutes
+				z.Status.Conditions[1].LastTransitionTime = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+			})
+
+			reconcileUpgrade := func(updated, ready int32) {
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z, zk.MakeStatefulSet(z)).WithStatusSubresource(z).Build()
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				sts.Status.CurrentRevision = "v1"
+				sts.Status.UpdateRevision = "v2"
+				sts.Status.Replicas = 3
+				sts.Status.UpdatedReplicas = updated
+				sts.Status.ReadyReplicas = ready
+				Ω(cl.Status().Update(context.TODO(), sts)).To(BeNil())
+				recorder = record.NewFakeRecorder(10)
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient, Recorder: recorder}
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			upgradingReason := func() string {
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionUpgrading)
+				return condition.Reason
+			}
+
+			It("should hold the upgrade once the canary is ready", func() {
+				canary := int32(1)
+				z.Spec.UpgradePolicy.Canary = &canary
+				reconcileUpgrade(1, 3)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+				Ω(upgradingReason()).To(Equal(api.CanaryUpgradedReason))
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				Ω(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(2))
+			})
+
+			It("should fail a canary which is not ready", func() {
+				canary := int32(1)
+				z.Spec.UpgradePolicy.Canary = &canary
+				reconcileUpgrade(1, 2)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+			})
+
+			It("should never fail a paused upgrade", func() {
+				z.Spec.UpgradePolicy.Paused = true
+				reconcileUpgrade(1, 2)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+				Ω(upgradingReason()).To(Equal(api.UpgradePausedReason))
+			})
+
+			It("should go on once the upgrade is resumed", func() {
+				z.Status.SetUpgradingConditionTrue(api.CanaryUpgradedReason, "1")
+				z.Status.Conditions[1].LastTransitionTime = metav1.NewTime(time.Now().Add(-11 * time.Minute))
+				reconcileUpgrade(1, 3)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+				Ω(upgradingReason()).To(Equal(api.UpdatingZookeeperReason))
+				Eventually(recorder.Events).Should(Receive(Equal("Normal UpgradeProgressing 1 of 3 members upgraded to 0.2.7")))
+			})
+
+			It("should wait for the progress deadline of the policy", func() {
+				z.Spec.UpgradePolicy.ProgressDeadlineSeconds = 3600
+				reconcileUpgrade(1, 2)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeFalse())
+			})
+
+			It("should fail after the default progress deadline", func() {
+				reconcileUpgrade(1, 2)
+				Ω(foundZk.Status.IsClusterInUpgradeFailedState()).To(BeTrue())
+				Eventually(recorder.Events).Should(Receive(Equal("Warning UpgradeFailed Upgrade from 0.2.6 to 0.2.7 failed: progress deadline exceeded")))
+			})
+		})
+
+		Context("refused upgrade", func() {
+			var (
+				cl      client.Client
+				err     error
+				foundZk *api.ZookeeperCluster
+			)
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				z.Status.Init()
+				z.Status.SetPodsReadyConditionTrue()
+				z.Status.CurrentVersion = "3.6.4"
+				z.Spec.Image.Tag = "3.8.4"
+			})
+
+			reconcileUpgrade := func() {
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				for i := 0; i < 2; i++ {
+					_, err = r.Reconcile(context.TODO(), req)
+					Ω(err).To(BeNil())
+				}
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			stsImage := func() string {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				return sts.Spec.Template.Spec.Containers[0].Image
+			}
+
+			It("should keep the current version and say why", func() {
+				reconcileUpgrade()
+				Ω(stsImage()).To(HaveSuffix(":3.6.4"))
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionUpgrading)
+				Ω(condition.Status).To(Equal(metav1.ConditionFalse))
+				Ω(condition.Reason).To(Equal(api.UpgradeRefusedReason))
+				Ω(condition.Message).To(Equal("upgrade from 3.6.4 to 3.8.4 skips minor releases, upgrade to 3.7 first"))
+			})
+
+			It("should upgrade with the override annotation", func() {
+				z.Annotations = map[string]string{api.AllowUpgradeAnnotation: "3.8.4"}
+				reconcileUpgrade()
+				Ω(stsImage()).To(HaveSuffix(":3.8.4"))
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionUpgrading)
+				Ω(condition.Reason).NotTo(Equal(api.UpgradeRefusedReason))
+			})
+
+			It("should reconcile when the override annotation is added", func() {
+				allow := annotationChangedPredicate(api.AllowUpgradeAnnotation, api.PausedAnnotation)
+				annotated := z.DeepCopy()
+				annotated.Annotations = map[string]string{api.AllowUpgradeAnnotation: "3.8.4"}
+				Ω(predicate.GenerationChangedPredicate{}.Update(event.UpdateEvent{ObjectOld: z, ObjectNew: annotated})).To(BeFalse())
+				Ω(allow.Update(event.UpdateEvent{ObjectOld: z, ObjectNew: annotated})).To(BeTrue())
+				Ω(allow.Update(event.UpdateEvent{ObjectOld: annotated, ObjectNew: annotated.DeepCopy()})).To(BeFalse())
+			})
+
+			It("should clear the condition once the tag is fixed", func() {
+				z.Status.SetUpgradeRefused("refused")
+				z.Spec.Image.Tag = "3.7.2"
+				reconcileUpgrade()
+				Ω(stsImage()).To(HaveSuffix(":3.7.2"))
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionUpgrading)
+				Ω(condition.Reason).To(Equal(api.NotUpgradingReason))
+			})
+		})
+
 		Context("Upgrading with Targetversion empty", func() {
 			var (
 				cl  client.Client
@@ -513,7 +1021,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 				Ω(err).To(BeNil())
 			})
 			It("should set the upgrade condition to false", func() {
-				foundZookeeper := &v1beta1.ZookeeperCluster{}
+				foundZookeeper := &api.ZookeeperCluster{}
 				_ = cl.Get(context.TODO(), req.NamespacedName, foundZookeeper)
 				Ω(foundZookeeper.Status.IsClusterInUpgradingState()).To(Equal(false))
 			})
@@ -555,7 +1063,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 
 			It("should not raise an error", func() {
-				err = mockZkClient.Connect("127.0.0.0:2181")
+				err = mockZkClient.Connect("127.0.0.0:2181", nil)
 				Ω(err).To(BeNil())
 			})
 			It("should not raise an error", func() {
@@ -581,7 +1089,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 			It("should not raise an error", func() {
 				_ = cl.Get(context.TODO(), req.NamespacedName, z)
-				z.Spec.Persistence.VolumeReclaimPolicy = v1beta1.VolumeReclaimPolicyDelete
+				z.Spec.Persistence.VolumeReclaimPolicy = api.VolumeReclaimPolicyDelete
 				cl.Update(context.TODO(), z)
 				err = r.reconcileFinalizers(z)
 				Ω(err).To(BeNil())
@@ -595,8 +1103,8 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 					},
 				}
 				r.Client.Create(context.TODO(), pvcDelete)
-				r.deletePVC(*pvcDelete)
-				r.deletePVC(*pvcDelete)
+				r.deletePVC(z, *pvcDelete, pvcOrphaned)
+				r.deletePVC(z, *pvcDelete, pvcOrphaned)
 			})
 
 			It("should not raise an error", func() {
@@ -611,6 +1119,46 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			})
 		})
 
+		Context("Connecting with client TLS", func() {
+			var (
+				cl  client.Client
+				err error
+			)
+
+			BeforeEach(func() {
+				z.Spec.TLS = &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}}
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			})
+
+			It("should fail without the secret", func() {
+				err = r.connectZk(z, "example-client:2281")
+				Ω(err).NotTo(BeNil())
+			})
+
+			It("should connect over TLS with the secret", func() {
+				key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour), IsCA: true}
+				der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+				keyDer, _ := x509.MarshalPKCS8PrivateKey(key)
+				cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "example-tls", Namespace: Namespace},
+					Data: map[string][]byte{
+						"tls.crt": cert,
+						"tls.key": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}),
+						"ca.crt":  cert,
+					},
+				}
+				Ω(cl.Create(context.TODO(), secret)).To(Succeed())
+				err = r.connectZk(z, "example-client:2281")
+				Ω(err).To(BeNil())
+				Ω(mockZkClient.tlsConfig).NotTo(BeNil())
+				Ω(mockZkClient.tlsConfig.ServerName).To(Equal("example-client.default.svc.cluster.local"))
+			})
+		})
+
 		Context("With an update to the client svc", func() {
 			var (
 				cl  client.Client
@@ -620,7 +1168,7 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 			BeforeEach(func() {
 				z.WithDefaults()
 				next := z.DeepCopy()
-				next.Spec.Ports[0].ContainerPort = 2182
+				next.Spec.Ports.Client = 2182
 				svc := zk.MakeClientService(z)
 				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, svc).WithStatusSubresource(next).Build()
 				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
@@ -728,87 +1276,1546 @@ var _ = Describe("ZookeeperCluster Controller", func() {
 
 		Context("trigger rolling restart", func() {
 			var (
-				cl      client.Client
-				err     error
-				foundZk = &v1beta1.ZookeeperCluster{}
-				next    *v1beta1.ZookeeperCluster
-				svc     *corev1.Service
+				cl       client.Client
+				err      error
+				foundZk  = &api.ZookeeperCluster{}
+				foundSts = &appsv1.StatefulSet{}
+				next     *api.ZookeeperCluster
 			)
 			BeforeEach(func() {
 				z.WithDefaults()
 				next = z.DeepCopy()
-				next.Spec.TriggerRollingRestart = true
-				svc = zk.MakeClientService(z)
-				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, svc).WithStatusSubresource(next).Build()
+				next.Spec.RestartTrigger = "1"
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next).WithStatusSubresource(next).Build()
 				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
 				res, err = r.Reconcile(context.TODO(), req)
-				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
+				Ω(err).To(BeNil())
+				err = cl.Get(context.TODO(), req.NamespacedName, foundSts)
 			})
 
-			It("should update restartTime annotation and reset triggerRollingRestart to false when triggerRollingRestart is set to true", func() {
-				Ω(res.Requeue).To(Equal(true))
+			It("should copy the restart trigger to the pod template", func() {
 				Ω(err).To(BeNil())
-				Ω(foundZk.Spec.TriggerRollingRestart).To(Equal(false))
-				_, restartTimeExists := foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
+				Ω(foundSts.Spec.Template.Annotations).To(HaveKeyWithValue(api.RestartTriggerAnnotation, "1"))
 			})
 
-			It("should not update restartTime annotation when set triggerRollingRestart to false", func() {
-				_, restartTimeExists := foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
+			It("should not modify the restart trigger", func() {
+				Ω(res.Requeue).To(Equal(false))
+				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
+				Ω(err).To(BeNil())
+				Ω(foundZk.Spec.RestartTrigger).To(Equal("1"))
+				Ω(foundZk.Spec.Pod.Annotations).NotTo(HaveKey(api.RestartTriggerAnnotation))
+			})
 
-				next.Spec.TriggerRollingRestart = false
-				svc = zk.MakeClientService(z)
-				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, svc).WithStatusSubresource(next).Build()
-				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			It("should update the pod template when the restart trigger changes", func() {
+				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
+				Ω(err).To(BeNil())
+				foundZk.Spec.RestartTrigger = "2"
+				err = cl.Update(context.TODO(), foundZk)
+				Ω(err).To(BeNil())
 				res, err = r.Reconcile(context.TODO(), req)
-
-				Ω(res.Requeue).To(Equal(false))
 				Ω(err).To(BeNil())
-				Ω(foundZk.Spec.TriggerRollingRestart).To(Equal(false))
-				_, restartTimeExists = foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
+				err = cl.Get(context.TODO(), req.NamespacedName, foundSts)
+				Ω(err).To(BeNil())
+				Ω(foundSts.Spec.Template.Annotations).To(HaveKeyWithValue(api.RestartTriggerAnnotation, "2"))
 			})
+		})
+
+		Context("configuration changes", func() {
+			var (
+				cl      client.Client
+				err     error
+				foundZk *api.ZookeeperCluster
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
 
-			It("should update restartTime annotation to new value when rolling restart is triggered multiple times", func() {
-				oldRestartValue, restartTimeExists := foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
+			configHash := func() string {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				return sts.Spec.Template.Annotations[api.ConfigHashAnnotation]
+			}
 
-				// wait 1 second to ensure that restartTime, if set, will have a different value
-				time.Sleep(1 * time.Second)
+			changeConfig := func(change func(z *api.ZookeeperCluster)) {
+				change(foundZk)
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+			}
 
-				// update the crd instance
-				next.Spec.TriggerRollingRestart = false
-				svc = zk.MakeClientService(z)
+			BeforeEach(func() {
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
 				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
-				res, err = r.Reconcile(context.TODO(), req)
-				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
+				reconcileAndGet()
+			})
 
-				// check that restartTime was not updated
-				Ω(res.Requeue).To(Equal(false))
+			It("should start the members with the hash of the configuration", func() {
+				cm := &corev1.ConfigMap{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: z.ConfigMapName(), Namespace: Namespace}, cm)).To(BeNil())
+				Ω(configHash()).To(Equal(zk.ConfigMapHash(cm)))
+				Ω(foundZk.Status.Config.Hash).To(Equal(configHash()))
+				Ω(foundZk.Status.Config.RestartPending).To(BeFalse())
+			})
+
+			It("should roll the members when the configuration changes", func() {
+				hash := configHash()
+				changeConfig(func(z *api.ZookeeperCluster) { z.Spec.Conf.SnapCount = 20000 })
+				Ω(configHash()).NotTo(Equal(hash))
+				Ω(configHash()).To(Equal(foundZk.Status.Config.Hash))
+				Ω(foundZk.Status.Config.RestartPending).To(BeFalse())
+			})
+
+			It("should wait for the restart trigger with the manual restart policy", func() {
+				hash := configHash()
+				changeConfig(func(z *api.ZookeeperCluster) {
+					z.Spec.Conf.RestartPolicy = api.ConfigRestartManual
+					z.Spec.Conf.SnapCount = 20000
+				})
+				Ω(configHash()).To(Equal(hash))
+				Ω(foundZk.Status.Config.RolledOutHash).To(Equal(hash))
+				Ω(foundZk.Status.Config.Hash).NotTo(Equal(hash))
+				Ω(foundZk.Status.Config.RestartPending).To(BeTrue())
+
+				changeConfig(func(z *api.ZookeeperCluster) { z.Spec.RestartTrigger = "1" })
+				Ω(configHash()).To(Equal(foundZk.Status.Config.Hash))
+				Ω(foundZk.Status.Config.RestartPending).To(BeFalse())
+			})
+
+			It("should roll the members when a TLS secret rotates", func() {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				Ω(sts.Spec.Template.Annotations).NotTo(HaveKey(api.TLSSecretsHashAnnotation))
+
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "example-tls", Namespace: Namespace},
+					Data:       map[string][]byte{"tls.crt": []byte("first")},
+				}
+				Ω(cl.Create(context.TODO(), secret)).To(Succeed())
+				foundZk.Spec.TLS = &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}}
+				Ω(r.reconcileTLSSecrets(foundZk)).To(Succeed())
+				hash := zk.MakeStatefulSet(foundZk).Spec.Template.Annotations[api.TLSSecretsHashAnnotation]
+				Ω(hash).NotTo(BeEmpty())
+				Ω(r.reconcileTLSSecrets(foundZk)).To(Succeed())
+				Ω(zk.MakeStatefulSet(foundZk).Spec.Template.Annotations).To(HaveKeyWithValue(api.TLSSecretsHashAnnotation, hash))
+
+				secret.Data["tls.crt"] = []byte("renewed")
+				Ω(cl.Update(context.TODO(), secret)).To(Succeed())
+				Ω(r.reconcileTLSSecrets(foundZk)).To(Succeed())
+				Ω(zk.MakeStatefulSet(foundZk).Spec.Template.Annotations[api.TLSSecretsHashAnnotation]).NotTo(Equal(hash))
+			})
+		})
+
+		Context("paused cluster", func() {
+			var (
+				cl       client.Client
+				err      error
+				foundZk  *api.ZookeeperCluster
+				recorder *record.FakeRecorder
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
 				Ω(err).To(BeNil())
-				Ω(foundZk.Spec.TriggerRollingRestart).To(Equal(false))
-				_, restartTimeExists = foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			update := func(change func(z *api.ZookeeperCluster)) {
+				change(foundZk)
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+			}
 
-				// wait 1 second to ensure that restartTime, if set, will have a different value
-				time.Sleep(1 * time.Second)
+			stsReplicas := func() int32 {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				return *sts.Spec.Replicas
+			}
 
-				// update the crd instance to trigger rolling restart
-				next.Spec.TriggerRollingRestart = true
-				svc = zk.MakeClientService(z)
-				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(next, svc).WithStatusSubresource(next).Build()
-				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
-				res, err = r.Reconcile(context.TODO(), req)
-				err = cl.Get(context.TODO(), req.NamespacedName, foundZk)
+			pausedCondition := func() *metav1.Condition {
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionPaused)
+				return condition
+			}
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				recorder = record.NewFakeRecorder(10)
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient, Recorder: recorder}
+				reconcileAndGet()
+				Ω(recorder.Events).To(Receive(ContainSubstring(StatefulSetCreatedEvent)))
+			})
+
+			It("should leave the resources alone", func() {
+				update(func(z *api.ZookeeperCluster) {
+					z.Spec.Paused = true
+					z.Spec.Replicas = 5
+				})
+				Ω(stsReplicas()).To(BeEquivalentTo(3))
+				Ω(pausedCondition().Status).To(Equal(metav1.ConditionTrue))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Warning Paused")))
+			})
+
+			It("should record the pause once", func() {
+				update(func(z *api.ZookeeperCluster) { z.Spec.Paused = true })
+				reconcileAndGet()
+				Ω(recorder.Events).To(HaveLen(1))
+			})
+
+			It("should reconcile again once resumed", func() {
+				update(func(z *api.ZookeeperCluster) {
+					z.Annotations = map[string]string{api.PausedAnnotation: "true"}
+					z.Spec.Replicas = 5
+				})
+				Ω(stsReplicas()).To(BeEquivalentTo(3))
+				update(func(z *api.ZookeeperCluster) { z.Annotations = nil })
+				Ω(stsReplicas()).To(BeEquivalentTo(5))
+				Ω(pausedCondition().Status).To(Equal(metav1.ConditionFalse))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Warning Paused")))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal Resumed")))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal ScalingUp")))
+				// scaling leaves the configuration hash alone
+				Ω(recorder.Events).To(BeEmpty())
+			})
+
+			It("should only pause the given subsystems", func() {
+				update(func(z *api.ZookeeperCluster) {
+					z.Spec.PausedSubsystems = []api.Subsystem{api.SubsystemStatefulSet}
+					z.Spec.Replicas = 5
+					z.Spec.Conf.SnapCount = 20000
+				})
+				Ω(stsReplicas()).To(BeEquivalentTo(3))
+				cm := &corev1.ConfigMap{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: z.ConfigMapName(), Namespace: Namespace}, cm)).To(BeNil())
+				Ω(cm.Data["zoo.cfg"]).To(ContainSubstring("snapCount=20000"))
+				Ω(pausedCondition().Message).To(ContainSubstring("StatefulSet"))
+			})
+
+			It("should keep the volume claims of a deleted cluster while their cleanup is paused", func() {
+				now := metav1.Now()
+				foundZk.Annotations = map[string]string{api.PausedAnnotation: "PVCCleanup"}
+				foundZk.Finalizers = []string{utils.ZkFinalizer}
+				foundZk.DeletionTimestamp = &now
+				Ω(r.reconcileFinalizers(foundZk)).To(BeNil())
+				Ω(foundZk.Finalizers).To(ContainElement(utils.ZkFinalizer))
+			})
+		})
+
+		Context("events", func() {
+			var (
+				cl       client.Client
+				recorder *record.FakeRecorder
+			)
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				recorder = record.NewFakeRecorder(10)
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient, Recorder: recorder}
+			})
 
-				// check that restartTime was updated
-				Ω(res.Requeue).To(Equal(true))
+			It("should record the creation of the StatefulSet", func() {
+				_, err := r.Reconcile(context.TODO(), req)
 				Ω(err).To(BeNil())
-				Ω(foundZk.Spec.TriggerRollingRestart).To(Equal(false))
-				newRestartValue, restartTimeExists := foundZk.Spec.Pod.Annotations["restartTime"]
-				Ω(restartTimeExists).To(Equal(true))
-				Ω(oldRestartValue).NotTo(Equal(newRestartValue))
+				Ω(recorder.Events).To(Receive(Equal("Normal StatefulSetCreated Created StatefulSet example with 3 members")))
+			})
+
+			It("should record the creation of the metadata znode", func() {
+				z.Status.ReadyReplicas = 3
+				Ω(r.reconcileClusterStatus(z)).To(BeNil())
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal MetadataZnodeCreated")))
+			})
+
+			It("should record a connection error once", func() {
+				r.ZkClient = &failingZookeeperClient{}
+				Ω(r.connectZk(z, "example-client:2181")).NotTo(BeNil())
+				Ω(r.connectZk(z, "example-client:2181")).NotTo(BeNil())
+				Ω(recorder.Events).To(Receive(ContainSubstring("Warning ZookeeperConnectError")))
+				Ω(recorder.Events).To(BeEmpty())
+			})
+
+			It("should record the deletion of an orphan volume claim", func() {
+				pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-example-3", Namespace: Namespace}}
+				Ω(cl.Create(context.TODO(), pvc)).To(BeNil())
+				r.deletePVC(z, *pvc, pvcOrphaned)
+				Ω(recorder.Events).To(Receive(Equal("Normal PVCDeleted Deleted PVC data-example-3 of a removed member")))
+			})
+
+			It("should record the restart of the members", func() {
+				foundSts := zk.MakeStatefulSet(z)
+				z.Spec.RestartTrigger = "2021-01-01T00:00:00Z"
+				r.recordRestart(z, foundSts, zk.MakeStatefulSet(z))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal RollingRestart")))
+				r.recordRestart(z, zk.MakeStatefulSet(z), zk.MakeStatefulSet(z))
+				Ω(recorder.Events).To(BeEmpty())
+			})
+
+			It("should record an event again after the window", func() {
+				c := &eventCache{}
+				now := time.Now()
+				Ω(c.record("key", now)).To(BeTrue())
+				Ω(c.record("key", now.Add(ReconcileTime))).To(BeFalse())
+				Ω(c.record("key", now.Add(EventDedupWindow))).To(BeTrue())
+			})
+
+			It("should record an event again once forgotten", func() {
+				r.recordEvent(z, corev1.EventTypeNormal, UpgradeStartedEvent, "Upgrading from 3.8.3 to 3.8.4")
+				r.events.forget(z, UpgradeStartedEvent)
+				r.recordEvent(z, corev1.EventTypeNormal, UpgradeStartedEvent, "Upgrading from 3.8.3 to 3.8.4")
+				Ω(recorder.Events).To(HaveLen(2))
+			})
+		})
+
+		Context("health", func() {
+			var (
+				cl          client.Client
+				recorder    *record.FakeRecorder
+				adminClient *MockAdminClient
+			)
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				recorder = record.NewFakeRecorder(10)
+				adminClient = &MockAdminClient{
+					states: map[string]string{
+						"example-0": "follower",
+						"example-1": "leader",
+						"example-2": "follower",
+					},
+					zxids: map[string]int64{
+						"example-0": 0x200000005,
+						"example-1": 0x200000005,
+						"example-2": 0x200000004,
+					},
+					versions: map[string]int64{
+						"example-0": 0x100000002,
+						"example-1": 0x100000002,
+						"example-2": 0x100000002,
+					},
+				}
+				objs := []runtime.Object{z}
+				for i := 0; i < 3; i++ {
+					objs = append(objs, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("example-%d", i),
+						Namespace: Namespace,
+						Labels:    map[string]string{"app": "example"},
+					}})
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient, AdminClient: adminClient, Recorder: recorder}
+			})
+
+			reconcileHealth := func() *api.ZookeeperCluster {
+				foundZk := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundZk.WithDefaults()
+				Ω(r.reconcileHealth(foundZk)).To(BeNil())
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				return foundZk
+			}
+
+			quorumHealthy := func(z *api.ZookeeperCluster) *metav1.Condition {
+				_, condition := z.Status.GetClusterCondition(api.ClusterConditionQuorumHealthy)
+				Ω(condition).NotTo(BeNil())
+				return condition
+			}
+
+			It("should record what the members report", func() {
+				foundZk := reconcileHealth()
+				health := foundZk.Status.Health
+				Ω(health).NotTo(BeNil())
+				Ω(health.Leader).To(Equal("example-1"))
+				Ω(health.LastProbeTime).NotTo(BeNil())
+				Ω(health.Members).To(HaveLen(3))
+				Ω(health.Members[0].Name).To(Equal("example-0"))
+				Ω(health.Members[0].Role).To(Equal("follower"))
+				Ω(health.Members[0].Zxid).To(Equal("0x200000005"))
+				Ω(health.Members[0].Epoch).To(BeEquivalentTo(2))
+				Ω(health.Members[0].ConfigVersion).To(Equal("0x100000002"))
+				Ω(quorumHealthy(foundZk).Status).To(Equal(metav1.ConditionTrue))
+				Ω(foundZk.Status.IsQuorumHealthy()).To(BeTrue())
+			})
+
+			It("should only write the status when the members report a change", func() {
+				foundZk := reconcileHealth()
+				version := foundZk.ResourceVersion
+				Ω(reconcileHealth().ResourceVersion).To(Equal(version))
+				adminClient.zxids["example-2"] = 0x200000005
+				foundZk = reconcileHealth()
+				Ω(foundZk.ResourceVersion).NotTo(Equal(version))
+				Ω(foundZk.Status.Health.Members[2].Zxid).To(Equal("0x200000005"))
+			})
+
+			It("should not be healthy while the ensemble is split", func() {
+				adminClient.states["example-2"] = "leader"
+				foundZk := reconcileHealth()
+				Ω(foundZk.Status.Health.Leader).To(BeEmpty())
+				Ω(quorumHealthy(foundZk).Status).To(Equal(metav1.ConditionFalse))
+				Ω(quorumHealthy(foundZk).Reason).To(Equal(api.MultipleLeadersReason))
+			})
+
+			It("should not be healthy without a majority following the leader", func() {
+				delete(adminClient.states, "example-0")
+				adminClient.zxids["example-2"] = 0x100000009
+				foundZk := reconcileHealth()
+				Ω(foundZk.Status.Health.Members[0].Error).To(ContainSubstring("not reachable"))
+				Ω(quorumHealthy(foundZk).Reason).To(Equal(api.NoQuorumReason))
+			})
+
+			It("should not be healthy while the voting members disagree on the dynamic config", func() {
+				adminClient.versions["example-2"] = 0x200000003
+				foundZk := reconcileHealth()
+				Ω(foundZk.Status.Health.Members[2].ConfigVersion).To(Equal("0x200000003"))
+				Ω(quorumHealthy(foundZk).Status).To(Equal(metav1.ConditionFalse))
+				Ω(quorumHealthy(foundZk).Reason).To(Equal(api.ConfigVersionMismatchReason))
+			})
+
+			It("should record when the quorum is lost and restored", func() {
+				reconcileHealth()
+				Ω(recorder.Events).To(BeEmpty())
+				adminClient.states["example-1"] = "looking"
+				Ω(quorumHealthy(reconcileHealth()).Reason).To(Equal(api.NoLeaderReason))
+				Ω(recorder.Events).To(Receive(Equal("Warning QuorumLost No voting member reports it is the leader")))
+				adminClient.states["example-1"] = "leader"
+				reconcileHealth()
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal QuorumRestored")))
+			})
+
+			It("should not probe the members without an admin client", func() {
+				r.AdminClient = nil
+				foundZk := reconcileHealth()
+				Ω(foundZk.Status.Health).To(BeNil())
+			})
+		})
+
+		Context("dynamic config", func() {
+			var (
+				cl       client.Client
+				recorder *record.FakeRecorder
+				zkClient *MockZookeeperClient
+			)
+
+			server := func(ord int) string {
+				return fmt.Sprintf("example-%d.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181", ord)
+			}
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				z.Status.Init()
+				z.Status.SetQuorumHealthyCondition(true, api.QuorumHealthyReason, "")
+				recorder = record.NewFakeRecorder(10)
+				zkClient = &MockZookeeperClient{config: &zk.DynamicConfig{
+					Servers: map[int32]string{1: server(0), 2: server(1), 3: server(2)},
+					Version: 0x100000002,
+				}}
+				objs := []runtime.Object{z, zk.MakeStatefulSet(z)}
+				for i := 0; i < 3; i++ {
+					objs = append(objs, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      fmt.Sprintf("example-%d", i),
+							Namespace: Namespace,
+							Labels:    map[string]string{"app": "example"},
+						},
+						Status: corev1.PodStatus{
+							Phase:      corev1.PodRunning,
+							Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+							ContainerStatuses: []corev1.ContainerStatus{{
+								Name:  "zookeeper",
+								State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+							}},
+						},
+					})
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: zkClient, Recorder: recorder}
+			})
+
+			reconcileDynamicConfig := func() *metav1.Condition {
+				foundZk := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundZk.WithDefaults()
+				Ω(r.reconcileDynamicConfig(foundZk)).To(BeNil())
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				_, condition := foundZk.Status.GetClusterCondition(api.ClusterConditionDynamicConfigDrift)
+				return condition
+			}
+
+			It("should report a config holding the members", func() {
+				condition := reconcileDynamicConfig()
+				Ω(condition).NotTo(BeNil())
+				Ω(condition.Status).To(Equal(metav1.ConditionFalse))
+				Ω(condition.Reason).To(Equal(api.DynamicConfigInSyncReason))
+			})
+
+			It("should only write the status when the condition changes", func() {
+				reconcileDynamicConfig()
+				foundZk := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				version := foundZk.ResourceVersion
+				reconcileDynamicConfig()
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				Ω(foundZk.ResourceVersion).To(Equal(version))
+			})
+
+			It("should not read the config without a healthy quorum", func() {
+				z.Status.SetQuorumHealthyCondition(false, api.NoLeaderReason, "")
+				Ω(cl.Status().Update(context.TODO(), z)).To(BeNil())
+				Ω(reconcileDynamicConfig()).To(BeNil())
+			})
+
+			It("should report the drift before repairing it", func() {
+				zkClient.config.Servers[4] = "example-3.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181"
+				condition := reconcileDynamicConfig()
+				Ω(condition.Status).To(Equal(metav1.ConditionTrue))
+				Ω(condition.Reason).To(Equal(api.DynamicConfigDriftReason))
+				Ω(condition.Message).To(Equal("In the dynamic config, server 4 is not a member of the cluster"))
+				Ω(zkClient.config.Servers).To(HaveKey(int32(4)))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Warning DynamicConfigDrift")))
+			})
+
+			It("should remove a ghost and add a missing member once the drift persists", func() {
+				zkClient.config.Servers[4] = "example-3.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181"
+				delete(zkClient.config.Servers, 3)
+				reconcileDynamicConfig()
+				condition := reconcileDynamicConfig()
+				Ω(zkClient.config.Servers).NotTo(HaveKey(int32(4)))
+				// the member joins as an observer, zookeeperReady.sh promotes it
+				Ω(zkClient.config.Servers).To(HaveKeyWithValue(int32(3),
+					"example-2.example-headless.default.svc.cluster.local:2888:3888:observer;2181"))
+				Ω(condition.Message).To(Equal("In the dynamic config, server 3 is with another address or role"))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Warning DynamicConfigDrift")))
+				Ω(recorder.Events).To(Receive(ContainSubstring("Normal DynamicConfigRepaired Repaired the dynamic config, removed server 4, added server.3=")))
+			})
+
+			It("should add back a running member whose readiness probe fails", func() {
+				// zookeeperReady.sh fails while the member is not in the config
+				pod := &corev1.Pod{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: "example-2", Namespace: Namespace}, pod)).To(BeNil())
+				pod.Status.Conditions[0].Status = corev1.ConditionFalse
+				Ω(cl.Status().Update(context.TODO(), pod)).To(BeNil())
+				delete(zkClient.config.Servers, 3)
+				reconcileDynamicConfig()
+				reconcileDynamicConfig()
+				Ω(zkClient.config.Servers).To(HaveKeyWithValue(int32(3),
+					"example-2.example-headless.default.svc.cluster.local:2888:3888:observer;2181"))
+			})
+
+			It("should not add back a member whose zookeeper container does not run", func() {
+				pod := &corev1.Pod{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: "example-2", Namespace: Namespace}, pod)).To(BeNil())
+				pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+				Ω(cl.Status().Update(context.TODO(), pod)).To(BeNil())
+				delete(zkClient.config.Servers, 3)
+				reconcileDynamicConfig()
+				condition := reconcileDynamicConfig()
+				Ω(condition.Reason).To(Equal(api.DynamicConfigDriftReason))
+				Ω(zkClient.config.Servers).NotTo(HaveKey(int32(3)))
+			})
+
+			It("should not repair while the cluster scales", func() {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				replicas := int32(4)
+				sts.Spec.Replicas = &replicas
+				Ω(cl.Update(context.TODO(), sts)).To(BeNil())
+				zkClient.config.Servers[4] = "example-3.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181"
+				reconcileDynamicConfig()
+				condition := reconcileDynamicConfig()
+				Ω(condition.Reason).To(Equal(api.DynamicConfigDriftReason))
+				Ω(zkClient.config.Servers).To(HaveKey(int32(4)))
+			})
+
+			It("should not remove a server whose pod still exists", func() {
+				pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "example-3", Namespace: Namespace}}
+				Ω(cl.Create(context.TODO(), pod)).To(BeNil())
+				zkClient.config.Servers[4] = "example-3.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181"
+				reconcileDynamicConfig()
+				condition := reconcileDynamicConfig()
+				Ω(condition.Reason).To(Equal(api.DynamicConfigDriftReason))
+				Ω(zkClient.config.Servers).To(HaveKey(int32(4)))
+			})
+
+			It("should only report the drift while paused", func() {
+				z.Spec.PausedSubsystems = []api.Subsystem{api.SubsystemDynamicConfig}
+				Ω(cl.Update(context.TODO(), z)).To(BeNil())
+				delete(zkClient.config.Servers, 3)
+				reconcileDynamicConfig()
+				condition := reconcileDynamicConfig()
+				Ω(condition.Status).To(Equal(metav1.ConditionTrue))
+				Ω(zkClient.config.Servers).NotTo(HaveKey(int32(3)))
+			})
+
+			It("should report a failed repair", func() {
+				delete(zkClient.config.Servers, 3)
+				reconcileDynamicConfig()
+				r.ZkClient = &staleConfigZookeeperClient{MockZookeeperClient: zkClient}
+				condition := reconcileDynamicConfig()
+				Ω(condition.Reason).To(Equal(api.DynamicConfigRepairFailedReason))
+			})
+		})
+
+		Context("monitoring", func() {
+			var (
+				cl client.Client
+				ms *runtime.Scheme
+			)
+
+			monitoringResource := func(kind string) (*unstructured.Unstructured, error) {
+				u := &unstructured.Unstructured{}
+				u.SetGroupVersionKind(zk.MonitoringGroupVersion.WithKind(kind))
+				err := cl.Get(context.TODO(), req.NamespacedName, u)
+				return u, err
+			}
+
+			reconcileMonitoring := func() {
+				foundZk := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundZk.WithDefaults()
+				Ω(r.reconcileMonitoring(foundZk)).To(BeNil())
+			}
+
+			update := func(change func(z *api.ZookeeperCluster)) {
+				foundZk := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				change(foundZk)
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileMonitoring()
+			}
+
+			BeforeEach(func() {
+				// a scheme with the kinds of the Prometheus operator, as
+				// if its CRDs were installed
+				ms = runtime.NewScheme()
+				Ω(scheme.AddToScheme(ms)).To(Succeed())
+				Ω(api.AddToScheme(ms)).To(Succeed())
+				for _, kind := range zk.MonitoringKinds {
+					ms.AddKnownTypeWithName(zk.MonitoringGroupVersion.WithKind(kind), &unstructured.Unstructured{})
+					ms.AddKnownTypeWithName(zk.MonitoringGroupVersion.WithKind(kind+"List"), &unstructured.UnstructuredList{})
+				}
+				z.Spec.Monitoring = &api.Monitoring{Alerts: &api.Alerts{}}
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(ms).WithRuntimeObjects(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: ms, ZkClient: mockZkClient}
+				reconcileMonitoring()
+			})
+
+			It("should create a PodMonitor owned by the cluster", func() {
+				monitor, err := monitoringResource("PodMonitor")
+				Ω(err).To(BeNil())
+				Ω(monitor.GetOwnerReferences()).To(HaveLen(1))
+				Ω(monitor.GetOwnerReferences()[0].Name).To(Equal(Name))
+			})
+
+			It("should create the rule with the alerts", func() {
+				rule, err := monitoringResource("PrometheusRule")
+				Ω(err).To(BeNil())
+				groups, _, _ := unstructured.NestedSlice(rule.Object, "spec", "groups")
+				Ω(groups).To(HaveLen(1))
+				rules := groups[0].(map[string]interface{})["rules"].([]interface{})
+				Ω(rules).To(HaveLen(4))
+			})
+
+			It("should replace the PodMonitor with a ServiceMonitor", func() {
+				update(func(z *api.ZookeeperCluster) { z.Spec.Monitoring.Kind = api.ServiceMonitorKind })
+				_, err := monitoringResource("ServiceMonitor")
+				Ω(err).To(BeNil())
+				_, err = monitoringResource("PodMonitor")
+				Ω(errors.IsNotFound(err)).To(BeTrue())
+			})
+
+			It("should update the thresholds of the alerts", func() {
+				update(func(z *api.ZookeeperCluster) { z.Spec.Monitoring.Alerts.OutstandingRequests = 50 })
+				rule, err := monitoringResource("PrometheusRule")
+				Ω(err).To(BeNil())
+				groups, _, _ := unstructured.NestedSlice(rule.Object, "spec", "groups")
+				rules := groups[0].(map[string]interface{})["rules"].([]interface{})
+				Ω(rules[1].(map[string]interface{})["expr"]).To(HaveSuffix("> 50"))
+			})
+
+			It("should delete the resources once the monitoring is disabled", func() {
+				update(func(z *api.ZookeeperCluster) { z.Spec.Monitoring = nil })
+				for _, kind := range zk.MonitoringKinds {
+					_, err := monitoringResource(kind)
+					Ω(errors.IsNotFound(err)).To(BeTrue())
+				}
+			})
+
+			It("should leave the resources it does not own alone", func() {
+				pm, err := monitoringResource("PodMonitor")
+				Ω(err).To(BeNil())
+				pm.SetOwnerReferences(nil)
+				Ω(cl.Update(context.TODO(), pm)).To(BeNil())
+				update(func(z *api.ZookeeperCluster) { z.Spec.Monitoring = nil })
+				_, err = monitoringResource("PodMonitor")
+				Ω(err).To(BeNil())
+			})
+
+			It("should skip the resources without CRDs", func() {
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				Ω(r.reconcileMonitoring(z)).To(BeNil())
+			})
+		})
+
+		Context("metrics", func() {
+			var cl client.Client
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			})
+
+			It("should report the replicas and state of the cluster", func() {
+				_, err := r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(testutil.ToFloat64(clusterDesiredReplicas.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(3))
+				Ω(testutil.ToFloat64(clusterReadyReplicas.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(0))
+				Ω(testutil.ToFloat64(clusterUpgrading.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(0))
+				Ω(testutil.ToFloat64(clusterMetaRootCreated.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(0))
+			})
+
+			It("should time the reconcile steps", func() {
+				_, err := r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(testutil.CollectAndCount(reconcileDuration)).To(BeNumerically(">=", 3))
+			})
+
+			It("should count the failed reconcile steps", func() {
+				errors := reconcileErrors.WithLabelValues(Namespace, Name, "reconcileStatefulSet")
+				before := testutil.ToFloat64(errors)
+				err := observeReconcileStep(z, "reconcileStatefulSet", func(*api.ZookeeperCluster) error {
+					return fmt.Errorf("failed")
+				})
+				Ω(err).NotTo(BeNil())
+				Ω(testutil.ToFloat64(errors)).To(Equal(before + 1))
+			})
+
+			It("should count the deleted volume claims", func() {
+				deletions := pvcDeletions.WithLabelValues(Namespace, Name, pvcOrphaned)
+				before := testutil.ToFloat64(deletions)
+				pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-example-3", Namespace: Namespace}}
+				Ω(cl.Create(context.TODO(), pvc)).To(BeNil())
+				r.deletePVC(z, *pvc, pvcOrphaned)
+				r.deletePVC(z, *pvc, pvcOrphaned)
+				Ω(testutil.ToFloat64(deletions)).To(Equal(before + 1))
+			})
+
+			It("should count the failed connections", func() {
+				failures := zkConnectFailures.WithLabelValues(Namespace, Name)
+				before := testutil.ToFloat64(failures)
+				r.ZkClient = &failingZookeeperClient{}
+				Ω(r.connectZk(z, "example-client:2181")).NotTo(BeNil())
+				Ω(testutil.ToFloat64(failures)).To(Equal(before + 1))
+			})
+
+			It("should forget a deleted cluster", func() {
+				_, err := r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(testutil.ToFloat64(clusterDesiredReplicas.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(3))
+				Ω(cl.Delete(context.TODO(), z)).To(BeNil())
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(testutil.ToFloat64(clusterDesiredReplicas.WithLabelValues(Namespace, Name))).To(BeEquivalentTo(0))
+			})
+		})
+
+		Context("quorum TLS", func() {
+			var (
+				cl       client.Client
+				err      error
+				foundZk  *api.ZookeeperCluster
+				foundSts *appsv1.StatefulSet
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundSts = &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundSts)).To(BeNil())
+			}
+
+			// the fake client has no StatefulSet controller, mark the
+			// current pod template as rolled out to all members
+			rollOut := func() {
+				foundSts.Status.ObservedGeneration = foundSts.Generation
+				foundSts.Status.Replicas = *foundSts.Spec.Replicas
+				foundSts.Status.ReadyReplicas = *foundSts.Spec.Replicas
+				foundSts.Status.UpdatedReplicas = *foundSts.Spec.Replicas
+				foundSts.Status.CurrentRevision = "1"
+				foundSts.Status.UpdateRevision = "1"
+				Ω(cl.Status().Update(context.TODO(), foundSts)).To(BeNil())
+			}
+
+			setQuorumTLS := func(quorum *api.QuorumTLS) {
+				foundZk.Spec.TLS = &api.TLS{Quorum: quorum}
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+			}
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			})
+
+			It("should start a new cluster with quorum TLS enabled", func() {
+				foundZk = z
+				setQuorumTLS(&api.QuorumTLS{SecretName: "example-quorum-tls"})
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumTLSPhase()).To(Equal(api.QuorumTLSEnabled))
+				Ω(foundSts.Spec.Template.Annotations).To(HaveKeyWithValue(api.QuorumTLSPhaseAnnotation, "Enabled"))
+				cm := &corev1.ConfigMap{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: z.ConfigMapName(), Namespace: Namespace}, cm)).To(BeNil())
+				Ω(cm.Data["zoo.cfg"]).To(ContainSubstring("sslQuorum=true\nportUnification=false\n"))
+			})
+
+			It("should enable and disable quorum TLS one rolled out phase at a time", func() {
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumTLS).To(BeNil())
+
+				setQuorumTLS(&api.QuorumTLS{SecretName: "example-quorum-tls"})
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumTLSPhase()).To(Equal(api.QuorumTLSDisabled))
+
+				for _, phase := range []api.QuorumTLSPhase{api.QuorumTLSPortUnification, api.QuorumTLSSSLQuorum, api.QuorumTLSEnabled} {
+					rollOut()
+					reconcileAndGet()
+					Ω(foundZk.Status.QuorumTLSPhase()).To(Equal(phase))
+					Ω(foundSts.Spec.Template.Annotations).To(HaveKeyWithValue(api.QuorumTLSPhaseAnnotation, string(phase)))
+					foundSts.Status.UpdateRevision = "2"
+					Ω(cl.Status().Update(context.TODO(), foundSts)).To(BeNil())
+					reconcileAndGet()
+					Ω(foundZk.Status.QuorumTLSPhase()).To(Equal(phase))
+				}
+
+				foundZk.Spec.TLS = nil
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				for _, phase := range []api.QuorumTLSPhase{api.QuorumTLSSSLQuorum, api.QuorumTLSPortUnification} {
+					rollOut()
+					reconcileAndGet()
+					Ω(foundZk.Status.QuorumTLSPhase()).To(Equal(phase))
+					Ω(foundSts.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Name", "quorum-tls-secret")))
+				}
+				rollOut()
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumTLS).To(BeNil())
+				Ω(foundSts.Spec.Template.Annotations).NotTo(HaveKey(api.QuorumTLSPhaseAnnotation))
+				Ω(foundSts.Spec.Template.Spec.InitContainers).To(BeEmpty())
+			})
+
+			It("should roll out a new secret", func() {
+				foundZk = z
+				setQuorumTLS(&api.QuorumTLS{SecretName: "example-quorum-tls"})
+				reconcileAndGet()
+				setQuorumTLS(&api.QuorumTLS{SecretName: "example-new-tls"})
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumTLS).To(Equal(&api.QuorumTLSStatus{Phase: api.QuorumTLSEnabled, SecretName: "example-new-tls"}))
+				Ω(foundSts.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+					Name: "quorum-tls-secret",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{SecretName: "example-new-tls"},
+					},
+				}))
+			})
+		})
+
+		Context("auth", func() {
+			var (
+				cl       client.Client
+				err      error
+				foundZk  *api.ZookeeperCluster
+				foundSts *appsv1.StatefulSet
+				secret   *corev1.Secret
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundSts = &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundSts)).To(BeNil())
+				secret = &corev1.Secret{}
+				err = cl.Get(context.TODO(), types.NamespacedName{Name: z.AuthSecretName(), Namespace: Namespace}, secret)
+			}
+
+			rollOut := func() {
+				foundSts.Status.ObservedGeneration = foundSts.Generation
+				foundSts.Status.Replicas = *foundSts.Spec.Replicas
+				foundSts.Status.ReadyReplicas = *foundSts.Spec.Replicas
+				foundSts.Status.UpdatedReplicas = *foundSts.Spec.Replicas
+				foundSts.Status.CurrentRevision = "1"
+				foundSts.Status.UpdateRevision = "1"
+				Ω(cl.Status().Update(context.TODO(), foundSts)).To(BeNil())
+			}
+
+			BeforeEach(func() {
+				z.Spec.Auth = &api.Auth{SecretName: "example-users"}
+				z.WithDefaults()
+				users := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "example-users", Namespace: Namespace},
+					Data: map[string][]byte{
+						"super":  []byte("secret"),
+						"quorum": []byte("q"),
+					},
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z, users).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			})
+
+			It("should generate the JAAS configuration of the members", func() {
+				reconcileAndGet()
+				Ω(err).To(BeNil())
+				Ω(string(secret.Data["jaas.conf"])).To(ContainSubstring(`user_super="secret"`))
+				Ω(secret.OwnerReferences).To(HaveLen(1))
+			})
+
+			It("should fail without the users secret", func() {
+				Ω(cl.Delete(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "example-users", Namespace: Namespace}})).To(Succeed())
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).NotTo(BeNil())
+			})
+
+			It("should restrict the metadata znodes created before auth was enabled", func() {
+				open := []zk.ACL{{Perms: 31, Scheme: "world", ID: "anyone"}}
+				mockZkClient.nodes = map[string]string{
+					"/zookeeper-operator":         "",
+					"/zookeeper-operator/example": "CLUSTER_SIZE=3",
+				}
+				mockZkClient.acls = map[string][]zk.ACL{
+					"/zookeeper-operator":         open,
+					"/zookeeper-operator/example": open,
+				}
+				z.Status.Init()
+				z.Status.MetaRootCreated = true
+				z.Status.ReadyReplicas = 3
+				Ω(r.reconcileClusterStatus(z)).To(Succeed())
+				acl := zk.SuperUserACL("super", "secret")
+				Ω(mockZkClient.acls["/zookeeper-operator"]).To(Equal(acl))
+				Ω(mockZkClient.acls["/zookeeper-operator/example"]).To(Equal(acl))
+				Ω(mockZkClient.acls).NotTo(HaveKey("/zookeeper-operator/example/observers"))
+				Ω(z.Status.MetaRootSecured).To(BeTrue())
+
+				// the ACL is only checked once
+				mockZkClient.acls["/zookeeper-operator/example"] = open
+				Ω(r.reconcileClusterStatus(z)).To(Succeed())
+				Ω(mockZkClient.acls["/zookeeper-operator/example"]).To(Equal(open))
+			})
+
+			It("should connect as the super user", func() {
+				err = r.connectZk(z, "example-client:2181")
+				Ω(err).To(BeNil())
+				Ω(mockZkClient.user).To(Equal("super"))
+				Ω(mockZkClient.password).To(Equal("secret"))
+			})
+
+			It("should delete the generated secret once auth is disabled", func() {
+				reconcileAndGet()
+				foundZk.Spec.Auth = nil
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+				Ω(err).NotTo(BeNil())
+				Ω(foundSts.Spec.Template.Spec.Volumes).NotTo(ContainElement(HaveField("Name", "auth")))
+			})
+
+			It("should enable and disable quorum authentication one rolled out phase at a time", func() {
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumAuth).To(BeNil())
+
+				foundZk.Spec.Auth.QuorumUser = "quorum"
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+				Ω(foundZk.Status.QuorumAuthPhase()).To(Equal(api.QuorumAuthDisabled))
+
+				for _, phase := range []api.QuorumAuthPhase{api.QuorumAuthSASLEnabled, api.QuorumAuthLearnerRequired, api.QuorumAuthRequired} {
+					rollOut()
+					reconcileAndGet()
+					Ω(foundZk.Status.QuorumAuthPhase()).To(Equal(phase))
+					Ω(foundSts.Spec.Template.Annotations).To(HaveKeyWithValue(api.QuorumAuthPhaseAnnotation, string(phase)))
+					Ω(string(secret.Data["jaas.conf"])).To(ContainSubstring("QuorumLearner"))
+				}
+
+				// the members keep the generated secret until they no longer
+				// authenticate each other
+				foundZk.Spec.Auth = nil
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				for _, phase := range []api.QuorumAuthPhase{api.QuorumAuthLearnerRequired, api.QuorumAuthSASLEnabled} {
+					rollOut()
+					reconcileAndGet()
+					Ω(err).To(BeNil())
+					Ω(foundZk.Status.QuorumAuthPhase()).To(Equal(phase))
+				}
+				rollOut()
+				reconcileAndGet()
+				Ω(err).NotTo(BeNil())
+				Ω(foundZk.Status.QuorumAuth).To(BeNil())
+			})
+		})
+
+		Context("observers", func() {
+			var (
+				cl       client.Client
+				err      error
+				foundZk  *api.ZookeeperCluster
+				foundSts *appsv1.StatefulSet
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+				foundSts = &appsv1.StatefulSet{}
+				err = cl.Get(context.TODO(), types.NamespacedName{Name: z.GetObserverName(), Namespace: Namespace}, foundSts)
+			}
+
+			getService := func(name string) error {
+				return cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: Namespace}, &corev1.Service{})
+			}
+
+			BeforeEach(func() {
+				z.Spec.Observers = &api.Observers{Replicas: 2}
+				z.WithDefaults()
+				observer := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "example-observer-0",
+						Namespace: Namespace,
+						Labels:    map[string]string{"app": "example-observer"},
+					},
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z, observer).WithStatusSubresource(z).Build()
+				mockZkClient.nodes = nil
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			})
+
+			It("should create the observers once the voting members are ready", func() {
+				reconcileAndGet()
+				Ω(errors.IsNotFound(err)).To(BeTrue())
+				Ω(getService("example-observer-headless")).To(BeNil())
+				Ω(getService("example-observer-client")).To(BeNil())
+
+				voters := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, voters)).To(BeNil())
+				voters.Status.ReadyReplicas = *voters.Spec.Replicas
+				Ω(cl.Status().Update(context.TODO(), voters)).To(BeNil())
+				reconcileAndGet()
+				Ω(foundZk.Status.MetaRootCreated).To(BeTrue())
+				reconcileAndGet()
+				Ω(err).To(BeNil())
+				Ω(*foundSts.Spec.Replicas).To(BeEquivalentTo(2))
+				Ω(mockZkClient.nodes).To(HaveKeyWithValue("/zookeeper-operator/example/observers", "CLUSTER_SIZE=2"))
+			})
+
+			It("should report the observers separately", func() {
+				reconcileAndGet()
+				Ω(foundZk.Status.Members.Ready).To(BeEmpty())
+				Ω(foundZk.Status.Members.Observers).NotTo(BeNil())
+				Ω(foundZk.Status.Members.Observers.Ready).To(ConsistOf("example-observer-0"))
+			})
+
+			It("should store the new count before scaling and remove the observers", func() {
+				z.Status.MetaRootCreated = true
+				Ω(cl.Status().Update(context.TODO(), z)).To(BeNil())
+				reconcileAndGet()
+				Ω(err).To(BeNil())
+
+				foundZk.Spec.Observers.Replicas = 1
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+				Ω(*foundSts.Spec.Replicas).To(BeEquivalentTo(1))
+				Ω(mockZkClient.nodes).To(HaveKeyWithValue("/zookeeper-operator/example/observers", "CLUSTER_SIZE=1"))
+
+				foundSts.Status.Replicas = 1
+				Ω(cl.Status().Update(context.TODO(), foundSts)).To(BeNil())
+				foundZk.Spec.Observers = nil
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+				Ω(err).To(BeNil())
+				Ω(*foundSts.Spec.Replicas).To(BeEquivalentTo(0))
+				Ω(mockZkClient.nodes).To(HaveKeyWithValue("/zookeeper-operator/example/observers", "CLUSTER_SIZE=0"))
+				Ω(getService("example-observer-client")).To(BeNil())
+
+				foundSts.Status.Replicas = 0
+				Ω(cl.Status().Update(context.TODO(), foundSts)).To(BeNil())
+				reconcileAndGet()
+				Ω(errors.IsNotFound(err)).To(BeTrue())
+				Ω(errors.IsNotFound(getService("example-observer-headless"))).To(BeTrue())
+				Ω(errors.IsNotFound(getService("example-observer-client"))).To(BeTrue())
+			})
+
+			It("should remove the observers from the dynamic config before scaling", func() {
+				z.Status.MetaRootCreated = true
+				Ω(cl.Status().Update(context.TODO(), z)).To(BeNil())
+				reconcileAndGet()
+				Ω(err).To(BeNil())
+
+				mockZkClient.config = &zk.DynamicConfig{
+					Servers: map[int32]string{
+						1:   "example-0:2888:3888:participant;0.0.0.0:2181",
+						129: "example-observer-0:2888:3888:observer;0.0.0.0:2181",
+						130: "example-observer-1:2888:3888:observer;0.0.0.0:2181",
+					},
+					Version: 1,
+				}
+				defer func() { mockZkClient.config = nil }()
+				foundZk.Spec.Observers.Replicas = 1
+				Ω(cl.Update(context.TODO(), foundZk)).To(BeNil())
+				reconcileAndGet()
+				Ω(*foundSts.Spec.Replicas).To(BeEquivalentTo(1))
+				Ω(mockZkClient.config.Servers).To(HaveLen(2))
+				Ω(mockZkClient.config.Servers).NotTo(HaveKey(int32(130)))
+			})
+
+			It("should wait for the observers to roll out a quorum phase", func() {
+				rolledOut := func(name string) *appsv1.StatefulSet {
+					replicas := int32(1)
+					sts := &appsv1.StatefulSet{}
+					sts.Name = name
+					sts.Spec.Replicas = &replicas
+					sts.Spec.Template.Annotations = map[string]string{api.QuorumTLSPhaseAnnotation: "SSLQuorum"}
+					sts.Status.UpdatedReplicas = 1
+					sts.Status.ReadyReplicas = 1
+					return sts
+				}
+				voters, observers := rolledOut("example"), rolledOut("example-observer")
+				Ω(podTemplateRolledOut([]*appsv1.StatefulSet{voters, observers}, api.QuorumTLSPhaseAnnotation, "SSLQuorum")).To(BeTrue())
+				observers.Status.ReadyReplicas = 0
+				Ω(podTemplateRolledOut([]*appsv1.StatefulSet{voters, observers}, api.QuorumTLSPhaseAnnotation, "SSLQuorum")).To(BeFalse())
+			})
+		})
+
+		Context("restore", func() {
+			var (
+				cl      client.Client
+				err     error
+				backup  *api.ZookeeperBackup
+				foundZk *api.ZookeeperCluster
+			)
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			getSts := func() error {
+				return cl.Get(context.TODO(), req.NamespacedName, &appsv1.StatefulSet{})
+			}
+
+			finishJob := func(ord int, condition batchv1.JobConditionType) {
+				job := &batchv1.Job{}
+				name := types.NamespacedName{Name: fmt.Sprintf("example-restore-%d", ord), Namespace: Namespace}
+				Ω(cl.Get(context.TODO(), name, job)).To(BeNil())
+				job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: corev1.ConditionTrue}}
+				Ω(cl.Update(context.TODO(), job)).To(BeNil())
+			}
+
+			BeforeEach(func() {
+				backup = &api.ZookeeperBackup{
+					ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: Namespace},
+					Spec: api.ZookeeperBackupSpec{
+						ZookeeperCluster: "old",
+						Target:           api.BackupTarget{PVC: &api.PVCTarget{ClaimName: "backups"}},
+					},
+					Status: api.ZookeeperBackupStatus{
+						Phase:    api.BackupSucceeded,
+						Location: "pvc://backups/nightly/snapshot.100000002",
+						Zxid:     "0x100000002",
+						Checksum: "abc",
+					},
+				}
+				s.AddKnownTypes(api.GroupVersion, backup, &api.ZookeeperBackupList{})
+				z.Spec.RestoreFrom = &api.RestoreSource{Backup: "nightly"}
+				z.WithDefaults()
+			})
+
+			build := func(objs ...client.Object) {
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			}
+
+			It("should wait for the backup", func() {
+				backup.Status = api.ZookeeperBackupStatus{Phase: api.BackupRunning}
+				build(z, backup)
+				reconcileAndGet()
+				Ω(foundZk.Status.Restore.Phase).To(Equal(api.RestorePending))
+				Ω(foundZk.Status.Restore.Message).To(ContainSubstring("nightly"))
+				Ω(errors.IsNotFound(getSts())).To(BeTrue())
+			})
+
+			It("should seed the members one after the other before creating the stateful set", func() {
+				build(z, backup)
+				reconcileAndGet()
+				Ω(foundZk.Status.Restore.Phase).To(Equal(api.RestoreRunning))
+				Ω(foundZk.Status.Restore.Zxid).To(Equal("0x100000002"))
+				Ω(foundZk.Status.Restore.Location).To(Equal("pvc://backups/nightly/snapshot.100000002"))
+				Ω(foundZk.Status.Restore.Members).To(BeEquivalentTo(3))
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: "data-example-0", Namespace: Namespace}, &corev1.PersistentVolumeClaim{})).To(BeNil())
+				Ω(errors.IsNotFound(getSts())).To(BeTrue())
+
+				for ord := 0; ord < 3; ord++ {
+					Ω(foundZk.Status.Restore.RestoredMembers).To(BeEquivalentTo(ord))
+					Ω(errors.IsNotFound(getSts())).To(BeTrue())
+					finishJob(ord, batchv1.JobComplete)
+					reconcileAndGet()
+				}
+				Ω(foundZk.Status.Restore.Phase).To(Equal(api.RestoreSucceeded))
+				Ω(foundZk.Status.Restore.RestoredMembers).To(BeEquivalentTo(3))
+				Ω(getSts()).To(BeNil())
+			})
+
+			It("should not start the members when seeding one failed", func() {
+				build(z, backup)
+				reconcileAndGet()
+				finishJob(0, batchv1.JobFailed)
+				reconcileAndGet()
+				Ω(foundZk.Status.Restore.Phase).To(Equal(api.RestoreFailed))
+				Ω(foundZk.Status.Restore.Message).To(ContainSubstring("member 0"))
+				reconcileAndGet()
+				Ω(errors.IsNotFound(getSts())).To(BeTrue())
+			})
+
+			It("should not restore a cluster which already has members", func() {
+				sts := zk.MakeStatefulSet(z)
+				build(z, backup, sts)
+				reconcileAndGet()
+				Ω(foundZk.Status.Restore).To(BeNil())
+				jobs := &batchv1.JobList{}
+				Ω(cl.List(context.TODO(), jobs)).To(BeNil())
+				Ω(jobs.Items).To(BeEmpty())
+			})
+		})
+
+		Context("LeaderAware upgrade", func() {
+			var (
+				cl          client.Client
+				err         error
+				foundZk     *api.ZookeeperCluster
+				adminClient *MockAdminClient
+			)
+
+			member := func(ord int, revision string, ready bool) *corev1.Pod {
+				status := corev1.ConditionTrue
+				if !ready {
+					status = corev1.ConditionFalse
+				}
+				return &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("example-%d", ord),
+						Namespace: Namespace,
+						Labels: map[string]string{
+							"app":                                 "example",
+							appsv1.ControllerRevisionHashLabelKey: revision,
+						},
+					},
+					Status: corev1.PodStatus{
+						Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+					},
+				}
+			}
+
+			memberExists := func(ord int) bool {
+				err := cl.Get(context.TODO(), types.NamespacedName{Name: fmt.Sprintf("example-%d", ord), Namespace: Namespace}, &corev1.Pod{})
+				return err == nil
+			}
+
+			BeforeEach(func() {
+				z.Spec.UpgradePolicy = &api.UpgradePolicy{Strategy: api.LeaderAwareUpgrade}
+				z.WithDefaults()
+				z.Status.Init()
+				adminClient = &MockAdminClient{states: map[string]string{
+					"example-0": "follower",
+					"example-1": "leader",
+					"example-2": "follower",
+				}}
+			})
+
+			reconcileWith := func(pods ...*corev1.Pod) {
+				sts := zk.MakeStatefulSet(z)
+				sts.Status.CurrentRevision = "v1"
+				sts.Status.UpdateRevision = "v2"
+				objs := []runtime.Object{z, sts}
+				for _, pod := range pods {
+					objs = append(objs, pod)
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient, AdminClient: adminClient}
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			It("should leave the pods to the operator", func() {
+				reconcileWith()
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				Ω(sts.Spec.UpdateStrategy.Type).To(Equal(appsv1.OnDeleteStatefulSetStrategyType))
+			})
+
+			It("should restart a follower first", func() {
+				reconcileWith(member(0, "v1", true), member(1, "v1", true), member(2, "v1", true))
+				Ω(memberExists(2)).To(BeFalse())
+				Ω(memberExists(0)).To(BeTrue())
+				Ω(memberExists(1)).To(BeTrue())
+				Ω(foundZk.Status.Upgrade).NotTo(BeNil())
+				Ω(foundZk.Status.Upgrade.Member).To(Equal("example-2"))
+				Ω(foundZk.Status.Upgrade.Role).To(Equal("follower"))
+			})
+
+			It("should restart the next member once the last one rejoined", func() {
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-2", Role: "follower"}
+				reconcileWith(member(0, "v1", true), member(1, "v1", true), member(2, "v2", true))
+				Ω(memberExists(0)).To(BeFalse())
+				Ω(memberExists(1)).To(BeTrue())
+				Ω(foundZk.Status.Upgrade.Member).To(Equal("example-0"))
+			})
+
+			It("should not restart the members below the canary", func() {
+				canary := int32(1)
+				z.Spec.UpgradePolicy.Canary = &canary
+				z.Status.CurrentVersion = "0.2.14"
+				z.Status.TargetVersion = z.Spec.Image.Tag
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-2", Role: "follower"}
+				reconcileWith(member(0, "v1", true), member(1, "v1", true), member(2, "v2", true))
+				Ω(memberExists(0)).To(BeTrue())
+				Ω(memberExists(1)).To(BeTrue())
+				Ω(foundZk.Status.Upgrade).To(BeNil())
+			})
+
+			It("should restart the members below the canary with a new configuration", func() {
+				canary := int32(1)
+				z.Spec.UpgradePolicy.Canary = &canary
+				z.Status.CurrentVersion = z.Spec.Image.Tag
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-2", Role: "follower"}
+				reconcileWith(member(0, "v1", true), member(1, "v1", true), member(2, "v2", true))
+				Ω(memberExists(0)).To(BeFalse())
+				Ω(foundZk.Status.Upgrade.Member).To(Equal("example-0"))
+			})
+
+			It("should restart the leader last", func() {
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-0", Role: "follower"}
+				reconcileWith(member(0, "v2", true), member(1, "v1", true), member(2, "v2", true))
+				Ω(memberExists(1)).To(BeFalse())
+				Ω(foundZk.Status.Upgrade.Member).To(Equal("example-1"))
+				Ω(foundZk.Status.Upgrade.Role).To(Equal("leader"))
+			})
+
+			It("should wait for the last member to rejoin the ensemble", func() {
+				adminClient.states["example-2"] = "looking"
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-2", Role: "follower"}
+				reconcileWith(member(0, "v1", true), member(1, "v1", true), member(2, "v2", true))
+				Ω(memberExists(0)).To(BeTrue())
+				Ω(foundZk.Status.Upgrade.Member).To(Equal("example-2"))
+			})
+
+			It("should wait for all the members to be ready", func() {
+				reconcileWith(member(0, "v1", false), member(1, "v1", true), member(2, "v1", true))
+				Ω(memberExists(2)).To(BeTrue())
+				Ω(foundZk.Status.Upgrade).To(BeNil())
+			})
+
+			It("should be done once all the members run the new revision", func() {
+				z.Status.Upgrade = &api.UpgradeStatus{Member: "example-1", Role: "leader"}
+				adminClient.states["example-1"] = "follower"
+				reconcileWith(member(0, "v2", true), member(1, "v2", true), member(2, "v2", true))
+				Ω(foundZk.Status.Upgrade).To(BeNil())
+				Ω(memberExists(0)).To(BeTrue())
+				Ω(memberExists(1)).To(BeTrue())
+				Ω(memberExists(2)).To(BeTrue())
+			})
+		})
+
+		Context("volume expansion", func() {
+			var (
+				cl      client.Client
+				err     error
+				class   *storagev1.StorageClass
+				foundZk *api.ZookeeperCluster
+			)
+
+			pvc := func(ord int) *corev1.PersistentVolumeClaim {
+				className := "standard"
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("data-example-%d", ord),
+						Namespace: Namespace,
+						Labels:    map[string]string{"app": "example", "uid": ""},
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						StorageClassName: &className,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+						},
+					},
+					Status: corev1.PersistentVolumeClaimStatus{
+						Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+					},
+				}
+			}
+
+			getPVC := func(ord int) *corev1.PersistentVolumeClaim {
+				found := &corev1.PersistentVolumeClaim{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: fmt.Sprintf("data-example-%d", ord), Namespace: Namespace}, found)).To(BeNil())
+				return found
+			}
+
+			reconcileAndGet := func() {
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				foundZk = &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, foundZk)).To(BeNil())
+			}
+
+			templateSize := func() string {
+				sts := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, sts)).To(BeNil())
+				size, ok := zk.DataVolumeClaimSize(sts)
+				Ω(ok).To(BeTrue())
+				return size.String()
+			}
+
+			BeforeEach(func() {
+				z.WithDefaults()
+				allow := true
+				class = &storagev1.StorageClass{
+					ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+					Provisioner:          "example.com/csi",
+					AllowVolumeExpansion: &allow,
+				}
+			})
+
+			build := func() {
+				sts := zk.MakeStatefulSet(z)
+				grown := z.DeepCopy()
+				grown.Spec.Persistence.PersistentVolumeClaimSpec.Resources.Requests = corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("40Gi"),
+				}
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+					WithRuntimeObjects(grown, sts, class, pvc(0), pvc(1), pvc(2)).
+					WithStatusSubresource(grown).Build()
+				mockZkClient.nodes = nil
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+			}
+
+			It("should expand the claims and recreate the StatefulSet", func() {
+				build()
+				reconcileAndGet()
+				for i := 0; i < 3; i++ {
+					Ω(getPVC(i).Spec.Resources.Requests.Storage().String()).To(Equal("40Gi"))
+				}
+				Ω(templateSize()).To(Equal("40Gi"))
+			})
+
+			It("should track the progress of each member", func() {
+				build()
+				reconcileAndGet()
+				expansion := foundZk.Status.VolumeExpansion
+				Ω(expansion).NotTo(BeNil())
+				Ω(expansion.Size).To(Equal("40Gi"))
+				Ω(expansion.Members).To(HaveLen(3))
+				Ω(expansion.Members[0]).To(Equal(api.MemberVolumeExpansion{
+					Name:     "example-0",
+					Claim:    "data-example-0",
+					Capacity: "20Gi",
+					Phase:    api.VolumeExpansionResizing,
+				}))
+
+				resized := getPVC(0)
+				resized.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("40Gi")}
+				Ω(cl.Status().Update(context.TODO(), resized)).To(BeNil())
+				pending := getPVC(1)
+				pending.Status.Conditions = []corev1.PersistentVolumeClaimCondition{{
+					Type:   corev1.PersistentVolumeClaimFileSystemResizePending,
+					Status: corev1.ConditionTrue,
+				}}
+				Ω(cl.Status().Update(context.TODO(), pending)).To(BeNil())
+				reconcileAndGet()
+				members := foundZk.Status.VolumeExpansion.Members
+				Ω(members[0].Phase).To(Equal(api.VolumeExpansionCompleted))
+				Ω(members[0].Capacity).To(Equal("40Gi"))
+				Ω(members[1].Phase).To(Equal(api.VolumeExpansionFileSystemResizePending))
+				Ω(members[2].Phase).To(Equal(api.VolumeExpansionResizing))
+			})
+
+			It("should not expand the claims when the StorageClass does not allow it", func() {
+				class.AllowVolumeExpansion = nil
+				build()
+				reconcileAndGet()
+				Ω(getPVC(0).Spec.Resources.Requests.Storage().String()).To(Equal("20Gi"))
+				Ω(templateSize()).To(Equal("20Gi"))
+				expansion := foundZk.Status.VolumeExpansion
+				Ω(expansion.Message).To(ContainSubstring("does not allow volume expansion"))
+				Ω(expansion.Members[0].Phase).To(Equal(api.VolumeExpansionPending))
+			})
+
+			It("should not expand the claims of a cluster whose size did not change", func() {
+				sts := zk.MakeStatefulSet(z)
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+					WithRuntimeObjects(z, sts, class, pvc(0)).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				reconcileAndGet()
+				Ω(foundZk.Status.VolumeExpansion).To(BeNil())
+				Ω(templateSize()).To(Equal("20Gi"))
+			})
+		})
+
+		Context("transaction log volume", func() {
+			var (
+				cl  client.Client
+				err error
+			)
+
+			claim := func(name string) *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: Namespace,
+						Labels:    map[string]string{"app": "example", "uid": ""},
+					},
+				}
+			}
+
+			BeforeEach(func() {
+				z.WithDefaults()
+			})
+
+			It("should create the StatefulSet again with the volume claim template", func() {
+				sts := zk.MakeStatefulSet(z)
+				z.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(z, sts).WithStatusSubresource(z).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				Ω(errors.IsNotFound(cl.Get(context.TODO(), req.NamespacedName, &appsv1.StatefulSet{}))).To(BeTrue())
+
+				_, err = r.Reconcile(context.TODO(), req)
+				Ω(err).To(BeNil())
+				found := &appsv1.StatefulSet{}
+				Ω(cl.Get(context.TODO(), req.NamespacedName, found)).To(BeNil())
+				Ω(found.Spec.VolumeClaimTemplates).To(HaveLen(2))
+				Ω(found.Spec.VolumeClaimTemplates[1].Name).To(Equal("datalog"))
+			})
+
+			It("should only delete the volumes whose reclaim policy is Delete", func() {
+				z.Spec.Persistence.DataLog = &api.DataLogPersistence{VolumeReclaimPolicy: api.VolumeReclaimPolicyDelete}
+				z.WithDefaults()
+				cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+					WithRuntimeObjects(z, claim("data-example-0"), claim("datalog-example-0")).Build()
+				r = &ZookeeperClusterReconciler{Client: cl, Scheme: s, ZkClient: mockZkClient}
+				Ω(r.cleanUpAllPVCs(z)).To(BeNil())
+				pvcs := &corev1.PersistentVolumeClaimList{}
+				Ω(cl.List(context.TODO(), pvcs)).To(BeNil())
+				Ω(pvcs.Items).To(HaveLen(1))
+				Ω(pvcs.Items[0].Name).To(Equal("data-example-0"))
 			})
 		})
 	})
@@ -0,0 +1,250 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	zookeeperv1 "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+)
+
+var znodeLog = logf.Log.WithName("controller_zookeeperznode")
+
+var _ reconcile.Reconciler = &ZookeeperZNodeReconciler{}
+
+// ZookeeperZNodeReconciler reconciles a ZookeeperZNode object
+type ZookeeperZNodeReconciler struct {
+	Client   client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	ZkClient zk.ZookeeperClient
+}
+
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperznodes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=zookeeper.pravega.io,resources=zookeeperznodes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+
+// Reconcile syncs the znode with its spec every ReconcileTime, which
+// recreates it and reverts changes made to it by hand
+func (r *ZookeeperZNodeReconciler) Reconcile(_ context.Context, request ctrl.Request) (ctrl.Result, error) {
+	r.Log = znodeLog.WithValues(
+		"Request.Namespace", request.Namespace,
+		"Request.Name", request.Name)
+	r.Log.Info("Reconciling ZookeeperZNode")
+
+	znode := &zookeeperv1.ZookeeperZNode{}
+	err := r.Client.Get(context.TODO(), request.NamespacedName, znode)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if znode.DeletionTimestamp != nil {
+		return r.reconcileZNodeDeletion(znode)
+	}
+	if err = r.reconcileZNodeFinalizer(znode); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	status := znode.Status.DeepCopy()
+	if err = r.syncZNode(znode); err != nil {
+		r.Log.Info("Failed to sync the znode", "Path", znode.Spec.Path, "Error", err.Error())
+		znode.Status.Synced = false
+		znode.Status.Message = err.Error()
+	} else {
+		znode.Status.Synced = true
+		znode.Status.Message = ""
+		znode.Status.ObservedGeneration = znode.Generation
+	}
+	if !reflect.DeepEqual(status, &znode.Status) {
+		if err = r.Client.Status().Update(context.TODO(), znode); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+}
+
+// reconcileZNodeFinalizer adds the finalizer deleting the znode if the
+// deletion policy asks for it, and removes it otherwise
+func (r *ZookeeperZNodeReconciler) reconcileZNodeFinalizer(znode *zookeeperv1.ZookeeperZNode) error {
+	has := utils.ContainsString(znode.Finalizers, utils.ZkZNodeFinalizer)
+	if znode.Spec.DeletesZNode() == has {
+		return nil
+	}
+	if has {
+		znode.Finalizers = utils.RemoveString(znode.Finalizers, utils.ZkZNodeFinalizer)
+	} else {
+		znode.Finalizers = append(znode.Finalizers, utils.ZkZNodeFinalizer)
+	}
+	return r.Client.Update(context.TODO(), znode)
+}
+
+// syncZNode creates the znode if it does not exist, or sets its data and
+// ACL if they differ from the spec
+func (r *ZookeeperZNodeReconciler) syncZNode(znode *zookeeperv1.ZookeeperZNode) error {
+	if err := znode.Spec.Validate(); err != nil {
+		return err
+	}
+	cluster, err := r.znodeCluster(znode)
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return fmt.Errorf("ZookeeperCluster %s not found", znode.Spec.ZookeeperCluster)
+	}
+	if !cluster.Status.MetaRootCreated {
+		return fmt.Errorf("Waiting for ZookeeperCluster %s to be ready", cluster.Name)
+	}
+	data, err := r.znodeData(znode)
+	if err != nil {
+		return err
+	}
+	acl := zk.ZNodeACL(znode.Spec.ACLs)
+
+	if err = connectZkClient(r.Client, r.ZkClient, cluster, utils.GetZkServiceUri(cluster)); err != nil {
+		return err
+	}
+	defer r.ZkClient.Close()
+	path := znode.Spec.Path
+	node, err := r.ZkClient.GetNode(path)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		r.Log.Info("Creating the znode", "Path", path, "Type", znode.Spec.Type)
+		znode.Status.DataVersion = 0
+		return r.ZkClient.CreateZNode(path, data, acl, znode.Spec.IsContainer())
+	}
+	znode.Status.DataVersion = node.Version
+	if node.Data != data {
+		r.Log.Info("Setting the data of the znode", "Path", path)
+		if err = r.ZkClient.SetNode(path, data); err != nil {
+			return err
+		}
+		znode.Status.DataVersion = node.Version + 1
+	}
+	if acl != nil && !zk.EqualACL(node.ACL, acl) {
+		r.Log.Info("Setting the ACL of the znode", "Path", path)
+		if err = r.ZkClient.SetACL(path, acl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// znodeCluster returns the defaulted cluster of the znode, or nil if it
+// does not exist
+func (r *ZookeeperZNodeReconciler) znodeCluster(znode *zookeeperv1.ZookeeperZNode) (*zookeeperv1.ZookeeperCluster, error) {
+	cluster := &zookeeperv1.ZookeeperCluster{}
+	name := types.NamespacedName{Name: znode.Spec.ZookeeperCluster, Namespace: znode.Namespace}
+	if err := r.Client.Get(context.TODO(), name, cluster); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cluster.WithDefaults()
+	return cluster, nil
+}
+
+// znodeData returns the data of the spec, read from its ConfigMap or Secret
+// if it refers to one
+func (r *ZookeeperZNodeReconciler) znodeData(znode *zookeeperv1.ZookeeperZNode) (string, error) {
+	source := znode.Spec.Data
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		cm := &corev1.ConfigMap{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: znode.Namespace}, cm)
+		if err != nil && !(errors.IsNotFound(err) && ref.Optional != nil && *ref.Optional) {
+			return "", fmt.Errorf("Error getting ConfigMap %s: %v", ref.Name, err)
+		}
+		data, ok := cm.Data[ref.Key]
+		if !ok && (ref.Optional == nil || !*ref.Optional) {
+			return "", fmt.Errorf("Key %s not found in ConfigMap %s", ref.Key, ref.Name)
+		}
+		return data, nil
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		secret := &corev1.Secret{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: znode.Namespace}, secret)
+		if err != nil && !(errors.IsNotFound(err) && ref.Optional != nil && *ref.Optional) {
+			return "", fmt.Errorf("Error getting Secret %s: %v", ref.Name, err)
+		}
+		data, ok := secret.Data[ref.Key]
+		if !ok && (ref.Optional == nil || !*ref.Optional) {
+			return "", fmt.Errorf("Key %s not found in Secret %s", ref.Key, ref.Name)
+		}
+		return string(data), nil
+	}
+	return source.Value, nil
+}
+
+// reconcileZNodeDeletion deletes the znode before releasing its
+// ZookeeperZNode if the deletion policy asks for it. The znode of a deleted
+// cluster is gone with it.
+func (r *ZookeeperZNodeReconciler) reconcileZNodeDeletion(znode *zookeeperv1.ZookeeperZNode) (ctrl.Result, error) {
+	if !utils.ContainsString(znode.Finalizers, utils.ZkZNodeFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	if znode.Spec.DeletesZNode() {
+		cluster, err := r.znodeCluster(znode)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if cluster != nil && cluster.DeletionTimestamp == nil {
+			if err = r.deleteZNode(cluster, znode.Spec.Path); err != nil {
+				r.Log.Info("Failed to delete the znode", "Path", znode.Spec.Path, "Error", err.Error())
+				if znode.Status.Message != err.Error() {
+					znode.Status.Synced = false
+					znode.Status.Message = err.Error()
+					if err := r.Client.Status().Update(context.TODO(), znode); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+				return reconcile.Result{RequeueAfter: ReconcileTime}, nil
+			}
+		}
+	}
+	znode.Finalizers = utils.RemoveString(znode.Finalizers, utils.ZkZNodeFinalizer)
+	return reconcile.Result{}, r.Client.Update(context.TODO(), znode)
+}
+
+func (r *ZookeeperZNodeReconciler) deleteZNode(cluster *zookeeperv1.ZookeeperCluster, path string) error {
+	if err := connectZkClient(r.Client, r.ZkClient, cluster, utils.GetZkServiceUri(cluster)); err != nil {
+		return err
+	}
+	defer r.ZkClient.Close()
+	r.Log.Info("Deleting the znode", "Path", path)
+	return r.ZkClient.DeleteNode(path)
+}
+
+func (r *ZookeeperZNodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&zookeeperv1.ZookeeperZNode{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
@@ -0,0 +1,259 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/utils"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZookeeperZNode Controller", func() {
+	const (
+		Name      = "chroot"
+		Namespace = "default"
+	)
+
+	var (
+		s      = scheme.Scheme
+		r      *ZookeeperZNodeReconciler
+		cl     client.Client
+		req    reconcile.Request
+		z      *api.ZookeeperCluster
+		n      *api.ZookeeperZNode
+		mockZk *MockZookeeperClient
+		res    reconcile.Result
+		err    error
+	)
+
+	BeforeEach(func() {
+		req = reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+		}
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: Namespace,
+			},
+			Status: api.ZookeeperClusterStatus{MetaRootCreated: true},
+		}
+		n = &api.ZookeeperZNode{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Name,
+				Namespace: Namespace,
+			},
+			Spec: api.ZookeeperZNodeSpec{
+				ZookeeperCluster: "example",
+				Path:             "/pravega/chroot",
+				Data:             api.ZNodeData{Value: "v1"},
+			},
+		}
+		mockZk = new(MockZookeeperClient)
+		s.AddKnownTypes(api.GroupVersion, z, n, &api.ZookeeperZNodeList{})
+	})
+
+	reconcileZNode := func(objs ...client.Object) {
+		cl = fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(n).Build()
+		r = &ZookeeperZNodeReconciler{Client: cl, Scheme: s, ZkClient: mockZk}
+		res, err = r.Reconcile(context.TODO(), req)
+	}
+
+	foundZNode := func() *api.ZookeeperZNode {
+		found := &api.ZookeeperZNode{}
+		Ω(cl.Get(context.TODO(), req.NamespacedName, found)).To(Succeed())
+		return found
+	}
+
+	Context("A new znode", func() {
+		BeforeEach(func() {
+			n.Spec.Type = api.ZNodeContainer
+			n.Spec.ACLs = []api.ZNodeACL{
+				{Scheme: "world", Permissions: []api.ZNodePermission{"read"}},
+				{Scheme: "digest", ID: "app:hash", Permissions: []api.ZNodePermission{"read", "write"}},
+			}
+			reconcileZNode(z, n)
+		})
+
+		It("should create the znode", func() {
+			Ω(err).To(BeNil())
+			Ω(mockZk.nodes).To(HaveKeyWithValue("/pravega/chroot", "v1"))
+			Ω(mockZk.containers["/pravega/chroot"]).To(BeTrue())
+		})
+
+		It("should set the ACL of the spec", func() {
+			Ω(mockZk.acls["/pravega/chroot"]).To(ConsistOf(
+				zk.ACL{Scheme: "world", ID: "anyone", Perms: 1},
+				zk.ACL{Scheme: "digest", ID: "app:hash", Perms: 3},
+			))
+		})
+
+		It("should be synced and reconciled again", func() {
+			Ω(foundZNode().Status.Synced).To(BeTrue())
+			Ω(res.RequeueAfter).To(Equal(ReconcileTime))
+		})
+
+		It("should not add the finalizer of the delete policy", func() {
+			Ω(foundZNode().Finalizers).To(BeEmpty())
+		})
+	})
+
+	Context("A znode changed by hand", func() {
+		BeforeEach(func() {
+			n.Spec.ACLs = []api.ZNodeACL{{Scheme: "world", Permissions: []api.ZNodePermission{"all"}}}
+			mockZk.nodes = map[string]string{"/pravega/chroot": "changed"}
+			mockZk.acls = map[string][]zk.ACL{"/pravega/chroot": {{Scheme: "world", ID: "anyone", Perms: 1}}}
+			reconcileZNode(z, n)
+		})
+
+		It("should get the data and ACL of the spec back", func() {
+			Ω(err).To(BeNil())
+			Ω(mockZk.nodes["/pravega/chroot"]).To(Equal("v1"))
+			Ω(mockZk.acls["/pravega/chroot"]).To(Equal([]zk.ACL{{Scheme: "world", ID: "anyone", Perms: 31}}))
+		})
+	})
+
+	Context("A znode without ACLs", func() {
+		BeforeEach(func() {
+			mockZk.nodes = map[string]string{"/pravega/chroot": "v1"}
+			mockZk.acls = map[string][]zk.ACL{"/pravega/chroot": {{Scheme: "auth", Perms: 31}}}
+			reconcileZNode(z, n)
+		})
+
+		It("should keep the ACL of the znode", func() {
+			Ω(err).To(BeNil())
+			Ω(mockZk.acls["/pravega/chroot"]).To(Equal([]zk.ACL{{Scheme: "auth", Perms: 31}}))
+		})
+	})
+
+	Context("A znode with data from a ConfigMap", func() {
+		var cm *corev1.ConfigMap
+
+		BeforeEach(func() {
+			n.Spec.Data = api.ZNodeData{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"},
+					Key:                  "settings",
+				},
+			}
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: Namespace},
+				Data:       map[string]string{"settings": "a=b"},
+			}
+		})
+
+		It("should read the data from the key", func() {
+			reconcileZNode(z, n, cm)
+			Ω(err).To(BeNil())
+			Ω(mockZk.nodes["/pravega/chroot"]).To(Equal("a=b"))
+		})
+
+		It("should not be synced without the ConfigMap", func() {
+			reconcileZNode(z, n)
+			Ω(err).To(BeNil())
+			found := foundZNode()
+			Ω(found.Status.Synced).To(BeFalse())
+			Ω(found.Status.Message).To(ContainSubstring("app-config"))
+			Ω(mockZk.nodes).To(BeEmpty())
+		})
+	})
+
+	Context("A znode of a cluster which is not ready", func() {
+		BeforeEach(func() {
+			z.Status.MetaRootCreated = false
+			reconcileZNode(z, n)
+		})
+
+		It("should wait for the cluster", func() {
+			Ω(err).To(BeNil())
+			Ω(foundZNode().Status.Message).To(ContainSubstring("Waiting for ZookeeperCluster example"))
+			Ω(mockZk.nodes).To(BeEmpty())
+		})
+	})
+
+	Context("A znode of a missing cluster", func() {
+		BeforeEach(func() {
+			reconcileZNode(n)
+		})
+
+		It("should not be synced", func() {
+			Ω(err).To(BeNil())
+			Ω(foundZNode().Status.Message).To(ContainSubstring("not found"))
+		})
+	})
+
+	Context("A znode under the metadata of the operator", func() {
+		BeforeEach(func() {
+			n.Spec.Path = "/zookeeper-operator/example"
+			reconcileZNode(z, n)
+		})
+
+		It("should not be created", func() {
+			Ω(err).To(BeNil())
+			Ω(foundZNode().Status.Synced).To(BeFalse())
+			Ω(mockZk.nodes).To(BeEmpty())
+		})
+	})
+
+	Context("A znode with the delete policy", func() {
+		BeforeEach(func() {
+			n.Spec.DeletionPolicy = api.ZNodeDelete
+		})
+
+		It("should add the finalizer", func() {
+			reconcileZNode(z, n)
+			Ω(err).To(BeNil())
+			Ω(foundZNode().Finalizers).To(ContainElement(utils.ZkZNodeFinalizer))
+		})
+
+		It("should delete the znode and its children when deleted", func() {
+			now := metav1.Now()
+			n.DeletionTimestamp = &now
+			n.Finalizers = []string{utils.ZkZNodeFinalizer}
+			mockZk.nodes = map[string]string{"/pravega/chroot": "v1", "/pravega/chroot/app": "", "/pravega/other": ""}
+			reconcileZNode(z, n)
+			Ω(err).To(BeNil())
+			Ω(mockZk.nodes).To(Equal(map[string]string{"/pravega/other": ""}))
+			Ω(cl.Get(context.TODO(), req.NamespacedName, &api.ZookeeperZNode{})).NotTo(Succeed())
+		})
+	})
+
+	Context("A deleted znode with the orphan policy", func() {
+		BeforeEach(func() {
+			now := metav1.Now()
+			n.DeletionTimestamp = &now
+			n.Finalizers = []string{utils.ZkZNodeFinalizer}
+			mockZk.nodes = map[string]string{"/pravega/chroot": "v1"}
+			reconcileZNode(z, n)
+		})
+
+		It("should keep the znode", func() {
+			Ω(err).To(BeNil())
+			Ω(mockZk.nodes).To(HaveKey("/pravega/chroot"))
+			Ω(cl.Get(context.TODO(), req.NamespacedName, &api.ZookeeperZNode{})).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,78 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+
+# Streams a snapshot from the admin server of the leader to BACKUP_DIR and
+# uploads it to S3_BUCKET when set. The zxid, size, checksum and location of
+# the snapshot are written to the termination log, the operator records them
+# in the status of the backup.
+
+set -e
+
+TERMINATION_LOG=/dev/termination-log
+
+function fail() {
+  echo "$1" | tee $TERMINATION_LOG
+  exit 1
+}
+
+AUTH=()
+if [[ -n "$ADMIN_AUTH_USER" ]]; then
+  AUTH=(-H "Authorization: digest $ADMIN_AUTH_USER:$ADMIN_AUTH_PASSWORD")
+fi
+
+LEADER=""
+for MEMBER in $MEMBERS; do
+  if curl -sf "http://$MEMBER:$ADMIN_SERVER_PORT/commands/leader" | grep -q '"is_leader" *: *true'; then
+    LEADER=$MEMBER
+    break
+  fi
+done
+if [[ -z "$LEADER" ]]; then
+  fail "No leader found among $MEMBERS"
+fi
+echo "Taking a snapshot of $LEADER"
+
+mkdir -p "$BACKUP_DIR"
+SNAPSHOT=$BACKUP_DIR/snapshot.tmp
+HEADERS=$(mktemp)
+# the admin server rate limits snapshots, a 429 fails the backup
+curl -sS -f "${AUTH[@]}" -D "$HEADERS" -o "$SNAPSHOT" \
+  "http://$LEADER:$ADMIN_SERVER_PORT/commands/snapshot?streaming=true" ||
+  fail "Streaming the snapshot from $LEADER failed: $(head -1 "$HEADERS" | tr -d '\r')"
+
+ZXID=$(grep -i '^last_zxid:' "$HEADERS" | tr -d '\r' | awk '{print tolower($2)}')
+ZXID=${ZXID#0x}
+if [[ -z "$ZXID" ]]; then
+  fail "The admin server of $LEADER returned no last_zxid"
+fi
+# zookeeper names its snapshots after the zxid in hex, so that a restored
+# data directory picks it up as is
+FILE=snapshot.$ZXID
+SIZE=$(stat -c %s "$SNAPSHOT")
+CHECKSUM=$(sha256sum "$SNAPSHOT" | cut -d ' ' -f 1)
+
+if [[ -n "$S3_BUCKET" ]]; then
+  KEY=$S3_KEY_PREFIX$FILE
+  curl -sS -f --aws-sigv4 "aws:amz:$S3_REGION:s3" \
+    --user "$AWS_ACCESS_KEY_ID:$AWS_SECRET_ACCESS_KEY" \
+    -H "x-amz-content-sha256: $CHECKSUM" \
+    -T "$SNAPSHOT" "$S3_ENDPOINT/$S3_BUCKET/$KEY" ||
+    fail "Uploading the snapshot to $S3_ENDPOINT/$S3_BUCKET/$KEY failed"
+  rm -f "$SNAPSHOT"
+  LOCATION=s3://$S3_BUCKET/$KEY
+else
+  mv "$SNAPSHOT" "$BACKUP_DIR/$FILE"
+  LOCATION=$BACKUP_LOCATION/$FILE
+fi
+
+echo "Stored snapshot 0x$ZXID of $SIZE bytes at $LOCATION"
+printf '{"zxid":"0x%s","size":%d,"checksum":"%s","location":"%s"}' \
+  "$ZXID" "$SIZE" "$CHECKSUM" "$LOCATION" > $TERMINATION_LOG
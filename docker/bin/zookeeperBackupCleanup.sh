@@ -0,0 +1,39 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+
+# Deletes the snapshot of a deleted backup, from S3_BUCKET when set or else
+# from SNAPSHOT_PATH together with its directory once empty. A snapshot which
+# is already gone is not an error.
+
+set -e
+
+TERMINATION_LOG=/dev/termination-log
+# sha256 of an empty payload, the delete request has no body
+EMPTY_PAYLOAD_SHA256=e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+
+function fail() {
+  echo "$1" | tee $TERMINATION_LOG
+  exit 1
+}
+
+if [[ -n "$S3_BUCKET" ]]; then
+  curl -sS -f -X DELETE --aws-sigv4 "aws:amz:$S3_REGION:s3" \
+    --user "$AWS_ACCESS_KEY_ID:$AWS_SECRET_ACCESS_KEY" \
+    -H "x-amz-content-sha256: $EMPTY_PAYLOAD_SHA256" \
+    "$S3_ENDPOINT/$S3_BUCKET/$S3_KEY" ||
+    fail "Deleting the snapshot $S3_ENDPOINT/$S3_BUCKET/$S3_KEY failed"
+  echo "Deleted s3://$S3_BUCKET/$S3_KEY"
+else
+  rm -f "$SNAPSHOT_PATH" || fail "Deleting the snapshot $SNAPSHOT_PATH failed"
+  # each backup has its own directory, other files in it are kept
+  rmdir --ignore-fail-on-non-empty "$(dirname "$SNAPSHOT_PATH")" 2>/dev/null || true
+  echo "Deleted $SNAPSHOT_PATH"
+fi
@@ -13,7 +13,8 @@
 set -ex
 
 function zkConfig() {
-  echo "$HOST.$DOMAIN:$QUORUM_PORT:$LEADER_PORT:$ROLE;$CLIENT_PORT"
+  # Observers are resolved through their own headless service
+  echo "$HOST.${SERVER_DOMAIN:-$DOMAIN}:$QUORUM_PORT:$LEADER_PORT:$ROLE;$CLIENT_PORT"
 }
 
 function zkConnectionString() {
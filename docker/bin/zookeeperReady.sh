@@ -44,7 +44,7 @@ if [[ "$OK" == "imok" ]]; then
         echo Failed to parse name and ordinal of Pod
         exit 1
     fi
-    MYID=$((ORD+1))
+    MYID=$((ORD+1+${MYID_OFFSET:-0}))
     ONDISK_CONFIG=false
     if [ -f $MYID_FILE ]; then
       EXISTING_ID="`cat $DATA_DIR/myid`"
@@ -55,7 +55,7 @@ if [[ "$OK" == "imok" ]]; then
           DYN_CFG_FILE=${DYN_CFG_FILE_LINE##dynamicConfigFile=}
           SERVER_FOUND=`cat $DYN_CFG_FILE | grep "server.${MYID}=" | wc -l`
           if [[ "$SERVER_FOUND" == "0" ]]; then
-            echo "Server not found in ensemble. Exiting ..."
+            echo "Server not found in ensemble, see the DynamicConfigDrift condition of the cluster. Exiting ..."
             exit 1
           fi
           SERVER=`cat $DYN_CFG_FILE | grep "server.${MYID}="`
@@ -70,6 +70,9 @@ if [[ "$OK" == "imok" ]]; then
     if [[ "$ROLE" == "participant" ]]; then
       echo "Zookeeper service is available and an active participant"
       exit 0
+    elif [[ "$ROLE" == "observer" && "$ZK_ROLE" == "observer" ]]; then
+      echo "Zookeeper service is available and an active observer"
+      exit 0
     elif [[ "$ROLE" == "observer" ]]; then
       echo "Zookeeper service is ready to be upgraded from observer to participant."
       ROLE=participant
@@ -0,0 +1,64 @@
+#!/usr/bin/env bash
+#
+# Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+
+# Seeds the data volume of a member of a restored cluster before it first
+# starts. The snapshot, myid, dynamic config and static config written here
+# make zookeeperStart.sh start the member as it would restart it, as a
+# participant of the restored ensemble which neither bootstraps nor registers.
+
+set -e
+
+DATA_DIR=/data
+SNAPSHOT_DIR=$DATA_DIR/version-2
+TERMINATION_LOG=/dev/termination-log
+# sha256 of an empty payload, the snapshot download has no body
+EMPTY_PAYLOAD_SHA256=e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+
+function fail() {
+  echo "$1" | tee $TERMINATION_LOG
+  exit 1
+}
+
+echo "Seeding member $MYID with $SNAPSHOT_FILE"
+# the volume may hold the data of a former cluster of the same name
+rm -rf $SNAPSHOT_DIR $DATA_DIR/conf $DATA_DIR/myid $DATA_DIR/zoo.cfg.dynamic*
+# so may the volume of the transaction logs, when they have one
+DATA_LOG_DIR=$(sed -n 's/^dataLogDir=//p' /conf/zoo.cfg)
+if [[ -n "$DATA_LOG_DIR" ]]; then
+  rm -rf $DATA_LOG_DIR/version-2
+fi
+mkdir -p $SNAPSHOT_DIR $DATA_DIR/conf
+
+DOWNLOAD=$SNAPSHOT_DIR/$SNAPSHOT_FILE.tmp
+if [[ -n "$S3_BUCKET" ]]; then
+  curl -sS -f --aws-sigv4 "aws:amz:$S3_REGION:s3" \
+    --user "$AWS_ACCESS_KEY_ID:$AWS_SECRET_ACCESS_KEY" \
+    -H "x-amz-content-sha256: $EMPTY_PAYLOAD_SHA256" \
+    -o "$DOWNLOAD" "$S3_ENDPOINT/$S3_BUCKET/$S3_KEY" ||
+    fail "Downloading the snapshot from $S3_ENDPOINT/$S3_BUCKET/$S3_KEY failed"
+else
+  cp "$SNAPSHOT_PATH" "$DOWNLOAD" || fail "Copying the snapshot from $SNAPSHOT_PATH failed"
+fi
+
+if [[ -n "$SNAPSHOT_CHECKSUM" ]]; then
+  CHECKSUM=$(sha256sum "$DOWNLOAD" | cut -d ' ' -f 1)
+  if [[ "$CHECKSUM" != "$SNAPSHOT_CHECKSUM" ]]; then
+    fail "The checksum of the snapshot is $CHECKSUM, expected $SNAPSHOT_CHECKSUM"
+  fi
+fi
+mv "$DOWNLOAD" "$SNAPSHOT_DIR/$SNAPSHOT_FILE"
+
+echo $MYID > $DATA_DIR/myid
+printf '%s' "$DYNAMIC_CONFIG" > $DATA_DIR/zoo.cfg.dynamic
+# zookeeperStart.sh keeps the last line of this copy, which points at the
+# dynamic config above, and takes the rest from /conf
+cp /conf/zoo.cfg $DATA_DIR/conf/zoo.cfg
+echo "Member $MYID seeded"
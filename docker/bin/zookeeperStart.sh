@@ -30,7 +30,8 @@ else
     exit 1
 fi
 
-MYID=$((ORD+1))
+# Observers are numbered after the voting members
+MYID=$((ORD+1+${MYID_OFFSET:-0}))
 
 # Values for first startup
 WRITE_CONFIGURATION=true
@@ -152,6 +153,15 @@ cp -f /conf/log4j.properties $ZOOCFGDIR
 cp -f /conf/log4j-quiet.properties $ZOOCFGDIR
 cp -f /conf/env.sh $ZOOCFGDIR
 
+# A member which ran before the transaction logs got a volume of their own
+# has them with its snapshots, zookeeper refuses to start until they move
+DATA_LOG_DIR=$(sed -n 's/^dataLogDir=//p' $STATIC_CONFIG)
+if [[ -n "$DATA_LOG_DIR" && "$DATA_LOG_DIR" != "$DATA_DIR" ]] && ls $DATA_DIR/version-2/log.* >/dev/null 2>&1; then
+  echo Moving the transaction logs to $DATA_LOG_DIR
+  mkdir -p $DATA_LOG_DIR/version-2
+  mv -f $DATA_DIR/version-2/log.* $DATA_LOG_DIR/version-2/
+fi
+
 if [ -f $DYNCONFIG ]; then
   # Node registered, start server
   echo Starting zookeeper service
@@ -38,12 +38,20 @@ set -e
 MYID=`cat $MYID_FILE`
 
 ZNODE_PATH="/zookeeper-operator/$CLUSTER_NAME"
+if [[ "$ZK_ROLE" == "observer" ]]; then
+  # The operator records the number of observers separately
+  ZNODE_PATH="$ZNODE_PATH/observers"
+fi
 CLUSTERSIZE=`java -Dlog4j.configuration=file:"$LOG4J_CONF" -jar /opt/libs/zu.jar sync $ZKURL $ZNODE_PATH`
 echo "CLUSTER_SIZE=$CLUSTERSIZE, MyId=$MYID"
-if [[ -n "$CLUSTERSIZE" && "$CLUSTERSIZE" -lt "$MYID" ]]; then
-  # If ClusterSize < MyId, this server is being permanantly removed.
+if [[ -n "$CLUSTERSIZE" && "$CLUSTERSIZE" -lt "$((MYID-${MYID_OFFSET:-0}))" ]]; then
+  # If ClusterSize < MyId, this server is being permanantly removed. The
+  # operator removes it from the dynamic config before scaling down, this is
+  # only a fallback for the operators which do not.
+  set +e
   java -Dlog4j.configuration=file:"$LOG4J_CONF" -jar /opt/libs/zu.jar remove $ZKURL $MYID
   echo $?
+  set -e
 fi
 
 # Kill the primary process ourselves to circumvent the terminationGracePeriodSeconds
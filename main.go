@@ -22,6 +22,7 @@ import (
 	zkConfig "github.com/pravega/zookeeper-operator/pkg/controller/config"
 	"github.com/pravega/zookeeper-operator/pkg/utils"
 	"github.com/pravega/zookeeper-operator/pkg/version"
+	"github.com/pravega/zookeeper-operator/pkg/webhook"
 	zkClient "github.com/pravega/zookeeper-operator/pkg/zk"
 	"github.com/sirupsen/logrus"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
@@ -34,23 +35,34 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	api "github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	apiv1beta1 "github.com/pravega/zookeeper-operator/api/v1beta1"
 	"github.com/pravega/zookeeper-operator/controllers"
 	// +kubebuilder:scaffold:imports
 )
 
 var (
-	log         = ctrl.Log.WithName("cmd")
-	versionFlag bool
-	scheme      = apimachineryruntime.NewScheme()
+	log                   = ctrl.Log.WithName("cmd")
+	versionFlag           bool
+	webhookFlag           bool
+	conversionWebhookFlag bool
+	scheme                = apimachineryruntime.NewScheme()
 )
 
 func init() {
 	flag.BoolVar(&versionFlag, "version", false, "Show version and quit")
 	flag.BoolVar(&zkConfig.DisableFinalizer, "disableFinalizer", false,
 		"Disable finalizers for zookeeperclusters. Use this flag with awareness of the consequences")
+	flag.BoolVar(&webhookFlag, "webhook", true,
+		"Enable the defaulting and validating admission webhooks for zookeeperclusters, which also serve the conversion webhook. "+
+			"Requires serving certificates in the webhook certificate directory")
+	flag.BoolVar(&conversionWebhookFlag, "conversionWebhook", true,
+		"Serve the conversion webhook for zookeeperclusters, without which the v1beta1 API cannot be used, even with the admission webhooks disabled. "+
+			"Requires serving certificates in the webhook certificate directory")
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(api.AddToScheme(scheme))
+	// v1beta1 is served through the conversion webhook
+	utilruntime.Must(apiv1beta1.AddToScheme(scheme))
 }
 
 func printVersion() {
@@ -127,14 +139,52 @@ func main() {
 	}
 
 	if err = (&controllers.ZookeeperClusterReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("ZookeeperCluster"),
+		Scheme:      mgr.GetScheme(),
+		ZkClient:    new(zkClient.DefaultZookeeperClient),
+		AdminClient: new(zkClient.DefaultAdminClient),
+		Recorder:    mgr.GetEventRecorderFor("zookeeper-operator"),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "ZookeeperCluster")
+		os.Exit(1)
+	}
+	if err = (&controllers.ZookeeperBackupReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ZookeeperBackup"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "ZookeeperBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.ZookeeperBackupScheduleReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ZookeeperBackupSchedule"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "ZookeeperBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.ZookeeperZNodeReconciler{
 		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("ZookeeperCluster"),
+		Log:      ctrl.Log.WithName("controllers").WithName("ZookeeperZNode"),
 		Scheme:   mgr.GetScheme(),
 		ZkClient: new(zkClient.DefaultZookeeperClient),
 	}).SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "ZookeeperCluster")
+		log.Error(err, "unable to create controller", "controller", "ZookeeperZNode")
 		os.Exit(1)
 	}
+	if webhookFlag {
+		if err = webhook.SetupZookeeperClusterWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "ZookeeperCluster")
+			os.Exit(1)
+		}
+	} else if conversionWebhookFlag {
+		webhook.SetupZookeeperClusterConversionWebhookWithManager(mgr)
+		logrus.Warn("----- Running with admission webhooks disabled. -----")
+	} else {
+		logrus.Warn("----- Running with admission and conversion webhooks disabled, only the v1 API can be used. -----")
+	}
 	// +kubebuilder:scaffold:builder
 
 	log.Info("starting manager")
@@ -0,0 +1,88 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CRDName is the name of the ZookeeperCluster custom resource definition
+const CRDName = "zookeeperclusters.zookeeper.pravega.io"
+
+// MigrateStorageVersion rewrites every ZookeeperCluster in the current storage
+// version of the CRD and then removes the older versions from the CRD
+// status.storedVersions, so that they can be dropped from the CRD later on.
+func MigrateStorageVersion(ctx context.Context, c client.Client, log logr.Logger) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err := c.Get(ctx, types.NamespacedName{Name: CRDName}, crd)
+	if err != nil {
+		return fmt.Errorf("failed to get crd %s: %v", CRDName, err)
+	}
+	storageVersion := ""
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			storageVersion = v.Name
+		}
+	}
+	if storageVersion != api.GroupVersion.Version {
+		return fmt.Errorf("crd %s stores version %q, apply the %s crd before migrating", CRDName, storageVersion, api.GroupVersion.Version)
+	}
+
+	list := &api.ZookeeperClusterList{}
+	err = c.List(ctx, list)
+	if err != nil {
+		return fmt.Errorf("failed to list zookeeper clusters: %v", err)
+	}
+	for _, item := range list.Items {
+		key := client.ObjectKeyFromObject(&item)
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			z := &api.ZookeeperCluster{}
+			if err := c.Get(ctx, key, z); err != nil {
+				return err
+			}
+			// An unchanged update is enough for the api server to encode
+			// the object again in the storage version
+			return c.Update(ctx, z)
+		})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate zookeeper cluster %s: %v", key, err)
+		}
+		log.Info("Migrated zookeeper cluster", "ZookeeperCluster", key.String(), "Version", storageVersion)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, types.NamespacedName{Name: CRDName}, crd); err != nil {
+			return err
+		}
+		if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == storageVersion {
+			return nil
+		}
+		crd.Status.StoredVersions = []string{storageVersion}
+		return c.Status().Update(ctx, crd)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update stored versions of crd %s: %v", CRDName, err)
+	}
+	log.Info("Updated crd stored versions", "CRD", CRDName, "StoredVersions", crd.Status.StoredVersions)
+	return nil
+}
@@ -0,0 +1,97 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package migration
+
+import (
+	"context"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storage version migration", func() {
+	var (
+		s   *runtime.Scheme
+		crd *apiextensionsv1.CustomResourceDefinition
+		cl  client.Client
+		err error
+	)
+
+	BeforeEach(func() {
+		s = runtime.NewScheme()
+		Ω(api.AddToScheme(s)).To(Succeed())
+		Ω(apiextensionsv1.AddToScheme(s)).To(Succeed())
+		crd = &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: CRDName},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{Name: "v1", Served: true, Storage: true},
+					{Name: "v1beta1", Served: true},
+				},
+			},
+			Status: apiextensionsv1.CustomResourceDefinitionStatus{
+				StoredVersions: []string{"v1beta1", "v1"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		objs := []client.Object{crd}
+		for _, name := range []string{"zk1", "zk2"} {
+			objs = append(objs, &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			})
+		}
+		cl = fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(crd).Build()
+		err = MigrateStorageVersion(context.TODO(), cl, logf.Log)
+	})
+
+	Context("with v1 as the storage version", func() {
+		It("should rewrite every cluster", func() {
+			Ω(err).To(BeNil())
+			for _, name := range []string{"zk1", "zk2"} {
+				z := &api.ZookeeperCluster{}
+				Ω(cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "default"}, z)).To(Succeed())
+				// the fake client seeds objects with resource version 999 and bumps it on every write
+				Ω(z.ResourceVersion).NotTo(Equal("999"))
+			}
+		})
+
+		It("should only keep v1 in the stored versions", func() {
+			found := &apiextensionsv1.CustomResourceDefinition{}
+			Ω(cl.Get(context.TODO(), types.NamespacedName{Name: CRDName}, found)).To(Succeed())
+			Ω(found.Status.StoredVersions).To(Equal([]string{"v1"}))
+		})
+	})
+
+	Context("with v1beta1 still the storage version", func() {
+		BeforeEach(func() {
+			crd.Spec.Versions[0].Storage = false
+			crd.Spec.Versions[1].Storage = true
+		})
+
+		It("should fail without touching the crd", func() {
+			Ω(err).NotTo(BeNil())
+			found := &apiextensionsv1.CustomResourceDefinition{}
+			Ω(cl.Get(context.TODO(), types.NamespacedName{Name: CRDName}, found)).To(Succeed())
+			Ω(found.Status.StoredVersions).To(Equal([]string{"v1beta1", "v1"}))
+		})
+	})
+})
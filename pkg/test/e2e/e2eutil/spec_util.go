@@ -11,7 +11,7 @@
 package e2eutil
 
 import (
-	api "github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -44,7 +44,7 @@ func NewClusterWithVersion(namespace, version string) *api.ZookeeperCluster {
 func NewClusterWithEmptyDir(namespace string) *api.ZookeeperCluster {
 	cluster := NewDefaultCluster(namespace)
 	cluster.Spec = api.ZookeeperClusterSpec{
-		StorageType: "ephemeral",
+		StorageType: api.StorageTypeEphemeral,
 	}
 	return cluster
 }
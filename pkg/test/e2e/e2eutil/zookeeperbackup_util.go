@@ -0,0 +1,62 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package e2eutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+var BackupTimeout = time.Minute * 5
+
+// NewBackup returns a backup of the cluster to the given claim
+func NewBackup(namespace, name, cluster, claim string) *api.ZookeeperBackup {
+	return &api.ZookeeperBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: api.ZookeeperBackupSpec{
+			ZookeeperCluster: cluster,
+			Target: api.BackupTarget{
+				PVC: &api.PVCTarget{ClaimName: claim},
+			},
+		},
+	}
+}
+
+// WaitForBackupToFinish will wait until the backup succeeds or fails, and
+// returns it
+func WaitForBackupToFinish(logger logr.Logger, k8client client.Client, b *api.ZookeeperBackup) (*api.ZookeeperBackup, error) {
+	logger.Info(fmt.Sprintf("waiting for backup to finish: %s", b.Name))
+	backup := &api.ZookeeperBackup{}
+	err := wait.PollUntilContextTimeout(context.TODO(), RetryInterval, BackupTimeout, false, func(ctx context.Context) (done bool, err error) {
+		if err := k8client.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Name}, backup); err != nil {
+			return false, fmt.Errorf("failed to obtain backup: %v", err)
+		}
+		logger.Info(fmt.Sprintf("waiting for backup to finish (phase: %s)", backup.Status.Phase))
+		return backup.Status.Finished(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("backup finished: %s (%s)", b.Name, backup.Status.Phase))
+	return backup, nil
+}
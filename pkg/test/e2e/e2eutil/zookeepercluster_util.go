@@ -18,13 +18,14 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 
-	api "github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 )
 
 var (
@@ -95,7 +96,7 @@ func WaitForClusterToBecomeReady(logger logr.Logger, k8client client.Client, z *
 
 		logger.Info(fmt.Sprintf("waiting for pods to become ready (%d/%d), pods (%v)", cluster.Status.ReadyReplicas, size, cluster.Status.Members.Ready))
 		_, condition := cluster.Status.GetClusterCondition(api.ClusterConditionPodsReady)
-		if condition != nil && condition.Status == corev1.ConditionTrue && cluster.Status.ReadyReplicas == int32(size) {
+		if condition != nil && condition.Status == metav1.ConditionTrue && cluster.Status.ReadyReplicas == int32(size) {
 			return true, nil
 		}
 		return false, nil
@@ -123,11 +124,11 @@ func WaitForClusterToUpgrade(logger logr.Logger, k8client client.Client, z *api.
 
 		logger.Info(fmt.Sprintf("waiting for cluster to upgrade (upgrading: %s; error: %s)", upgradeCondition.Status, errorCondition.Status))
 
-		if errorCondition.Status == corev1.ConditionTrue {
+		if errorCondition.Status == metav1.ConditionTrue {
 			return false, fmt.Errorf("failed upgrading cluster: [%s] %s", errorCondition.Reason, errorCondition.Message)
 		}
 
-		if upgradeCondition.Status == corev1.ConditionFalse && cluster.Status.CurrentVersion == targetVersion {
+		if upgradeCondition.Status == metav1.ConditionFalse && cluster.Status.CurrentVersion == targetVersion {
 			// Cluster upgraded
 			return true, nil
 		}
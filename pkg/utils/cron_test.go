@@ -0,0 +1,70 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package utils
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cron schedules", func() {
+	// a monday
+	start := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	next := func(expr string, t time.Time) time.Time {
+		s, err := ParseCron(expr)
+		Ω(err).To(BeNil())
+		return s.Next(t)
+	}
+
+	It("should run daily at the given time", func() {
+		Ω(next("0 2 * * *", start)).To(Equal(time.Date(2024, 1, 16, 2, 0, 0, 0, time.UTC)))
+		Ω(next("@daily", start)).To(Equal(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("should run strictly after the given time", func() {
+		Ω(next("30 10 * * *", start)).To(Equal(time.Date(2024, 1, 16, 10, 30, 0, 0, time.UTC)))
+		Ω(next("*/15 * * * *", start)).To(Equal(time.Date(2024, 1, 15, 10, 45, 0, 0, time.UTC)))
+	})
+
+	It("should accept ranges, steps and lists", func() {
+		Ω(next("0 8-18/4 * * *", start)).To(Equal(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)))
+		Ω(next("5,10 23 * * *", start)).To(Equal(time.Date(2024, 1, 15, 23, 5, 0, 0, time.UTC)))
+	})
+
+	It("should match the day of week, with 7 as sunday", func() {
+		Ω(next("0 0 * * 7", start)).To(Equal(time.Date(2024, 1, 21, 0, 0, 0, 0, time.UTC)))
+		Ω(next("0 0 * * 3", start)).To(Equal(time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("should match either day field when both are restricted", func() {
+		Ω(next("0 0 1 * 3", start)).To(Equal(time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)))
+		Ω(next("0 0 16 * 0", start)).To(Equal(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("should roll over months and years", func() {
+		Ω(next("0 0 29 2 *", start)).To(Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)))
+		Ω(next("@yearly", start)).To(Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("should return the zero time for a schedule which never runs", func() {
+		Ω(next("0 0 30 2 *", start).IsZero()).To(BeTrue())
+	})
+
+	It("should reject invalid expressions", func() {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := ParseCron(expr)
+			Ω(err).NotTo(BeNil(), expr)
+		}
+	})
+})
@@ -17,6 +17,11 @@ import (
 
 const (
 	ZkFinalizer = "cleanUpZookeeperPVC"
+	// ZkBackupFinalizer makes the deletion of a backup delete its snapshot
+	ZkBackupFinalizer = "cleanUpZookeeperBackup"
+	// ZkZNodeFinalizer makes the deletion of a ZookeeperZNode delete its
+	// znode
+	ZkZNodeFinalizer = "cleanUpZookeeperZNode"
 )
 
 func ContainsString(slice []string, str string) bool {
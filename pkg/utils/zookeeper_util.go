@@ -14,7 +14,7 @@ import (
 	"fmt"
 	"strconv"
 
-	v1beta1 "github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -23,16 +23,32 @@ const (
 	ZKMetaRoot = "/zookeeper-operator"
 )
 
-func GetZkServiceUri(zoo *v1beta1.ZookeeperCluster) (zkUri string) {
-	zkClientPort, _ := ContainerPortByName(zoo.Spec.Ports, "client")
-	zkUri = zoo.GetClientServiceName() + "." + zoo.GetNamespace() + ".svc." + zoo.GetKubernetesClusterDomain() + ":" + strconv.Itoa(int(zkClientPort))
+// GetZkServiceUri returns the address of the client service, on the secure
+// client port if client TLS is enabled
+func GetZkServiceUri(zoo *api.ZookeeperCluster) (zkUri string) {
+	zkClientPort := zoo.Spec.Ports.Client
+	if zoo.Spec.TLS.ClientEnabled() {
+		zkClientPort = zoo.Spec.Ports.SecureClient
+	}
+	zkUri = GetZkServiceHost(zoo) + ":" + strconv.Itoa(int(zkClientPort))
 	return zkUri
 }
 
-func GetMetaPath(zoo *v1beta1.ZookeeperCluster) (path string) {
+// GetZkServiceHost returns the fully qualified name of the client service
+func GetZkServiceHost(zoo *api.ZookeeperCluster) string {
+	return zoo.GetClientServiceName() + "." + zoo.GetNamespace() + ".svc." + zoo.GetKubernetesClusterDomain()
+}
+
+func GetMetaPath(zoo *api.ZookeeperCluster) (path string) {
 	return fmt.Sprintf("%s/%s", ZKMetaRoot, zoo.Name)
 }
 
+// GetObserversMetaPath returns the znode holding the number of observers,
+// which the observers check when they shut down
+func GetObserversMetaPath(zoo *api.ZookeeperCluster) (path string) {
+	return GetMetaPath(zoo) + "/observers"
+}
+
 // ContainerPortByName returns a container port of name provided
 func ContainerPortByName(ports []corev1.ContainerPort, name string) (cPort int32, err error) {
 	for _, port := range ports {
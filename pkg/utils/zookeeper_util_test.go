@@ -10,7 +10,7 @@
 package utils
 
 import (
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	. "github.com/onsi/ginkgo"
@@ -20,9 +20,9 @@ import (
 var _ = Describe("Zookeeper Utils", func() {
 
 	Context("with defaults", func() {
-		var zkuri, path, containerport string
+		var zkuri, path, observersPath, containerport string
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
@@ -31,7 +31,8 @@ var _ = Describe("Zookeeper Utils", func() {
 			z.WithDefaults()
 			zkuri = GetZkServiceUri(z)
 			path = GetMetaPath(z)
-			_, err := ContainerPortByName(z.Spec.Ports, "cl")
+			observersPath = GetObserversMetaPath(z)
+			_, err := ContainerPortByName(z.Spec.Ports.ContainerPorts(), "cl")
 			if err != nil {
 				containerport = err.Error()
 			}
@@ -42,8 +43,31 @@ var _ = Describe("Zookeeper Utils", func() {
 		It("should set the path", func() {
 			Ω(path).To(Equal("/zookeeper-operator/example"))
 		})
+		It("should set the observers path", func() {
+			Ω(observersPath).To(Equal("/zookeeper-operator/example/observers"))
+		})
 		It("should give error message", func() {
 			Ω(containerport).To(Equal("port not found"))
 		})
 	})
+
+	Context("with client TLS", func() {
+		var zkuri string
+		BeforeEach(func() {
+			z := &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					TLS: &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}},
+				},
+			}
+			z.WithDefaults()
+			zkuri = GetZkServiceUri(z)
+		})
+		It("should use the secure client port", func() {
+			Ω(zkuri).To(Equal("example-client.default.svc.cluster.local:2281"))
+		})
+	})
 })
@@ -0,0 +1,387 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+)
+
+// MaxReplicas is the largest ensemble size supported by the operator
+const MaxReplicas = 7
+
+var log = logf.Log.WithName("webhook_zookeepercluster")
+
+// SetupZookeeperClusterWebhookWithManager registers the ZookeeperCluster
+// admission webhooks with the manager's webhook server
+func SetupZookeeperClusterWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&api.ZookeeperCluster{}).
+		WithDefaulter(&ZookeeperClusterDefaulter{}).
+		WithValidator(&ZookeeperClusterValidator{}).
+		Complete()
+}
+
+// SetupZookeeperClusterConversionWebhookWithManager serves the conversion
+// webhook of v1beta1 alone, which the admission webhooks serve otherwise
+func SetupZookeeperClusterConversionWebhookWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register("/convert", conversion.NewWebhookHandler(mgr.GetScheme()))
+}
+
+// +kubebuilder:webhook:path=/mutate-zookeeper-pravega-io-v1-zookeepercluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=zookeeper.pravega.io,resources=zookeeperclusters,verbs=create;update,versions=v1,name=mzookeepercluster.kb.io,admissionReviewVersions=v1
+
+// ZookeeperClusterDefaulter fills in the defaults of a ZookeeperCluster spec
+// when it is stored, so the operator never has to write them back itself
+type ZookeeperClusterDefaulter struct{}
+
+var _ admission.CustomDefaulter = &ZookeeperClusterDefaulter{}
+
+// Default applies the ZookeeperCluster defaults
+func (d *ZookeeperClusterDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	z, err := toZookeeperCluster(obj)
+	if err != nil {
+		return err
+	}
+	if z.WithDefaults() {
+		log.Info("Applying defaults", "Namespace", z.Namespace, "Name", z.Name)
+	}
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-zookeeper-pravega-io-v1-zookeepercluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=zookeeper.pravega.io,resources=zookeeperclusters,verbs=create;update,versions=v1,name=vzookeepercluster.kb.io,admissionReviewVersions=v1
+
+// ZookeeperClusterValidator rejects ZookeeperCluster specs the operator
+// cannot reconcile, and updates that would break an existing cluster
+type ZookeeperClusterValidator struct{}
+
+var _ admission.CustomValidator = &ZookeeperClusterValidator{}
+
+// ValidateCreate validates a new ZookeeperCluster
+func (v *ZookeeperClusterValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	z, err := toZookeeperCluster(obj)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Validating create", "Namespace", z.Namespace, "Name", z.Name)
+	return nil, invalid(z, validateSpec(z))
+}
+
+// ValidateUpdate validates an update to an existing ZookeeperCluster
+func (v *ZookeeperClusterValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldZk, err := toZookeeperCluster(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	z, err := toZookeeperCluster(newObj)
+	if err != nil {
+		return nil, err
+	}
+	// Updates which leave the spec alone (finalizers, labels, ...) must not be
+	// blocked by a spec that was stored before this webhook was installed.
+	if equality.Semantic.DeepEqual(oldZk.Spec, z.Spec) {
+		return nil, nil
+	}
+	log.Info("Validating update", "Namespace", z.Namespace, "Name", z.Name)
+	allErrs := validateSpec(z)
+	allErrs = append(allErrs, validateSpecUpdate(oldZk, z)...)
+	var warnings admission.Warnings
+	if refusal := z.UpgradeRefusal(); refusal != nil {
+		warnings = append(warnings, fmt.Sprintf("%v, the members keep running %s unless the cluster is annotated with %s=%s",
+			refusal, z.Status.CurrentVersion, api.AllowUpgradeAnnotation, z.Spec.Image.Tag))
+	}
+	return warnings, invalid(z, allErrs)
+}
+
+// ValidateDelete allows every ZookeeperCluster to be deleted
+func (v *ZookeeperClusterValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func toZookeeperCluster(obj runtime.Object) (*api.ZookeeperCluster, error) {
+	z, ok := obj.(*api.ZookeeperCluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a ZookeeperCluster but got a %T", obj)
+	}
+	return z, nil
+}
+
+func invalid(z *api.ZookeeperCluster, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(api.GroupVersion.WithKind("ZookeeperCluster").GroupKind(), z.Name, allErrs)
+}
+
+// withDefaults returns a copy of the cluster with defaults applied, so that
+// values the operator fills in are validated the same way as explicit ones
+func withDefaults(z *api.ZookeeperCluster) *api.ZookeeperCluster {
+	d := z.DeepCopy()
+	d.WithDefaults()
+	return d
+}
+
+func validateSpec(z *api.ZookeeperCluster) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+
+	// A replica count of 0 is replaced by the default size
+	if z.Spec.Replicas < 0 || z.Spec.Replicas > MaxReplicas {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), z.Spec.Replicas,
+			fmt.Sprintf("must be between 1 and %d", MaxReplicas)))
+	}
+	if o := z.Spec.Observers; o != nil && (o.Replicas < 0 || o.Replicas > api.MaxObservers) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("observers", "replicas"), o.Replicas,
+			fmt.Sprintf("must be between 0 and %d", api.MaxObservers)))
+	}
+	allErrs = append(allErrs, validateStorage(&z.Spec, specPath)...)
+
+	allErrs = append(allErrs, validateTLS(&z.Spec, specPath)...)
+	allErrs = append(allErrs, validateAuth(z.Spec.Auth, specPath.Child("auth"))...)
+	allErrs = append(allErrs, validateRestore(&z.Spec, specPath)...)
+	allErrs = append(allErrs, validateUpgradePolicy(z.Spec.UpgradePolicy, specPath.Child("upgradePolicy"))...)
+
+	d := withDefaults(z)
+	allErrs = append(allErrs, validatePorts(&d.Spec.Ports, specPath.Child("ports"))...)
+	allErrs = append(allErrs, validateAdditionalConfig(d, specPath.Child("config", "additionalConfig"))...)
+	return allErrs
+}
+
+func validateUpgradePolicy(policy *api.UpgradePolicy, policyPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if policy == nil {
+		return allErrs
+	}
+	for tag, version := range policy.Versions {
+		if _, err := api.ParseZookeeperVersion(version); err != nil {
+			allErrs = append(allErrs, field.Invalid(policyPath.Child("versions").Key(tag), version, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateStorage(spec *api.ZookeeperClusterSpec, specPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.StorageType != "" && spec.StorageType != api.StorageTypePersistence && spec.StorageType != api.StorageTypeEphemeral {
+		return append(allErrs, field.NotSupported(specPath.Child("storageType"), spec.StorageType,
+			[]string{string(api.StorageTypePersistence), string(api.StorageTypeEphemeral)}))
+	}
+	ephemeral := spec.StorageType == api.StorageTypeEphemeral
+	switch {
+	case spec.Persistence != nil && spec.Ephemeral != nil:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("ephemeral"),
+			"may not be specified together with persistence"))
+	case ephemeral && spec.Persistence != nil:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("persistence"),
+			"may not be specified when storageType is ephemeral"))
+	case !ephemeral && spec.Ephemeral != nil:
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("ephemeral"),
+			"may only be specified when storageType is ephemeral"))
+	}
+	return allErrs
+}
+
+func validateTLS(spec *api.ZookeeperClusterSpec, specPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.TLS.ClientEnabled() && spec.TLS.Client.SecretName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("tls", "client", "secretName"), ""))
+	}
+	if spec.TLS.QuorumEnabled() && spec.TLS.Quorum.SecretName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("tls", "quorum", "secretName"), ""))
+	}
+	return allErrs
+}
+
+func validateAuth(auth *api.Auth, authPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if auth == nil {
+		return allErrs
+	}
+	if auth.SecretName == "" {
+		allErrs = append(allErrs, field.Required(authPath.Child("secretName"), ""))
+	}
+	// the users are the keys of the secret
+	for _, user := range []struct {
+		path *field.Path
+		name string
+	}{
+		{authPath.Child("superUser"), auth.SuperUser},
+		{authPath.Child("quorumUser"), auth.QuorumUser},
+	} {
+		if user.name == "" {
+			continue
+		}
+		for _, msg := range validation.IsConfigMapKey(user.name) {
+			allErrs = append(allErrs, field.Invalid(user.path, user.name, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateRestore(spec *api.ZookeeperClusterSpec, specPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	from := spec.RestoreFrom
+	if from == nil {
+		return allErrs
+	}
+	restorePath := specPath.Child("restoreFrom")
+	if err := from.Validate(); err != nil {
+		allErrs = append(allErrs, field.Invalid(restorePath, "", err.Error()))
+	}
+	if spec.StorageType == api.StorageTypeEphemeral {
+		allErrs = append(allErrs, field.Forbidden(restorePath,
+			"may not be specified when storageType is ephemeral"))
+	}
+	return allErrs
+}
+
+func validatePorts(ports *api.Ports, portsPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	type port struct {
+		path     *field.Path
+		number   int32
+		protocol v1.Protocol
+	}
+	all := []port{
+		{portsPath.Child("client"), ports.Client, v1.ProtocolTCP},
+		{portsPath.Child("quorum"), ports.Quorum, v1.ProtocolTCP},
+		{portsPath.Child("leaderElection"), ports.LeaderElection, v1.ProtocolTCP},
+		{portsPath.Child("metrics"), ports.Metrics, v1.ProtocolTCP},
+		{portsPath.Child("adminServer"), ports.AdminServer, v1.ProtocolTCP},
+	}
+	if ports.SecureClient != 0 {
+		all = append(all, port{portsPath.Child("secureClient"), ports.SecureClient, v1.ProtocolTCP})
+	}
+	// The well-known ports are named after their field in the container
+	names := map[string]bool{}
+	for _, p := range (&api.Ports{SecureClient: api.DefaultSecureClientPort}).ContainerPorts() {
+		names[p.Name] = true
+	}
+	for i, p := range ports.Additional {
+		idxPath := portsPath.Child("additional").Index(i)
+		if p.Name != "" {
+			if names[p.Name] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), p.Name))
+			}
+			names[p.Name] = true
+		}
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = v1.ProtocolTCP
+		}
+		all = append(all, port{idxPath.Child("containerPort"), p.ContainerPort, protocol})
+	}
+	numbers := map[string]bool{}
+	for _, p := range all {
+		if p.number < 1 || p.number > 65535 {
+			allErrs = append(allErrs, field.Invalid(p.path, p.number,
+				"must be between 1 and 65535, inclusive"))
+		}
+		key := fmt.Sprintf("%d/%s", p.number, p.protocol)
+		if numbers[key] {
+			allErrs = append(allErrs, field.Duplicate(p.path, p.number))
+		}
+		numbers[key] = true
+	}
+	return allErrs
+}
+
+func validateAdditionalConfig(z *api.ZookeeperCluster, configPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	reserved := map[string]bool{}
+	for _, key := range zk.ConfigKeys(z) {
+		reserved[key] = true
+	}
+	keys := make([]string, 0, len(z.Spec.Conf.AdditionalConfig))
+	for key := range z.Spec.Conf.AdditionalConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if reserved[key] {
+			allErrs = append(allErrs, field.Forbidden(configPath.Key(key),
+				"is generated by the operator and cannot be overridden"))
+		}
+	}
+	return allErrs
+}
+
+func validateSpecUpdate(oldZk, z *api.ZookeeperCluster) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+	oldSpec := withDefaults(oldZk).Spec
+	newSpec := withDefaults(z).Spec
+
+	allErrs = append(allErrs, apivalidation.ValidateImmutableField(newSpec.StorageType, oldSpec.StorageType, specPath.Child("storageType"))...)
+	if oldSpec.StorageType == api.StorageTypePersistence && newSpec.StorageType == api.StorageTypePersistence &&
+		oldSpec.Persistence != nil && newSpec.Persistence != nil {
+		allErrs = append(allErrs, validateClaimSpecUpdate(
+			&oldSpec.Persistence.PersistentVolumeClaimSpec,
+			&newSpec.Persistence.PersistentVolumeClaimSpec,
+			specPath.Child("persistence", "spec"))...)
+		allErrs = append(allErrs, validateDataLogUpdate(oldSpec.Persistence.DataLog, newSpec.Persistence.DataLog,
+			specPath.Child("persistence", "dataLog"))...)
+	}
+	// a running cluster is never restored, restoreFrom may only be removed
+	if newSpec.RestoreFrom != nil {
+		allErrs = append(allErrs, apivalidation.ValidateImmutableField(newSpec.RestoreFrom, oldSpec.RestoreFrom, specPath.Child("restoreFrom"))...)
+	}
+	return allErrs
+}
+
+// validateClaimSpecUpdate only allows the storage request of the data volumes
+// to grow, the operator expands the claims of the members then
+func validateClaimSpecUpdate(oldClaim, claim *v1.PersistentVolumeClaimSpec, claimPath *field.Path) field.ErrorList {
+	storagePath := claimPath.Child("resources", "requests", string(v1.ResourceStorage))
+	oldSize := oldClaim.Resources.Requests[v1.ResourceStorage]
+	size := claim.Resources.Requests[v1.ResourceStorage]
+	if size.Cmp(oldSize) < 0 {
+		return field.ErrorList{field.Forbidden(storagePath,
+			fmt.Sprintf("may not be decreased below %s", oldSize.String()))}
+	}
+	// any other change is immutable
+	oldRest := oldClaim.DeepCopy()
+	rest := claim.DeepCopy()
+	delete(oldRest.Resources.Requests, v1.ResourceStorage)
+	delete(rest.Resources.Requests, v1.ResourceStorage)
+	return apivalidation.ValidateImmutableField(rest, oldRest, claimPath)
+}
+
+// validateDataLogUpdate allows the transaction logs of a running cluster to be
+// moved to a volume of their own, but not back
+func validateDataLogUpdate(oldDataLog, dataLog *api.DataLogPersistence, dataLogPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if oldDataLog == nil {
+		return allErrs
+	}
+	if dataLog == nil {
+		return append(allErrs, field.Forbidden(dataLogPath, "may not be removed"))
+	}
+	return append(allErrs, apivalidation.ValidateImmutableField(
+		dataLog.PersistentVolumeClaimSpec,
+		oldDataLog.PersistentVolumeClaimSpec,
+		dataLogPath.Child("spec"))...)
+}
@@ -0,0 +1,405 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package webhook_test
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/webhook"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// causeFields returns the field paths reported by an Invalid error
+func causeFields(err error) []string {
+	var fields []string
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, c := range status.Status().Details.Causes {
+			fields = append(fields, c.Field)
+		}
+	}
+	return fields
+}
+
+var _ = Describe("ZookeeperCluster Validating Webhook", func() {
+	var (
+		v   *webhook.ZookeeperClusterValidator
+		z   *api.ZookeeperCluster
+		err error
+	)
+
+	BeforeEach(func() {
+		v = &webhook.ZookeeperClusterValidator{}
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+	})
+
+	Context("#ValidateCreate", func() {
+		It("should accept an empty spec", func() {
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should accept a defaulted spec", func() {
+			z.WithDefaults()
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject more than 7 replicas", func() {
+			z.Spec.Replicas = 8
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(apierrors.IsInvalid(err)).To(BeTrue())
+			Ω(causeFields(err)).To(ConsistOf("spec.replicas"))
+		})
+
+		It("should accept observers", func() {
+			z.Spec.Observers = &api.Observers{Replicas: 3}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject more observers than server ids", func() {
+			z.Spec.Observers = &api.Observers{Replicas: api.MaxObservers + 1}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.observers.replicas"))
+		})
+
+		It("should reject an unknown storage type", func() {
+			z.Spec.StorageType = "nfs"
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.storageType"))
+		})
+
+		It("should accept ephemeral storage", func() {
+			z.Spec.StorageType = api.StorageTypeEphemeral
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject both persistence and ephemeral", func() {
+			z.Spec.Persistence = &api.Persistence{}
+			z.Spec.Ephemeral = &api.Ephemeral{}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ephemeral"))
+		})
+
+		It("should reject persistence with ephemeral storage type", func() {
+			z.Spec.StorageType = "ephemeral"
+			z.Spec.Persistence = &api.Persistence{}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.persistence"))
+		})
+
+		It("should reject ephemeral without ephemeral storage type", func() {
+			z.Spec.Ephemeral = &api.Ephemeral{}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ephemeral"))
+		})
+
+		It("should reject a port colliding with a default port", func() {
+			z.Spec.Ports.Client = 3888
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ports.leaderElection"))
+		})
+
+		It("should reject an additional port colliding with another port", func() {
+			z.Spec.Ports.Additional = []v1.ContainerPort{
+				{Name: "jmx", ContainerPort: 2181},
+			}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ports.additional[0].containerPort"))
+		})
+
+		It("should accept an additional port using another protocol", func() {
+			z.Spec.Ports.Additional = []v1.ContainerPort{
+				{Name: "udp", ContainerPort: 2181, Protocol: v1.ProtocolUDP},
+			}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject additional ports named like a well-known port", func() {
+			z.Spec.Ports.Additional = []v1.ContainerPort{
+				{Name: "client", ContainerPort: 2182},
+				{Name: "jmx", ContainerPort: 9404},
+				{Name: "jmx", ContainerPort: 9405},
+			}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ports.additional[0].name", "spec.ports.additional[2].name"))
+		})
+
+		It("should accept client TLS", func() {
+			z.Spec.TLS = &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject client TLS without a secret", func() {
+			z.Spec.TLS = &api.TLS{Client: &api.ClientTLS{}}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.tls.client.secretName"))
+		})
+
+		It("should reject a secure client port colliding with another port", func() {
+			z.Spec.TLS = &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}}
+			z.Spec.Ports.SecureClient = 8080
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.ports.secureClient"))
+		})
+
+		It("should reject additional config generated by the operator", func() {
+			z.Spec.Conf.AdditionalConfig = map[string]string{
+				"tcpKeepAlive": "true",
+				"dataDir":      "/tmp",
+			}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.config.additionalConfig[dataDir]"))
+		})
+
+		It("should accept quorum TLS", func() {
+			z.Spec.TLS = &api.TLS{Quorum: &api.QuorumTLS{SecretName: "example-quorum-tls"}}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject quorum TLS without a secret", func() {
+			z.Spec.TLS = &api.TLS{Quorum: &api.QuorumTLS{}}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.tls.quorum.secretName"))
+		})
+
+		It("should accept auth", func() {
+			z.Spec.Auth = &api.Auth{SecretName: "example-users", QuorumUser: "quorum"}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject auth without a secret or with invalid user names", func() {
+			z.Spec.Auth = &api.Auth{SuperUser: "super user", QuorumUser: "quorum/1"}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.auth.secretName", "spec.auth.superUser", "spec.auth.quorumUser"))
+		})
+
+		It("should reject additional config managed by quorum TLS", func() {
+			z.Spec.TLS = &api.TLS{Quorum: &api.QuorumTLS{SecretName: "example-quorum-tls"}}
+			z.Spec.Conf.AdditionalConfig = map[string]string{"sslQuorum": "false"}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.config.additionalConfig[sslQuorum]"))
+		})
+
+		It("should accept a restore from a backup", func() {
+			z.Spec.RestoreFrom = &api.RestoreSource{Backup: "example-backup"}
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject a restore with two sources or ephemeral storage", func() {
+			z.Spec.RestoreFrom = &api.RestoreSource{
+				Backup:   "example-backup",
+				Snapshot: &api.SnapshotSource{PVC: &api.PVCSnapshot{ClaimName: "backups", Path: "snapshot.1"}},
+			}
+			z.Spec.StorageType = api.StorageTypeEphemeral
+			_, err = v.ValidateCreate(context.TODO(), z)
+			Ω(causeFields(err)).To(ConsistOf("spec.restoreFrom", "spec.restoreFrom"))
+		})
+	})
+
+	Context("#ValidateUpdate", func() {
+		var next *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z.WithDefaults()
+			next = z.DeepCopy()
+		})
+
+		It("should accept scaling the cluster", func() {
+			next.Spec.Replicas = 5
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should accept applying defaults to a stored spec", func() {
+			_, err = v.ValidateUpdate(context.TODO(), &api.ZookeeperCluster{ObjectMeta: z.ObjectMeta}, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject changing the storage type", func() {
+			next.Spec.StorageType = "ephemeral"
+			next.Spec.Persistence = nil
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.storageType"))
+		})
+
+		It("should reject changing the volume claim spec", func() {
+			class := "fast"
+			next.Spec.Persistence.PersistentVolumeClaimSpec.StorageClassName = &class
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.persistence.spec"))
+		})
+
+		It("should accept growing the volumes", func() {
+			next.Spec.Persistence.PersistentVolumeClaimSpec.Resources.Requests = v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse("40Gi"),
+			}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject shrinking the volumes", func() {
+			next.Spec.Persistence.PersistentVolumeClaimSpec.Resources.Requests = v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse("10Gi"),
+			}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.persistence.spec.resources.requests.storage"))
+		})
+
+		It("should accept moving the transaction logs to a volume of their own", func() {
+			next.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject removing or changing the transaction log volume", func() {
+			z.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+			z.WithDefaults()
+			next = z.DeepCopy()
+			next.Spec.Persistence.DataLog = nil
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.persistence.dataLog"))
+
+			next = z.DeepCopy()
+			next.Spec.Persistence.DataLog.PersistentVolumeClaimSpec.Resources.Requests = v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse("40Gi"),
+			}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.persistence.dataLog.spec"))
+		})
+
+		It("should reject restoring a running cluster", func() {
+			next.Spec.RestoreFrom = &api.RestoreSource{Backup: "example-backup"}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.restoreFrom"))
+		})
+
+		It("should accept removing the restore source", func() {
+			z.Spec.RestoreFrom = &api.RestoreSource{Backup: "example-backup"}
+			next.Spec.RestoreFrom = nil
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should warn about an unsupported upgrade path", func() {
+			z.Status.CurrentVersion = "3.6.4"
+			next.Status.CurrentVersion = "3.6.4"
+			next.Spec.Image.Tag = "3.8.4"
+			warnings, err := v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+			Ω(warnings).To(HaveLen(1))
+			Ω(warnings[0]).To(ContainSubstring("upgrade to 3.7 first"))
+		})
+
+		It("should reject a version mapping which is not a ZooKeeper version", func() {
+			next.Spec.UpgradePolicy = &api.UpgradePolicy{Versions: map[string]string{"0.2.15": "latest"}}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(causeFields(err)).To(ConsistOf("spec.upgradePolicy.versions[0.2.15]"))
+		})
+
+		It("should not block updates that leave an invalid spec alone", func() {
+			z.Spec.Conf.AdditionalConfig = map[string]string{"dataDir": "/tmp"}
+			next = z.DeepCopy()
+			next.Finalizers = []string{"cleanUpZookeeperPVC"}
+			_, err = v.ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+	})
+
+	Context("#ValidateDelete", func() {
+		It("should accept deletion", func() {
+			_, err = v.ValidateDelete(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+	})
+})
+
+var _ = Describe("ZookeeperCluster Defaulting Webhook", func() {
+	var (
+		d   *webhook.ZookeeperClusterDefaulter
+		z   *api.ZookeeperCluster
+		err error
+	)
+
+	BeforeEach(func() {
+		d = &webhook.ZookeeperClusterDefaulter{}
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		}
+	})
+
+	Context("#Default", func() {
+		It("should apply the defaults to an empty spec", func() {
+			err = d.Default(context.TODO(), z)
+			Ω(err).To(BeNil())
+			Ω(z.Spec.Replicas).To(BeEquivalentTo(3))
+			Ω(z.Spec.Image.Repository).To(Equal(api.DefaultZkContainerRepository))
+			Ω(z.Spec.Persistence).NotTo(BeNil())
+		})
+
+		It("should keep values set by the user", func() {
+			z.Spec.Replicas = 5
+			z.Spec.StorageType = "ephemeral"
+			err = d.Default(context.TODO(), z)
+			Ω(err).To(BeNil())
+			Ω(z.Spec.Replicas).To(BeEquivalentTo(5))
+			Ω(z.Spec.Persistence).To(BeNil())
+			Ω(z.Spec.Ephemeral).NotTo(BeNil())
+		})
+
+		It("should produce a spec the validator accepts", func() {
+			err = d.Default(context.TODO(), z)
+			Ω(err).To(BeNil())
+			_, err = (&webhook.ZookeeperClusterValidator{}).ValidateCreate(context.TODO(), z)
+			Ω(err).To(BeNil())
+		})
+
+		It("should let client TLS be disabled on a defaulted cluster", func() {
+			z.Spec.TLS = &api.TLS{Client: &api.ClientTLS{SecretName: "example-tls"}}
+			Ω(d.Default(context.TODO(), z)).To(BeNil())
+			Ω(z.Spec.Ports.SecureClient).To(BeEquivalentTo(api.DefaultSecureClientPort))
+			next := z.DeepCopy()
+			next.Spec.TLS = nil
+			Ω(d.Default(context.TODO(), next)).To(BeNil())
+			Ω(next.Spec.Ports.SecureClient).To(BeZero())
+			Ω(next.Spec.Ports.ContainerPorts()).NotTo(ContainElement(HaveField("Name", "client-tls")))
+			_, err = (&webhook.ZookeeperClusterValidator{}).ValidateUpdate(context.TODO(), z, next)
+			Ω(err).To(BeNil())
+		})
+
+		It("should reject other kinds", func() {
+			err = d.Default(context.TODO(), &v1.Pod{})
+			Ω(err).NotTo(BeNil())
+		})
+	})
+})
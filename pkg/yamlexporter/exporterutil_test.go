@@ -14,7 +14,7 @@ import (
 	"os"
 	"testing"
 
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	. "github.com/onsi/ginkgo"
@@ -30,7 +30,7 @@ var _ = Describe("ZookeeperCluster yamlExporter", func() {
 	Context("with defaults", func() {
 		var err, err2, err3, err4 error
 		BeforeEach(func() {
-			z1 := &v1beta1.ZookeeperCluster{
+			z1 := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
@@ -57,4 +57,33 @@ var _ = Describe("ZookeeperCluster yamlExporter", func() {
 			Ω(err4).To(BeNil())
 		})
 	})
+	Context("with a v1beta1 input file", func() {
+		var (
+			z   *api.ZookeeperCluster
+			err error
+		)
+		BeforeEach(func() {
+			in := []byte(`apiVersion: zookeeper.pravega.io/v1beta1
+kind: ZookeeperCluster
+metadata:
+  name: example
+spec:
+  replicas: 5
+  storageType: ephemeral
+  ports:
+  - containerPort: 2182
+    name: client
+`)
+			Ω(os.WriteFile("v1beta1.yaml", in, 0644)).To(Succeed())
+			z, err = ReadInputClusterYAMLFile("v1beta1.yaml")
+			_ = os.Remove("v1beta1.yaml")
+		})
+		It("should convert it to v1", func() {
+			Ω(err).To(BeNil())
+			Ω(z.APIVersion).To(Equal("zookeeper.pravega.io/v1"))
+			Ω(z.Spec.Replicas).To(BeEquivalentTo(5))
+			Ω(z.Spec.StorageType).To(Equal(api.StorageTypeEphemeral))
+			Ω(z.Spec.Ports.Client).To(BeEquivalentTo(2182))
+		})
+	})
 })
@@ -17,7 +17,9 @@ import (
 
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // YAMLOutputDir where the zookeeper YAML resources will get generated
@@ -72,15 +74,32 @@ func CreateYAMLOutputDir(maindir string) error {
 }
 
 // ReadInputClusterYAMLFile will read input YAML file and returns Go struct for ZookeeperCluster
-func ReadInputClusterYAMLFile(inyamlfile string) (*v1beta1.ZookeeperCluster, error) {
+// v1beta1 input is converted to v1
+func ReadInputClusterYAMLFile(inyamlfile string) (*api.ZookeeperCluster, error) {
 	if _, err := os.Stat(inyamlfile); os.IsNotExist(err) {
 		return nil, err
 	}
-	var z v1beta1.ZookeeperCluster
 	source, err := os.ReadFile(inyamlfile)
 	if err != nil {
 		return nil, err
 	}
+	var t metav1.TypeMeta
+	err = yaml.Unmarshal(source, &t)
+	if err != nil {
+		return nil, err
+	}
+	var z api.ZookeeperCluster
+	if t.APIVersion == v1beta1.GroupVersion.String() {
+		var old v1beta1.ZookeeperCluster
+		err = yaml.Unmarshal(source, &old)
+		if err != nil {
+			return nil, err
+		}
+		err = old.ConvertTo(&z)
+		z.APIVersion = api.GroupVersion.String()
+		z.Kind = t.Kind
+		return &z, err
+	}
 	err = yaml.Unmarshal(source, &z)
 	if err != nil {
 		return nil, err
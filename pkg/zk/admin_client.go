@@ -0,0 +1,151 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+package zk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// AdminClient queries the admin server of a member
+type AdminClient interface {
+	// ServerState returns the state of the member at the given address,
+	// leader, follower, observer, or looking while there is no quorum
+	ServerState(string) (string, error)
+
+	// ServerStats returns the statistics the member at the given address
+	// reports about itself
+	ServerStats(string) (*ServerStats, error)
+
+	// ConfigVersion returns the version of the dynamic config of the member
+	// at the given client address, which the members of a quorum agree on
+	ConfigVersion(string) (int64, error)
+}
+
+type DefaultAdminClient struct {
+	// HTTPClient defaults to a client with a 5 seconds timeout
+	HTTPClient *http.Client
+}
+
+// ServerStats is the part of the statistics of the stat command the
+// operator reads
+type ServerStats struct {
+	ServerState               string  `json:"server_state"`
+	LastProcessedZxid         int64   `json:"last_processed_zxid"`
+	OutstandingRequests       int64   `json:"outstanding_requests"`
+	AvgLatency                float64 `json:"avg_latency"`
+	MaxLatency                int64   `json:"max_latency"`
+	NumAliveClientConnections int64   `json:"num_alive_client_connections"`
+}
+
+// statResponse is the part of the response of the stat command the
+// operator reads
+type statResponse struct {
+	ServerStats ServerStats `json:"server_stats"`
+	Error       *string     `json:"error"`
+}
+
+// ServerState runs the stat command of the admin server at the given
+// host:port
+func (client *DefaultAdminClient) ServerState(address string) (string, error) {
+	stats, err := client.ServerStats(address)
+	if err != nil {
+		return "", err
+	}
+	return stats.ServerState, nil
+}
+
+// ServerStats runs the stat command of the admin server at the given
+// host:port
+func (client *DefaultAdminClient) ServerStats(address string) (*ServerStats, error) {
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := httpClient.Get("http://" + address + "/commands/stat")
+	if err != nil {
+		return nil, fmt.Errorf("Error querying the admin server %s: %v", address, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error querying the admin server %s: %s", address, resp.Status)
+	}
+	stat := &statResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(stat); err != nil {
+		return nil, fmt.Errorf("Invalid response of the admin server %s: %v", address, err)
+	}
+	if stat.Error != nil {
+		return nil, fmt.Errorf("Error querying the admin server %s: %s", address, *stat.Error)
+	}
+	return &stat.ServerStats, nil
+}
+
+// ConfigVersion runs the conf four letter word on the client port at the
+// given host:port, and reads the version of the membership it prints
+func (client *DefaultAdminClient) ConfigVersion(address string) (int64, error) {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return 0, fmt.Errorf("Error querying the member %s: %v", address, err)
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return 0, fmt.Errorf("Error querying the member %s: %v", address, err)
+	}
+	if _, err = conn.Write([]byte("conf")); err != nil {
+		return 0, fmt.Errorf("Error querying the member %s: %v", address, err)
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return 0, fmt.Errorf("Error querying the member %s: %v", address, err)
+	}
+	return ParseConfigVersion(string(data))
+}
+
+// ParseConfigVersion returns the version of the membership in the output of
+// the conf four letter word
+func ParseConfigVersion(conf string) (int64, error) {
+	_, membership, found := strings.Cut(conf, "membership:")
+	if !found {
+		return 0, fmt.Errorf("The member does not report its membership")
+	}
+	config, err := ParseDynamicConfig(membership)
+	if err != nil {
+		return 0, err
+	}
+	if config.Version == -1 {
+		return 0, fmt.Errorf("The member does not report the version of its membership")
+	}
+	return config.Version, nil
+}
+
+// MemberAdminAddress returns the address of the admin server of the member
+// with the given pod name
+func MemberAdminAddress(z *api.ZookeeperCluster, pod string) string {
+	return fmt.Sprintf("%s.%s:%d", pod, headlessDomain(z), z.Spec.Ports.AdminServer)
+}
+
+// MemberClientAddress returns the address of the client port of the member
+// with the given pod name
+func MemberClientAddress(z *api.ZookeeperCluster, pod string) string {
+	return fmt.Sprintf("%s.%s:%d", pod, headlessDomain(z), z.Spec.Ports.Client)
+}
+
+// ObserverAdminAddress returns the address of the admin server of the
+// observer with the given pod name
+func ObserverAdminAddress(z *api.ZookeeperCluster, pod string) string {
+	return fmt.Sprintf("%s.%s:%d", pod, observerHeadlessDomain(z), z.Spec.Ports.AdminServer)
+}
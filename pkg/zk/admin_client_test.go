@@ -0,0 +1,92 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Admin Client", func() {
+	var (
+		server   *httptest.Server
+		response string
+		status   int
+		path     string
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	address := func() string {
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+
+	It("should return the state of the member", func() {
+		response = `{"version":"3.9.3","read_only":false,"server_stats":{"server_state":"leader"},"command":"stats","error":null}`
+		state, err := new(zk.DefaultAdminClient).ServerState(address())
+		Ω(err).To(BeNil())
+		Ω(state).To(Equal("leader"))
+		Ω(path).To(Equal("/commands/stat"))
+	})
+
+	It("should return the statistics of the member", func() {
+		response = `{"server_stats":{"server_state":"follower","last_processed_zxid":8589934597,"outstanding_requests":3,` +
+			`"avg_latency":0.5,"max_latency":12,"num_alive_client_connections":7},"command":"stats","error":null}`
+		stats, err := new(zk.DefaultAdminClient).ServerStats(address())
+		Ω(err).To(BeNil())
+		Ω(*stats).To(Equal(zk.ServerStats{
+			ServerState:               "follower",
+			LastProcessedZxid:         0x200000005,
+			OutstandingRequests:       3,
+			AvgLatency:                0.5,
+			MaxLatency:                12,
+			NumAliveClientConnections: 7,
+		}))
+	})
+
+	It("should fail on the error of the command", func() {
+		response = `{"command":"stats","error":"This ZooKeeper instance is not currently serving requests"}`
+		_, err := new(zk.DefaultAdminClient).ServerState(address())
+		Ω(err).NotTo(BeNil())
+		Ω(err.Error()).To(ContainSubstring("not currently serving requests"))
+	})
+
+	It("should fail on an error status", func() {
+		status = http.StatusServiceUnavailable
+		_, err := new(zk.DefaultAdminClient).ServerState(address())
+		Ω(err).NotTo(BeNil())
+	})
+
+	It("should address the member in the headless service", func() {
+		z := &api.ZookeeperCluster{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+		z.WithDefaults()
+		Ω(zk.MemberAdminAddress(z, "example-1")).To(Equal("example-1.example-headless.default.svc.cluster.local:8080"))
+		Ω(zk.ObserverAdminAddress(z, "example-observer-0")).To(Equal("example-observer-0.example-observer-headless.default.svc.cluster.local:8080"))
+	})
+})
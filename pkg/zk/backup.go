@@ -0,0 +1,278 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+const (
+	backupVolume         = "backup"
+	backupDir            = "/backup"
+	backupCommand        = "zookeeperBackup.sh"
+	backupCleanupCommand = "zookeeperBackupCleanup.sh"
+)
+
+// BackupResult is what the backup script writes to the termination log of
+// its container once the snapshot is stored
+type BackupResult struct {
+	Zxid     string `json:"zxid"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+	Location string `json:"location"`
+}
+
+// ParseBackupResult parses the termination message of a backup container
+func ParseBackupResult(message string) (*BackupResult, error) {
+	result := &BackupResult{}
+	if err := json.Unmarshal([]byte(message), result); err != nil {
+		return nil, fmt.Errorf("Error parsing backup result %q: %v", message, err)
+	}
+	if result.Zxid == "" || result.Checksum == "" || result.Location == "" {
+		return nil, fmt.Errorf("Incomplete backup result %q", message)
+	}
+	return result, nil
+}
+
+// BackupJobLabels returns the labels of a backup job and its pod. They must
+// not have the app label of the cluster, the pod would be taken for a
+// member.
+func BackupJobLabels(b *api.ZookeeperBackup) map[string]string {
+	return map[string]string{
+		"zookeeper-cluster": b.Spec.ZookeeperCluster,
+		"zookeeper-backup":  b.GetName(),
+	}
+}
+
+// BackupCleanupJobLabels returns the labels of the job deleting the snapshot
+// of a backup and of its pod
+func BackupCleanupJobLabels(b *api.ZookeeperBackup) map[string]string {
+	return map[string]string{
+		"zookeeper-cluster":        b.Spec.ZookeeperCluster,
+		"zookeeper-backup-cleanup": b.GetName(),
+	}
+}
+
+// BackupScheduleLabels returns the labels of the backups created by a
+// schedule
+func BackupScheduleLabels(s *api.ZookeeperBackupSchedule) map[string]string {
+	return map[string]string{
+		"zookeeper-cluster":         s.Spec.ZookeeperCluster,
+		"zookeeper-backup-schedule": s.GetName(),
+	}
+}
+
+// MakeScheduledBackup returns the backup a schedule creates for the run due
+// at the given time
+func MakeScheduledBackup(s *api.ZookeeperBackupSchedule, due time.Time) *api.ZookeeperBackup {
+	return &api.ZookeeperBackup{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ZookeeperBackup",
+			APIVersion: api.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.BackupName(due),
+			Namespace: s.Namespace,
+			Labels:    BackupScheduleLabels(s),
+		},
+		Spec: api.ZookeeperBackupSpec{
+			ZookeeperCluster: s.Spec.ZookeeperCluster,
+			Target:           *s.Spec.Target.DeepCopy(),
+		},
+	}
+}
+
+// votingMemberAddresses returns the addresses of the voting members, one of
+// which is the leader
+func votingMemberAddresses(z *api.ZookeeperCluster) []string {
+	addresses := make([]string, z.Spec.Replicas)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("%s-%d.%s", z.GetName(), i, headlessDomain(z))
+	}
+	return addresses
+}
+
+// MakeBackupJob returns the job streaming a snapshot from the admin server
+// of the leader of the cluster to the target of the backup. The script of
+// the image reports the stored snapshot in its termination message.
+func MakeBackupJob(b *api.ZookeeperBackup, z *api.ZookeeperCluster) *batchv1.Job {
+	labels := BackupJobLabels(b)
+	container := v1.Container{
+		Name:    "backup",
+		Command: []string{backupCommand},
+		Env: []v1.EnvVar{
+			{Name: "MEMBERS", Value: strings.Join(votingMemberAddresses(z), " ")},
+			{Name: "ADMIN_SERVER_PORT", Value: strconv.Itoa(int(z.Spec.Ports.AdminServer))},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: backupVolume, MountPath: backupDir},
+		},
+	}
+	if z.Spec.Auth != nil {
+		// the snapshot command of the admin server requires all
+		// permissions on the root znode, the super user has them
+		container.Env = append(container.Env,
+			v1.EnvVar{Name: "ADMIN_AUTH_USER", Value: z.Spec.Auth.SuperUser},
+			v1.EnvVar{
+				Name: "ADMIN_AUTH_PASSWORD",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: z.Spec.Auth.SecretName},
+						Key:                  z.Spec.Auth.SuperUser,
+					},
+				},
+			},
+		)
+	}
+
+	var volume v1.Volume
+	target := b.Spec.Target
+	if target.PVC != nil {
+		volume = v1.Volume{
+			Name: backupVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: target.PVC.ClaimName,
+				},
+			},
+		}
+		dir := path.Join(target.PVC.Path, b.GetName())
+		container.Env = append(container.Env,
+			v1.EnvVar{Name: "BACKUP_DIR", Value: path.Join(backupDir, dir)},
+			v1.EnvVar{Name: "BACKUP_LOCATION", Value: "pvc://" + path.Join(target.PVC.ClaimName, dir)},
+		)
+	} else if target.S3 != nil {
+		// the snapshot is checksummed before the upload, it needs
+		// scratch space
+		volume = v1.Volume{
+			Name: backupVolume,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		}
+		container.Env = append(container.Env,
+			v1.EnvVar{Name: "BACKUP_DIR", Value: backupDir},
+			v1.EnvVar{Name: "S3_ENDPOINT", Value: strings.TrimSuffix(target.S3.Endpoint, "/")},
+			v1.EnvVar{Name: "S3_BUCKET", Value: target.S3.Bucket},
+			v1.EnvVar{Name: "S3_KEY_PREFIX", Value: target.S3.Prefix + b.GetName() + "/"},
+			v1.EnvVar{Name: "S3_REGION", Value: target.S3.GetRegion()},
+			s3CredentialEnv("AWS_ACCESS_KEY_ID", target.S3.CredentialsSecret),
+			s3CredentialEnv("AWS_SECRET_ACCESS_KEY", target.S3.CredentialsSecret),
+		)
+	}
+
+	return makeJob(z, b.JobName(), labels, container, []v1.Volume{volume})
+}
+
+// MakeBackupCleanupJob returns the job deleting the snapshot stored by a
+// succeeded backup. The cluster only provides the image and the pod
+// settings, it may be a defaulted one if the cluster was deleted.
+func MakeBackupCleanupJob(b *api.ZookeeperBackup, z *api.ZookeeperCluster) (*batchv1.Job, error) {
+	snapshot, err := BackupSnapshot(b)
+	if err != nil {
+		return nil, err
+	}
+	container := v1.Container{
+		Name:    "cleanup",
+		Command: []string{backupCleanupCommand},
+	}
+	var volumes []v1.Volume
+	if snapshot.PVC != nil {
+		volumes = append(volumes, v1.Volume{
+			Name: backupVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: snapshot.PVC.ClaimName,
+				},
+			},
+		})
+		container.VolumeMounts = []v1.VolumeMount{
+			{Name: backupVolume, MountPath: backupDir},
+		}
+		container.Env = []v1.EnvVar{
+			{Name: "SNAPSHOT_PATH", Value: path.Join(backupDir, snapshot.PVC.Path)},
+		}
+	} else {
+		container.Env = []v1.EnvVar{
+			{Name: "S3_ENDPOINT", Value: strings.TrimSuffix(snapshot.S3.Endpoint, "/")},
+			{Name: "S3_BUCKET", Value: snapshot.S3.Bucket},
+			{Name: "S3_KEY", Value: snapshot.S3.Key},
+			{Name: "S3_REGION", Value: snapshot.S3.GetRegion()},
+			s3CredentialEnv("AWS_ACCESS_KEY_ID", snapshot.S3.CredentialsSecret),
+			s3CredentialEnv("AWS_SECRET_ACCESS_KEY", snapshot.S3.CredentialsSecret),
+		}
+	}
+	job := makeJob(z, b.CleanupJobName(), BackupCleanupJobLabels(b), container, volumes)
+	job.Namespace = b.Namespace
+	return job, nil
+}
+
+// makeJob returns a job running a script of the image of the cluster once,
+// on the nodes and with the security context of its members
+func makeJob(z *api.ZookeeperCluster, name string, labels map[string]string, container v1.Container, volumes []v1.Volume) *batchv1.Job {
+	backoffLimit := int32(0)
+	container.Image = z.Image().ToString()
+	container.ImagePullPolicy = z.Spec.Image.PullPolicy
+	container.TerminationMessagePolicy = v1.TerminationMessageFallbackToLogsOnError
+	podSpec := v1.PodSpec{
+		Containers:         []v1.Container{container},
+		Volumes:            volumes,
+		RestartPolicy:      v1.RestartPolicyNever,
+		ServiceAccountName: z.Spec.Pod.ServiceAccountName,
+		ImagePullSecrets:   z.Spec.Pod.ImagePullSecrets,
+		NodeSelector:       z.Spec.Pod.NodeSelector,
+		Tolerations:        z.Spec.Pod.Tolerations,
+		SecurityContext:    z.Spec.Pod.SecurityContext,
+	}
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: z.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+func s3CredentialEnv(key string, secretName string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: key,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
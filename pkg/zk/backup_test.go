@@ -0,0 +1,263 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func envValue(env []v1.EnvVar, name string) string {
+	for _, e := range env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+var _ = Describe("Backup Spec", func() {
+
+	Context("#MakeBackupJob", func() {
+		var (
+			z   *api.ZookeeperCluster
+			b   *api.ZookeeperBackup
+			job *batchv1.Job
+			c   v1.Container
+		)
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			z.WithDefaults()
+			b = &api.ZookeeperBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "nightly",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperBackupSpec{
+					ZookeeperCluster: "example",
+				},
+			}
+		})
+
+		Context("with a pvc target", func() {
+			BeforeEach(func() {
+				b.Spec.Target.PVC = &api.PVCTarget{ClaimName: "backups", Path: "zk"}
+				job = zk.MakeBackupJob(b, z)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should be named after the backup and never retried", func() {
+				Ω(job.Name).To(Equal("nightly-backup"))
+				Ω(*job.Spec.BackoffLimit).To(BeEquivalentTo(0))
+				Ω(job.Spec.Template.Spec.RestartPolicy).To(Equal(v1.RestartPolicyNever))
+			})
+
+			It("should not label the pod as a member", func() {
+				Ω(job.Spec.Template.Labels).NotTo(HaveKey("app"))
+				Ω(job.Spec.Template.Labels).To(HaveKeyWithValue("zookeeper-backup", "nightly"))
+			})
+
+			It("should run the backup script of the cluster image", func() {
+				Ω(c.Image).To(Equal(z.Spec.Image.ToString()))
+				Ω(c.Command).To(Equal([]string{"zookeeperBackup.sh"}))
+			})
+
+			It("should list the voting members", func() {
+				Ω(envValue(c.Env, "MEMBERS")).To(Equal(
+					"example-0.example-headless.default.svc.cluster.local " +
+						"example-1.example-headless.default.svc.cluster.local " +
+						"example-2.example-headless.default.svc.cluster.local"))
+				Ω(envValue(c.Env, "ADMIN_SERVER_PORT")).To(Equal("8080"))
+			})
+
+			It("should store the snapshot in a directory of the claim", func() {
+				Ω(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+				Ω(envValue(c.Env, "BACKUP_DIR")).To(Equal("/backup/zk/nightly"))
+				Ω(envValue(c.Env, "BACKUP_LOCATION")).To(Equal("pvc://backups/zk/nightly"))
+				Ω(envValue(c.Env, "S3_BUCKET")).To(BeEmpty())
+			})
+
+			It("should not authenticate", func() {
+				Ω(envValue(c.Env, "ADMIN_AUTH_USER")).To(BeEmpty())
+			})
+		})
+
+		Context("with an s3 target", func() {
+			BeforeEach(func() {
+				b.Spec.Target.S3 = &api.S3Target{
+					Endpoint:          "http://minio:9000/",
+					Bucket:            "zk",
+					Prefix:            "prod/",
+					CredentialsSecret: "minio-creds",
+				}
+				job = zk.MakeBackupJob(b, z)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should use scratch space", func() {
+				Ω(job.Spec.Template.Spec.Volumes[0].EmptyDir).NotTo(BeNil())
+				Ω(envValue(c.Env, "BACKUP_DIR")).To(Equal("/backup"))
+			})
+
+			It("should upload under the prefix of the backup", func() {
+				Ω(envValue(c.Env, "S3_ENDPOINT")).To(Equal("http://minio:9000"))
+				Ω(envValue(c.Env, "S3_BUCKET")).To(Equal("zk"))
+				Ω(envValue(c.Env, "S3_KEY_PREFIX")).To(Equal("prod/nightly/"))
+				Ω(envValue(c.Env, "S3_REGION")).To(Equal(api.DefaultBackupS3Region))
+			})
+
+			It("should take the credentials from the secret", func() {
+				for _, e := range c.Env {
+					if e.Name == "AWS_ACCESS_KEY_ID" || e.Name == "AWS_SECRET_ACCESS_KEY" {
+						Ω(e.ValueFrom.SecretKeyRef.Name).To(Equal("minio-creds"))
+						Ω(e.ValueFrom.SecretKeyRef.Key).To(Equal(e.Name))
+					}
+				}
+			})
+		})
+
+		Context("with auth", func() {
+			BeforeEach(func() {
+				z.Spec.Auth = &api.Auth{SecretName: "zk-users"}
+				z.WithDefaults()
+				b.Spec.Target.PVC = &api.PVCTarget{ClaimName: "backups"}
+				job = zk.MakeBackupJob(b, z)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should authenticate as the super user", func() {
+				Ω(envValue(c.Env, "ADMIN_AUTH_USER")).To(Equal(api.DefaultSuperUser))
+				for _, e := range c.Env {
+					if e.Name == "ADMIN_AUTH_PASSWORD" {
+						Ω(e.ValueFrom.SecretKeyRef.Name).To(Equal("zk-users"))
+						Ω(e.ValueFrom.SecretKeyRef.Key).To(Equal(api.DefaultSuperUser))
+					}
+				}
+			})
+		})
+	})
+
+	Context("#ParseBackupResult", func() {
+		It("should parse the termination message", func() {
+			result, err := zk.ParseBackupResult(`{"zxid":"0x100000002","size":42,"checksum":"abc","location":"pvc://backups/nightly/snapshot.100000002"}`)
+			Ω(err).To(BeNil())
+			Ω(result.Zxid).To(Equal("0x100000002"))
+			Ω(result.Size).To(BeEquivalentTo(42))
+		})
+
+		It("should reject an incomplete message", func() {
+			_, err := zk.ParseBackupResult(`{"zxid":"0x1"}`)
+			Ω(err).NotTo(BeNil())
+		})
+
+		It("should reject logs", func() {
+			_, err := zk.ParseBackupResult("No leader found")
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("#MakeBackupCleanupJob", func() {
+		var (
+			z *api.ZookeeperCluster
+			b *api.ZookeeperBackup
+		)
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			z.WithDefaults()
+			b = &api.ZookeeperBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "nightly",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperBackupSpec{
+					ZookeeperCluster: "example",
+				},
+			}
+		})
+
+		It("should delete the snapshot from its claim", func() {
+			b.Status.Location = "pvc://backups/zk/nightly/snapshot.100000002"
+			job, err := zk.MakeBackupCleanupJob(b, z)
+			Ω(err).To(BeNil())
+			Ω(job.Name).To(Equal("nightly-cleanup"))
+			Ω(job.Namespace).To(Equal("default"))
+			c := job.Spec.Template.Spec.Containers[0]
+			Ω(c.Command).To(Equal([]string{"zookeeperBackupCleanup.sh"}))
+			Ω(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+			Ω(envValue(c.Env, "SNAPSHOT_PATH")).To(Equal("/backup/zk/nightly/snapshot.100000002"))
+		})
+
+		It("should delete the snapshot from its bucket", func() {
+			b.Spec.Target.S3 = &api.S3Target{Endpoint: "http://minio:9000/", Bucket: "zk", CredentialsSecret: "minio-creds"}
+			b.Status.Location = "s3://zk/nightly/snapshot.1a"
+			job, err := zk.MakeBackupCleanupJob(b, z)
+			Ω(err).To(BeNil())
+			c := job.Spec.Template.Spec.Containers[0]
+			Ω(job.Spec.Template.Spec.Volumes).To(BeEmpty())
+			Ω(envValue(c.Env, "S3_ENDPOINT")).To(Equal("http://minio:9000"))
+			Ω(envValue(c.Env, "S3_KEY")).To(Equal("nightly/snapshot.1a"))
+		})
+
+		It("should not label the pod like the backup pod", func() {
+			b.Status.Location = "pvc://backups/nightly/snapshot.1"
+			job, err := zk.MakeBackupCleanupJob(b, z)
+			Ω(err).To(BeNil())
+			Ω(job.Spec.Template.Labels).NotTo(HaveKey("zookeeper-backup"))
+		})
+
+		It("should reject a backup without a snapshot", func() {
+			_, err := zk.MakeBackupCleanupJob(b, z)
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("#MakeScheduledBackup", func() {
+		It("should name the backup after the run", func() {
+			s := &api.ZookeeperBackupSchedule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "nightly",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperBackupScheduleSpec{
+					ZookeeperCluster: "example",
+					Target:           api.BackupTarget{PVC: &api.PVCTarget{ClaimName: "backups"}},
+				},
+			}
+			b := zk.MakeScheduledBackup(s, time.Date(2024, 1, 15, 2, 0, 0, 0, time.UTC))
+			Ω(b.Name).To(Equal("nightly-20240115-0200"))
+			Ω(b.Namespace).To(Equal("default"))
+			Ω(b.Labels).To(HaveKeyWithValue("zookeeper-backup-schedule", "nightly"))
+			Ω(b.Spec.ZookeeperCluster).To(Equal("example"))
+			Ω(b.Spec.Target.PVC.ClaimName).To(Equal("backups"))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// Roles of the servers in the dynamic config
+const (
+	participantRole = "participant"
+	observerRole    = "observer"
+)
+
+// ExpectedServers returns the specs of the servers the dynamic config of the
+// cluster should hold by id, the voting members of Spec.Replicas and the
+// observers, as zookeeperStart.sh registers them
+func ExpectedServers(z *api.ZookeeperCluster) map[int32]string {
+	servers := map[int32]string{}
+	for ord := int32(0); ord < z.Spec.Replicas; ord++ {
+		pod := fmt.Sprintf("%s-%d", z.GetName(), ord)
+		servers[ord+1] = serverSpec(z, pod+"."+headlessDomain(z), participantRole)
+	}
+	for ord := int32(0); ord < z.ObserverReplicas(); ord++ {
+		pod := fmt.Sprintf("%s-%d", z.GetObserverName(), ord)
+		servers[api.ObserverIDOffset+ord+1] = serverSpec(z, pod+"."+observerHeadlessDomain(z), observerRole)
+	}
+	return servers
+}
+
+// ServerPodName returns the name of the pod of the voting member or of the
+// observer with the given server id
+func ServerPodName(z *api.ZookeeperCluster, id int32) string {
+	if id > api.ObserverIDOffset {
+		return fmt.Sprintf("%s-%d", z.GetObserverName(), id-api.ObserverIDOffset-1)
+	}
+	return fmt.Sprintf("%s-%d", z.GetName(), id-1)
+}
+
+func serverSpec(z *api.ZookeeperCluster, host string, role string) string {
+	ports := z.Spec.Ports
+	return fmt.Sprintf("%s:%d:%d:%s;%d", host, ports.Quorum, ports.LeaderElection, role, ports.Client)
+}
+
+// ObserverSpec returns the spec of a server with the observer role. A member
+// joins the ensemble as an observer, zookeeperReady.sh promotes it to a
+// participant once it runs.
+func ObserverSpec(spec string) string {
+	server, client, _ := strings.Cut(spec, ";")
+	fields := strings.Split(server, ":")
+	if len(fields) > 3 {
+		fields[3] = observerRole
+	} else {
+		fields = append(fields, observerRole)
+	}
+	return strings.Join(fields, ":") + ";" + client
+}
+
+// parseServerSpec returns the host and the role of the spec of a server,
+// host:quorumPort:electionPort[:role][;[clientAddress:]clientPort]
+func parseServerSpec(spec string) (host string, role string) {
+	server, _, _ := strings.Cut(spec, ";")
+	fields := strings.Split(server, ":")
+	role = participantRole
+	if len(fields) > 3 {
+		role = fields[3]
+	}
+	return fields[0], role
+}
+
+// ConfigDrift is the difference between the dynamic config committed by the
+// ensemble and the servers it should hold
+type ConfigDrift struct {
+	// Ghosts are the ids of the servers of the config which are not members
+	// of the cluster, left by forced pod deletions or failed teardowns
+	Ghosts []int32
+	// Missing are the ids of the members which are not in the config
+	Missing []int32
+	// Mismatched are the ids of the members which are in the config with
+	// another address or role, as while a new member is promoted from
+	// observer to participant
+	Mismatched []int32
+}
+
+// DetectConfigDrift compares the committed config with the expected servers
+func DetectConfigDrift(config *DynamicConfig, expected map[int32]string) *ConfigDrift {
+	drift := &ConfigDrift{}
+	for id, spec := range config.Servers {
+		want, ok := expected[id]
+		if !ok {
+			drift.Ghosts = append(drift.Ghosts, id)
+			continue
+		}
+		host, role := parseServerSpec(spec)
+		wantHost, wantRole := parseServerSpec(want)
+		if host != wantHost || role != wantRole {
+			drift.Mismatched = append(drift.Mismatched, id)
+		}
+	}
+	for id := range expected {
+		if _, ok := config.Servers[id]; !ok {
+			drift.Missing = append(drift.Missing, id)
+		}
+	}
+	for _, ids := range [][]int32{drift.Ghosts, drift.Missing, drift.Mismatched} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return drift
+}
+
+// InSync returns true if the config holds exactly the expected servers
+func (d *ConfigDrift) InSync() bool {
+	return len(d.Ghosts) == 0 && len(d.Missing) == 0 && len(d.Mismatched) == 0
+}
+
+// String describes the drift for the condition of the cluster
+func (d *ConfigDrift) String() string {
+	var parts []string
+	for _, p := range []struct {
+		what string
+		ids  []int32
+	}{
+		{"not a member of the cluster", d.Ghosts},
+		{"missing", d.Missing},
+		{"with another address or role", d.Mismatched},
+	} {
+		switch len(p.ids) {
+		case 0:
+		case 1:
+			parts = append(parts, fmt.Sprintf("server %d is %s", p.ids[0], p.what))
+		default:
+			parts = append(parts, fmt.Sprintf("servers %s are %s", formatIDs(p.ids), strings.Replace(p.what, "a member", "members", 1)))
+		}
+	}
+	if len(parts) == 0 {
+		return "The dynamic config holds the members of the cluster"
+	}
+	return "In the dynamic config, " + strings.Join(parts, ", ")
+}
+
+func formatIDs(ids []int32) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,83 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dynamic config drift", func() {
+	var (
+		z      *api.ZookeeperCluster
+		config *zk.DynamicConfig
+	)
+
+	BeforeEach(func() {
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+			Spec:       api.ZookeeperClusterSpec{Observers: &api.Observers{Replicas: 1}},
+		}
+		z.WithDefaults()
+		config, _ = zk.ParseDynamicConfig(
+			"server.1=example-0.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181\n" +
+				"server.2=example-1.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181\n" +
+				"server.3=example-2.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181\n" +
+				"server.129=example-observer-0.example-observer-headless.default.svc.cluster.local:2888:3888:observer;0.0.0.0:2181\n" +
+				"version=100000004")
+	})
+
+	It("should expect the voting members and the observers", func() {
+		servers := zk.ExpectedServers(z)
+		Ω(servers).To(HaveLen(4))
+		Ω(servers).To(HaveKeyWithValue(int32(1), "example-0.example-headless.default.svc.cluster.local:2888:3888:participant;2181"))
+		Ω(servers).To(HaveKeyWithValue(int32(129), "example-observer-0.example-observer-headless.default.svc.cluster.local:2888:3888:observer;2181"))
+	})
+
+	It("should name the pods of the servers", func() {
+		Ω(zk.ServerPodName(z, 3)).To(Equal("example-2"))
+		Ω(zk.ServerPodName(z, 129)).To(Equal("example-observer-0"))
+	})
+
+	It("should ignore the client address the ensemble adds", func() {
+		drift := zk.DetectConfigDrift(config, zk.ExpectedServers(z))
+		Ω(drift.InSync()).To(BeTrue())
+		Ω(drift.String()).To(Equal("The dynamic config holds the members of the cluster"))
+	})
+
+	It("should detect the ghosts and the missing members", func() {
+		config.Servers[5] = "example-4.example-headless.default.svc.cluster.local:2888:3888:participant;0.0.0.0:2181"
+		delete(config.Servers, 2)
+		delete(config.Servers, 3)
+		drift := zk.DetectConfigDrift(config, zk.ExpectedServers(z))
+		Ω(drift.Ghosts).To(Equal([]int32{5}))
+		Ω(drift.Missing).To(Equal([]int32{2, 3}))
+		Ω(drift.String()).To(Equal("In the dynamic config, server 5 is not a member of the cluster, servers 2, 3 are missing"))
+	})
+
+	It("should detect a member with another role", func() {
+		config.Servers[3] = "example-2.example-headless.default.svc.cluster.local:2888:3888:observer;0.0.0.0:2181"
+		drift := zk.DetectConfigDrift(config, zk.ExpectedServers(z))
+		Ω(drift.InSync()).To(BeFalse())
+		Ω(drift.Mismatched).To(Equal([]int32{3}))
+	})
+
+	It("should give the spec of a joining member the observer role", func() {
+		Ω(zk.ObserverSpec("example-2.example-headless.default.svc.cluster.local:2888:3888:participant;2181")).
+			To(Equal("example-2.example-headless.default.svc.cluster.local:2888:3888:observer;2181"))
+		Ω(zk.ObserverSpec("example-2:2888:3888;2181")).To(Equal("example-2:2888:3888:observer;2181"))
+	})
+})
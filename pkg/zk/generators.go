@@ -11,18 +11,24 @@
 package zk
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 )
 
 const (
@@ -30,22 +36,169 @@ const (
 	dot                      = "."
 )
 
-func headlessDomain(z *v1beta1.ZookeeperCluster) string {
+func headlessDomain(z *api.ZookeeperCluster) string {
 	return fmt.Sprintf("%s.%s.svc.%s", headlessSvcName(z), z.GetNamespace(), z.GetKubernetesClusterDomain())
 }
 
-func headlessSvcName(z *v1beta1.ZookeeperCluster) string {
+func headlessSvcName(z *api.ZookeeperCluster) string {
 	return fmt.Sprintf("%s-headless", z.GetName())
 }
 
 var zkDataVolume = "data"
 
+const (
+	// DataLogVolume is the name of the volume of the transaction logs,
+	// their PVCs are named datalog-<statefulset>-<ordinal>
+	DataLogVolume = "datalog"
+	dataLogDir    = "/datalog"
+)
+
+const (
+	authVolume        = "auth"
+	authDir           = "/auth"
+	jaasConfKey       = "jaas.conf"
+	serverJVMFlagsKey = "server-jvm-flags"
+)
+
+// authMounted returns true if the members need the generated auth secret.
+// Members still authenticating each other keep it after spec.auth is
+// removed, until quorum authentication is disabled.
+func authMounted(z *api.ZookeeperCluster) bool {
+	return z.Spec.Auth != nil || z.Status.QuorumAuthPhase() != api.QuorumAuthDisabled
+}
+
+// tlsFiles are the volumes holding a TLS secret and the PEM key store
+// zookeeper loads, which has the private key and the certificate in a single
+// file, where they are mounted and the secret they come from
+type tlsFiles struct {
+	volume       string
+	secretVolume string
+	dir          string
+	secretDir    string
+	secretName   string
+}
+
+var (
+	clientTLSFiles = tlsFiles{
+		volume:       "client-tls",
+		secretVolume: "client-tls-secret",
+		dir:          "/tls/client",
+		secretDir:    "/tls/client-secret",
+	}
+	quorumTLSFiles = tlsFiles{
+		volume:       "quorum-tls",
+		secretVolume: "quorum-tls-secret",
+		dir:          "/tls/quorum",
+		secretDir:    "/tls/quorum-secret",
+	}
+)
+
+func (f tlsFiles) keyStore() string {
+	return f.dir + "/keystore.pem"
+}
+
+func (f tlsFiles) trustStore() string {
+	return f.secretDir + "/ca.crt"
+}
+
+func (f tlsFiles) withSecret(secretName string) tlsFiles {
+	f.secretName = secretName
+	return f
+}
+
+func (f tlsFiles) volumes() []v1.Volume {
+	return []v1.Volume{
+		{
+			Name: f.secretVolume,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: f.secretName,
+				},
+			},
+		},
+		{
+			Name: f.volume,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{
+					Medium: v1.StorageMediumMemory,
+				},
+			},
+		},
+	}
+}
+
+func (f tlsFiles) volumeMounts() []v1.VolumeMount {
+	return []v1.VolumeMount{
+		{Name: f.secretVolume, MountPath: f.secretDir, ReadOnly: true},
+		{Name: f.volume, MountPath: f.dir},
+	}
+}
+
+func (f tlsFiles) keyStoreCommand() string {
+	return fmt.Sprintf("cat %s/tls.key %s/tls.crt > %s", f.secretDir, f.secretDir, f.keyStore())
+}
+
+// members are the properties telling the StatefulSet of the voting members
+// and the one of the observers apart
+type members struct {
+	name        string
+	serviceName string
+	replicas    int32
+	kind        string
+	pod         *api.PodPolicy
+	env         []v1.EnvVar
+}
+
+func votingMembers(z *api.ZookeeperCluster) members {
+	return members{
+		name:        z.GetName(),
+		serviceName: headlessSvcName(z),
+		replicas:    z.Spec.Replicas,
+		kind:        "ZookeeperMember",
+		pod:         &z.Spec.Pod,
+	}
+}
+
+func observerMembers(z *api.ZookeeperCluster) members {
+	m := members{
+		name:        z.GetObserverName(),
+		serviceName: z.GetObserverHeadlessServiceName(),
+		replicas:    z.ObserverReplicas(),
+		kind:        "ZookeeperObserver",
+		pod:         &api.PodPolicy{},
+		// the start and teardown scripts of the image register the
+		// observers under their own ids and service
+		env: []v1.EnvVar{
+			{Name: "ZK_ROLE", Value: "observer"},
+			{Name: "MYID_OFFSET", Value: strconv.Itoa(api.ObserverIDOffset)},
+			{Name: "SERVER_DOMAIN", Value: observerHeadlessDomain(z)},
+		},
+	}
+	if z.Spec.Observers != nil {
+		m.pod = &z.Spec.Observers.Pod
+	}
+	return m
+}
+
+func observerHeadlessDomain(z *api.ZookeeperCluster) string {
+	return fmt.Sprintf("%s.%s.svc.%s", z.GetObserverHeadlessServiceName(), z.GetNamespace(), z.GetKubernetesClusterDomain())
+}
+
 // MakeStatefulSet return a zookeeper stateful set from the zk spec
-func MakeStatefulSet(z *v1beta1.ZookeeperCluster) *appsv1.StatefulSet {
+func MakeStatefulSet(z *api.ZookeeperCluster) *appsv1.StatefulSet {
+	return makeStatefulSet(z, votingMembers(z))
+}
+
+// MakeObserverStatefulSet returns the stateful set of the observers, which
+// has no replicas when the spec has no observers
+func MakeObserverStatefulSet(z *api.ZookeeperCluster) *appsv1.StatefulSet {
+	return makeStatefulSet(z, observerMembers(z))
+}
+
+func makeStatefulSet(z *api.ZookeeperCluster, m members) *appsv1.StatefulSet {
 	extraVolumes := []v1.Volume{}
-	persistence := z.Spec.Persistence
 	pvcs := []v1.PersistentVolumeClaim{}
-	if strings.EqualFold(z.Spec.StorageType, "ephemeral") {
+	if z.Spec.StorageType == api.StorageTypeEphemeral {
 		extraVolumes = append(extraVolumes, v1.Volume{
 			Name: zkDataVolume,
 			VolumeSource: v1.VolumeSource{
@@ -53,65 +206,154 @@ func MakeStatefulSet(z *v1beta1.ZookeeperCluster) *appsv1.StatefulSet {
 			},
 		})
 	} else {
-		pvcs = append(pvcs, v1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: zkDataVolume,
-				Labels: mergeLabels(
-					z.Spec.Labels,
-					map[string]string{"app": z.GetName(), "uid": string(z.UID)},
-				),
-				Annotations: z.Spec.Persistence.Annotations,
-			},
-			Spec: persistence.PersistentVolumeClaimSpec,
-		})
+		pvcs = append(pvcs, dataPVC(z, m.name))
+	}
+	if dataLog := z.DataLog(); dataLog != nil {
+		pvcs = append(pvcs, dataLogPVC(z, dataLog, m.name))
 	}
+	replicas := m.replicas
 	return &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
 			APIVersion: "apps/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      z.GetName(),
+			Name:      m.name,
 			Namespace: z.Namespace,
 			Labels:    z.Spec.Labels,
 		},
 		Spec: appsv1.StatefulSetSpec{
-			ServiceName: headlessSvcName(z),
-			Replicas:    &z.Spec.Replicas,
+			ServiceName: m.serviceName,
+			Replicas:    &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
-					"app": z.GetName(),
+					"app": m.name,
 				},
 			},
-			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
-				Type: appsv1.RollingUpdateStatefulSetStrategyType,
-			},
+			UpdateStrategy:      updateStrategy(z, m),
 			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					GenerateName: z.GetName(),
+					GenerateName: m.name,
 					Labels: mergeLabels(
 						z.Spec.Labels,
-						z.Spec.Pod.Labels,
+						m.pod.Labels,
 						map[string]string{
-							"app":  z.GetName(),
-							"kind": "ZookeeperMember",
+							"app":  m.name,
+							"kind": m.kind,
 						},
 					),
-					Annotations: z.Spec.Pod.Annotations,
+					Annotations: podAnnotations(z, m.pod),
 				},
-				Spec: makeZkPodSpec(z, extraVolumes),
+				Spec: makeZkPodSpec(z, m, extraVolumes),
 			},
 			VolumeClaimTemplates: pvcs,
 		},
 	}
 }
 
-func makeZkPodSpec(z *v1beta1.ZookeeperCluster, volumes []v1.Volume) v1.PodSpec {
+// updateStrategy returns the OnDelete strategy for the voting members of a
+// cluster with LeaderAware upgrades, the operator deletes their pods itself.
+// The rolling update of the others stops at the partition of the upgrade
+// policy, and the observers wait until all the voting members are upgraded.
+func updateStrategy(z *api.ZookeeperCluster, m members) appsv1.StatefulSetUpdateStrategy {
+	voters := m.name == z.GetName()
+	if z.LeaderAwareUpgrades() && voters {
+		return appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	}
+	strategy := appsv1.StatefulSetUpdateStrategy{
+		Type: appsv1.RollingUpdateStatefulSetStrategyType,
+	}
+	partition := z.UpgradePartition()
+	if partition > 0 {
+		if !voters {
+			partition = m.replicas
+		}
+		strategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+	}
+	return strategy
+}
+
+// dataPVC returns the claim template of the data volume of the pods with the
+// given app label
+func dataPVC(z *api.ZookeeperCluster, app string) v1.PersistentVolumeClaim {
+	return v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: zkDataVolume,
+			Labels: mergeLabels(
+				z.Spec.Labels,
+				map[string]string{"app": app, "uid": string(z.UID)},
+			),
+			Annotations: z.Spec.Persistence.Annotations,
+		},
+		Spec: z.Spec.Persistence.PersistentVolumeClaimSpec,
+	}
+}
+
+// dataLogPVC returns the claim template of the transaction log volume of the
+// pods with the given app label
+func dataLogPVC(z *api.ZookeeperCluster, dataLog *api.DataLogPersistence, app string) v1.PersistentVolumeClaim {
+	return v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: DataLogVolume,
+			Labels: mergeLabels(
+				z.Spec.Labels,
+				map[string]string{"app": app, "uid": string(z.UID)},
+			),
+			Annotations: dataLog.Annotations,
+		},
+		Spec: dataLog.PersistentVolumeClaimSpec,
+	}
+}
+
+// DataVolumeClaimSize returns the storage requested by the data volume
+// claim template of a StatefulSet, if it has one
+func DataVolumeClaimSize(sts *appsv1.StatefulSet) (resource.Quantity, bool) {
+	for _, pvc := range sts.Spec.VolumeClaimTemplates {
+		if pvc.Name == zkDataVolume {
+			size, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			return size, ok
+		}
+	}
+	return resource.Quantity{}, false
+}
+
+// podAnnotations returns the annotations of the pod template. A new
+// restart trigger, configuration or quorum phase changes the template,
+// which rolls all pods.
+func podAnnotations(z *api.ZookeeperCluster, pod *api.PodPolicy) map[string]string {
+	rolling := map[string]string{}
+	if z.Spec.RestartTrigger != "" {
+		rolling[api.RestartTriggerAnnotation] = z.Spec.RestartTrigger
+	}
+	if config := z.Status.Config; config != nil && config.RolledOutHash != "" {
+		rolling[api.ConfigHashAnnotation] = config.RolledOutHash
+	}
+	if config := z.Status.Config; config != nil && config.TLSSecretsHash != "" {
+		rolling[api.TLSSecretsHashAnnotation] = config.TLSSecretsHash
+	}
+	if phase := z.Status.QuorumTLSPhase(); phase != api.QuorumTLSDisabled {
+		rolling[api.QuorumTLSPhaseAnnotation] = string(phase)
+	}
+	if phase := z.Status.QuorumAuthPhase(); phase != api.QuorumAuthDisabled {
+		rolling[api.QuorumAuthPhaseAnnotation] = string(phase)
+	}
+	if len(rolling) == 0 {
+		return pod.Annotations
+	}
+	annotations := copyMap(pod.Annotations)
+	for k, v := range rolling {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+func makeZkPodSpec(z *api.ZookeeperCluster, m members, volumes []v1.Volume) v1.PodSpec {
+	pod := m.pod
 	zkContainer := v1.Container{
 		Name:  "zookeeper",
-		Image: z.Spec.Image.ToString(),
-		Ports: z.Spec.Ports,
+		Image: z.Image().ToString(),
+		Ports: z.Spec.Ports.ContainerPorts(),
 		Env: []v1.EnvVar{
 			{
 				Name: "ENVOY_SIDECAR_STATUS",
@@ -157,8 +399,14 @@ func makeZkPodSpec(z *v1beta1.ZookeeperCluster, volumes []v1.Volume) v1.PodSpec
 		},
 		Command: []string{"/usr/local/bin/zookeeperStart.sh"},
 	}
-	if z.Spec.Pod.Resources.Limits != nil || z.Spec.Pod.Resources.Requests != nil {
-		zkContainer.Resources = z.Spec.Pod.Resources
+	if pod.Resources.Limits != nil || pod.Resources.Requests != nil {
+		zkContainer.Resources = pod.Resources
+	}
+	if z.DataLog() != nil {
+		zkContainer.VolumeMounts = append(zkContainer.VolumeMounts, v1.VolumeMount{
+			Name:      DataLogVolume,
+			MountPath: dataLogDir,
+		})
 	}
 	volumes = append(volumes, v1.Volume{
 		Name: "conf",
@@ -171,40 +419,123 @@ func makeZkPodSpec(z *v1beta1.ZookeeperCluster, volumes []v1.Volume) v1.PodSpec
 		},
 	})
 
-	zkContainer.Env = append(zkContainer.Env, z.Spec.Pod.Env...)
+	tlsFiles := podTLSFiles(z)
+	for _, f := range tlsFiles {
+		volumes = append(volumes, f.volumes()...)
+		zkContainer.VolumeMounts = append(zkContainer.VolumeMounts, f.volumeMounts()...)
+	}
+
+	if authMounted(z) {
+		volumes = append(volumes, v1.Volume{
+			Name: authVolume,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: z.AuthSecretName(),
+				},
+			},
+		})
+		zkContainer.VolumeMounts = append(zkContainer.VolumeMounts, v1.VolumeMount{
+			Name:      authVolume,
+			MountPath: authDir,
+			ReadOnly:  true,
+		})
+		// the membership scripts of the image run their java client
+		// without options, the JVM picks up the JAAS file from the
+		// environment
+		zkContainer.Env = append(zkContainer.Env,
+			v1.EnvVar{
+				Name:  "JAVA_TOOL_OPTIONS",
+				Value: "-Djava.security.auth.login.config=" + authDir + "/" + jaasConfKey,
+			},
+			v1.EnvVar{
+				Name: "SERVER_JVMFLAGS",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: z.AuthSecretName()},
+						Key:                  serverJVMFlagsKey,
+					},
+				},
+			},
+		)
+	}
+
+	zkContainer.Env = append(zkContainer.Env, m.env...)
+	zkContainer.Env = append(zkContainer.Env, pod.Env...)
 	podSpec := v1.PodSpec{
 		Containers:                append(z.Spec.Containers, zkContainer),
-		Affinity:                  z.Spec.Pod.Affinity,
-		TopologySpreadConstraints: z.Spec.Pod.TopologySpreadConstraints,
+		Affinity:                  pod.Affinity,
+		TopologySpreadConstraints: pod.TopologySpreadConstraints,
 		Volumes:                   append(z.Spec.Volumes, volumes...),
 	}
-	if !reflect.DeepEqual(v1.PodSecurityContext{}, z.Spec.Pod.SecurityContext) {
-		podSpec.SecurityContext = z.Spec.Pod.SecurityContext
+	if !reflect.DeepEqual(v1.PodSecurityContext{}, pod.SecurityContext) {
+		podSpec.SecurityContext = pod.SecurityContext
 	}
-	podSpec.NodeSelector = z.Spec.Pod.NodeSelector
-	podSpec.Tolerations = z.Spec.Pod.Tolerations
-	podSpec.TerminationGracePeriodSeconds = &z.Spec.Pod.TerminationGracePeriodSeconds
-	podSpec.ServiceAccountName = z.Spec.Pod.ServiceAccountName
+	podSpec.NodeSelector = pod.NodeSelector
+	podSpec.Tolerations = pod.Tolerations
+	podSpec.TerminationGracePeriodSeconds = &pod.TerminationGracePeriodSeconds
+	podSpec.ServiceAccountName = pod.ServiceAccountName
 	if z.Spec.InitContainers != nil {
 		podSpec.InitContainers = z.Spec.InitContainers
 	}
+	if len(tlsFiles) > 0 {
+		podSpec.InitContainers = append(podSpec.InitContainers, makeTLSKeyStoreContainer(z, tlsFiles))
+	}
 
 	return podSpec
 }
 
+// podTLSFiles returns the TLS files the members need. The quorum
+// certificates follow the quorum TLS status rather than the spec, they are
+// only added or removed once the members no longer need them.
+func podTLSFiles(z *api.ZookeeperCluster) []tlsFiles {
+	var files []tlsFiles
+	if z.Spec.TLS.ClientEnabled() {
+		files = append(files, clientTLSFiles.withSecret(z.Spec.TLS.Client.SecretName))
+	}
+	if z.Status.QuorumTLSPhase() != api.QuorumTLSDisabled {
+		files = append(files, quorumTLSFiles.withSecret(z.Status.QuorumTLS.SecretName))
+	}
+	return files
+}
+
+// makeTLSKeyStoreContainer returns an init container writing the PEM key
+// stores zookeeper expects from the separate keys of the secrets
+func makeTLSKeyStoreContainer(z *api.ZookeeperCluster, files []tlsFiles) v1.Container {
+	commands := []string{"umask 077"}
+	var mounts []v1.VolumeMount
+	for _, f := range files {
+		commands = append(commands, f.keyStoreCommand())
+		mounts = append(mounts, f.volumeMounts()...)
+	}
+	return v1.Container{
+		Name:            "tls-keystore",
+		Image:           z.Image().ToString(),
+		ImagePullPolicy: z.Spec.Image.PullPolicy,
+		Command:         []string{"sh", "-c", strings.Join(commands, " && ")},
+		VolumeMounts:    mounts,
+	}
+}
+
 // MakeClientService returns a client service resource for the zookeeper cluster
-func MakeClientService(z *v1beta1.ZookeeperCluster) *v1.Service {
-	ports := z.ZookeeperPorts()
+func MakeClientService(z *api.ZookeeperCluster) *v1.Service {
+	return makeService(z.GetClientServiceName(), clientServicePorts(z), true, false, z.Spec.ClientService.Annotations, z)
+}
+
+func clientServicePorts(z *api.ZookeeperCluster) []v1.ServicePort {
+	ports := z.Spec.Ports
 	svcPorts := []v1.ServicePort{
 		{Name: "tcp-client", Port: ports.Client},
 	}
-	return makeService(z.GetClientServiceName(), svcPorts, true, false, z.Spec.ClientService.Annotations, z)
+	if z.Spec.TLS.ClientEnabled() {
+		svcPorts = append(svcPorts, v1.ServicePort{Name: "tcp-client-tls", Port: ports.SecureClient})
+	}
+	return svcPorts
 }
 
 // MakeAdminServerService returns a service which provides an interface
 // to access the zookeeper admin server port
-func MakeAdminServerService(z *v1beta1.ZookeeperCluster) *v1.Service {
-	ports := z.ZookeeperPorts()
+func MakeAdminServerService(z *api.ZookeeperCluster) *v1.Service {
+	ports := z.Spec.Ports
 	svcPorts := []v1.ServicePort{
 		{Name: "tcp-admin-server", Port: ports.AdminServer},
 	}
@@ -214,7 +545,7 @@ func MakeAdminServerService(z *v1beta1.ZookeeperCluster) *v1.Service {
 }
 
 // MakeConfigMap returns a zookeeper config map
-func MakeConfigMap(z *v1beta1.ZookeeperCluster) *v1.ConfigMap {
+func MakeConfigMap(z *api.ZookeeperCluster) *v1.ConfigMap {
 	return &v1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
@@ -234,32 +565,197 @@ func MakeConfigMap(z *v1beta1.ZookeeperCluster) *v1.ConfigMap {
 	}
 }
 
+// TLSSecretNames returns the names of the TLS secrets the members mount
+func TLSSecretNames(z *api.ZookeeperCluster) []string {
+	var names []string
+	for _, f := range podTLSFiles(z) {
+		names = append(names, f.secretName)
+	}
+	return names
+}
+
+// TLSSecretsHash returns the hash of the data of the TLS secrets of the
+// members, or an empty string without TLS secrets
+func TLSSecretsHash(secrets []*v1.Secret) string {
+	if len(secrets) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, secret := range secrets {
+		keys := make([]string, 0, len(secret.Data))
+		for k := range secret.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(h, "%s\x00", secret.Name)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s\x00%s\x00", k, secret.Data[k])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ConfigMapHash returns the hash of the data of a ConfigMap which needs a
+// restart of the members. CLUSTER_SIZE of env.sh is left out, it is only
+// read by the membership scripts and changes with every scale.
+func ConfigMapHash(cm *v1.ConfigMap) string {
+	keys := make([]string, 0, len(cm.Data))
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		data := cm.Data[k]
+		if k == "env.sh" {
+			data = withoutClusterSize(data)
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", k, data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func withoutClusterSize(env string) string {
+	lines := strings.Split(env, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "CLUSTER_SIZE=") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// MakeAuthSecret returns the secret holding the JAAS configuration of the
+// members and their JVM flags, generated from the passwords of the users
+// secret. The JAAS file lets the members and their membership scripts
+// authenticate as the super user, which the operator connects as with the
+// digest scheme.
+func MakeAuthSecret(z *api.ZookeeperCluster, users *v1.Secret) (*v1.Secret, error) {
+	auth := z.Spec.Auth
+	password := func(user string) (string, error) {
+		p, ok := users.Data[user]
+		if !ok {
+			return "", fmt.Errorf("Secret %s has no password for user %s", users.Name, user)
+		}
+		return string(p), nil
+	}
+	superPassword, err := password(auth.SuperUser)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(users.Data))
+	for name := range users.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var serverUsers []string
+	for _, name := range names {
+		serverUsers = append(serverUsers, fmt.Sprintf("user_%s=%s", name, jaasQuote(string(users.Data[name]))))
+	}
+	jaas := jaasSection("Server", serverUsers) +
+		jaasSection("Client", []string{"username=" + jaasQuote(auth.SuperUser), "password=" + jaasQuote(superPassword)})
+	if z.Status.QuorumAuthPhase() != api.QuorumAuthDisabled {
+		quorumUser := z.Status.QuorumAuth.User
+		quorumPassword, err := password(quorumUser)
+		if err != nil {
+			return nil, err
+		}
+		jaas = jaas +
+			jaasSection("QuorumServer", []string{fmt.Sprintf("user_%s=%s", quorumUser, jaasQuote(quorumPassword))}) +
+			jaasSection("QuorumLearner", []string{"username=" + jaasQuote(quorumUser), "password=" + jaasQuote(quorumPassword)})
+	}
+	digest := sha1.Sum([]byte(auth.SuperUser + ":" + superPassword))
+	return &v1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      z.AuthSecretName(),
+			Namespace: z.Namespace,
+			Labels:    z.Spec.Labels,
+		},
+		Data: map[string][]byte{
+			jaasConfKey: []byte(jaas),
+			// the snapshot command of the admin server, which backups
+			// stream from, is off by default and only serves the super user
+			serverJVMFlagsKey: []byte("-Dzookeeper.superUser=" + auth.SuperUser +
+				" -Dzookeeper.DigestAuthenticationProvider.superDigest=" + auth.SuperUser + ":" +
+				base64.StdEncoding.EncodeToString(digest[:]) +
+				" -Dzookeeper.admin.snapshot.enabled=true"),
+		},
+	}, nil
+}
+
+func jaasSection(name string, options []string) string {
+	return name + " {\n" +
+		"  org.apache.zookeeper.server.auth.DigestLoginModule required\n" +
+		"  " + strings.Join(options, "\n  ") + ";\n" +
+		"};\n"
+}
+
+func jaasQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
 // MakeHeadlessService returns an internal headless-service for the zk
 // stateful-set
-func MakeHeadlessService(z *v1beta1.ZookeeperCluster) *v1.Service {
-	ports := z.ZookeeperPorts()
+func MakeHeadlessService(z *api.ZookeeperCluster) *v1.Service {
+	return makeService(headlessSvcName(z), headlessServicePorts(z), false, false, z.Spec.HeadlessService.Annotations, z)
+}
+
+func headlessServicePorts(z *api.ZookeeperCluster) []v1.ServicePort {
+	ports := z.Spec.Ports
 	svcPorts := []v1.ServicePort{
 		{Name: "tcp-client", Port: ports.Client},
 		{Name: "tcp-quorum", Port: ports.Quorum},
-		{Name: "tcp-leader-election", Port: ports.Leader},
+		{Name: "tcp-leader-election", Port: ports.LeaderElection},
 		{Name: "tcp-metrics", Port: ports.Metrics},
 		{Name: "tcp-admin-server", Port: ports.AdminServer},
 	}
-	return makeService(headlessSvcName(z), svcPorts, false, false, z.Spec.HeadlessService.Annotations, z)
+	if z.Spec.TLS.ClientEnabled() {
+		svcPorts = append(svcPorts, v1.ServicePort{Name: "tcp-client-tls", Port: ports.SecureClient})
+	}
+	return svcPorts
 }
 
-func makeZkConfigString(z *v1beta1.ZookeeperCluster) string {
-	ports := z.ZookeeperPorts()
+// MakeObserverHeadlessService returns the headless service the observers
+// are registered under in the dynamic config
+func MakeObserverHeadlessService(z *api.ZookeeperCluster) *v1.Service {
+	svc := makeService(z.GetObserverHeadlessServiceName(), headlessServicePorts(z), false, false, z.Spec.HeadlessService.Annotations, z)
+	svc.Spec.Selector = map[string]string{"app": z.GetObserverName()}
+	return svc
+}
 
+// MakeObserverClientService returns a client service which only selects the
+// observers, for the clients which should not add load to the voting members
+func MakeObserverClientService(z *api.ZookeeperCluster) *v1.Service {
+	annotations := z.Spec.Observers.ClientService.Annotations
+	svc := makeService(z.GetObserverClientServiceName(), clientServicePorts(z), true, false, annotations, z)
+	svc.Spec.Selector = map[string]string{"app": z.GetObserverName()}
+	return svc
+}
+
+func makeZkConfigString(z *api.ZookeeperCluster) string {
+	ports := z.Spec.Ports
+
+	// sorted, so that the hash of the ConfigMap does not change with the
+	// order of the map
+	keys := make([]string, 0, len(z.Spec.Conf.AdditionalConfig))
+	for key := range z.Spec.Conf.AdditionalConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	var zkConfig = ""
-	for key, value := range z.Spec.Conf.AdditionalConfig {
-		zkConfig = zkConfig + fmt.Sprintf("%s=%s\n", key, value)
+	for _, key := range keys {
+		zkConfig = zkConfig + fmt.Sprintf("%s=%s\n", key, z.Spec.Conf.AdditionalConfig[key])
 	}
-	return zkConfig + "4lw.commands.whitelist=cons, envi, conf, crst, srvr, stat, mntr, ruok\n" +
+	zkConfig = zkConfig + "4lw.commands.whitelist=cons, envi, conf, crst, srvr, stat, mntr, ruok\n" +
 		"dataDir=/data\n" +
 		"standaloneEnabled=false\n" +
 		"reconfigEnabled=true\n" +
-		"skipACL=yes\n" +
+		"skipACL=" + skipACL(z) + "\n" +
 		"metricsProvider.className=org.apache.zookeeper.metrics.prometheus.PrometheusMetricsProvider\n" +
 		"metricsProvider.httpPort=" + strconv.Itoa(int(ports.Metrics)) + "\n" +
 		"metricsProvider.exportJvmInfo=true\n" +
@@ -278,8 +774,66 @@ func makeZkConfigString(z *v1beta1.ZookeeperCluster) string {
 		"autopurge.snapRetainCount=" + strconv.Itoa(z.Spec.Conf.AutoPurgeSnapRetainCount) + "\n" +
 		"autopurge.purgeInterval=" + strconv.Itoa(z.Spec.Conf.AutoPurgePurgeInterval) + "\n" +
 		"quorumListenOnAllIPs=" + strconv.FormatBool(z.Spec.Conf.QuorumListenOnAllIPs) + "\n" +
-		"admin.serverPort=" + strconv.Itoa(int(ports.AdminServer)) + "\n" +
-		"dynamicConfigFile=/data/zoo.cfg.dynamic\n"
+		"admin.serverPort=" + strconv.Itoa(int(ports.AdminServer)) + "\n"
+	if z.DataLog() != nil {
+		zkConfig = zkConfig + "dataLogDir=" + dataLogDir + "\n"
+	}
+	if z.Spec.TLS.ClientEnabled() {
+		zkConfig = zkConfig + "secureClientPort=" + strconv.Itoa(int(ports.SecureClient)) + "\n" +
+			"serverCnxnFactory=org.apache.zookeeper.server.NettyServerCnxnFactory\n" +
+			"ssl.keyStore.location=" + clientTLSFiles.keyStore() + "\n" +
+			"ssl.keyStore.type=PEM\n" +
+			"ssl.trustStore.location=" + clientTLSFiles.trustStore() + "\n" +
+			"ssl.trustStore.type=PEM\n"
+	}
+	if phase := z.Status.QuorumTLSPhase(); phase != api.QuorumTLSDisabled {
+		zkConfig = zkConfig + "sslQuorum=" + strconv.FormatBool(phase != api.QuorumTLSPortUnification) + "\n" +
+			"portUnification=" + strconv.FormatBool(phase != api.QuorumTLSEnabled) + "\n" +
+			"ssl.quorum.keyStore.location=" + quorumTLSFiles.keyStore() + "\n" +
+			"ssl.quorum.keyStore.type=PEM\n" +
+			"ssl.quorum.trustStore.location=" + quorumTLSFiles.trustStore() + "\n" +
+			"ssl.quorum.trustStore.type=PEM\n"
+	}
+	if z.Spec.Auth != nil {
+		zkConfig = zkConfig + "authProvider.sasl=org.apache.zookeeper.server.auth.SASLAuthenticationProvider\n"
+		if z.Spec.Auth.RequireClientAuth {
+			// the operator authenticates with the digest scheme
+			zkConfig = zkConfig + "enforce.auth.enabled=true\n" +
+				"enforce.auth.schemes=sasl,digest\n"
+		}
+	}
+	if phase := z.Status.QuorumAuthPhase(); phase != api.QuorumAuthDisabled {
+		zkConfig = zkConfig + "quorum.auth.enableSasl=true\n" +
+			"quorum.auth.learnerRequireSasl=" + strconv.FormatBool(phase != api.QuorumAuthSASLEnabled) + "\n" +
+			"quorum.auth.serverRequireSasl=" + strconv.FormatBool(phase == api.QuorumAuthRequired) + "\n"
+	}
+	// zookeeperStart.sh replaces the last line with the dynamic config file
+	// zookeeper recorded on disk, so it has to stay last
+	return zkConfig + "dynamicConfigFile=/data/zoo.cfg.dynamic\n"
+}
+
+func skipACL(z *api.ZookeeperCluster) string {
+	if z.Spec.Auth != nil && !z.Spec.Auth.SkipACL {
+		return "no"
+	}
+	return "yes"
+}
+
+// ConfigKeys returns the keys the operator itself writes to zoo.cfg for the
+// given cluster. ZooKeeper keeps the last value it reads for a key, so any
+// additionalConfig entry using one of these keys would silently be ignored.
+func ConfigKeys(z *api.ZookeeperCluster) []string {
+	c := z.DeepCopy()
+	c.Spec.Conf.AdditionalConfig = nil
+	c.InitQuorumTLS()
+	c.InitQuorumAuth()
+	var keys []string
+	for _, line := range strings.Split(makeZkConfigString(c), "\n") {
+		if i := strings.Index(line, "="); i > 0 {
+			keys = append(keys, line[:i])
+		}
+	}
+	return keys
 }
 
 func makeZkLog4JQuietConfigString() string {
@@ -300,12 +854,12 @@ func makeZkLog4JConfigString() string {
 		"log4j.appender.CONSOLE.layout.ConversionPattern=%d{ISO8601} [myid:%X{myid}] - %-5p [%t:%C{1}@%L] - %m%n\n"
 }
 
-func makeZkEnvConfigString(z *v1beta1.ZookeeperCluster) string {
-	ports := z.ZookeeperPorts()
+func makeZkEnvConfigString(z *api.ZookeeperCluster) string {
+	ports := z.Spec.Ports
 	return "#!/usr/bin/env bash\n\n" +
 		"DOMAIN=" + headlessDomain(z) + "\n" +
 		"QUORUM_PORT=" + strconv.Itoa(int(ports.Quorum)) + "\n" +
-		"LEADER_PORT=" + strconv.Itoa(int(ports.Leader)) + "\n" +
+		"LEADER_PORT=" + strconv.Itoa(int(ports.LeaderElection)) + "\n" +
 		"CLIENT_HOST=" + z.GetClientServiceName() + "\n" +
 		"CLIENT_PORT=" + strconv.Itoa(int(ports.Client)) + "\n" +
 		"ADMIN_SERVER_HOST=" + z.GetAdminServerServiceName() + "\n" +
@@ -314,7 +868,7 @@ func makeZkEnvConfigString(z *v1beta1.ZookeeperCluster) string {
 		"CLUSTER_SIZE=" + fmt.Sprint(z.Spec.Replicas) + "\n"
 }
 
-func makeService(name string, ports []v1.ServicePort, clusterIP bool, external bool, annotations map[string]string, z *v1beta1.ZookeeperCluster) *v1.Service {
+func makeService(name string, ports []v1.ServicePort, clusterIP bool, external bool, annotations map[string]string, z *api.ZookeeperCluster) *v1.Service {
 	var dnsName string
 	var annotationMap = copyMap(annotations)
 	if !clusterIP && z.Spec.DomainName != "" {
@@ -355,7 +909,7 @@ func makeService(name string, ports []v1.ServicePort, clusterIP bool, external b
 }
 
 // MakePodDisruptionBudget returns a pdb for the zookeeper cluster
-func MakePodDisruptionBudget(z *v1beta1.ZookeeperCluster) *policyv1.PodDisruptionBudget {
+func MakePodDisruptionBudget(z *api.ZookeeperCluster) *policyv1.PodDisruptionBudget {
 	pdbCount := intstr.FromInt(int(z.Spec.MaxUnavailableReplicas))
 	return &policyv1.PodDisruptionBudget{
 		TypeMeta: metav1.TypeMeta{
@@ -379,7 +933,7 @@ func MakePodDisruptionBudget(z *v1beta1.ZookeeperCluster) *policyv1.PodDisruptio
 }
 
 // MakeServiceAccount returns the service account for zookeeper Cluster
-func MakeServiceAccount(z *v1beta1.ZookeeperCluster) *v1.ServiceAccount {
+func MakeServiceAccount(z *api.ZookeeperCluster) *v1.ServiceAccount {
 	return &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      z.Spec.Pod.ServiceAccountName,
@@ -19,9 +19,10 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	"github.com/pravega/zookeeper-operator/pkg/utils"
 	"github.com/pravega/zookeeper-operator/pkg/zk"
 
@@ -38,16 +39,16 @@ var _ = Describe("Generators Spec", func() {
 			var cfg string
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{
+					Spec: api.ZookeeperClusterSpec{
 						Labels: map[string]string{
 							"exampleLabel": "exampleValue",
 						},
-						Conf: v1beta1.ZookeeperConfig{
+						Conf: api.ZookeeperConfig{
 							AdditionalConfig: map[string]string{
 								"tcpKeepAlive": "true",
 							},
@@ -149,12 +150,12 @@ var _ = Describe("Generators Spec", func() {
 			var cfg string
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{
+					Spec: api.ZookeeperClusterSpec{
 						KubernetesClusterDomain: "foo.bar",
 					},
 				}
@@ -183,12 +184,12 @@ var _ = Describe("Generators Spec", func() {
 		Context("with defaults", func() {
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{
+					Spec: api.ZookeeperClusterSpec{
 						Labels: map[string]string{
 							"exampleLabel": "exampleValue",
 						},
@@ -218,13 +219,13 @@ var _ = Describe("Generators Spec", func() {
 		Context("with pod policy annotations", func() {
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{
-						Pod: v1beta1.PodPolicy{
+					Spec: api.ZookeeperClusterSpec{
+						Pod: api.PodPolicy{
 							Annotations: map[string]string{
 								"example-annotation": "example-value",
 							},
@@ -243,20 +244,211 @@ var _ = Describe("Generators Spec", func() {
 		})
 	})
 
+	Context("#MakeStatefulSet with a transaction log volume", func() {
+		var (
+			z   *api.ZookeeperCluster
+			sts *appsv1.StatefulSet
+		)
+
+		BeforeEach(func() {
+			class := "fast"
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Persistence: &api.Persistence{
+						DataLog: &api.DataLogPersistence{
+							PersistentVolumeClaimSpec: v1.PersistentVolumeClaimSpec{StorageClassName: &class},
+						},
+					},
+				},
+			}
+			z.WithDefaults()
+			sts = zk.MakeStatefulSet(z)
+		})
+
+		It("should have a second volume claim template", func() {
+			Ω(sts.Spec.VolumeClaimTemplates).To(HaveLen(2))
+			dataLog := sts.Spec.VolumeClaimTemplates[1]
+			Ω(dataLog.Name).To(Equal("datalog"))
+			Ω(*dataLog.Spec.StorageClassName).To(Equal("fast"))
+			Ω(dataLog.Spec.Resources.Requests.Storage().String()).To(Equal("10Gi"))
+			Ω(dataLog.Labels).To(HaveKeyWithValue("app", "example"))
+		})
+
+		It("should mount the volume", func() {
+			container := sts.Spec.Template.Spec.Containers[0]
+			Ω(container.VolumeMounts).To(ContainElement(v1.VolumeMount{Name: "datalog", MountPath: "/datalog"}))
+		})
+
+		It("should hash the data of the ConfigMap", func() {
+			cm := zk.MakeConfigMap(z)
+			hash := zk.ConfigMapHash(cm)
+			Ω(hash).To(HaveLen(16))
+			Ω(zk.ConfigMapHash(zk.MakeConfigMap(z))).To(Equal(hash))
+			cm.Data["zoo.cfg"] += "snapCount=1\n"
+			Ω(zk.ConfigMapHash(cm)).NotTo(Equal(hash))
+		})
+
+		It("should hash the data of the TLS secrets", func() {
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-tls"},
+				Data:       map[string][]byte{"tls.crt": []byte("a"), "tls.key": []byte("b")},
+			}
+			Ω(zk.TLSSecretsHash(nil)).To(BeEmpty())
+			hash := zk.TLSSecretsHash([]*v1.Secret{secret})
+			Ω(hash).To(HaveLen(16))
+			secret.Data["tls.crt"] = []byte("c")
+			Ω(zk.TLSSecretsHash([]*v1.Secret{secret})).NotTo(Equal(hash))
+		})
+
+		It("should hash the additional config in the same order", func() {
+			z.Spec.Conf.AdditionalConfig = map[string]string{
+				"snapCount":              "10000",
+				"globalOutstandingLimit": "1000",
+				"preAllocSize":           "65536",
+				"maxSessionTimeout":      "40000",
+				"commitLogCount":         "500",
+			}
+			hash := zk.ConfigMapHash(zk.MakeConfigMap(z))
+			for i := 0; i < 50; i++ {
+				Ω(zk.ConfigMapHash(zk.MakeConfigMap(z))).To(Equal(hash))
+			}
+		})
+
+		It("should not change the hash when the cluster is scaled", func() {
+			hash := zk.ConfigMapHash(zk.MakeConfigMap(z))
+			z.Spec.Replicas++
+			cm := zk.MakeConfigMap(z)
+			Ω(cm.Data["env.sh"]).To(ContainSubstring(fmt.Sprintf("CLUSTER_SIZE=%d", z.Spec.Replicas)))
+			Ω(zk.ConfigMapHash(cm)).To(Equal(hash))
+		})
+
+		It("should carry the rolled out configuration in the pod template", func() {
+			z.Status.Config = &api.ConfigStatus{Hash: "b", RolledOutHash: "a"}
+			sts = zk.MakeStatefulSet(z)
+			Ω(sts.Spec.Template.Annotations).To(HaveKeyWithValue(api.ConfigHashAnnotation, "a"))
+		})
+
+		It("should set dataLogDir", func() {
+			cm := zk.MakeConfigMap(z)
+			Ω(cm.Data["zoo.cfg"]).To(ContainSubstring("dataLogDir=/datalog\n"))
+		})
+
+		It("should not be used with ephemeral storage", func() {
+			z.Spec.StorageType = api.StorageTypeEphemeral
+			z.Spec.Ephemeral = &api.Ephemeral{}
+			sts = zk.MakeStatefulSet(z)
+			Ω(sts.Spec.VolumeClaimTemplates).To(BeEmpty())
+			Ω(zk.MakeConfigMap(z).Data["zoo.cfg"]).NotTo(ContainSubstring("dataLogDir"))
+		})
+	})
+
+	Context("#MakeStatefulSet with LeaderAware upgrades", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Observers:     &api.Observers{Replicas: 1},
+					UpgradePolicy: &api.UpgradePolicy{Strategy: api.LeaderAwareUpgrade},
+				},
+			}
+			z.WithDefaults()
+		})
+
+		It("should leave the restarts of the voting members to the operator", func() {
+			sts := zk.MakeStatefulSet(z)
+			Ω(sts.Spec.UpdateStrategy.Type).To(Equal(appsv1.OnDeleteStatefulSetStrategyType))
+		})
+
+		It("should keep the rolling update of the observers", func() {
+			sts := zk.MakeObserverStatefulSet(z)
+			Ω(sts.Spec.UpdateStrategy.Type).To(Equal(appsv1.RollingUpdateStatefulSetStrategyType))
+		})
+
+		It("should roll the voting members by default", func() {
+			z.Spec.UpgradePolicy = nil
+			sts := zk.MakeStatefulSet(z)
+			Ω(sts.Spec.UpdateStrategy.Type).To(Equal(appsv1.RollingUpdateStatefulSetStrategyType))
+		})
+	})
+
+	Context("#MakeStatefulSet with an upgrade policy", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			canary := int32(1)
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Observers:     &api.Observers{Replicas: 2},
+					UpgradePolicy: &api.UpgradePolicy{Canary: &canary},
+				},
+			}
+			z.WithDefaults()
+			z.Status.CurrentVersion = "0.2.14"
+			z.Status.TargetVersion = z.Spec.Image.Tag
+		})
+
+		It("should only roll the canary", func() {
+			sts := zk.MakeStatefulSet(z)
+			Ω(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(2))
+		})
+
+		It("should hold the observers until the voting members are upgraded", func() {
+			sts := zk.MakeObserverStatefulSet(z)
+			Ω(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(2))
+		})
+
+		It("should hold all the members when paused", func() {
+			z.Spec.UpgradePolicy.Paused = true
+			sts := zk.MakeStatefulSet(z)
+			Ω(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).To(BeEquivalentTo(3))
+		})
+
+		It("should roll all the members with a new configuration", func() {
+			z.Status.CurrentVersion = z.Spec.Image.Tag
+			z.Status.TargetVersion = ""
+			z.Status.Config = &api.ConfigStatus{Hash: "b", RolledOutHash: "b"}
+			sts := zk.MakeStatefulSet(z)
+			Ω(sts.Spec.Template.Annotations).To(HaveKeyWithValue(api.ConfigHashAnnotation, "b"))
+			Ω(sts.Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+			Ω(zk.MakeObserverStatefulSet(z).Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+			z.Spec.UpgradePolicy.Paused = true
+			Ω(zk.MakeStatefulSet(z).Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+		})
+
+		It("should roll all the members without a canary", func() {
+			z.Spec.UpgradePolicy.Canary = nil
+			Ω(zk.MakeStatefulSet(z).Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+			Ω(zk.MakeObserverStatefulSet(z).Spec.UpdateStrategy.RollingUpdate).To(BeNil())
+		})
+	})
+
 	Context("#MakeStatefulSet with Ephemeral storage", func() {
 		var sts *appsv1.StatefulSet
 
 		Context("with defaults", func() {
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{},
+					Spec: api.ZookeeperClusterSpec{},
 				}
-				z.Spec = v1beta1.ZookeeperClusterSpec{
+				z.Spec = api.ZookeeperClusterSpec{
 					StorageType: "ephemeral",
 				}
 				z.WithDefaults()
@@ -273,12 +465,12 @@ var _ = Describe("Generators Spec", func() {
 		Context("with defaults", func() {
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{},
+					Spec: api.ZookeeperClusterSpec{},
 				}
 				z.Spec.Pod.ServiceAccountName = "zookeeper"
 				z.WithDefaults()
@@ -297,12 +489,12 @@ var _ = Describe("Generators Spec", func() {
 		Context("with defaults", func() {
 
 			BeforeEach(func() {
-				z := &v1beta1.ZookeeperCluster{
+				z := &api.ZookeeperCluster{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "example",
 						Namespace: "default",
 					},
-					Spec: v1beta1.ZookeeperClusterSpec{},
+					Spec: api.ZookeeperClusterSpec{},
 				}
 				z.WithDefaults()
 				z.Spec.InitContainers = []v1.Container{
@@ -328,17 +520,17 @@ var _ = Describe("Generators Spec", func() {
 
 		BeforeEach(func() {
 			domainName = "zk.com."
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
+				Spec: api.ZookeeperClusterSpec{
 					DomainName: domainName,
 					Labels: map[string]string{
 						"exampleLabel": "exampleValue",
 					},
-					ClientService: v1beta1.ClientServicePolicy{
+					ClientService: api.ClientServicePolicy{
 						Annotations: map[string]string{
 							"exampleAnnotation": "exampleValue",
 						},
@@ -386,17 +578,17 @@ var _ = Describe("Generators Spec", func() {
 
 		BeforeEach(func() {
 			domainName = "zk.com."
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
+				Spec: api.ZookeeperClusterSpec{
 					DomainName: domainName,
 					Labels: map[string]string{
 						"exampleLabel": "exampleValue",
 					},
-					HeadlessService: v1beta1.HeadlessServicePolicy{
+					HeadlessService: api.HeadlessServicePolicy{
 						Annotations: map[string]string{
 							"exampleAnnotation": "exampleValue",
 						},
@@ -464,12 +656,12 @@ var _ = Describe("Generators Spec", func() {
 
 		BeforeEach(func() {
 			domainName = "zkcom"
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
+				Spec: api.ZookeeperClusterSpec{
 					DomainName: domainName,
 				},
 			}
@@ -488,16 +680,16 @@ var _ = Describe("Generators Spec", func() {
 		var s *v1.Service
 
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
+				Spec: api.ZookeeperClusterSpec{
 					Labels: map[string]string{
 						"exampleLabel": "exampleValue",
 					},
-					AdminServerService: v1beta1.AdminServerServicePolicy{
+					AdminServerService: api.AdminServerServicePolicy{
 						Annotations: map[string]string{
 							"exampleAnnotation": "exampleValue",
 						},
@@ -543,13 +735,13 @@ var _ = Describe("Generators Spec", func() {
 		var s *v1.Service
 
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
-					AdminServerService: v1beta1.AdminServerServicePolicy{
+				Spec: api.ZookeeperClusterSpec{
+					AdminServerService: api.AdminServerServicePolicy{
 						External: true,
 					},
 				},
@@ -570,12 +762,12 @@ var _ = Describe("Generators Spec", func() {
 
 		BeforeEach(func() {
 			domainName = "zk.com."
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
 				},
-				Spec: v1beta1.ZookeeperClusterSpec{
+				Spec: api.ZookeeperClusterSpec{
 					DomainName: domainName,
 					Labels: map[string]string{
 						"exampleLabel": "exampleValue",
@@ -601,4 +793,376 @@ var _ = Describe("Generators Spec", func() {
 				"exampleValue"))
 		})
 	})
+
+	Context("#ConfigKeys", func() {
+		var keys []string
+
+		BeforeEach(func() {
+			z := &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Conf: api.ZookeeperConfig{
+						AdditionalConfig: map[string]string{
+							"tcpKeepAlive": "true",
+						},
+					},
+				},
+			}
+			z.WithDefaults()
+			keys = zk.ConfigKeys(z)
+		})
+
+		It("should contain the keys written by the operator", func() {
+			Ω(keys).To(ContainElements("dataDir", "skipACL", "tickTime", "dynamicConfigFile"))
+		})
+
+		It("should not contain additional configuration keys", func() {
+			Ω(keys).NotTo(ContainElement("tcpKeepAlive"))
+		})
+	})
+
+	Context("with client TLS", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					TLS: &api.TLS{
+						Client: &api.ClientTLS{SecretName: "example-tls"},
+					},
+				},
+			}
+			z.WithDefaults()
+		})
+
+		It("should configure the secure client port in zoo.cfg", func() {
+			cfg := zk.MakeConfigMap(z).Data["zoo.cfg"]
+			Ω(cfg).To(ContainSubstring("secureClientPort=2281\n"))
+			Ω(cfg).To(ContainSubstring("serverCnxnFactory=org.apache.zookeeper.server.NettyServerCnxnFactory\n"))
+			Ω(cfg).To(ContainSubstring("ssl.keyStore.location=/tls/client/keystore.pem\n"))
+			Ω(cfg).To(ContainSubstring("ssl.trustStore.location=/tls/client-secret/ca.crt\n"))
+		})
+
+		It("should keep the dynamic config file on the last line", func() {
+			lines := strings.Split(strings.TrimSpace(zk.MakeConfigMap(z).Data["zoo.cfg"]), "\n")
+			Ω(lines[len(lines)-1]).To(Equal("dynamicConfigFile=/data/zoo.cfg.dynamic"))
+		})
+
+		It("should report the ssl keys as written by the operator", func() {
+			Ω(zk.ConfigKeys(z)).To(ContainElements("secureClientPort", "ssl.keyStore.location"))
+		})
+
+		It("should mount the secret and prepare the key store", func() {
+			sts := zk.MakeStatefulSet(z)
+			spec := sts.Spec.Template.Spec
+			var secretVolume *v1.Volume
+			for i := range spec.Volumes {
+				if spec.Volumes[i].Secret != nil {
+					secretVolume = &spec.Volumes[i]
+				}
+			}
+			Ω(secretVolume).NotTo(BeNil())
+			Ω(secretVolume.Secret.SecretName).To(Equal("example-tls"))
+			Ω(spec.InitContainers).To(HaveLen(1))
+			Ω(spec.InitContainers[0].Command[2]).To(ContainSubstring("/tls/client/keystore.pem"))
+			zkContainer := spec.Containers[len(spec.Containers)-1]
+			Ω(zkContainer.Ports).To(ContainElement(v1.ContainerPort{Name: "client-tls", ContainerPort: 2281}))
+			Ω(zkContainer.VolumeMounts).To(ContainElement(v1.VolumeMount{Name: "client-tls", MountPath: "/tls/client"}))
+		})
+
+		It("should expose the secure client port on the services", func() {
+			for _, svc := range []*v1.Service{zk.MakeClientService(z), zk.MakeHeadlessService(z)} {
+				p, err := utils.ServicePortByName(svc.Spec.Ports, "tcp-client-tls")
+				Ω(err).To(BeNil())
+				Ω(p.Port).To(BeEquivalentTo(2281))
+			}
+		})
+
+		It("should not change anything without TLS", func() {
+			z.Spec.TLS = nil
+			z.Spec.Ports.SecureClient = 0
+			Ω(zk.MakeConfigMap(z).Data["zoo.cfg"]).NotTo(ContainSubstring("ssl."))
+			Ω(zk.MakeStatefulSet(z).Spec.Template.Spec.InitContainers).To(BeEmpty())
+			_, err := utils.ServicePortByName(zk.MakeClientService(z).Spec.Ports, "tcp-client-tls")
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("with quorum TLS", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					TLS: &api.TLS{
+						Quorum: &api.QuorumTLS{SecretName: "example-quorum-tls"},
+					},
+				},
+			}
+			z.WithDefaults()
+		})
+
+		It("should follow the status rather than the spec", func() {
+			Ω(zk.MakeConfigMap(z).Data["zoo.cfg"]).NotTo(ContainSubstring("sslQuorum"))
+			Ω(zk.MakeStatefulSet(z).Spec.Template.Spec.InitContainers).To(BeEmpty())
+		})
+
+		It("should configure zoo.cfg for each phase", func() {
+			for phase, cfg := range map[api.QuorumTLSPhase][]string{
+				api.QuorumTLSPortUnification: {"sslQuorum=false\n", "portUnification=true\n"},
+				api.QuorumTLSSSLQuorum:       {"sslQuorum=true\n", "portUnification=true\n"},
+				api.QuorumTLSEnabled:         {"sslQuorum=true\n", "portUnification=false\n"},
+			} {
+				z.Status.QuorumTLS = &api.QuorumTLSStatus{Phase: phase, SecretName: "example-quorum-tls"}
+				zooCfg := zk.MakeConfigMap(z).Data["zoo.cfg"]
+				Ω(zooCfg).To(ContainSubstring(cfg[0]))
+				Ω(zooCfg).To(ContainSubstring(cfg[1]))
+				Ω(zooCfg).To(ContainSubstring("ssl.quorum.keyStore.location=/tls/quorum/keystore.pem\n"))
+				Ω(zooCfg).To(ContainSubstring("ssl.quorum.trustStore.location=/tls/quorum-secret/ca.crt\n"))
+				lines := strings.Split(strings.TrimSpace(zooCfg), "\n")
+				Ω(lines[len(lines)-1]).To(Equal("dynamicConfigFile=/data/zoo.cfg.dynamic"))
+			}
+		})
+
+		It("should roll the pods for each phase", func() {
+			z.Status.QuorumTLS = &api.QuorumTLSStatus{Phase: api.QuorumTLSSSLQuorum, SecretName: "example-quorum-tls"}
+			annotations := zk.MakeStatefulSet(z).Spec.Template.Annotations
+			Ω(annotations).To(HaveKeyWithValue(api.QuorumTLSPhaseAnnotation, "SSLQuorum"))
+		})
+
+		It("should mount the secret of the status and prepare the key store", func() {
+			z.Spec.TLS.Quorum.SecretName = "example-new-tls"
+			z.Status.QuorumTLS = &api.QuorumTLSStatus{Phase: api.QuorumTLSEnabled, SecretName: "example-quorum-tls"}
+			spec := zk.MakeStatefulSet(z).Spec.Template.Spec
+			Ω(spec.Volumes).To(ContainElement(v1.Volume{
+				Name: "quorum-tls-secret",
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{SecretName: "example-quorum-tls"},
+				},
+			}))
+			Ω(spec.InitContainers).To(HaveLen(1))
+			Ω(spec.InitContainers[0].Command[2]).To(ContainSubstring("/tls/quorum/keystore.pem"))
+			zkContainer := spec.Containers[len(spec.Containers)-1]
+			Ω(zkContainer.VolumeMounts).To(ContainElement(v1.VolumeMount{Name: "quorum-tls", MountPath: "/tls/quorum"}))
+		})
+
+		It("should prepare both key stores with client TLS", func() {
+			z.Spec.TLS.Client = &api.ClientTLS{SecretName: "example-tls"}
+			z.WithDefaults()
+			z.Status.QuorumTLS = &api.QuorumTLSStatus{Phase: api.QuorumTLSEnabled, SecretName: "example-quorum-tls"}
+			spec := zk.MakeStatefulSet(z).Spec.Template.Spec
+			Ω(spec.InitContainers).To(HaveLen(1))
+			Ω(spec.InitContainers[0].Command[2]).To(ContainSubstring("/tls/client/keystore.pem"))
+			Ω(spec.InitContainers[0].Command[2]).To(ContainSubstring("/tls/quorum/keystore.pem"))
+		})
+
+		It("should report the quorum keys as written by the operator", func() {
+			Ω(zk.ConfigKeys(z)).To(ContainElements("sslQuorum", "portUnification", "ssl.quorum.keyStore.location"))
+		})
+	})
+
+	Context("with auth", func() {
+		var (
+			z     *api.ZookeeperCluster
+			users *v1.Secret
+		)
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Auth: &api.Auth{SecretName: "example-users"},
+				},
+			}
+			z.WithDefaults()
+			users = &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-users"},
+				Data: map[string][]byte{
+					"super":  []byte("secret"),
+					"quorum": []byte("q"),
+					"app":    []byte(`a"b\c`),
+				},
+			}
+		})
+
+		It("should enable the SASL provider and the ACL checks", func() {
+			cfg := zk.MakeConfigMap(z).Data["zoo.cfg"]
+			Ω(cfg).To(ContainSubstring("skipACL=no\n"))
+			Ω(cfg).To(ContainSubstring("authProvider.sasl=org.apache.zookeeper.server.auth.SASLAuthenticationProvider\n"))
+			Ω(cfg).NotTo(ContainSubstring("enforce.auth.enabled"))
+			Ω(cfg).NotTo(ContainSubstring("quorum.auth"))
+		})
+
+		It("should keep skipping the ACL checks if asked to", func() {
+			z.Spec.Auth.SkipACL = true
+			Ω(zk.MakeConfigMap(z).Data["zoo.cfg"]).To(ContainSubstring("skipACL=yes\n"))
+		})
+
+		It("should require clients to authenticate", func() {
+			z.Spec.Auth.RequireClientAuth = true
+			cfg := zk.MakeConfigMap(z).Data["zoo.cfg"]
+			Ω(cfg).To(ContainSubstring("enforce.auth.enabled=true\n"))
+			Ω(cfg).To(ContainSubstring("enforce.auth.schemes=sasl,digest\n"))
+		})
+
+		It("should configure zoo.cfg for each quorum phase", func() {
+			for phase, cfg := range map[api.QuorumAuthPhase]string{
+				api.QuorumAuthSASLEnabled:     "quorum.auth.learnerRequireSasl=false\nquorum.auth.serverRequireSasl=false\n",
+				api.QuorumAuthLearnerRequired: "quorum.auth.learnerRequireSasl=true\nquorum.auth.serverRequireSasl=false\n",
+				api.QuorumAuthRequired:        "quorum.auth.learnerRequireSasl=true\nquorum.auth.serverRequireSasl=true\n",
+			} {
+				z.Status.QuorumAuth = &api.QuorumAuthStatus{Phase: phase, User: "quorum"}
+				zooCfg := zk.MakeConfigMap(z).Data["zoo.cfg"]
+				Ω(zooCfg).To(ContainSubstring("quorum.auth.enableSasl=true\n" + cfg))
+				lines := strings.Split(strings.TrimSpace(zooCfg), "\n")
+				Ω(lines[len(lines)-1]).To(Equal("dynamicConfigFile=/data/zoo.cfg.dynamic"))
+			}
+		})
+
+		It("should mount the generated secret", func() {
+			z.Status.QuorumAuth = &api.QuorumAuthStatus{Phase: api.QuorumAuthRequired, User: "quorum"}
+			sts := zk.MakeStatefulSet(z)
+			Ω(sts.Spec.Template.Annotations).To(HaveKeyWithValue(api.QuorumAuthPhaseAnnotation, "Required"))
+			spec := sts.Spec.Template.Spec
+			Ω(spec.Volumes).To(ContainElement(v1.Volume{
+				Name: "auth",
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{SecretName: "example-auth"},
+				},
+			}))
+			zkContainer := spec.Containers[len(spec.Containers)-1]
+			Ω(zkContainer.Env).To(ContainElement(v1.EnvVar{
+				Name:  "JAVA_TOOL_OPTIONS",
+				Value: "-Djava.security.auth.login.config=/auth/jaas.conf",
+			}))
+			Ω(zkContainer.Env).To(ContainElement(HaveField("Name", "SERVER_JVMFLAGS")))
+		})
+
+		It("should keep the generated secret while the members authenticate each other", func() {
+			z.Spec.Auth = nil
+			z.Status.QuorumAuth = &api.QuorumAuthStatus{Phase: api.QuorumAuthSASLEnabled, User: "quorum"}
+			Ω(zk.MakeStatefulSet(z).Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Name", "auth")))
+			z.Status.QuorumAuth = nil
+			Ω(zk.MakeStatefulSet(z).Spec.Template.Spec.Volumes).NotTo(ContainElement(HaveField("Name", "auth")))
+		})
+
+		It("should generate the JAAS configuration of the members", func() {
+			secret, err := zk.MakeAuthSecret(z, users)
+			Ω(err).To(BeNil())
+			Ω(secret.Name).To(Equal("example-auth"))
+			jaas := string(secret.Data["jaas.conf"])
+			Ω(jaas).To(ContainSubstring("Server {\n  org.apache.zookeeper.server.auth.DigestLoginModule required\n" +
+				"  user_app=\"a\\\"b\\\\c\"\n  user_quorum=\"q\"\n  user_super=\"secret\";\n};\n"))
+			Ω(jaas).To(ContainSubstring("Client {\n  org.apache.zookeeper.server.auth.DigestLoginModule required\n" +
+				"  username=\"super\"\n  password=\"secret\";\n};\n"))
+			Ω(jaas).NotTo(ContainSubstring("Quorum"))
+			// printf 'super:secret' | openssl dgst -binary -sha1 | base64
+			Ω(string(secret.Data["server-jvm-flags"])).To(Equal("-Dzookeeper.superUser=super " +
+				"-Dzookeeper.DigestAuthenticationProvider.superDigest=super:lK75jTNcA+U9vtVEw5vB51mj/w4= " +
+				"-Dzookeeper.admin.snapshot.enabled=true"))
+		})
+
+		It("should add the quorum sections once the members authenticate each other", func() {
+			z.Status.QuorumAuth = &api.QuorumAuthStatus{Phase: api.QuorumAuthSASLEnabled, User: "quorum"}
+			secret, err := zk.MakeAuthSecret(z, users)
+			Ω(err).To(BeNil())
+			jaas := string(secret.Data["jaas.conf"])
+			Ω(jaas).To(ContainSubstring("QuorumServer {\n  org.apache.zookeeper.server.auth.DigestLoginModule required\n  user_quorum=\"q\";\n};\n"))
+			Ω(jaas).To(ContainSubstring("QuorumLearner {\n  org.apache.zookeeper.server.auth.DigestLoginModule required\n" +
+				"  username=\"quorum\"\n  password=\"q\";\n};\n"))
+		})
+
+		It("should fail without the password of the super user", func() {
+			delete(users.Data, "super")
+			_, err := zk.MakeAuthSecret(z, users)
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("with observers", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+				Spec: api.ZookeeperClusterSpec{
+					Observers: &api.Observers{
+						Replicas: 2,
+						Pod: api.PodPolicy{
+							Labels:    map[string]string{"tier": "read"},
+							Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+						},
+						ClientService: api.ClientServicePolicy{Annotations: map[string]string{"read": "only"}},
+					},
+				},
+			}
+			z.WithDefaults()
+		})
+
+		It("should render the observers in their own StatefulSet", func() {
+			sts := zk.MakeObserverStatefulSet(z)
+			Ω(sts.Name).To(Equal("example-observer"))
+			Ω(*sts.Spec.Replicas).To(BeEquivalentTo(2))
+			Ω(sts.Spec.ServiceName).To(Equal("example-observer-headless"))
+			Ω(sts.Spec.Selector.MatchLabels).To(Equal(map[string]string{"app": "example-observer"}))
+			Ω(sts.Spec.Template.Labels).To(HaveKeyWithValue("app", "example-observer"))
+			Ω(sts.Spec.Template.Labels).To(HaveKeyWithValue("kind", "ZookeeperObserver"))
+			Ω(sts.Spec.Template.Labels).To(HaveKeyWithValue("tier", "read"))
+			Ω(sts.Spec.VolumeClaimTemplates[0].Labels).To(HaveKeyWithValue("app", "example-observer"))
+			zkContainer := sts.Spec.Template.Spec.Containers[0]
+			Ω(zkContainer.Resources.Requests).To(HaveKey(v1.ResourceCPU))
+			Ω(zkContainer.Env).To(ContainElements(
+				v1.EnvVar{Name: "ZK_ROLE", Value: "observer"},
+				v1.EnvVar{Name: "MYID_OFFSET", Value: "128"},
+				v1.EnvVar{Name: "SERVER_DOMAIN", Value: "example-observer-headless.default.svc.cluster.local"},
+			))
+		})
+
+		It("should leave the voting members alone", func() {
+			sts := zk.MakeStatefulSet(z)
+			Ω(sts.Spec.Template.Labels).To(HaveKeyWithValue("app", "example"))
+			Ω(sts.Spec.Template.Labels).NotTo(HaveKey("tier"))
+			Ω(sts.Spec.Template.Spec.Containers[0].Env).NotTo(ContainElement(HaveField("Name", "ZK_ROLE")))
+		})
+
+		It("should roll the observers with the quorum phases", func() {
+			z.Status.QuorumTLS = &api.QuorumTLSStatus{Phase: api.QuorumTLSSSLQuorum, SecretName: "example-quorum-tls"}
+			Ω(zk.MakeObserverStatefulSet(z).Spec.Template.Annotations).To(HaveKeyWithValue(api.QuorumTLSPhaseAnnotation, "SSLQuorum"))
+		})
+
+		It("should have no replicas without observers", func() {
+			z.Spec.Observers = nil
+			Ω(*zk.MakeObserverStatefulSet(z).Spec.Replicas).To(BeEquivalentTo(0))
+		})
+
+		It("should select only the observers in their services", func() {
+			headless := zk.MakeObserverHeadlessService(z)
+			Ω(headless.Name).To(Equal("example-observer-headless"))
+			Ω(headless.Spec.ClusterIP).To(Equal(v1.ClusterIPNone))
+			Ω(headless.Spec.Selector).To(Equal(map[string]string{"app": "example-observer"}))
+			client := zk.MakeObserverClientService(z)
+			Ω(client.Name).To(Equal("example-observer-client"))
+			Ω(client.Spec.ClusterIP).To(Equal(""))
+			Ω(client.Spec.Selector).To(Equal(map[string]string{"app": "example-observer"}))
+			Ω(client.Annotations).To(Equal(map[string]string{"read": "only"}))
+			Ω(zk.MakeClientService(z).Spec.Selector).To(Equal(map[string]string{"app": "example"}))
+		})
+	})
 })
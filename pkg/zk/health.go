@@ -0,0 +1,131 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// ProbeMembers queries the admin servers of the voting members and the
+// observers with the given pod names in parallel, and returns the state
+// they report, with the version of the dynamic config of the voting
+// members. The members which cannot be probed have an error instead.
+func ProbeMembers(client AdminClient, z *api.ZookeeperCluster, members []string, observers []string) *api.HealthStatus {
+	members = append([]string(nil), members...)
+	observers = append([]string(nil), observers...)
+	sort.Strings(members)
+	sort.Strings(observers)
+	health := &api.HealthStatus{
+		Members:       make([]api.MemberHealth, len(members)+len(observers)),
+		LastProbeTime: &metav1.Time{Time: time.Now()},
+	}
+	var wg sync.WaitGroup
+	probe := func(i int, pod string, address string, clientAddress string) {
+		defer wg.Done()
+		stats, err := client.ServerStats(address)
+		health.Members[i] = MakeMemberHealth(pod, stats, err)
+		if err != nil || clientAddress == "" {
+			return
+		}
+		if version, err := client.ConfigVersion(clientAddress); err != nil {
+			health.Members[i].Error = err.Error()
+		} else {
+			health.Members[i].ConfigVersion = "0x" + strconv.FormatInt(version, 16)
+		}
+	}
+	for i, pod := range members {
+		wg.Add(1)
+		go probe(i, pod, MemberAdminAddress(z, pod), MemberClientAddress(z, pod))
+	}
+	for i, pod := range observers {
+		wg.Add(1)
+		go probe(len(members)+i, pod, ObserverAdminAddress(z, pod), "")
+	}
+	wg.Wait()
+	if leaders := health.Leaders(); len(leaders) == 1 {
+		health.Leader = leaders[0]
+	}
+	return health
+}
+
+// MakeMemberHealth returns the health of the member with the given pod name
+// from the statistics it reported, or the error probing it
+func MakeMemberHealth(pod string, stats *ServerStats, err error) api.MemberHealth {
+	if err != nil {
+		return api.MemberHealth{Name: pod, Error: err.Error()}
+	}
+	return api.MemberHealth{
+		Name:                pod,
+		Role:                stats.ServerState,
+		Zxid:                "0x" + strconv.FormatInt(stats.LastProcessedZxid, 16),
+		Epoch:               stats.LastProcessedZxid >> 32,
+		OutstandingRequests: stats.OutstandingRequests,
+		AvgLatency:          strconv.FormatFloat(stats.AvgLatency, 'f', -1, 64),
+		MaxLatency:          stats.MaxLatency,
+		Connections:         stats.NumAliveClientConnections,
+	}
+}
+
+// EvaluateQuorum returns whether the voting members reported a healthy
+// quorum: a single leader, followed by a majority of the voting members of
+// the spec in its epoch, all with the same version of the dynamic config.
+// The reason and the message are the ones of the QuorumHealthy condition.
+func EvaluateQuorum(z *api.ZookeeperCluster, health *api.HealthStatus) (healthy bool, reason string, message string) {
+	leaders := health.Leaders()
+	switch {
+	case len(leaders) == 0:
+		return false, api.NoLeaderReason, "No voting member reports it is the leader"
+	case len(leaders) > 1:
+		return false, api.MultipleLeadersReason,
+			fmt.Sprintf("The voting members %s all report they are the leader, the ensemble is split", strings.Join(leaders, ", "))
+	}
+	var leader api.MemberHealth
+	for _, m := range health.Members {
+		if m.Name == leaders[0] {
+			leader = m
+		}
+	}
+	inQuorum := 1
+	versions := []string{}
+	mismatch := false
+	for _, m := range health.Members {
+		if m.Name != leader.Name && (m.Role != api.MemberRoleFollower || m.Epoch != leader.Epoch) {
+			continue
+		}
+		if m.Name != leader.Name {
+			inQuorum++
+		}
+		if m.ConfigVersion != "" {
+			mismatch = mismatch || m.ConfigVersion != leader.ConfigVersion
+			versions = append(versions, m.Name+" "+m.ConfigVersion)
+		}
+	}
+	quorum := int(z.Spec.Replicas)/2 + 1
+	if inQuorum < quorum {
+		return false, api.NoQuorumReason,
+			fmt.Sprintf("Only %d of the %d voting members follow the leader %s, %d are needed", inQuorum, z.Spec.Replicas, leader.Name, quorum)
+	}
+	if mismatch {
+		return false, api.ConfigVersionMismatchReason,
+			fmt.Sprintf("The voting members run different versions of the dynamic config: %s", strings.Join(versions, ", "))
+	}
+	return true, api.QuorumHealthyReason,
+		fmt.Sprintf("%d of the %d voting members follow the leader %s", inQuorum, z.Spec.Replicas, leader.Name)
+}
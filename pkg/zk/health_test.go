@@ -0,0 +1,163 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// statsAdminClient returns the statistics and the config versions of the
+// members by pod name
+type statsAdminClient struct {
+	stats    map[string]*zk.ServerStats
+	versions map[string]int64
+}
+
+func (client statsAdminClient) ServerState(address string) (string, error) {
+	stats, err := client.ServerStats(address)
+	if err != nil {
+		return "", err
+	}
+	return stats.ServerState, nil
+}
+
+func (client statsAdminClient) ServerStats(address string) (*zk.ServerStats, error) {
+	stats, ok := client.stats[strings.Split(address, ".")[0]]
+	if !ok {
+		return nil, fmt.Errorf("%s is not reachable", address)
+	}
+	return stats, nil
+}
+
+func (client statsAdminClient) ConfigVersion(address string) (int64, error) {
+	version, ok := client.versions[strings.Split(address, ".")[0]]
+	if !ok {
+		return 0, fmt.Errorf("%s is not reachable", address)
+	}
+	return version, nil
+}
+
+var _ = Describe("Health", func() {
+	var (
+		z      *api.ZookeeperCluster
+		client statsAdminClient
+	)
+
+	BeforeEach(func() {
+		z = &api.ZookeeperCluster{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+		z.WithDefaults()
+		client = statsAdminClient{
+			stats: map[string]*zk.ServerStats{
+				"example-0":          {ServerState: "follower", LastProcessedZxid: 0x300000001},
+				"example-1":          {ServerState: "leader", LastProcessedZxid: 0x300000002, AvgLatency: 1.25, NumAliveClientConnections: 4},
+				"example-2":          {ServerState: "follower", LastProcessedZxid: 0x300000002},
+				"example-observer-0": {ServerState: "observer", LastProcessedZxid: 0x300000002},
+			},
+			versions: map[string]int64{"example-0": 0x100000000, "example-1": 0x100000000, "example-2": 0x100000000},
+		}
+	})
+
+	probe := func() *api.HealthStatus {
+		return zk.ProbeMembers(client, z, []string{"example-2", "example-0", "example-1"}, []string{"example-observer-0"})
+	}
+
+	It("should probe the members and the observers by name", func() {
+		health := probe()
+		Ω(health.LastProbeTime).NotTo(BeNil())
+		Ω(health.Leader).To(Equal("example-1"))
+		Ω(health.Members).To(HaveLen(4))
+		Ω(health.Members[1]).To(Equal(api.MemberHealth{
+			Name:          "example-1",
+			Role:          "leader",
+			Zxid:          "0x300000002",
+			Epoch:         3,
+			ConfigVersion: "0x100000000",
+			AvgLatency:    "1.25",
+			Connections:   4,
+		}))
+		Ω(health.Members[3].Role).To(Equal("observer"))
+		Ω(health.Members[3].ConfigVersion).To(BeEmpty())
+	})
+
+	It("should record the error of a member which cannot be probed", func() {
+		delete(client.stats, "example-2")
+		health := probe()
+		Ω(health.Members[2].Name).To(Equal("example-2"))
+		Ω(health.Members[2].Role).To(BeEmpty())
+		Ω(health.Members[2].Error).To(ContainSubstring("not reachable"))
+	})
+
+	It("should be healthy with a leader followed by a majority", func() {
+		delete(client.stats, "example-2")
+		healthy, reason, message := zk.EvaluateQuorum(z, probe())
+		Ω(healthy).To(BeTrue())
+		Ω(reason).To(Equal(api.QuorumHealthyReason))
+		Ω(message).To(Equal("2 of the 3 voting members follow the leader example-1"))
+	})
+
+	It("should not be healthy without a leader", func() {
+		client.stats["example-1"].ServerState = "looking"
+		healthy, reason, _ := zk.EvaluateQuorum(z, probe())
+		Ω(healthy).To(BeFalse())
+		Ω(reason).To(Equal(api.NoLeaderReason))
+	})
+
+	It("should not be healthy while the ensemble is split", func() {
+		client.stats["example-2"].ServerState = "leader"
+		healthy, reason, message := zk.EvaluateQuorum(z, probe())
+		Ω(healthy).To(BeFalse())
+		Ω(reason).To(Equal(api.MultipleLeadersReason))
+		Ω(message).To(ContainSubstring("example-1, example-2"))
+	})
+
+	It("should not count the followers of another epoch or the observers", func() {
+		client.stats["example-0"].LastProcessedZxid = 0x200000007
+		client.stats["example-2"].ServerState = "looking"
+		healthy, reason, _ := zk.EvaluateQuorum(z, probe())
+		Ω(healthy).To(BeFalse())
+		Ω(reason).To(Equal(api.NoQuorumReason))
+	})
+
+	It("should not be healthy while the voting members disagree on the dynamic config", func() {
+		client.versions["example-2"] = 0x300000004
+		healthy, reason, message := zk.EvaluateQuorum(z, probe())
+		Ω(healthy).To(BeFalse())
+		Ω(reason).To(Equal(api.ConfigVersionMismatchReason))
+		Ω(message).To(ContainSubstring("example-1 0x100000000, example-2 0x300000004"))
+	})
+
+	It("should record the error of a member whose config version cannot be read", func() {
+		delete(client.versions, "example-2")
+		health := probe()
+		Ω(health.Members[2].Role).To(Equal("follower"))
+		Ω(health.Members[2].Error).To(ContainSubstring("not reachable"))
+		healthy, _, _ := zk.EvaluateQuorum(z, health)
+		Ω(healthy).To(BeTrue())
+	})
+
+	It("should read the version of the membership of the conf command", func() {
+		version, err := zk.ParseConfigVersion("clientPort=2181\ndataDir=/data\nserverId=1\nmembership: \n" +
+			"server.1=example-0:2888:3888:participant;0.0.0.0:2181\nversion=100000002\n")
+		Ω(err).To(BeNil())
+		Ω(version).To(Equal(int64(0x100000002)))
+		_, err = zk.ParseConfigVersion("clientPort=2181\n")
+		Ω(err).NotTo(BeNil())
+	})
+})
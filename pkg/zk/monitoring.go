@@ -0,0 +1,151 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+// MonitoringGroupVersion is the API of the resources of the Prometheus
+// operator. The operator does not depend on its Go types, the resources are
+// unstructured.
+var MonitoringGroupVersion = schema.GroupVersion{Group: "monitoring.coreos.com", Version: "v1"}
+
+// PrometheusRuleKind is the kind of the resource holding the alerts
+const PrometheusRuleKind = "PrometheusRule"
+
+// MonitoringKinds are the kinds of the resources the operator creates for
+// Spec.Monitoring, all of them named after the cluster
+var MonitoringKinds = []string{string(api.PodMonitorKind), string(api.ServiceMonitorKind), PrometheusRuleKind}
+
+// MakeMonitoring returns the monitor and the rule of Spec.Monitoring by
+// kind. The kinds it has no resource of are deleted.
+func MakeMonitoring(z *api.ZookeeperCluster) map[string]*unstructured.Unstructured {
+	resources := map[string]*unstructured.Unstructured{}
+	m := z.Spec.Monitoring
+	if m == nil {
+		return resources
+	}
+	if m.Kind == api.ServiceMonitorKind {
+		resources[string(api.ServiceMonitorKind)] = MakeServiceMonitor(z)
+	} else {
+		resources[string(api.PodMonitorKind)] = MakePodMonitor(z)
+	}
+	if m.Alerts != nil {
+		resources[PrometheusRuleKind] = MakePrometheusRule(z)
+	}
+	return resources
+}
+
+// MakePodMonitor returns a PodMonitor scraping the metrics port of the
+// voting members and the observers
+func MakePodMonitor(z *api.ZookeeperCluster) *unstructured.Unstructured {
+	u := makeMonitoringResource(z, string(api.PodMonitorKind))
+	u.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchExpressions": []interface{}{
+				map[string]interface{}{
+					"key":      "app",
+					"operator": "In",
+					"values":   []interface{}{z.GetName(), z.GetObserverName()},
+				},
+			},
+		},
+		"podMetricsEndpoints": []interface{}{metricsEndpoint(z, "metrics")},
+	}
+	return u
+}
+
+// MakeServiceMonitor returns a ServiceMonitor scraping the members through
+// the headless services of the voting members and the observers
+func MakeServiceMonitor(z *api.ZookeeperCluster) *unstructured.Unstructured {
+	u := makeMonitoringResource(z, string(api.ServiceMonitorKind))
+	u.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				"app":      z.GetName(),
+				"headless": "true",
+			},
+		},
+		"endpoints": []interface{}{metricsEndpoint(z, "tcp-metrics")},
+	}
+	return u
+}
+
+func metricsEndpoint(z *api.ZookeeperCluster, port string) map[string]interface{} {
+	endpoint := map[string]interface{}{"port": port, "path": "/metrics"}
+	if z.Spec.Monitoring.Interval != "" {
+		endpoint["interval"] = z.Spec.Monitoring.Interval
+	}
+	return endpoint
+}
+
+// MakePrometheusRule returns the alerts on the health of the cluster, with
+// the thresholds of Spec.Monitoring.Alerts
+func MakePrometheusRule(z *api.ZookeeperCluster) *unstructured.Unstructured {
+	a := z.Spec.Monitoring.Alerts
+	voters := fmt.Sprintf(`namespace=%q,pod=~"%s-[0-9]+"`, z.Namespace, z.GetName())
+	members := fmt.Sprintf(`namespace=%q,pod=~"(%s|%s)-[0-9]+"`, z.Namespace, z.GetName(), z.GetObserverName())
+	quorum := z.Spec.Replicas/2 + 1
+	rules := []interface{}{
+		alertRule(z, "ZookeeperQuorumLost", "critical",
+			fmt.Sprintf(`sum by (namespace) (up{%s}) < %d or absent(up{%s})`, voters, quorum, voters),
+			fmt.Sprintf("Less than %d of the %d voting members of ZookeeperCluster %s are up, the cluster has no quorum", quorum, z.Spec.Replicas, z.Name)),
+		alertRule(z, "ZookeeperOutstandingRequests", "warning",
+			fmt.Sprintf(`max by (namespace, pod) (outstanding_requests{%s}) > %d`, members, a.OutstandingRequests),
+			fmt.Sprintf("Member {{ $labels.pod }} of ZookeeperCluster %s has more than %d requests queued", z.Name, a.OutstandingRequests)),
+		alertRule(z, "ZookeeperFsyncLatency", "warning",
+			fmt.Sprintf(`max by (namespace, pod) (rate(fsynctime_sum{%s}[5m]) / rate(fsynctime_count{%s}[5m])) > %d`, members, members, a.FsyncLatencyMilliseconds),
+			fmt.Sprintf("Member {{ $labels.pod }} of ZookeeperCluster %s takes more than %dms to sync its transaction log", z.Name, a.FsyncLatencyMilliseconds)),
+		alertRule(z, "ZookeeperZnodeGrowth", "warning",
+			fmt.Sprintf(`max by (namespace) (delta(znode_count{%s}[1h])) > %d`, voters, a.ZnodeGrowthPerHour),
+			fmt.Sprintf("More than %d znodes were created in ZookeeperCluster %s in the last hour", a.ZnodeGrowthPerHour, z.Name)),
+	}
+	u := makeMonitoringResource(z, PrometheusRuleKind)
+	u.Object["spec"] = map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name":  fmt.Sprintf("zookeeper-%s", z.Name),
+				"rules": rules,
+			},
+		},
+	}
+	return u
+}
+
+func alertRule(z *api.ZookeeperCluster, name, severity, expr, summary string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert": name,
+		"expr":  expr,
+		"for":   z.Spec.Monitoring.Alerts.For,
+		"labels": map[string]interface{}{
+			"severity":          severity,
+			"zookeeper_cluster": z.Name,
+		},
+		"annotations": map[string]interface{}{
+			"summary": summary,
+		},
+	}
+}
+
+func makeMonitoringResource(z *api.ZookeeperCluster, kind string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(MonitoringGroupVersion.WithKind(kind))
+	u.SetName(z.GetName())
+	u.SetNamespace(z.Namespace)
+	u.SetLabels(mergeLabels(z.Spec.Labels, z.Spec.Monitoring.Labels))
+	return u
+}
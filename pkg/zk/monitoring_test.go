@@ -0,0 +1,110 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitoring", func() {
+	var z *api.ZookeeperCluster
+
+	BeforeEach(func() {
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+			Spec: api.ZookeeperClusterSpec{
+				Monitoring: &api.Monitoring{
+					Interval: "15s",
+					Labels:   map[string]string{"release": "prometheus"},
+				},
+			},
+		}
+	})
+
+	alerts := func(u *unstructured.Unstructured) map[string]map[string]interface{} {
+		groups, _, _ := unstructured.NestedSlice(u.Object, "spec", "groups")
+		Ω(groups).To(HaveLen(1))
+		rules := map[string]map[string]interface{}{}
+		for _, rule := range groups[0].(map[string]interface{})["rules"].([]interface{}) {
+			r := rule.(map[string]interface{})
+			rules[r["alert"].(string)] = r
+		}
+		return rules
+	}
+
+	It("should make a PodMonitor by default", func() {
+		z.WithDefaults()
+		resources := zk.MakeMonitoring(z)
+		Ω(resources).To(HaveLen(1))
+		pm := resources["PodMonitor"]
+		Ω(pm).NotTo(BeNil())
+		Ω(pm.GetAPIVersion()).To(Equal("monitoring.coreos.com/v1"))
+		Ω(pm.GetLabels()).To(HaveKeyWithValue("release", "prometheus"))
+		values, _, _ := unstructured.NestedSlice(pm.Object, "spec", "selector", "matchExpressions")
+		Ω(values[0].(map[string]interface{})["values"]).To(ConsistOf("example", "example-observer"))
+		endpoints, _, _ := unstructured.NestedSlice(pm.Object, "spec", "podMetricsEndpoints")
+		Ω(endpoints[0]).To(HaveKeyWithValue("port", "metrics"))
+		Ω(endpoints[0]).To(HaveKeyWithValue("interval", "15s"))
+	})
+
+	It("should make a ServiceMonitor selecting the headless services", func() {
+		z.Spec.Monitoring.Kind = api.ServiceMonitorKind
+		z.WithDefaults()
+		sm := zk.MakeMonitoring(z)["ServiceMonitor"]
+		Ω(sm).NotTo(BeNil())
+		selector, _, _ := unstructured.NestedStringMap(sm.Object, "spec", "selector", "matchLabels")
+		Ω(selector).To(Equal(map[string]string{"app": "example", "headless": "true"}))
+		endpoints, _, _ := unstructured.NestedSlice(sm.Object, "spec", "endpoints")
+		Ω(endpoints[0]).To(HaveKeyWithValue("port", "tcp-metrics"))
+	})
+
+	It("should make no resources without monitoring", func() {
+		z.Spec.Monitoring = nil
+		z.WithDefaults()
+		Ω(zk.MakeMonitoring(z)).To(BeEmpty())
+	})
+
+	Context("with alerts", func() {
+		BeforeEach(func() {
+			z.Spec.Monitoring.Alerts = &api.Alerts{FsyncLatencyMilliseconds: 250}
+			z.WithDefaults()
+		})
+
+		It("should make the rule with the curated alerts", func() {
+			rule := zk.MakeMonitoring(z)["PrometheusRule"]
+			Ω(rule).NotTo(BeNil())
+			Ω(alerts(rule)).To(HaveLen(4))
+			Ω(alerts(rule)).To(HaveKey("ZookeeperQuorumLost"))
+			Ω(alerts(rule)).To(HaveKey("ZookeeperZnodeGrowth"))
+		})
+
+		It("should alert when a majority of the voting members is down", func() {
+			quorum := alerts(zk.MakePrometheusRule(z))["ZookeeperQuorumLost"]
+			Ω(quorum["expr"]).To(ContainSubstring(`up{namespace="default",pod=~"example-[0-9]+"}) < 2`))
+			Ω(quorum["labels"]).To(HaveKeyWithValue("severity", "critical"))
+		})
+
+		It("should use the thresholds of the spec and the defaults", func() {
+			rules := alerts(zk.MakePrometheusRule(z))
+			Ω(rules["ZookeeperFsyncLatency"]["expr"]).To(HaveSuffix("> 250"))
+			Ω(rules["ZookeeperOutstandingRequests"]["expr"]).To(HaveSuffix("> 10"))
+			Ω(rules["ZookeeperZnodeGrowth"]["expr"]).To(HaveSuffix("> 100000"))
+			Ω(rules["ZookeeperFsyncLatency"]["for"]).To(Equal("5m"))
+		})
+	})
+})
@@ -0,0 +1,252 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+)
+
+const (
+	restoreVolume  = "restore"
+	restoreDir     = "/restore"
+	restoreCommand = "zookeeperRestore.sh"
+	confVolume     = "conf"
+)
+
+var snapshotFileName = regexp.MustCompile(`^snapshot\.([0-9a-fA-F]+)$`)
+
+// RestoreSnapshot is the snapshot the members of a cluster are seeded with,
+// resolved from a backup or from the location of the spec
+type RestoreSnapshot struct {
+	// Zxid is the zxid of the snapshot in hex, prefixed with 0x
+	Zxid     string
+	Checksum string
+	PVC      *api.PVCSnapshot
+	S3       *api.S3Snapshot
+}
+
+// Location returns the location of the snapshot in the format of the
+// status of a backup
+func (s *RestoreSnapshot) Location() string {
+	if s.PVC != nil {
+		return "pvc://" + path.Join(s.PVC.ClaimName, s.PVC.Path)
+	}
+	return "s3://" + s.S3.Bucket + "/" + s.S3.Key
+}
+
+func (s *RestoreSnapshot) fileName() string {
+	return "snapshot." + strings.TrimPrefix(s.Zxid, "0x")
+}
+
+// snapshotZxid returns the zxid of a snapshot from the name zookeeper gave
+// its file
+func snapshotZxid(file string) (string, error) {
+	m := snapshotFileName.FindStringSubmatch(path.Base(file))
+	if m == nil {
+		return "", fmt.Errorf("%s is not named snapshot.<zxid>", file)
+	}
+	return "0x" + strings.ToLower(m[1]), nil
+}
+
+// SourceSnapshot returns the snapshot of a location set in the spec
+func SourceSnapshot(s *api.SnapshotSource) (*RestoreSnapshot, error) {
+	snapshot := &RestoreSnapshot{Checksum: s.Checksum, PVC: s.PVC, S3: s.S3}
+	file := ""
+	if s.PVC != nil {
+		file = s.PVC.Path
+	} else if s.S3 != nil {
+		file = s.S3.Key
+	}
+	zxid, err := snapshotZxid(file)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Zxid = zxid
+	return snapshot, nil
+}
+
+// BackupSnapshot returns the snapshot stored by a succeeded backup
+func BackupSnapshot(b *api.ZookeeperBackup) (*RestoreSnapshot, error) {
+	location := b.Status.Location
+	snapshot := &RestoreSnapshot{Checksum: b.Status.Checksum}
+	if rest, ok := strings.CutPrefix(location, "pvc://"); ok {
+		claim, file, _ := strings.Cut(rest, "/")
+		snapshot.PVC = &api.PVCSnapshot{ClaimName: claim, Path: file}
+	} else if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		target := b.Spec.Target.S3
+		if target == nil {
+			return nil, fmt.Errorf("backup %s has no s3 target for %s", b.Name, location)
+		}
+		bucket, key, _ := strings.Cut(rest, "/")
+		snapshot.S3 = &api.S3Snapshot{
+			Endpoint:          target.Endpoint,
+			Bucket:            bucket,
+			Key:               key,
+			Region:            target.Region,
+			CredentialsSecret: target.CredentialsSecret,
+		}
+	} else {
+		return nil, fmt.Errorf("backup %s has an unknown location %q", b.Name, location)
+	}
+	zxid, err := snapshotZxid(location)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Zxid = zxid
+	return snapshot, nil
+}
+
+// RestoreJobName returns the name of the job seeding the member with the
+// given ordinal
+func RestoreJobName(z *api.ZookeeperCluster, ord int32) string {
+	return fmt.Sprintf("%s-restore-%d", z.GetName(), ord)
+}
+
+// RestoreJobLabels returns the labels of the job seeding the member with the
+// given ordinal and of its pod
+func RestoreJobLabels(z *api.ZookeeperCluster, ord int32) map[string]string {
+	return map[string]string{
+		"zookeeper-cluster": z.GetName(),
+		"zookeeper-restore": strconv.Itoa(int(ord)),
+	}
+}
+
+// MakeRestorePVC returns the data volume claim of the member with the given
+// ordinal, named like the stateful set names it so that the member picks it
+// up once seeded
+func MakeRestorePVC(z *api.ZookeeperCluster, ord int32) *v1.PersistentVolumeClaim {
+	pvc := dataPVC(z, z.GetName())
+	pvc.Name = restorePVCName(z, ord)
+	pvc.Namespace = z.Namespace
+	return &pvc
+}
+
+// MakeRestoreDataLogPVC returns the transaction log volume of the member
+// with the given ordinal, which the restore empties, or nil if the logs are
+// stored on the data volume
+func MakeRestoreDataLogPVC(z *api.ZookeeperCluster, ord int32) *v1.PersistentVolumeClaim {
+	dataLog := z.DataLog()
+	if dataLog == nil {
+		return nil
+	}
+	pvc := dataLogPVC(z, dataLog, z.GetName())
+	pvc.Name = fmt.Sprintf("%s-%s-%d", DataLogVolume, z.GetName(), ord)
+	pvc.Namespace = z.Namespace
+	return &pvc
+}
+
+func restorePVCName(z *api.ZookeeperCluster, ord int32) string {
+	return fmt.Sprintf("%s-%s-%d", zkDataVolume, z.GetName(), ord)
+}
+
+// restoreDynamicConfig returns the dynamic config of the restored members,
+// all of them participants as if they had registered one after the other
+func restoreDynamicConfig(z *api.ZookeeperCluster, members int32) string {
+	ports := z.Spec.Ports
+	config := ""
+	for i := int32(0); i < members; i++ {
+		config = config + fmt.Sprintf("server.%d=%s-%d.%s:%d:%d:participant;%d\n",
+			i+1, z.GetName(), i, headlessDomain(z), ports.Quorum, ports.LeaderElection, ports.Client)
+	}
+	return config
+}
+
+// MakeRestoreJob returns the job writing the snapshot, the id and the
+// dynamic config of the member with the given ordinal to its data volume,
+// so that zookeeperStart.sh starts it as a member of the restored ensemble
+// of the given size
+func MakeRestoreJob(z *api.ZookeeperCluster, snapshot *RestoreSnapshot, ord int32, members int32) *batchv1.Job {
+	container := v1.Container{
+		Name:    "restore",
+		Command: []string{restoreCommand},
+		Env: []v1.EnvVar{
+			{Name: "MYID", Value: strconv.Itoa(int(ord) + 1)},
+			{Name: "SNAPSHOT_FILE", Value: snapshot.fileName()},
+			{Name: "SNAPSHOT_CHECKSUM", Value: snapshot.Checksum},
+			{Name: "DYNAMIC_CONFIG", Value: restoreDynamicConfig(z, members)},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: zkDataVolume, MountPath: "/data"},
+			{Name: confVolume, MountPath: "/conf"},
+		},
+	}
+	volumes := []v1.Volume{
+		{
+			Name: zkDataVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: restorePVCName(z, ord),
+				},
+			},
+		},
+		{
+			Name: confVolume,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: z.ConfigMapName()},
+				},
+			},
+		},
+	}
+	if dataLog := MakeRestoreDataLogPVC(z, ord); dataLog != nil {
+		// the logs of a former cluster of the same name are removed
+		volumes = append(volumes, v1.Volume{
+			Name: DataLogVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: dataLog.Name,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      DataLogVolume,
+			MountPath: dataLogDir,
+		})
+	}
+	if snapshot.PVC != nil {
+		volumes = append(volumes, v1.Volume{
+			Name: restoreVolume,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: snapshot.PVC.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      restoreVolume,
+			MountPath: restoreDir,
+			ReadOnly:  true,
+		})
+		container.Env = append(container.Env,
+			v1.EnvVar{Name: "SNAPSHOT_PATH", Value: path.Join(restoreDir, snapshot.PVC.Path)},
+		)
+	} else if snapshot.S3 != nil {
+		container.Env = append(container.Env,
+			v1.EnvVar{Name: "S3_ENDPOINT", Value: strings.TrimSuffix(snapshot.S3.Endpoint, "/")},
+			v1.EnvVar{Name: "S3_BUCKET", Value: snapshot.S3.Bucket},
+			v1.EnvVar{Name: "S3_KEY", Value: snapshot.S3.Key},
+			v1.EnvVar{Name: "S3_REGION", Value: snapshot.S3.GetRegion()},
+			s3CredentialEnv("AWS_ACCESS_KEY_ID", snapshot.S3.CredentialsSecret),
+			s3CredentialEnv("AWS_SECRET_ACCESS_KEY", snapshot.S3.CredentialsSecret),
+		)
+	}
+	return makeJob(z, RestoreJobName(z, ord), RestoreJobLabels(z, ord), container, volumes)
+}
@@ -0,0 +1,210 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package zk_test
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	"github.com/pravega/zookeeper-operator/pkg/zk"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Restore Spec", func() {
+	var z *api.ZookeeperCluster
+
+	BeforeEach(func() {
+		z = &api.ZookeeperCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+				UID:       "1234",
+			},
+		}
+		z.WithDefaults()
+	})
+
+	Context("#BackupSnapshot", func() {
+		var b *api.ZookeeperBackup
+
+		BeforeEach(func() {
+			b = &api.ZookeeperBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+				Status: api.ZookeeperBackupStatus{
+					Phase:    api.BackupSucceeded,
+					Checksum: "abc",
+				},
+			}
+		})
+
+		It("should resolve a snapshot stored on a claim", func() {
+			b.Status.Location = "pvc://backups/zk/nightly/snapshot.100000002"
+			s, err := zk.BackupSnapshot(b)
+			Ω(err).To(BeNil())
+			Ω(s.PVC).To(Equal(&api.PVCSnapshot{ClaimName: "backups", Path: "zk/nightly/snapshot.100000002"}))
+			Ω(s.Zxid).To(Equal("0x100000002"))
+			Ω(s.Checksum).To(Equal("abc"))
+			Ω(s.Location()).To(Equal(b.Status.Location))
+		})
+
+		It("should resolve a snapshot stored in a bucket with the target of the backup", func() {
+			b.Spec.Target.S3 = &api.S3Target{Endpoint: "http://minio:9000", Bucket: "zk", CredentialsSecret: "minio-creds"}
+			b.Status.Location = "s3://zk/prod/nightly/snapshot.1a"
+			s, err := zk.BackupSnapshot(b)
+			Ω(err).To(BeNil())
+			Ω(s.S3.Endpoint).To(Equal("http://minio:9000"))
+			Ω(s.S3.Key).To(Equal("prod/nightly/snapshot.1a"))
+			Ω(s.S3.CredentialsSecret).To(Equal("minio-creds"))
+			Ω(s.Location()).To(Equal(b.Status.Location))
+		})
+
+		It("should reject an unknown location", func() {
+			b.Status.Location = "file:///snapshot.1"
+			_, err := zk.BackupSnapshot(b)
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("#SourceSnapshot", func() {
+		It("should read the zxid from the file name", func() {
+			s, err := zk.SourceSnapshot(&api.SnapshotSource{
+				PVC: &api.PVCSnapshot{ClaimName: "backups", Path: "old/snapshot.2B"},
+			})
+			Ω(err).To(BeNil())
+			Ω(s.Zxid).To(Equal("0x2b"))
+		})
+
+		It("should reject a file not named after its zxid", func() {
+			_, err := zk.SourceSnapshot(&api.SnapshotSource{
+				S3: &api.S3Snapshot{Bucket: "zk", Key: "backup.tar"},
+			})
+			Ω(err).NotTo(BeNil())
+		})
+	})
+
+	Context("#MakeRestorePVC", func() {
+		It("should be named and labeled like the claims of the stateful set", func() {
+			pvc := zk.MakeRestorePVC(z, 1)
+			Ω(pvc.Name).To(Equal("data-example-1"))
+			Ω(pvc.Labels).To(HaveKeyWithValue("app", "example"))
+			Ω(pvc.Labels).To(HaveKeyWithValue("uid", "1234"))
+			Ω(pvc.Spec).To(Equal(z.Spec.Persistence.PersistentVolumeClaimSpec))
+		})
+
+		It("should have no transaction log volume by default", func() {
+			Ω(zk.MakeRestoreDataLogPVC(z, 1)).To(BeNil())
+		})
+
+		It("should name the transaction log volume like the claims of the stateful set", func() {
+			z.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+			z.WithDefaults()
+			pvc := zk.MakeRestoreDataLogPVC(z, 1)
+			Ω(pvc.Name).To(Equal("datalog-example-1"))
+			Ω(pvc.Labels).To(HaveKeyWithValue("app", "example"))
+			Ω(pvc.Spec).To(Equal(z.Spec.Persistence.DataLog.PersistentVolumeClaimSpec))
+		})
+	})
+
+	Context("#MakeRestoreJob", func() {
+		var (
+			job *batchv1.Job
+			c   v1.Container
+		)
+
+		Context("from a claim", func() {
+			BeforeEach(func() {
+				s := &zk.RestoreSnapshot{
+					Zxid:     "0x100000002",
+					Checksum: "abc",
+					PVC:      &api.PVCSnapshot{ClaimName: "backups", Path: "nightly/snapshot.100000002"},
+				}
+				job = zk.MakeRestoreJob(z, s, 1, 3)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should seed the data volume of the member", func() {
+				Ω(job.Name).To(Equal("example-restore-1"))
+				Ω(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("data-example-1"))
+				Ω(envValue(c.Env, "MYID")).To(Equal("2"))
+				Ω(envValue(c.Env, "SNAPSHOT_FILE")).To(Equal("snapshot.100000002"))
+				Ω(envValue(c.Env, "SNAPSHOT_CHECKSUM")).To(Equal("abc"))
+			})
+
+			It("should write a dynamic config with all members as participants", func() {
+				Ω(envValue(c.Env, "DYNAMIC_CONFIG")).To(Equal(
+					"server.1=example-0.example-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+						"server.2=example-1.example-headless.default.svc.cluster.local:2888:3888:participant;2181\n" +
+						"server.3=example-2.example-headless.default.svc.cluster.local:2888:3888:participant;2181\n"))
+			})
+
+			It("should mount the claim of the snapshot read-only", func() {
+				Ω(job.Spec.Template.Spec.Volumes[2].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+				Ω(job.Spec.Template.Spec.Volumes[2].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+				Ω(envValue(c.Env, "SNAPSHOT_PATH")).To(Equal("/restore/nightly/snapshot.100000002"))
+			})
+
+			It("should not label the pod as a member", func() {
+				Ω(job.Spec.Template.Labels).NotTo(HaveKey("app"))
+			})
+		})
+
+		Context("with a transaction log volume", func() {
+			BeforeEach(func() {
+				z.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+				z.WithDefaults()
+				s := &zk.RestoreSnapshot{
+					Zxid: "0x100000002",
+					PVC:  &api.PVCSnapshot{ClaimName: "backups", Path: "nightly/snapshot.100000002"},
+				}
+				job = zk.MakeRestoreJob(z, s, 1, 3)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should mount the transaction log volume of the member to empty it", func() {
+				Ω(c.VolumeMounts).To(ContainElement(v1.VolumeMount{Name: "datalog", MountPath: "/datalog"}))
+				Ω(job.Spec.Template.Spec.Volumes).To(ContainElement(v1.Volume{
+					Name: "datalog",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "datalog-example-1"},
+					},
+				}))
+			})
+		})
+
+		Context("from a bucket", func() {
+			BeforeEach(func() {
+				s := &zk.RestoreSnapshot{
+					Zxid: "0x1a",
+					S3: &api.S3Snapshot{
+						Endpoint:          "http://minio:9000",
+						Bucket:            "zk",
+						Key:               "nightly/snapshot.1a",
+						CredentialsSecret: "minio-creds",
+					},
+				}
+				job = zk.MakeRestoreJob(z, s, 0, 3)
+				c = job.Spec.Template.Spec.Containers[0]
+			})
+
+			It("should download the snapshot", func() {
+				Ω(job.Spec.Template.Spec.Volumes).To(HaveLen(2))
+				Ω(envValue(c.Env, "S3_BUCKET")).To(Equal("zk"))
+				Ω(envValue(c.Env, "S3_KEY")).To(Equal("nightly/snapshot.1a"))
+				Ω(envValue(c.Env, "S3_REGION")).To(Equal(api.DefaultBackupS3Region))
+				Ω(envValue(c.Env, "SNAPSHOT_FILE")).To(Equal("snapshot.1a"))
+			})
+		})
+	})
+})
@@ -22,6 +22,22 @@ func SyncStatefulSet(curr *appsv1.StatefulSet, next *appsv1.StatefulSet) {
 	curr.Spec.UpdateStrategy = next.Spec.UpdateStrategy
 }
 
+// VolumeClaimTemplatesAdded returns true if the next stateful-set has a volume
+// claim template the current one does not have. The volume claim templates
+// cannot be updated, the stateful-set has to be created again.
+func VolumeClaimTemplatesAdded(curr *appsv1.StatefulSet, next *appsv1.StatefulSet) bool {
+	names := map[string]bool{}
+	for _, pvc := range curr.Spec.VolumeClaimTemplates {
+		names[pvc.Name] = true
+	}
+	for _, pvc := range next.Spec.VolumeClaimTemplates {
+		if !names[pvc.Name] {
+			return true
+		}
+	}
+	return false
+}
+
 // SyncService synchronizes a service with an updated spec and validates it
 func SyncService(curr *v1.Service, next *v1.Service) {
 	curr.Spec.Ports = next.Spec.Ports
@@ -11,7 +11,7 @@
 package zk_test
 
 import (
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	"github.com/pravega/zookeeper-operator/pkg/zk"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
@@ -28,7 +28,7 @@ var _ = Describe("Synchronizers", func() {
 		var sts1 *appsv1.StatefulSet
 
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
@@ -53,12 +53,35 @@ var _ = Describe("Synchronizers", func() {
 		})
 	})
 
+	Context("with a transaction log volume", func() {
+		var z *api.ZookeeperCluster
+
+		BeforeEach(func() {
+			z = &api.ZookeeperCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+				},
+			}
+			z.WithDefaults()
+		})
+
+		It("should tell when the volume claim template is added", func() {
+			sts1 := zk.MakeStatefulSet(z)
+			z.Spec.Persistence.DataLog = &api.DataLogPersistence{}
+			z.WithDefaults()
+			sts2 := zk.MakeStatefulSet(z)
+			Ω(zk.VolumeClaimTemplatesAdded(sts1, sts2)).To(BeTrue())
+			Ω(zk.VolumeClaimTemplatesAdded(sts2, sts2)).To(BeFalse())
+		})
+	})
+
 	Context("with a valid update of Service port", func() {
 		var port int32
 		var value string
 
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
@@ -83,7 +106,7 @@ var _ = Describe("Synchronizers", func() {
 	Context("with a valid update of config map", func() {
 		var value string
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
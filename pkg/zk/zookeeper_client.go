@@ -10,50 +10,150 @@
 package zk
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
-	"github.com/samuel/go-zookeeper/zk"
+	"github.com/go-zookeeper/zk"
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	v1 "k8s.io/api/core/v1"
 )
 
+// caCertKey is the key of the CA certificate in a client TLS secret
+const caCertKey = "ca.crt"
+
+// ACL is an entry of the ACL of a znode
+type ACL = zk.ACL
+
+// ZNode is the data and ACL of an existing znode
+type ZNode struct {
+	Data    string
+	ACL     []ACL
+	Version int32
+}
+
+// configNode is the znode holding the dynamic config of the ensemble
+const configNode = "/zookeeper/config"
+
+// DynamicConfig is the dynamic config of the ensemble
+type DynamicConfig struct {
+	// Servers are the specs of the servers by id, e.g.
+	// host:2888:3888:participant;0.0.0.0:2181
+	Servers map[int32]string
+	// Version is the zxid the config was last changed at, -1 if unknown
+	Version int64
+}
+
+// ParseDynamicConfig parses the data of the config znode
+func ParseDynamicConfig(data string) (*DynamicConfig, error) {
+	config := &DynamicConfig{Servers: map[int32]string{}, Version: -1}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if key == "version" {
+			version, err := strconv.ParseInt(value, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid dynamic config version %s: %v", value, err)
+			}
+			config.Version = version
+		} else if strings.HasPrefix(key, "server.") {
+			id, err := strconv.ParseInt(strings.TrimPrefix(key, "server."), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid server id in dynamic config: %s", key)
+			}
+			config.Servers[int32(id)] = value
+		}
+	}
+	return config, nil
+}
+
+// ServersIn returns the ids of the servers of the config in the given
+// range, both ends included, in ascending order
+func (c *DynamicConfig) ServersIn(first int32, last int32) []int32 {
+	var ids []int32
+	for id := range c.Servers {
+		if id >= first && id <= last {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 type ZookeeperClient interface {
-	Connect(string) error
-	CreateNode(*v1beta1.ZookeeperCluster, string) error
+	Connect(string, *tls.Config) error
+	Authenticate(string, string) error
+	CreateNode(*api.ZookeeperCluster, string) error
 	NodeExists(string) (int32, error)
 	UpdateNode(string, string, int32) error
+	SetNode(string, string) error
+	GetNode(string) (*ZNode, error)
+	CreateZNode(string, string, []ACL, bool) error
+	SetACL(string, []ACL) error
+	DeleteNode(string) error
+	GetConfig() (*DynamicConfig, error)
+	Reconfig([]string, int64) error
+	IncrementalReconfig([]string, []string, int64) error
 	Close()
 }
 
 type DefaultZookeeperClient struct {
 	conn *zk.Conn
+	acl  []zk.ACL
 }
 
-func (client *DefaultZookeeperClient) Connect(zkUri string) (err error) {
+// Connect connects to the given zookeeper address, over TLS if tlsConfig is
+// not nil
+func (client *DefaultZookeeperClient) Connect(zkUri string, tlsConfig *tls.Config) (err error) {
 	host := []string{zkUri}
-	conn, _, err := zk.Connect(host, time.Second*5)
+	dialer := net.DialTimeout
+	if tlsConfig != nil {
+		dialer = func(network, address string, timeout time.Duration) (net.Conn, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, tlsConfig)
+		}
+	}
+	conn, _, err := zk.Connect(host, time.Second*5, zk.WithDialer(dialer))
 	if err != nil {
 		return fmt.Errorf("Failed to connect to zookeeper: %s, Reason: %v", zkUri, err)
 	}
 	client.conn = conn
+	client.acl = zk.WorldACL(zk.PermAll)
+	return nil
+}
+
+// Authenticate authenticates the connection with the digest scheme. The
+// znodes created afterwards are only accessible to the authenticated user.
+func (client *DefaultZookeeperClient) Authenticate(user string, password string) (err error) {
+	if err := client.conn.AddAuth("digest", []byte(user+":"+password)); err != nil {
+		return fmt.Errorf("Failed to authenticate to zookeeper as %s: %v", user, err)
+	}
+	client.acl = zk.AuthACL(zk.PermAll)
 	return nil
 }
 
-func (client *DefaultZookeeperClient) CreateNode(zoo *v1beta1.ZookeeperCluster, zNodePath string) (err error) {
+func (client *DefaultZookeeperClient) CreateNode(zoo *api.ZookeeperCluster, zNodePath string) (err error) {
 	paths := strings.Split(zNodePath, "/")
 	pathLength := len(paths)
 	var parentPath string
 	for i := 1; i < pathLength-1; i++ {
 		parentPath += "/" + paths[i]
-		if _, err := client.conn.Create(parentPath, nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		if _, err := client.conn.Create(parentPath, nil, 0, client.acl); err != nil && err != zk.ErrNodeExists {
 			return fmt.Errorf("Error creating parent zkNode: %s: %v", parentPath, err)
 		}
 	}
+	// a cluster restored from a snapshot finds the znode of the cluster
+	// it was taken from, which had another size
 	data := "CLUSTER_SIZE=" + strconv.Itoa(int(zoo.Spec.Replicas))
 	childNode := parentPath + "/" + paths[pathLength-1]
-	if _, err := client.conn.Create(childNode, []byte(data), 0, zk.WorldACL(zk.PermAll)); err != nil {
+	if err := client.SetNode(childNode, data); err != nil {
 		return fmt.Errorf("Error creating sub zkNode: %s: %v", childNode, err)
 	}
 	return nil
@@ -66,6 +166,19 @@ func (client *DefaultZookeeperClient) UpdateNode(path string, data string, versi
 	return nil
 }
 
+// SetNode sets the data of the znode, creating it if it does not exist. The
+// parent znode must exist.
+func (client *DefaultZookeeperClient) SetNode(path string, data string) (err error) {
+	_, err = client.conn.Set(path, []byte(data), -1)
+	if err == zk.ErrNoNode {
+		_, err = client.conn.Create(path, []byte(data), 0, client.acl)
+	}
+	if err != nil {
+		return fmt.Errorf("Error setting zkNode %s: %v", path, err)
+	}
+	return nil
+}
+
 func (client *DefaultZookeeperClient) NodeExists(zNodePath string) (version int32, err error) {
 	exists, zNodeStat, err := client.conn.Exists(zNodePath)
 	if err != nil || !exists {
@@ -74,6 +187,187 @@ func (client *DefaultZookeeperClient) NodeExists(zNodePath string) (version int3
 	return zNodeStat.Version, err
 }
 
+// GetNode returns the data and ACL of the znode, or nil if it does not exist
+func (client *DefaultZookeeperClient) GetNode(path string) (*ZNode, error) {
+	data, stat, err := client.conn.Get(path)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error getting zkNode %s: %v", path, err)
+	}
+	acl, _, err := client.conn.GetACL(path)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error getting ACL of zkNode %s: %v", path, err)
+	}
+	return &ZNode{Data: string(data), ACL: acl, Version: stat.Version}, nil
+}
+
+// CreateZNode creates the znode with the given ACL, or with the default one
+// if nil, as a container if asked to. Its missing parents are created as
+// persistent znodes with the default ACL.
+func (client *DefaultZookeeperClient) CreateZNode(path string, data string, acl []ACL, container bool) (err error) {
+	paths := strings.Split(path, "/")
+	var parentPath string
+	for i := 1; i < len(paths)-1; i++ {
+		parentPath += "/" + paths[i]
+		if _, err := client.conn.Create(parentPath, nil, 0, client.acl); err != nil && err != zk.ErrNodeExists {
+			return fmt.Errorf("Error creating parent zkNode: %s: %v", parentPath, err)
+		}
+	}
+	if acl == nil {
+		acl = client.acl
+	}
+	if container {
+		_, err = client.conn.CreateContainer(path, []byte(data), zk.FlagContainer, acl)
+	} else {
+		_, err = client.conn.Create(path, []byte(data), 0, acl)
+	}
+	if err != nil {
+		return fmt.Errorf("Error creating zkNode %s: %v", path, err)
+	}
+	return nil
+}
+
+// SetACL sets the ACL of the znode
+func (client *DefaultZookeeperClient) SetACL(path string, acl []ACL) (err error) {
+	if _, err := client.conn.SetACL(path, acl, -1); err != nil {
+		return fmt.Errorf("Error setting ACL of zkNode %s: %v", path, err)
+	}
+	return nil
+}
+
+// DeleteNode deletes the znode and all of its children, a missing znode is
+// not an error
+func (client *DefaultZookeeperClient) DeleteNode(path string) (err error) {
+	children, _, err := client.conn.Children(path)
+	if err == zk.ErrNoNode {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Error listing children of zkNode %s: %v", path, err)
+	}
+	for _, child := range children {
+		if err := client.DeleteNode(path + "/" + child); err != nil {
+			return err
+		}
+	}
+	if err := client.conn.Delete(path, -1); err != nil && err != zk.ErrNoNode {
+		return fmt.Errorf("Error deleting zkNode %s: %v", path, err)
+	}
+	return nil
+}
+
+// GetConfig returns the dynamic config the server the client is connected
+// to has committed
+func (client *DefaultZookeeperClient) GetConfig() (*DynamicConfig, error) {
+	data, _, err := client.conn.Get(configNode)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting the dynamic config: %v", err)
+	}
+	return ParseDynamicConfig(string(data))
+}
+
+// Reconfig replaces the members of the ensemble, given as
+// server.<id>=<spec>, if the config is still at the given version, or
+// whatever its version if -1
+func (client *DefaultZookeeperClient) Reconfig(members []string, version int64) (err error) {
+	if _, err := client.conn.Reconfig(members, version); err != nil {
+		return fmt.Errorf("Error reconfiguring the ensemble with %v: %v", members, err)
+	}
+	return nil
+}
+
+// IncrementalReconfig adds the joining servers, given as
+// server.<id>=<spec>, and removes the leaving ones, given by id, if the
+// config is still at the given version, or whatever its version if -1
+func (client *DefaultZookeeperClient) IncrementalReconfig(joining []string, leaving []string, version int64) (err error) {
+	if _, err := client.conn.IncrementalReconfig(joining, leaving, version); err != nil {
+		return fmt.Errorf("Error reconfiguring the ensemble, joining %v, leaving %v: %v", joining, leaving, err)
+	}
+	return nil
+}
+
 func (client *DefaultZookeeperClient) Close() {
 	client.conn.Close()
 }
+
+// znodePermissions are the permissions of the ZookeeperZNode ACLs
+var znodePermissions = map[api.ZNodePermission]int32{
+	"read":   zk.PermRead,
+	"write":  zk.PermWrite,
+	"create": zk.PermCreate,
+	"delete": zk.PermDelete,
+	"admin":  zk.PermAdmin,
+	"all":    zk.PermAll,
+}
+
+// ZNodeACL returns the ACL of the spec of a ZookeeperZNode, or nil if the
+// spec does not set one
+func ZNodeACL(acls []api.ZNodeACL) []ACL {
+	if len(acls) == 0 {
+		return nil
+	}
+	result := make([]ACL, 0, len(acls))
+	for _, a := range acls {
+		var perms int32
+		for _, p := range a.Permissions {
+			perms |= znodePermissions[p]
+		}
+		id := a.ID
+		if a.Scheme == "world" && id == "" {
+			id = "anyone"
+		}
+		result = append(result, ACL{Perms: perms, Scheme: a.Scheme, ID: id})
+	}
+	return result
+}
+
+// SuperUserACL returns the ACL granting all permissions to the super user
+// only, which the znodes the operator creates once authenticated get
+func SuperUserACL(user string, password string) []ACL {
+	return zk.DigestACL(zk.PermAll, user, password)
+}
+
+// EqualACL returns true if both ACLs have the same entries, in any order
+func EqualACL(a []ACL, b []ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(acl []ACL) []ACL {
+		s := append([]ACL(nil), acl...)
+		sort.Slice(s, func(i, j int) bool {
+			if s[i].Scheme != s[j].Scheme {
+				return s[i].Scheme < s[j].Scheme
+			}
+			return s[i].ID < s[j].ID
+		})
+		return s
+	}
+	a, b = sorted(a), sorted(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClientTLSConfig returns the TLS configuration to connect to a zookeeper
+// cluster with the certificates of the client TLS secret of the cluster
+func NewClientTLSConfig(secret *v1.Secret, serverName string) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate in secret %s: %v", secret.Name, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(secret.Data[caCertKey]) {
+		return nil, fmt.Errorf("No CA certificate in secret %s", secret.Name)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
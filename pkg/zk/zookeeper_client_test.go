@@ -11,19 +11,56 @@
 package zk_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	"github.com/pravega/zookeeper-operator/pkg/zk"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// makeTLSSecret returns a client TLS secret with a self-signed certificate
+func makeTLSSecret() *v1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).To(BeNil())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example-client"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Ω(err).To(BeNil())
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	Ω(err).To(BeNil())
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-tls"},
+		Data: map[string][]byte{
+			"tls.crt": cert,
+			"tls.key": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}),
+			"ca.crt":  cert,
+		},
+	}
+}
+
 var _ = Describe("Zookeeper Client", func() {
 
 	Context("with a valid update of Service port", func() {
 		var err1, err2, err3, err4, err5 error
 		BeforeEach(func() {
-			z := &v1beta1.ZookeeperCluster{
+			z := &api.ZookeeperCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "example",
 					Namespace: "default",
@@ -31,7 +68,7 @@ var _ = Describe("Zookeeper Client", func() {
 			}
 			zkclient := new(zk.DefaultZookeeperClient)
 			z.WithDefaults()
-			err1 = zkclient.Connect("127.0.0.0:2181")
+			err1 = zkclient.Connect("127.0.0.0:2181", nil)
 			err2 = zkclient.CreateNode(z, "temp/tmp/tmp")
 			err5 = zkclient.CreateNode(z, "temp/tmp")
 			err3 = zkclient.UpdateNode("temp/tem/temp", "dasd", 2)
@@ -54,4 +91,90 @@ var _ = Describe("Zookeeper Client", func() {
 			Ω(err5).ShouldNot(BeNil())
 		})
 	})
+
+	Context("TLS configuration", func() {
+		var (
+			secret *v1.Secret
+			config *tls.Config
+			err    error
+		)
+		BeforeEach(func() {
+			secret = makeTLSSecret()
+		})
+
+		Context("with a valid secret", func() {
+			BeforeEach(func() {
+				config, err = zk.NewClientTLSConfig(secret, "example-client.default.svc.cluster.local")
+			})
+			It("should use the certificate of the secret", func() {
+				Ω(err).To(BeNil())
+				Ω(config.Certificates).To(HaveLen(1))
+				Ω(config.RootCAs).NotTo(BeNil())
+				Ω(config.ServerName).To(Equal("example-client.default.svc.cluster.local"))
+			})
+		})
+
+		Context("without a CA certificate", func() {
+			BeforeEach(func() {
+				delete(secret.Data, "ca.crt")
+				config, err = zk.NewClientTLSConfig(secret, "example-client")
+			})
+			It("should fail", func() {
+				Ω(err).NotTo(BeNil())
+			})
+		})
+
+		Context("with an invalid key", func() {
+			BeforeEach(func() {
+				secret.Data["tls.key"] = []byte("invalid")
+				config, err = zk.NewClientTLSConfig(secret, "example-client")
+			})
+			It("should fail", func() {
+				Ω(err).NotTo(BeNil())
+			})
+		})
+	})
+
+	Context("ZNode ACLs", func() {
+		It("should convert the permissions of the spec", func() {
+			acl := zk.ZNodeACL([]api.ZNodeACL{
+				{Scheme: "world", Permissions: []api.ZNodePermission{"read", "create"}},
+				{Scheme: "ip", ID: "10.0.0.0/8", Permissions: []api.ZNodePermission{"all"}},
+			})
+			Ω(acl).To(Equal([]zk.ACL{
+				{Scheme: "world", ID: "anyone", Perms: 5},
+				{Scheme: "ip", ID: "10.0.0.0/8", Perms: 31},
+			}))
+		})
+
+		It("should leave the ACL to the default without entries", func() {
+			Ω(zk.ZNodeACL(nil)).To(BeNil())
+		})
+
+		It("should compare ACLs in any order", func() {
+			a := []zk.ACL{{Scheme: "world", ID: "anyone", Perms: 1}, {Scheme: "ip", ID: "10.0.0.1", Perms: 31}}
+			b := []zk.ACL{{Scheme: "ip", ID: "10.0.0.1", Perms: 31}, {Scheme: "world", ID: "anyone", Perms: 1}}
+			Ω(zk.EqualACL(a, b)).To(BeTrue())
+			b[0].Perms = 1
+			Ω(zk.EqualACL(a, b)).To(BeFalse())
+			Ω(zk.EqualACL(a, a[:1])).To(BeFalse())
+		})
+	})
+
+	Context("Dynamic config", func() {
+		It("should parse the servers and the version", func() {
+			config, err := zk.ParseDynamicConfig("server.1=example-0:2888:3888:participant;0.0.0.0:2181\n" +
+				"server.129=example-observer-0:2888:3888:observer;0.0.0.0:2181\n" +
+				"version=100000004\n")
+			Ω(err).To(BeNil())
+			Ω(config.Version).To(BeEquivalentTo(0x100000004))
+			Ω(config.Servers).To(HaveKeyWithValue(int32(1), "example-0:2888:3888:participant;0.0.0.0:2181"))
+			Ω(config.ServersIn(2, 255)).To(Equal([]int32{129}))
+		})
+
+		It("should fail on an invalid server id", func() {
+			_, err := zk.ParseDynamicConfig("server.a=example-0:2888:3888:participant;0.0.0.0:2181")
+			Ω(err).NotTo(BeNil())
+		})
+	})
 })
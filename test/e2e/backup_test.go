@@ -0,0 +1,74 @@
+/**
+ * Copyright (c) 2021 Dell Inc., or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ */
+
+package e2e
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/pravega/zookeeper-operator/api/v1"
+	zk_e2eutil "github.com/pravega/zookeeper-operator/pkg/test/e2e/e2eutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test a backup streaming a snapshot from the admin server of the leader
+var _ = Describe("Backup a Zookeeper cluster", func() {
+	Context("Check backup operation", func() {
+		It("should store a snapshot of the cluster", func() {
+			users := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "zookeeper-users", Namespace: testNamespace},
+				StringData: map[string]string{"super": "super-secret"},
+			}
+			Expect(k8sClient.Create(ctx, users)).Should(Succeed())
+			defer k8sClient.Delete(ctx, users)
+
+			claim := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "zookeeper-backups", Namespace: testNamespace},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, claim)).Should(Succeed())
+			defer k8sClient.Delete(ctx, claim)
+
+			By("create Zookeeper cluster with authentication")
+			cluster := zk_e2eutil.NewDefaultCluster(testNamespace)
+			cluster.Spec.Auth = &api.Auth{SecretName: users.Name}
+			cluster.WithDefaults()
+			cluster.Status.Init()
+			cluster.Spec.Persistence.VolumeReclaimPolicy = "Delete"
+
+			zk, err := zk_e2eutil.CreateCluster(logger, k8sClient, cluster)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(zk_e2eutil.WaitForClusterToBecomeReady(logger, k8sClient, zk, 3)).NotTo(HaveOccurred())
+
+			By("back up the cluster")
+			backup := zk_e2eutil.NewBackup(testNamespace, "zookeeper-backup", zk.Name, claim.Name)
+			Expect(k8sClient.Create(ctx, backup)).Should(Succeed())
+			defer k8sClient.Delete(ctx, backup)
+
+			backup, err = zk_e2eutil.WaitForBackupToFinish(logger, k8sClient, backup)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backup.Status.Message).To(BeEmpty())
+			Expect(backup.Status.Phase).To(Equal(api.BackupSucceeded))
+			Expect(backup.Status.Zxid).NotTo(BeEmpty())
+			Expect(backup.Status.Size).To(BeNumerically(">", 0))
+
+			By("delete created Zookeeper cluster")
+			Expect(k8sClient.Delete(ctx, zk)).Should(Succeed())
+			Expect(zk_e2eutil.WaitForClusterToTerminate(logger, k8sClient, zk)).NotTo(HaveOccurred())
+		})
+	})
+})
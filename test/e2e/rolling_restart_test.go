@@ -13,6 +13,7 @@ package e2e
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	zk_e2eutil "github.com/pravega/zookeeper-operator/pkg/test/e2e/e2eutil"
 	"time"
 )
@@ -41,12 +42,11 @@ var _ = Describe("Perform rolling restart on zk cluster", func() {
 			podList, err := zk_e2eutil.GetPods(k8sClient, zk)
 			Expect(err).NotTo(HaveOccurred())
 			for i := 0; i < len(podList.Items); i++ {
-				Expect(podList.Items[i].Annotations).NotTo(HaveKey("restartTime"))
+				Expect(podList.Items[i].Annotations).NotTo(HaveKey(api.RestartTriggerAnnotation))
 			}
-			Expect(zk.GetTriggerRollingRestart()).To(Equal(false))
 
 			// Trigger a rolling restart
-			zk.Spec.TriggerRollingRestart = true
+			zk.Spec.RestartTrigger = "1"
 			err = zk_e2eutil.UpdateCluster(logger, k8sClient, zk)
 			// zk_e2eutil.WaitForClusterToBecomeReady(...) will return as soon as any pod is restarted as the cluster is briefly reported to be healthy even though the restart is not completed. this method is hence called after a sleep to ensure that the restart has completed before asserting the test cases.
 			time.Sleep(time.Duration(clusterCreateDuration) * 2 * time.Second)
@@ -56,15 +56,13 @@ var _ = Describe("Perform rolling restart on zk cluster", func() {
 			Expect(err).NotTo(HaveOccurred())
 			newPodList, err := zk_e2eutil.GetPods(k8sClient, zk)
 			Expect(err).NotTo(HaveOccurred())
-			var firstRestartTime []string
 			for i := 0; i < len(newPodList.Items); i++ {
-				Expect(newPodList.Items[i].Annotations).To(HaveKey("restartTime"))
-				firstRestartTime = append(firstRestartTime, newPodList.Items[i].Annotations["restartTime"])
+				Expect(newPodList.Items[i].Annotations).To(HaveKeyWithValue(api.RestartTriggerAnnotation, "1"))
 			}
-			Expect(zk.GetTriggerRollingRestart()).To(Equal(false))
+			Expect(zk.Spec.RestartTrigger).To(Equal("1"))
 
 			// Trigger a rolling restart again
-			zk.Spec.TriggerRollingRestart = true
+			zk.Spec.RestartTrigger = "2"
 			err = zk_e2eutil.UpdateCluster(logger, k8sClient, zk)
 			// zk_e2eutil.WaitForClusterToBecomeReady(...) will return as soon as any pod is restarted as the cluster is briefly reported to be healthy even though the complete restart is not completed. this method is hence called after a sleep to ensure that the restart has completed before asserting the test cases.
 			time.Sleep(time.Duration(clusterCreateDuration) * 2 * time.Second)
@@ -75,10 +73,9 @@ var _ = Describe("Perform rolling restart on zk cluster", func() {
 			newPodList2, err := zk_e2eutil.GetPods(k8sClient, zk)
 			Expect(err).NotTo(HaveOccurred())
 			for i := 0; i < len(newPodList2.Items); i++ {
-				Expect(newPodList2.Items[i].Annotations).To(HaveKey("restartTime"))
-				Expect(newPodList2.Items[i].Annotations["restartTime"]).NotTo(Equal(firstRestartTime[i]))
+				Expect(newPodList2.Items[i].Annotations).To(HaveKeyWithValue(api.RestartTriggerAnnotation, "2"))
 			}
-			Expect(zk.GetTriggerRollingRestart()).To(Equal(false))
+			Expect(zk.Spec.RestartTrigger).To(Equal("2"))
 
 			// Delete cluster
 			Expect(zk_e2eutil.DeleteCluster(logger, k8sClient, zk)).NotTo(HaveOccurred())
@@ -13,8 +13,7 @@ package e2e
 import (
 	"context"
 	"github.com/go-logr/logr"
-	api "github.com/pravega/zookeeper-operator/api/v1beta1"
-	zookeeperv1beta1 "github.com/pravega/zookeeper-operator/api/v1beta1"
+	api "github.com/pravega/zookeeper-operator/api/v1"
 	zookeepercontroller "github.com/pravega/zookeeper-operator/controllers"
 	zkClient "github.com/pravega/zookeeper-operator/pkg/zk"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -67,7 +66,7 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
-	err = zookeeperv1beta1.AddToScheme(scheme.Scheme)
+	err = api.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
 	/*
@@ -98,6 +97,13 @@ var _ = BeforeSuite(func() {
 		}).SetupWithManager(k8sManager)
 		Expect(err).ToNot(HaveOccurred())
 
+		err = (&zookeepercontroller.ZookeeperBackupReconciler{
+			Client: k8sManager.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ZookeeperBackup"),
+			Scheme: k8sManager.GetScheme(),
+		}).SetupWithManager(k8sManager)
+		Expect(err).ToNot(HaveOccurred())
+
 		go func() {
 			defer GinkgoRecover()
 			err = k8sManager.Start(ctrl.SetupSignalHandler())